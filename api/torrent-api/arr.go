@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Types shared by the Radarr and Sonarr v3 APIs
+
+// ArrStatusError is an error response from the Radarr/Sonarr API
+type ArrStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *ArrStatusError) Error() string {
+	return fmt.Sprintf("API error: status %d, body: %s", e.StatusCode, e.Body)
+}
+
+// isArrNotFound reports whether err is a 404 from Radarr/Sonarr
+func isArrNotFound(err error) bool {
+	var statusErr *ArrStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// ArrQuality is the quality wrapper used on files and releases
+type ArrQuality struct {
+	Quality ArrQualityDefinition `json:"quality"`
+}
+
+type ArrQualityDefinition struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Source     string `json:"source,omitempty"`
+	Resolution int    `json:"resolution,omitempty"`
+}
+
+// ArrQualityProfileItem is a quality, or a group of qualities, in a quality profile
+type ArrQualityProfileItem struct {
+	ID      int                     `json:"id,omitempty"` // set on groups
+	Name    string                  `json:"name,omitempty"`
+	Quality *ArrQualityDefinition   `json:"quality,omitempty"`
+	Items   []ArrQualityProfileItem `json:"items,omitempty"`
+	Allowed bool                    `json:"allowed"`
+}
+
+// qualityProfileResolutions returns the resolutions a quality profile allows and
+// the resolution of its cutoff quality (or group)
+func qualityProfileResolutions(items []ArrQualityProfileItem, cutoff int) (allowed map[int]bool, cutoffResolution int) {
+	allowed = make(map[int]bool)
+	var walk func(items []ArrQualityProfileItem, allowedParent, inCutoff bool)
+	walk = func(items []ArrQualityProfileItem, allowedParent, inCutoff bool) {
+		for _, item := range items {
+			isCutoff := inCutoff || (item.Quality == nil && item.ID == cutoff)
+			if item.Quality != nil {
+				isCutoff = isCutoff || item.Quality.ID == cutoff
+				if item.Allowed || allowedParent {
+					allowed[item.Quality.Resolution] = true
+				}
+				if isCutoff && item.Quality.Resolution > cutoffResolution {
+					cutoffResolution = item.Quality.Resolution
+				}
+			}
+			walk(item.Items, item.Allowed, isCutoff)
+		}
+	}
+	walk(items, false, false)
+	return allowed, cutoffResolution
+}
+
+// qualityHints are the preferred_quality values the extension may send
+var qualityHints = map[string]int{
+	"480p": 480, "576p": 576, "720p": 720, "1080p": 1080, "2160p": 2160, "4k": 2160, "uhd": 2160,
+}
+
+// parseQualityHint returns the resolution of a preferred_quality hint, 0 for none
+func parseQualityHint(hint string) (int, error) {
+	if hint == "" {
+		return 0, nil
+	}
+	resolution, ok := qualityHints[strings.ToLower(strings.TrimSpace(hint))]
+	if !ok {
+		return 0, fmt.Errorf("unknown preferred_quality %q; use 480p, 576p, 720p, 1080p or 2160p", hint)
+	}
+	return resolution, nil
+}
+
+// nearestQualityProfile returns the index of the profile that allows resolution
+// and whose cutoff is nearest to it, preferring the narrower profile on a tie,
+// or -1 if no profile allows it
+func nearestQualityProfile(resolution int, profiles []RadarrQualityProfile) int {
+	best, bestDistance, bestWidth := -1, 0, 0
+	for i, profile := range profiles {
+		allowed, cutoff := qualityProfileResolutions(profile.Items, profile.Cutoff)
+		if !allowed[resolution] {
+			continue
+		}
+		distance := cutoff - resolution
+		if distance < 0 {
+			distance = -distance
+		}
+		if best < 0 || distance < bestDistance || (distance == bestDistance && len(allowed) < bestWidth) {
+			best, bestDistance, bestWidth = i, distance, len(allowed)
+		}
+	}
+	return best
+}
+
+// ArrRelease is an indexer result from the *arr release search endpoint
+type ArrRelease struct {
+	GUID        string     `json:"guid"`
+	Title       string     `json:"title"`
+	Indexer     string     `json:"indexer"`
+	Protocol    string     `json:"protocol"`
+	Size        int64      `json:"size"`
+	IndexerID   int        `json:"indexerId"`
+	Seeders     *int       `json:"seeders,omitempty"`
+	Leechers    *int       `json:"leechers,omitempty"`
+	MagnetURL   string     `json:"magnetUrl,omitempty"`
+	DownloadURL string     `json:"downloadUrl,omitempty"`
+	InfoHash    string     `json:"infoHash,omitempty"`
+	Quality     ArrQuality `json:"quality"`
+	Approved    bool       `json:"approved"`
+	Rejected    bool       `json:"rejected"`
+	Rejections  []string   `json:"rejections,omitempty"`
+}
+
+// preferredRelease returns the release with the most seeders among those of the
+// given resolution that the app would accept, or nil
+func preferredRelease(releases []ArrRelease, resolution int) *ArrRelease {
+	var best *ArrRelease
+	bestSeeders := -1
+	for i, r := range releases {
+		if r.Rejected || r.Quality.Quality.Resolution != resolution {
+			continue
+		}
+		seeders := 0
+		if r.Seeders != nil {
+			seeders = *r.Seeders
+		}
+		if seeders > bestSeeders {
+			best, bestSeeders = &releases[i], seeders
+		}
+	}
+	return best
+}
+
+// ArrSystemStatus is the subset of /api/v3/system/status we use
+type ArrSystemStatus struct {
+	AppName string `json:"appName"`
+	Version string `json:"version"`
+}
+
+// ArrPage is the paging envelope of wanted/history endpoints
+type ArrPage struct {
+	Page         int `json:"page"`
+	PageSize     int `json:"pageSize"`
+	TotalRecords int `json:"totalRecords"`
+}
+
+// ArrQueueRecord is a download in Radarr's or Sonarr's activity queue. Sonarr
+// has a record per episode, so a season pack shows up once per episode.
+type ArrQueueRecord struct {
+	ID                   int                 `json:"id"`
+	MovieID              int                 `json:"movieId,omitempty"`
+	SeriesID             int                 `json:"seriesId,omitempty"`
+	Title                string              `json:"title"` // the release name
+	Status               string              `json:"status"`
+	TrackedDownloadState string              `json:"trackedDownloadState"` // e.g. "downloading", "importPending" or "failedPending"
+	StatusMessages       []ArrStatusMessage  `json:"statusMessages,omitempty"`
+	ErrorMessage         string              `json:"errorMessage,omitempty"`
+	DownloadID           string              `json:"downloadId"` // the upper case info hash for torrents
+	Protocol             string              `json:"protocol"`
+	Size                 float64             `json:"size"`
+	SizeLeft             float64             `json:"sizeleft"`
+	Movie                *RadarrSearchResult `json:"movie,omitempty"`
+	Series               *SonarrSearchResult `json:"series,omitempty"`
+	Episode              *SonarrEpisode      `json:"episode,omitempty"`
+}
+
+// ArrStatusMessage is a warning on a queue record, e.g. why an import is stuck
+type ArrStatusMessage struct {
+	Title    string   `json:"title"`
+	Messages []string `json:"messages"`
+}
+
+// getArrQueue returns the whole activity queue; include names the records to
+// embed ("Movie", or "Series" and "Episode"). do is the client's doRequest.
+func getArrQueue(ctx context.Context, do func(ctx context.Context, method, endpoint string, body interface{}) ([]byte, error), include ...string) ([]ArrQueueRecord, error) {
+	endpoint := "/api/v3/queue?page=1&pageSize=1000"
+	for _, name := range include {
+		endpoint += "&include" + name + "=true"
+	}
+	respBody, err := do(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	var page struct {
+		ArrPage
+		Records []ArrQueueRecord `json:"records"`
+	}
+	if err := json.Unmarshal(respBody, &page); err != nil {
+		return nil, err
+	}
+	return page.Records, nil
+}
+
+// UpgradeSuggestion is an indexer release that would upgrade an item on disk
+type UpgradeSuggestion struct {
+	Title      string `json:"title"`
+	Quality    string `json:"quality"`
+	MagnetLink string `json:"magnet_link"`
+	Indexer    string `json:"indexer,omitempty"`
+	Seeders    int    `json:"seeders"`
+	Size       int64  `json:"size"`
+}
+
+// torrentUpgradeSuggestions picks the best non-rejected torrent releases that carry a magnet
+func torrentUpgradeSuggestions(releases []ArrRelease, limit int) []UpgradeSuggestion {
+	var suggestions []UpgradeSuggestion
+	for _, r := range releases {
+		if r.Protocol != "torrent" || r.Rejected || r.MagnetURL == "" {
+			continue
+		}
+		seeders := 0
+		if r.Seeders != nil {
+			seeders = *r.Seeders
+		}
+		suggestions = append(suggestions, UpgradeSuggestion{
+			Title:      r.Title,
+			Quality:    r.Quality.Quality.Name,
+			MagnetLink: r.MagnetURL,
+			Indexer:    r.Indexer,
+			Seeders:    seeders,
+			Size:       r.Size,
+		})
+	}
+
+	// Releases come back ranked by the *arr's own decision engine
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// ArrField is a name/value setting on *arr provider resources (download clients, indexers)
+type ArrField struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// ArrDownloadClient is a download client definition from /api/v3/downloadclient
+type ArrDownloadClient struct {
+	ID                       int        `json:"id,omitempty"`
+	Name                     string     `json:"name"`
+	Enable                   bool       `json:"enable"`
+	Protocol                 string     `json:"protocol"`
+	Priority                 int        `json:"priority"`
+	RemoveCompletedDownloads bool       `json:"removeCompletedDownloads"`
+	RemoveFailedDownloads    bool       `json:"removeFailedDownloads"`
+	Implementation           string     `json:"implementation"`
+	ImplementationName       string     `json:"implementationName,omitempty"`
+	ConfigContract           string     `json:"configContract"`
+	Fields                   []ArrField `json:"fields"`
+	Tags                     []int      `json:"tags"`
+}
+
+// Field returns the value of a setting, or nil if it is not present
+func (d *ArrDownloadClient) Field(name string) interface{} {
+	for _, f := range d.Fields {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return nil
+}
+
+// SetField sets a setting, adding it if it is not present
+func (d *ArrDownloadClient) SetField(name string, value interface{}) {
+	for i := range d.Fields {
+		if d.Fields[i].Name == name {
+			d.Fields[i].Value = value
+			return
+		}
+	}
+	d.Fields = append(d.Fields, ArrField{Name: name, Value: value})
+}
+
+// ArrTag is a label from /api/v3/tag
+type ArrTag struct {
+	ID    int    `json:"id,omitempty"`
+	Label string `json:"label"`
+}
+
+// listArrTags returns the app's tags; do is the client's doRequest
+func listArrTags(ctx context.Context, do func(ctx context.Context, method, endpoint string, body interface{}) ([]byte, error)) ([]ArrTag, error) {
+	respBody, err := do(ctx, "GET", "/api/v3/tag", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	var tags []ArrTag
+	if err := json.Unmarshal(respBody, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// ensureArrTag returns the ID of the tag with label, creating it if needed.
+// do is the client's doRequest.
+func ensureArrTag(ctx context.Context, do func(ctx context.Context, method, endpoint string, body interface{}) ([]byte, error), label string) (int, error) {
+	tags, err := listArrTags(ctx, do)
+	if err != nil {
+		return 0, err
+	}
+	for _, tag := range tags {
+		if strings.EqualFold(tag.Label, label) {
+			return tag.ID, nil
+		}
+	}
+
+	respBody, err := do(ctx, "POST", "/api/v3/tag", ArrTag{Label: label})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create tag %s: %w", label, err)
+	}
+	var tag ArrTag
+	if err := json.Unmarshal(respBody, &tag); err != nil {
+		return 0, err
+	}
+	return tag.ID, nil
+}
+
+// ArrDefaults picks the root folder and quality profile for new movies/series.
+// Empty fields use the first one the app lists.
+type ArrDefaults struct {
+	RootFolder     string // path, e.g. /data/movies
+	QualityProfile string // name or ID
+}
+
+// ArrChoice is the root folder and quality profile a request picked, e.g. from
+// the dropdowns /api/config/options fills; zero fields keep the defaults
+type ArrChoice struct {
+	RootFolder       string
+	QualityProfileID int
+}
+
+// with returns the defaults overridden by a request's choice
+func (d ArrDefaults) with(choice ArrChoice) ArrDefaults {
+	if choice.RootFolder != "" {
+		d.RootFolder = choice.RootFolder
+	}
+	if choice.QualityProfileID != 0 {
+		d.QualityProfile = strconv.Itoa(choice.QualityProfileID)
+	}
+	return d
+}
+
+// rootFolderIndex returns the index of the configured root folder among paths
+func (d ArrDefaults) rootFolderIndex(app string, paths []string) (int, error) {
+	if d.RootFolder == "" {
+		return 0, nil
+	}
+	for i, path := range paths {
+		if strings.TrimSuffix(path, "/") == strings.TrimSuffix(d.RootFolder, "/") {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("root folder %s not found in %s", d.RootFolder, app)
+}
+
+// qualityProfileID returns the ID of the configured quality profile, matched by
+// name (case-insensitive) or ID
+func (d ArrDefaults) qualityProfileID(app string, ids []int, names []string) (int, error) {
+	if d.QualityProfile == "" {
+		return ids[0], nil
+	}
+	for i, name := range names {
+		if strings.EqualFold(name, d.QualityProfile) || strconv.Itoa(ids[i]) == d.QualityProfile {
+			return ids[i], nil
+		}
+	}
+	return 0, fmt.Errorf("quality profile %s not found in %s", d.QualityProfile, app)
+}
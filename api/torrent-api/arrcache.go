@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Endpoints whose last answer is kept for failover
+const (
+	arrRootFolderEndpoint     = "/api/v3/rootfolder"
+	arrQualityProfileEndpoint = "/api/v3/qualityprofile"
+)
+
+// ArrCache keeps the last root folders and quality profiles each Radarr/Sonarr
+// instance returned, in memory and in ARR_CACHE_FILE when set, so an add can go
+// on with known defaults while the app is briefly unreachable
+type ArrCache struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]arrCacheEntry // "<base URL> <endpoint>"
+}
+
+type arrCacheEntry struct {
+	Body      json.RawMessage `json:"body"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+var arrCache = NewArrCache()
+
+func NewArrCache() *ArrCache {
+	return &ArrCache{entries: make(map[string]arrCacheEntry)}
+}
+
+// Load reads the cache file and saves to it from now on; a missing file is fine
+func (c *ArrCache) Load(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.path = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read arr cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return fmt.Errorf("failed to parse arr cache %s: %w", path, err)
+	}
+	return nil
+}
+
+// Fetch calls fetch and caches its answer. When the app is unavailable it
+// returns the cached answer instead, if there is one.
+func (c *ArrCache) Fetch(baseURL, endpoint string, fetch func() ([]byte, error)) ([]byte, error) {
+	key := baseURL + " " + endpoint
+	body, err := fetch()
+	if err == nil {
+		c.put(key, body)
+		return body, nil
+	}
+	if !isUnavailable(err) {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok {
+		return nil, err
+	}
+	log.Printf("Warning: %s is unavailable, using %s cached at %s: %v", baseURL, endpoint, entry.UpdatedAt.Format(time.RFC3339), err)
+	return entry.Body, nil
+}
+
+// Get returns the cached answer without calling the app
+func (c *ArrCache) Get(baseURL, endpoint string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[baseURL+" "+endpoint]
+	return entry.Body, ok
+}
+
+func (c *ArrCache) put(key string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	unchanged := bytes.Equal(c.entries[key].Body, body)
+	c.entries[key] = arrCacheEntry{Body: append(json.RawMessage(nil), body...), UpdatedAt: time.Now()}
+	if unchanged {
+		return
+	}
+	if err := c.save(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+func (c *ArrCache) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".arrcache-*")
+	if err != nil {
+		return fmt.Errorf("failed to save arr cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save arr cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save arr cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to save arr cache: %w", err)
+	}
+	return nil
+}
+
+// cachedDefaults returns the root folder and quality profile a new movie would
+// get, from the failover cache
+func (c *RadarrClient) cachedDefaults() (rootFolder, qualityProfile string, ok bool) {
+	folderBody, ok1 := arrCache.Get(c.baseURL.Get(), arrRootFolderEndpoint)
+	profileBody, ok2 := arrCache.Get(c.baseURL.Get(), arrQualityProfileEndpoint)
+	if !ok1 || !ok2 {
+		return "", "", false
+	}
+	var folders []RadarrRootFolder
+	var profiles []RadarrQualityProfile
+	if json.Unmarshal(folderBody, &folders) != nil || json.Unmarshal(profileBody, &profiles) != nil || len(folders) == 0 || len(profiles) == 0 {
+		return "", "", false
+	}
+
+	paths := make([]string, len(folders))
+	for i, folder := range folders {
+		paths[i] = folder.Path
+	}
+	ids, names := make([]int, len(profiles)), make([]string, len(profiles))
+	for i, profile := range profiles {
+		ids[i], names[i] = profile.ID, profile.Name
+	}
+	return resolveCachedDefaults(c.defaults, "Radarr", paths, ids, names)
+}
+
+// cachedDefaults returns the root folder and quality profile a new series would
+// get, from the failover cache
+func (c *SonarrClient) cachedDefaults() (rootFolder, qualityProfile string, ok bool) {
+	folderBody, ok1 := arrCache.Get(c.baseURL.Get(), arrRootFolderEndpoint)
+	profileBody, ok2 := arrCache.Get(c.baseURL.Get(), arrQualityProfileEndpoint)
+	if !ok1 || !ok2 {
+		return "", "", false
+	}
+	var folders []SonarrRootFolder
+	var profiles []SonarrQualityProfile
+	if json.Unmarshal(folderBody, &folders) != nil || json.Unmarshal(profileBody, &profiles) != nil || len(folders) == 0 || len(profiles) == 0 {
+		return "", "", false
+	}
+
+	paths := make([]string, len(folders))
+	for i, folder := range folders {
+		paths[i] = folder.Path
+	}
+	ids, names := make([]int, len(profiles)), make([]string, len(profiles))
+	for i, profile := range profiles {
+		ids[i], names[i] = profile.ID, profile.Name
+	}
+	return resolveCachedDefaults(c.defaults, "Sonarr", paths, ids, names)
+}
+
+func resolveCachedDefaults(defaults ArrDefaults, app string, paths []string, ids []int, names []string) (string, string, bool) {
+	folderIndex, err := defaults.rootFolderIndex(app, paths)
+	if err != nil {
+		return "", "", false
+	}
+	profileID, err := defaults.qualityProfileID(app, ids, names)
+	if err != nil {
+		return "", "", false
+	}
+	for i, id := range ids {
+		if id == profileID {
+			return paths[folderIndex], names[i], true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// ArrKeysRequest is the body of PUT /api/admin/arr-keys; empty keys are left as they are
+type ArrKeysRequest struct {
+	Radarr string `json:"radarr,omitempty"`
+	Sonarr string `json:"sonarr,omitempty"`
+}
+
+// ArrKeyStatus is whether Radarr or Sonarr accepts its API key
+type ArrKeyStatus struct {
+	App     string `json:"app"`
+	Status  string `json:"status"` // "ok", "auth_expired" or "unavailable"
+	Message string `json:"message,omitempty"`
+}
+
+type ArrKeysResponse struct {
+	Success bool           `json:"success"`
+	Message string         `json:"message"`
+	Code    string         `json:"code,omitempty"`
+	Apps    []ArrKeyStatus `json:"apps,omitempty"`
+}
+
+// arrKeyTester is the part of the Radarr and Sonarr clients key rotation needs
+type arrKeyTester interface {
+	TestAPIKey(ctx context.Context, key string) error
+	SetAPIKey(key string)
+}
+
+// arrKeyStatus tests key against the app
+func arrKeyStatus(ctx context.Context, app string, client arrKeyTester, key string) ArrKeyStatus {
+	err := client.TestAPIKey(ctx, key)
+	switch {
+	case err == nil:
+		return ArrKeyStatus{App: app, Status: "ok"}
+	case errorCode(err) == ErrCodeAuthExpired:
+		return ArrKeyStatus{App: app, Status: "auth_expired", Message: err.Error()}
+	}
+	return ArrKeyStatus{App: app, Status: "unavailable", Message: err.Error()}
+}
+
+// ArrKeys tests the Radarr/Sonarr API keys (GET) or replaces them after they
+// were rotated (PUT), without a restart. A new key is only used once the app
+// accepted it. Replaced keys last until the next restart, which reads the
+// configured ones again.
+func (h *TorrentHandler) ArrKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET and PUT requests
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(ArrKeysResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET or PUT.",
+		})
+		return
+	}
+	if key := apiKeyFromContext(r.Context()); key != nil && !key.Admin {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(ArrKeysResponse{
+			Success: false,
+			Message: "Only ADMIN_KEYS can see or change the Radarr/Sonarr API keys",
+		})
+		return
+	}
+
+	clients := map[string]arrKeyTester{"radarr": h.radarrClient, "sonarr": h.sonarrClient}
+	keys := map[string]string{"radarr": h.radarrClient.apiKey.Get(), "sonarr": h.sonarrClient.apiKey.Get()}
+	configured := map[string]bool{"radarr": h.radarrClient.baseURL.Get() != "", "sonarr": h.sonarrClient.baseURL.Get() != ""}
+
+	if r.Method == http.MethodPut {
+		var req ArrKeysRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ArrKeysResponse{
+				Success: false,
+				Message: "Invalid request body: " + err.Error(),
+			})
+			return
+		}
+		updates := map[string]string{}
+		for app, key := range map[string]string{"radarr": req.Radarr, "sonarr": req.Sonarr} {
+			if key == "" {
+				continue
+			}
+			if !configured[app] {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(ArrKeysResponse{
+					Success: false,
+					Message: app + " is not configured",
+				})
+				return
+			}
+			updates[app] = key
+		}
+		if len(updates) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ArrKeysResponse{
+				Success: false,
+				Message: "radarr or sonarr is required",
+			})
+			return
+		}
+
+		// Every new key is tested before any is used
+		var statuses []ArrKeyStatus
+		for _, app := range []string{"radarr", "sonarr"} {
+			if key, ok := updates[app]; ok {
+				statuses = append(statuses, arrKeyStatus(r.Context(), app, clients[app], key))
+			}
+		}
+		for _, status := range statuses {
+			if status.Status == "ok" {
+				continue
+			}
+			code, httpStatus := "", http.StatusBadGateway
+			if status.Status == "auth_expired" {
+				code, httpStatus = ErrCodeAuthExpired, http.StatusUnprocessableEntity
+			}
+			w.WriteHeader(httpStatus)
+			json.NewEncoder(w).Encode(ArrKeysResponse{
+				Success: false,
+				Message: "New " + status.App + " API key not applied: " + status.Message,
+				Code:    code,
+				Apps:    statuses,
+			})
+			return
+		}
+		for app, key := range updates {
+			clients[app].SetAPIKey(key)
+			keys[app] = key
+			log.Printf("%s API key replaced", app)
+		}
+	}
+
+	var statuses []ArrKeyStatus
+	for _, app := range []string{"radarr", "sonarr"} {
+		if configured[app] {
+			statuses = append(statuses, arrKeyStatus(r.Context(), app, clients[app], keys[app]))
+		}
+	}
+	message := "OK"
+	if r.Method == http.MethodPut {
+		message = "API keys replaced"
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ArrKeysResponse{
+		Success: true,
+		Message: message,
+		Apps:    statuses,
+	})
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// arrBaseURL is a Radarr/Sonarr base URL that follows the app when it redirects,
+// e.g. to HTTPS, or turns out to run under a URL base like /radarr
+type arrBaseURL struct {
+	mu     sync.RWMutex
+	url    string
+	probed bool // the URL base was looked up in initialize.json
+}
+
+func newArrBaseURL(baseURL string) *arrBaseURL {
+	return &arrBaseURL{url: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (b *arrBaseURL) Get() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.url
+}
+
+func (b *arrBaseURL) set(baseURL string) {
+	b.mu.Lock()
+	b.url = strings.TrimSuffix(baseURL, "/")
+	b.mu.Unlock()
+}
+
+// startProbe reports whether the URL base still needs to be looked up, once per client
+func (b *arrBaseURL) startProbe() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.probed {
+		return false
+	}
+	b.probed = true
+	return true
+}
+
+// arrAPIKey is a Radarr/Sonarr API key that can be replaced at runtime when the
+// key is rotated in the app
+type arrAPIKey struct {
+	mu  sync.RWMutex
+	key string
+}
+
+func newArrAPIKey(key string) *arrAPIKey {
+	return &arrAPIKey{key: key}
+}
+
+func (k *arrAPIKey) Get() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.key
+}
+
+func (k *arrAPIKey) set(key string) {
+	k.mu.Lock()
+	k.key = key
+	k.mu.Unlock()
+}
+
+// arrCheckRedirect stops the client from following redirects itself: Go turns a
+// redirected POST into a GET and would send the API key to any host named in
+// Location. doArrRequest handles them instead.
+func arrCheckRedirect(req *http.Request, via []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// redirectedBaseURL derives the new base URL from a redirect of endpoint. Only
+// redirects to the same host that keep the API path are accepted, and never a
+// downgrade from HTTPS.
+func redirectedBaseURL(baseURL, endpoint string, location *url.URL) (string, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+	if location.Hostname() != base.Hostname() {
+		return "", fmt.Errorf("refusing redirect to another host: %s", location.Host)
+	}
+	if base.Scheme == "https" && location.Scheme != "https" {
+		return "", fmt.Errorf("refusing redirect from HTTPS to %s", location.Scheme)
+	}
+	path := strings.SplitN(endpoint, "?", 2)[0]
+	if !strings.HasSuffix(location.Path, path) {
+		return "", fmt.Errorf("redirect to %s does not keep the API path", location.Path)
+	}
+	return location.Scheme + "://" + location.Host + strings.TrimSuffix(location.Path, path), nil
+}
+
+// detectArrURLBase reads the URL base from initialize.json, trying the base URL
+// itself and then the app's usual sub-path (e.g. /radarr). It returns "" when
+// the base URL is already right.
+func detectArrURLBase(ctx context.Context, client *http.Client, baseURL, app string) (string, error) {
+	for _, prefix := range []string{"", "/" + strings.ToLower(app)} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+prefix+"/initialize.json", nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		var initialize struct {
+			URLBase string `json:"urlBase"`
+		}
+		err = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&initialize)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || err != nil {
+			continue
+		}
+
+		urlBase := strings.TrimSuffix(initialize.URLBase, "/")
+		if urlBase == "" || strings.HasSuffix(baseURL, urlBase) {
+			return "", nil
+		}
+		return baseURL + urlBase, nil
+	}
+	return "", fmt.Errorf("no initialize.json found")
+}
+
+// doArrRequest sends a Radarr/Sonarr API request. It follows a redirect once by
+// moving the base URL, and on the first 404 checks whether the app runs under a
+// URL base the configured URL is missing.
+func doArrRequest(ctx context.Context, app string, client *http.Client, base *arrBaseURL, apiKey, method, endpoint string, body interface{}) ([]byte, error) {
+	var jsonData []byte
+	if body != nil {
+		var err error
+		if jsonData, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		var reqBody io.Reader
+		if jsonData != nil {
+			reqBody = bytes.NewBuffer(jsonData)
+		}
+
+		baseURL := base.Get()
+		req, err := http.NewRequestWithContext(ctx, method, baseURL+endpoint, reqBody)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("X-Api-Key", apiKey)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if isRedirect(resp.StatusCode) && attempt == 0 {
+			location, err := req.URL.Parse(resp.Header.Get("Location"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid redirect: %w", err)
+			}
+			newBase, err := redirectedBaseURL(baseURL, endpoint, location)
+			if err != nil {
+				return nil, fmt.Errorf("%s redirected: %w", app, err)
+			}
+			log.Printf("%s redirected to %s, using it as the base URL", app, newBase)
+			base.set(newBase)
+			continue
+		}
+
+		if resp.StatusCode == http.StatusNotFound && attempt == 0 && base.startProbe() {
+			if newBase, err := detectArrURLBase(ctx, client, baseURL, app); err == nil && newBase != "" {
+				log.Printf("%s runs under a URL base, using %s as the base URL", app, newBase)
+				base.set(newBase)
+				continue
+			}
+		}
+
+		statusErr := &ArrStatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("%w: %w", newAPIError(ErrCodeAuthExpired, "%s rejected the API key, which may have been rotated", app), statusErr)
+		}
+		if resp.StatusCode >= 400 {
+			return nil, statusErr
+		}
+		return respBody, nil
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Finished async adds can be polled this long
+const asyncAddRetention = time.Hour
+
+// The steps an async add reports on separately in its stages
+var asyncAddStages = []string{StepExtract, StepQBAdd, StepLibraryAdd}
+
+// AsyncAddJob is an add started with "async": true, as GET /api/jobs/{id} shows it
+type AsyncAddJob struct {
+	ID     string `json:"id"`
+	Status string `json:"status"` // "running", "succeeded" or "failed"
+	Title  string `json:"title"`
+	// Status of extract, qbittorrent_add and library_add: "pending" until the
+	// step ran, then its outcome ("ok", "failed" or "skipped")
+	Stages     map[string]string   `json:"stages"`
+	Steps      []StepResult        `json:"steps,omitempty"`
+	Result     *AddTorrentResponse `json:"result,omitempty"` // what /api/torrent would have answered, once finished
+	StartedAt  time.Time           `json:"started_at"`
+	FinishedAt *time.Time          `json:"finished_at,omitempty"`
+
+	apiKey string // name of the key that started it
+}
+
+type JobResponse struct {
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Job     *AsyncAddJob `json:"job,omitempty"`
+}
+
+// AsyncAdds keeps the adds started with "async": true until asyncAddRetention
+// after they finished; they are lost on restart, though the add itself is
+// journaled like any other
+type AsyncAdds struct {
+	mu     sync.Mutex
+	nextID int64
+	jobs   map[string]*AsyncAddJob
+}
+
+func NewAsyncAdds() *AsyncAdds {
+	return &AsyncAdds{jobs: make(map[string]*AsyncAddJob)}
+}
+
+// create registers a running job
+func (a *AsyncAdds) create(title, apiKey string) *AsyncAddJob {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Finished jobs are dropped as new ones come in
+	for id, job := range a.jobs {
+		if job.FinishedAt != nil && time.Since(*job.FinishedAt) > asyncAddRetention {
+			delete(a.jobs, id)
+		}
+	}
+	a.nextID++
+	job := &AsyncAddJob{
+		ID:        fmt.Sprintf("async-%d", a.nextID),
+		Status:    JobStatusRunning,
+		Title:     title,
+		Stages:    make(map[string]string, len(asyncAddStages)),
+		StartedAt: time.Now(),
+		apiKey:    apiKey,
+	}
+	for _, stage := range asyncAddStages {
+		job.Stages[stage] = JobStatusPending
+	}
+	a.jobs[job.ID] = job
+	return job
+}
+
+// update changes a job under the lock
+func (a *AsyncAdds) update(id string, change func(job *AsyncAddJob)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if job, ok := a.jobs[id]; ok {
+		change(job)
+	}
+}
+
+// Get returns a copy of the job, or nil
+func (a *AsyncAdds) Get(id string) *AsyncAddJob {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	job, ok := a.jobs[id]
+	if !ok {
+		return nil
+	}
+	copied := *job
+	copied.Stages = make(map[string]string, len(job.Stages))
+	for stage, status := range job.Stages {
+		copied.Stages[stage] = status
+	}
+	copied.Steps = append([]StepResult(nil), job.Steps...)
+	return &copied
+}
+
+// startAsyncAdd runs the add in the background, outliving the request, and
+// returns its job
+func (h *TorrentHandler) startAsyncAdd(ctx context.Context, req AddTorrentRequest, debug *DebugLog) *AsyncAddJob {
+	var apiKey string
+	if key := apiKeyFromContext(ctx); key != nil {
+		apiKey = key.Name
+	}
+	job := h.asyncAdds.create(extractNameFromMagnet(req.MagnetLink), apiKey)
+
+	progress := func(p *AddPipeline, step StepResult) {
+		h.asyncAdds.update(job.ID, func(job *AsyncAddJob) {
+			job.Steps = append(job.Steps, step)
+			if _, ok := job.Stages[step.Name]; ok {
+				job.Stages[step.Name] = step.Status
+			}
+		})
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		p, err := h.runAddPipeline(ctx, req, progress)
+		_, resp := addTorrentResult(p, err, debug)
+		if err == nil {
+			resp.UndoToken, resp.UndoExpiry = h.issueUndo(p)
+		}
+		h.asyncAdds.update(job.ID, func(job *AsyncAddJob) {
+			now := time.Now()
+			job.Status, job.Result, job.FinishedAt = JobStatusSucceeded, &resp, &now
+			if err != nil {
+				job.Status = JobStatusFailed
+			}
+			// Stages the add never reached, e.g. the library add of a non-media torrent
+			for stage, status := range job.Stages {
+				if status == JobStatusPending {
+					job.Stages[stage] = StepStatusSkipped
+				}
+			}
+		})
+	}()
+	return job
+}
+
+// JobByID shows an async add's progress, GET /api/jobs/{id}. Keys that aren't
+// admins only see their own adds.
+func (h *TorrentHandler) JobByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(JobResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/")
+	job := h.asyncAdds.Get(id)
+	key := apiKeyFromContext(r.Context())
+	if job == nil || (key != nil && !key.Admin && job.apiKey != key.Name) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(JobResponse{
+			Success: false,
+			Message: "No such job: " + id,
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(JobResponse{
+		Success: true,
+		Message: "OK",
+		Job:     job,
+	})
+}
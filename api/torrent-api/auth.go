@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIKey is a named client key with optional per-key restrictions
+type APIKey struct {
+	Name      string
+	Key       string
+	MaxRating string // highest certification this key may add, "" for no limit
+	Admin     bool   // may change maintenance mode, from ADMIN_KEYS
+	MediaType string // "movie" or "tv" if the key may only add that, from KEY_MEDIA_TYPES
+}
+
+// ErrorResponse is the body for errors raised outside a specific endpoint
+type ErrorResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
+}
+
+type apiKeyContextKey struct{}
+
+// anonymousContextKey marks a request to a public route that came without a key
+// while keys are required
+type anonymousContextKey struct{}
+
+// ProxyAuth maps the users a fronting proxy such as Authelia authenticated,
+// named in a header like Remote-User, to API keys
+type ProxyAuth struct {
+	Header  string
+	Users   map[string]*APIKey
+	Default *APIKey // for users without an entry of their own, from "*:name"
+}
+
+// parseProxyAuth parses PROXY_AUTH_USERS: comma-separated "user:key_name" entries,
+// e.g. "alice:parents,bob:kids,*:kids". It returns nil when header is empty.
+func parseProxyAuth(header, spec string, keys []*APIKey) (*ProxyAuth, error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return nil, nil
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("proxy users are mapped to API keys, set API_KEYS")
+	}
+
+	auth := &ProxyAuth{Header: http.CanonicalHeaderKey(header), Users: make(map[string]*APIKey)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		user, name, ok := strings.Cut(entry, ":")
+		if !ok || user == "" || name == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected user:key_name", entry)
+		}
+		var key *APIKey
+		for _, k := range keys {
+			if k.Name == name {
+				key = k
+			}
+		}
+		if key == nil {
+			return nil, fmt.Errorf("unknown API key %q", name)
+		}
+		if user == "*" {
+			auth.Default = key
+		} else {
+			auth.Users[user] = key
+		}
+	}
+	if len(auth.Users) == 0 && auth.Default == nil {
+		return nil, fmt.Errorf("no users mapped, set PROXY_AUTH_USERS")
+	}
+	return auth, nil
+}
+
+// keyFor returns the API key of the proxy user the request names, or nil when
+// there is none. The header is only believed from TRUSTED_PROXIES.
+func (a *ProxyAuth) keyFor(r *http.Request) (user string, key *APIKey) {
+	if a == nil || !fromTrustedProxy(r) {
+		return "", nil
+	}
+	user = strings.TrimSpace(r.Header.Get(a.Header))
+	if user == "" {
+		return "", nil
+	}
+	if key, ok := a.Users[user]; ok {
+		return user, key
+	}
+	return user, a.Default
+}
+
+// parseAPIKeys parses API_KEYS: comma-separated "name:key[:max_rating]" entries,
+// e.g. "parents:s3cret,kids:k1dskey:PG"
+func parseAPIKeys(spec string) ([]*APIKey, error) {
+	var keys []*APIKey
+	for i, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		// The entry is only named by position: without its colon, all of it is the key
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid API key entry %d, expected name:key[:max_rating]", i+1)
+		}
+
+		key := &APIKey{Name: parts[0], Key: parts[1]}
+		if len(parts) == 3 && parts[2] != "" {
+			if _, ok := ratingLevel(parts[2]); !ok {
+				return nil, fmt.Errorf("unknown max rating %q for API key %s", parts[2], key.Name)
+			}
+			key.MaxRating = parts[2]
+		}
+		secretRedactor.Add(key.Key)
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// markAdminKeys flags the keys named in the comma-separated ADMIN_KEYS
+func markAdminKeys(keys []*APIKey, spec string) error {
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, key := range keys {
+			if key.Name == name {
+				key.Admin, found = true, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown API key %q", name)
+		}
+	}
+	return nil
+}
+
+// markKeyMediaTypes limits the keys in KEY_MEDIA_TYPES, comma-separated
+// "name:movie" or "name:tv" entries, to adding movies or series only
+func markKeyMediaTypes(keys []*APIKey, spec string) error {
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, mediaType, _ := strings.Cut(entry, ":")
+		switch mediaType = strings.ToLower(mediaType); mediaType {
+		case "movie", "tv":
+		case "series":
+			mediaType = "tv"
+		default:
+			return fmt.Errorf("invalid entry %q, expected name:movie or name:tv", entry)
+		}
+		found := false
+		for _, key := range keys {
+			if key.Name == name {
+				key.MediaType, found = mediaType, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown API key %q", name)
+		}
+	}
+	return nil
+}
+
+// checkKeyMediaType refuses an add of kind ("movie", "tv", or a non-media kind
+// such as "game") by a key limited to movies or TV series
+func checkKeyMediaType(ctx context.Context, kind string) error {
+	key := apiKeyFromContext(ctx)
+	if key == nil || key.MediaType == "" || key.MediaType == kind {
+		return nil
+	}
+	labels := map[string]string{"movie": "movies", "tv": "TV series"}
+	what, ok := labels[kind]
+	if !ok {
+		what = kind + " torrents"
+	}
+	return newAPIError(ErrCodeMediaTypeNotAllowed, "API key %s may only add %s, not %s", key.Name, labels[key.MediaType], what)
+}
+
+// authMiddleware requires a valid X-Api-Key header (or apikey query parameter), or a
+// mapped user from a trusted proxy's auth header, on every route except the /health
+// probes, the OpenAPI document and Swagger UI, and the Discord interactions endpoint,
+// which verifies Discord's signature instead. A key sent to those still counts, so
+// /health can show its detail. With no keys configured, all requests pass.
+func authMiddleware(keys []*APIKey, proxyAuth *ProxyAuth, next http.Handler) http.Handler {
+	if len(keys) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := r.Header.Get("X-Api-Key")
+		if provided == "" {
+			provided = r.URL.Query().Get("apikey")
+		}
+
+		if isProbePath(r.URL.Path) || isDocsPath(r.URL.Path) || r.URL.Path == discordInteractionsPath {
+			ctx := context.WithValue(r.Context(), anonymousContextKey{}, true)
+			key := findAPIKey(keys, provided)
+			if key == nil {
+				_, key = proxyAuth.keyFor(r)
+			}
+			if key != nil {
+				ctx = context.WithValue(r.Context(), apiKeyContextKey{}, key)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		if key := findAPIKey(keys, provided); key != nil {
+			ctx := context.WithValue(r.Context(), apiKeyContextKey{}, key)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		// Without a key, the user the proxy vouches for acts as its mapped key
+		if user, key := proxyAuth.keyFor(r); key != nil {
+			ctx := context.WithValue(r.Context(), apiKeyContextKey{}, key)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		} else if user != "" && provided == "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(ErrorResponse{
+				Success: false,
+				Message: "Proxy user " + user + " is not mapped to an API key",
+			})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Success: false,
+			Message: "Invalid or missing API key",
+		})
+	})
+}
+
+// findAPIKey returns the key matching provided, or nil
+func findAPIKey(keys []*APIKey, provided string) *APIKey {
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(key.Key)) == 1 {
+			return key
+		}
+	}
+	return nil
+}
+
+// apiKeyFromContext returns the key the request authenticated with, or nil
+func apiKeyFromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value(apiKeyContextKey{}).(*APIKey)
+	return key
+}
+
+// isAnonymous reports whether a request reached a public route without the
+// key the other routes require
+func isAnonymous(ctx context.Context) bool {
+	anonymous, _ := ctx.Value(anonymousContextKey{}).(bool)
+	return anonymous
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// trustedProxies are the reverse proxies whose X-Forwarded-* headers are believed
+var trustedProxies []*net.IPNet
+
+// normalizeBasePath turns "torrent-api/" or "/torrent-api/" into "/torrent-api", and "/" into ""
+func normalizeBasePath(basePath string) string {
+	basePath = strings.Trim(strings.TrimSpace(basePath), "/")
+	if basePath == "" {
+		return ""
+	}
+	return "/" + basePath
+}
+
+// parseTrustedProxies parses a comma-separated list of CIDRs or single IPs
+func parseTrustedProxies(spec string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	for _, ipNet := range trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// peerHost returns the address of the connection's other end
+func peerHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// fromTrustedProxy reports whether the connection comes from a trusted proxy or a Unix socket
+func fromTrustedProxy(r *http.Request) bool {
+	host := peerHost(r)
+	// Connections on a LISTEN_ADDRS Unix socket come from the local reverse proxy
+	if host == "" || host == "@" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && isTrustedProxy(ip)
+}
+
+// clientIP returns the real client address. X-Forwarded-For is only honoured when the
+// connection comes from a trusted proxy or a Unix socket, and is walked from the right so a client
+// can't spoof its address by sending the header itself.
+func clientIP(r *http.Request) string {
+	host := peerHost(r)
+	if !fromTrustedProxy(r) {
+		return host
+	}
+
+	forwarded := r.Header.Get("X-Forwarded-For")
+	if forwarded == "" {
+		if realIP := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-Ip"))); realIP != nil {
+			return realIP.String()
+		}
+		return host
+	}
+
+	hops := strings.Split(forwarded, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		if !isTrustedProxy(hop) {
+			return hop.String()
+		}
+		host = hop.String()
+	}
+	return host
+}
+
+// basePathMiddleware serves next under basePath (e.g. "/torrent-api"), stripping the
+// prefix before routing. The /health probes also stay reachable at the root.
+func basePathMiddleware(basePath string, next http.Handler) http.Handler {
+	if basePath == "" {
+		return next
+	}
+
+	stripped := http.StripPrefix(basePath, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == basePath:
+			target := basePath + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusPermanentRedirect)
+		case strings.HasPrefix(r.URL.Path, basePath+"/"):
+			stripped.ServeHTTP(w, r)
+		case isProbePath(r.URL.Path):
+			next.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// accessLogMiddleware logs one line per request with the real client address
+func accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		// Probes would drown out everything else
+		if strings.HasSuffix(r.URL.Path, "/health") || strings.Contains(r.URL.Path, "/health/") {
+			return
+		}
+		line := fmt.Sprintf("%s %s %d %dms client=%s", r.Method, r.URL.Path, rec.status, time.Since(start).Milliseconds(), clientIP(r))
+		// The extension's version helps tell which build a support report is about
+		if v := extensionVersion(r); v != "" {
+			line += " ext=" + v
+		}
+		log.Print(line)
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// A batch is a pasted list, e.g. every episode on a season page; longer lists
+// are refused rather than queued for minutes
+const maxBatchItems = 100
+
+// batchWorkers adds are run at once per batch; ADD_CONCURRENCY still limits
+// the adds of all requests together
+const batchWorkers = 4
+
+// BatchAddItem is one magnet of a batch; an empty type uses the batch's
+type BatchAddItem struct {
+	MagnetLink string `json:"magnet_link"`
+	Type       string `json:"type,omitempty"`
+}
+
+type BatchAddRequest struct {
+	Items []BatchAddItem `json:"items"`
+	Type  string         `json:"type,omitempty"` // "movie" or "tv" for items that don't set one
+}
+
+// BatchAddResult is the outcome of one item, answered like /api/torrent
+type BatchAddResult struct {
+	Index int    `json:"index"`
+	Name  string `json:"name,omitempty"`
+	AddTorrentResponse
+}
+
+type BatchAddResponse struct {
+	Success bool             `json:"success"` // every item was added
+	Message string           `json:"message"`
+	Added   int              `json:"added"`
+	Failed  int              `json:"failed"`
+	Results []BatchAddResult `json:"results,omitempty"`
+}
+
+// AddTorrentBatch adds several magnets through the add pipeline, batchWorkers at
+// a time, and reports each item's outcome in request order. A failed item
+// doesn't stop the others.
+func (h *TorrentHandler) AddTorrentBatch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(BatchAddResponse{
+			Success: false,
+			Message: "Method not allowed. Use POST.",
+		})
+		return
+	}
+
+	var req BatchAddRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BatchAddResponse{
+			Success: false,
+			Message: "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+	if len(req.Items) == 0 || len(req.Items) > maxBatchItems {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BatchAddResponse{
+			Success: false,
+			Message: fmt.Sprintf("items must hold 1 to %d magnet links", maxBatchItems),
+		})
+		return
+	}
+
+	results := make([]BatchAddResult, len(req.Items))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < min(batchWorkers, len(req.Items)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = h.addBatchItem(r, req, i)
+			}
+		}()
+	}
+	for i := range req.Items {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	resp := BatchAddResponse{Results: results}
+	for _, result := range results {
+		if result.Success {
+			resp.Added++
+		} else {
+			resp.Failed++
+		}
+	}
+	resp.Success = resp.Failed == 0
+	resp.Message = fmt.Sprintf("%d of %d torrents added", resp.Added, len(results))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// addBatchItem validates and adds item i of the batch
+func (h *TorrentHandler) addBatchItem(r *http.Request, batch BatchAddRequest, i int) BatchAddResult {
+	item := batch.Items[i]
+	req := AddTorrentRequest{MagnetLink: item.MagnetLink, Type: item.Type}
+	if req.Type == "" {
+		req.Type = batch.Type
+	}
+	result := BatchAddResult{Index: i}
+	if err := validateAddRequest(req); err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	p, err := h.runAddPipeline(r.Context(), req, nil)
+	result.Name = p.TorrentName
+	_, result.AddTorrentResponse = addTorrentResult(p, err, nil)
+	if err == nil {
+		result.UndoToken, result.UndoExpiry = h.issueUndo(p)
+	}
+	return result
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Calendar range limits in days
+const (
+	defaultCalendarDays = 7
+	maxCalendarDays     = 90
+)
+
+// Kinds of calendar release
+const (
+	CalendarReleaseCinema   = "cinema"
+	CalendarReleasePhysical = "physical"
+	CalendarReleaseDigital  = "digital"
+	CalendarReleaseAiring   = "airing"
+)
+
+// CalendarItem is one movie release or episode airing
+type CalendarItem struct {
+	Type         string    `json:"type"` // "movie" or "episode"
+	Title        string    `json:"title"`
+	Year         int       `json:"year,omitempty"`
+	Episode      string    `json:"episode,omitempty"` // e.g. "S02E05"
+	EpisodeTitle string    `json:"episode_title,omitempty"`
+	Release      string    `json:"release"` // "cinema", "physical", "digital" or "airing"
+	Date         time.Time `json:"date"`
+	HasFile      bool      `json:"has_file"`
+	MediaID      int       `json:"media_id"`             // Radarr movie / Sonarr series ID
+	AddedHere    bool      `json:"added_here,omitempty"` // added through this service, per history
+}
+
+type CalendarResponse struct {
+	Success  bool           `json:"success"`
+	Message  string         `json:"message"`
+	Days     int            `json:"days,omitempty"`
+	Items    []CalendarItem `json:"items,omitempty"`
+	Warnings []string       `json:"warnings,omitempty"`
+}
+
+// Calendar merges the Radarr and Sonarr calendars for the next ?days= days
+// (default 7), for the extension's "coming this week" list
+func (h *TorrentHandler) Calendar(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(CalendarResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+
+	days := defaultCalendarDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxCalendarDays {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(CalendarResponse{
+				Success: false,
+				Message: fmt.Sprintf("days must be between 1 and %d", maxCalendarDays),
+			})
+			return
+		}
+		days = n
+	}
+
+	items, warnings, err := h.calendar(r.Context(), time.Now(), days)
+	if err != nil {
+		log.Printf("Error fetching calendar: %v", err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(CalendarResponse{
+			Success: false,
+			Message: "Failed to fetch calendar: " + err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(CalendarResponse{
+		Success:  true,
+		Message:  "OK",
+		Days:     days,
+		Items:    items,
+		Warnings: warnings,
+	})
+}
+
+// calendar returns the releases from the start of today until days ahead sorted
+// by date. One app failing only adds a warning; both failing is an error.
+func (h *TorrentHandler) calendar(ctx context.Context, now time.Time, days int) ([]CalendarItem, []string, error) {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	end := start.AddDate(0, 0, days)
+	addedMovies, addedSeries := h.addedMediaIDs()
+
+	items := []CalendarItem{}
+	var warnings []string
+	movies, movieErr := h.radarrClient.GetCalendar(ctx, start, end)
+	if movieErr != nil {
+		warnings = append(warnings, "Radarr calendar unavailable: "+movieErr.Error())
+	}
+	for _, movie := range movies {
+		for _, release := range []struct {
+			kind string
+			date *time.Time
+		}{
+			{CalendarReleaseCinema, movie.InCinemas},
+			{CalendarReleasePhysical, movie.PhysicalRelease},
+			{CalendarReleaseDigital, movie.DigitalRelease},
+		} {
+			// Radarr returns a movie when any of its dates is in range
+			if release.date == nil || release.date.Before(start) || release.date.After(end) {
+				continue
+			}
+			items = append(items, CalendarItem{
+				Type:      "movie",
+				Title:     movie.Title,
+				Year:      movie.Year,
+				Release:   release.kind,
+				Date:      *release.date,
+				HasFile:   movie.HasFile,
+				MediaID:   movie.ID,
+				AddedHere: addedMovies[movie.ID],
+			})
+		}
+	}
+
+	episodes, episodeErr := h.sonarrClient.GetCalendar(ctx, start, end)
+	if episodeErr != nil {
+		warnings = append(warnings, "Sonarr calendar unavailable: "+episodeErr.Error())
+	}
+	for _, episode := range episodes {
+		if episode.AirDateUTC == nil {
+			continue
+		}
+		item := CalendarItem{
+			Type:         "episode",
+			Episode:      fmt.Sprintf("S%02dE%02d", episode.SeasonNumber, episode.EpisodeNumber),
+			EpisodeTitle: episode.Title,
+			Release:      CalendarReleaseAiring,
+			Date:         *episode.AirDateUTC,
+			HasFile:      episode.HasFile,
+			MediaID:      episode.SeriesID,
+			AddedHere:    addedSeries[episode.SeriesID],
+		}
+		if episode.Series != nil {
+			item.Title, item.Year = episode.Series.Title, episode.Series.Year
+		}
+		items = append(items, item)
+	}
+
+	if movieErr != nil && episodeErr != nil {
+		return nil, nil, fmt.Errorf("radarr: %v; sonarr: %v", movieErr, episodeErr)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Date.Before(items[j].Date)
+	})
+	return items, warnings, nil
+}
+
+// addedMediaIDs returns the Radarr movie and Sonarr series IDs history says
+// were added through this service and are still in the library
+func (h *TorrentHandler) addedMediaIDs() (movies, series map[int]bool) {
+	movies, series = make(map[int]bool), make(map[int]bool)
+	if h.history == nil {
+		return movies, series
+	}
+	for _, record := range h.history.List() {
+		if record.MediaID == 0 || !record.Active() {
+			continue
+		}
+		switch record.MediaType {
+		case "movie":
+			movies[record.MediaID] = true
+		case "tv":
+			series[record.MediaID] = true
+		}
+	}
+	return movies, series
+}
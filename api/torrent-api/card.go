@@ -0,0 +1,69 @@
+package main
+
+import "strconv"
+
+// MediaCard summarizes a matched movie or series for chat bot replies
+type MediaCard struct {
+	Type     string // "movie" or "tv"
+	Title    string
+	Year     int
+	Overview string
+	Poster   string
+	Link     string // TMDB/TVDB page
+}
+
+func movieCard(match *RadarrSearchResult) *MediaCard {
+	card := &MediaCard{
+		Type:     "movie",
+		Title:    match.Title,
+		Year:     match.Year,
+		Overview: match.Overview,
+		Poster:   match.RemotePoster,
+	}
+	if match.TMDBID != 0 {
+		card.Link = "https://www.themoviedb.org/movie/" + strconv.Itoa(match.TMDBID)
+	}
+	return card
+}
+
+func seriesCard(match *SonarrSearchResult) *MediaCard {
+	card := &MediaCard{
+		Type:     "tv",
+		Title:    match.Title,
+		Year:     match.Year,
+		Overview: match.Overview,
+		Poster:   match.RemotePoster,
+	}
+	if match.TVDBID != 0 {
+		card.Link = "https://www.thetvdb.com/?tab=series&id=" + strconv.Itoa(match.TVDBID)
+	}
+	return card
+}
+
+// pipelineCard returns the card of the movie or series a torrent add matched, or nil
+func pipelineCard(p *AddPipeline) *MediaCard {
+	switch {
+	case p.MovieMatch != nil:
+		return movieCard(p.MovieMatch)
+	case p.SeriesMatch != nil:
+		return seriesCard(p.SeriesMatch)
+	}
+	return nil
+}
+
+// Heading is the title with its year, e.g. "Heat (1995)"
+func (c *MediaCard) Heading() string {
+	if c.Year == 0 {
+		return c.Title
+	}
+	return c.Title + " (" + strconv.Itoa(c.Year) + ")"
+}
+
+// truncate shortens s to at most n runes, ending in "…" when cut
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Chat bot commands
+const (
+	BotCommandAddMovie = "add-movie"
+	BotCommandAddTV    = "add-tv"
+	BotCommandMagnet   = "magnet"
+)
+
+var (
+	magnetInTextPattern = regexp.MustCompile(`magnet:\?\S+`)
+	// Telegram command names can't contain "-" and get "@botname" appended in groups
+	botCommandPattern = regexp.MustCompile(`^/(add[-_]movie|add[-_]tv)(?:@\w+)?(?:\s+(.*))?$`)
+	botYearPattern    = regexp.MustCompile(`\s*\((\d{4})\)$`)
+)
+
+// botRequest is an add asked for in a chat message or command
+type botRequest struct {
+	Command    string
+	Name       string
+	Year       string
+	MagnetLink string
+	Candidate  *botCandidate // match picked by the user, skipping the lookup
+}
+
+// parseBotMessage reads "/add-movie Name (Year)", "/add-tv Name" (or "/add_movie",
+// "/add_tv") or any message containing a magnet link. ok is false for other messages.
+func parseBotMessage(text string) (req botRequest, ok bool) {
+	text = strings.TrimSpace(text)
+	if m := botCommandPattern.FindStringSubmatch(text); m != nil {
+		return newBotMediaRequest(strings.ReplaceAll(m[1], "_", "-"), m[2], ""), true
+	}
+	if link := magnetInTextPattern.FindString(text); link != "" {
+		return botRequest{Command: BotCommandMagnet, MagnetLink: link}, true
+	}
+	return botRequest{}, false
+}
+
+// newBotMediaRequest builds an add-movie/add-tv request; a trailing "(Year)" in
+// the name is used when year is empty
+func newBotMediaRequest(command, name, year string) botRequest {
+	name = strings.TrimSpace(name)
+	if m := botYearPattern.FindStringSubmatch(name); m != nil && year == "" {
+		name, year = strings.TrimSpace(strings.TrimSuffix(name, m[0])), m[1]
+	}
+	return botRequest{Command: command, Name: name, Year: strings.TrimSpace(year)}
+}
+
+// botUpdate is the progress or final state of a chat bot add
+type botUpdate struct {
+	Done     bool
+	Success  bool
+	Message  string
+	Steps    []StepResult
+	Warnings []string
+	Card     *MediaCard
+}
+
+// runBotRequest runs an add for a chat bot through the same code as the API.
+// update is called after every torrent pipeline step and once more when done.
+func (h *TorrentHandler) runBotRequest(ctx context.Context, req botRequest, update func(botUpdate)) {
+	switch req.Command {
+	case BotCommandMagnet:
+		if !isValidMagnetLink(req.MagnetLink) {
+			update(botUpdate{Done: true, Message: "Invalid magnet link format"})
+			return
+		}
+		p, err := h.runAddPipeline(ctx, AddTorrentRequest{MagnetLink: req.MagnetLink}, func(p *AddPipeline, step StepResult) {
+			update(botUpdate{Message: "Adding " + p.TorrentName, Steps: p.Steps, Card: pipelineCard(p)})
+		})
+		if err != nil {
+			update(botUpdate{Done: true, Message: "Failed to add torrent: " + err.Error(), Steps: p.Steps, Card: pipelineCard(p)})
+			return
+		}
+		message := "Torrent added to qBittorrent"
+		switch {
+		case p.NonMedia != "":
+			message += " as " + p.NonMedia
+		case p.AddedToLibrary && p.IsMovie:
+			message += " and movie added to Radarr"
+		case p.AddedToLibrary:
+			message += " and series added to Sonarr"
+		}
+		update(botUpdate{Done: true, Success: true, Message: message, Steps: p.Steps, Warnings: p.Warnings, Card: pipelineCard(p)})
+
+	default:
+		if c := req.Candidate; c != nil {
+			var resp *AddMediaResponse
+			var err error
+			if c.Movie != nil {
+				resp, err = h.addMovieMatch(ctx, req.Name, c.Movie, ArrChoice{}, false, true, 0)
+			} else {
+				resp, err = h.addSeriesMatch(ctx, req.Name, c.Series, ArrChoice{}, false, true)
+			}
+			update(botUpdate{Done: true, Success: err == nil, Message: resp.Message, Warnings: resp.Warnings, Card: c.Card})
+			return
+		}
+		if req.Name == "" {
+			update(botUpdate{Done: true, Message: fmt.Sprintf("Usage: /%s <name> [(year)]", req.Command)})
+			return
+		}
+		mediaType := "movie"
+		if req.Command == BotCommandAddTV {
+			mediaType = "tv"
+		}
+		resp, card, err := h.addMedia(ctx, AddMediaRequest{Name: req.Name, Type: mediaType, Year: req.Year}, mediaType)
+		update(botUpdate{Done: true, Success: err == nil, Message: resp.Message, Warnings: resp.Warnings, Card: card})
+	}
+}
+
+// botCandidate is one lookup result offered for the user to pick
+type botCandidate struct {
+	Card   *MediaCard
+	Movie  *RadarrSearchResult
+	Series *SonarrSearchResult
+
+	rank int // position in its app's lookup results
+}
+
+// maxBotCandidates limits the choices offered for an ambiguous title
+const maxBotCandidates = 6
+
+// findBotCandidates looks name up in Radarr, Sonarr or both (mediaType "") and
+// returns up to limit results, exact title (and year) matches first and the
+// two apps' results interleaved otherwise. exact reports how many there are; a
+// single exact match needs no choice.
+func (h *TorrentHandler) findBotCandidates(ctx context.Context, name, year, mediaType string, limit int) (candidates []botCandidate, exact int, err error) {
+	searchTerm := strings.TrimSpace(name + " " + year)
+
+	var errs []string
+	if mediaType != "tv" {
+		var results []RadarrSearchResult
+		_, err := searchWithCorrections(searchTerm, func(term string) (bool, error) {
+			var err error
+			results, err = h.radarrClient.SearchMovie(ctx, term)
+			return len(results) > 0, err
+		})
+		if err != nil {
+			errs = append(errs, "Radarr: "+err.Error())
+		}
+		for i := range results {
+			candidates = append(candidates, botCandidate{Card: movieCard(&results[i]), Movie: &results[i], rank: i})
+		}
+	}
+	if mediaType != "movie" {
+		var results []SonarrSearchResult
+		_, err := searchWithCorrections(searchTerm, func(term string) (bool, error) {
+			var err error
+			results, err = h.sonarrClient.SearchSeries(ctx, term)
+			return len(results) > 0, err
+		})
+		if err != nil {
+			errs = append(errs, "Sonarr: "+err.Error())
+		}
+		for i := range results {
+			candidates = append(candidates, botCandidate{Card: seriesCard(&results[i]), Series: &results[i], rank: i})
+		}
+	}
+	if len(candidates) == 0 && len(errs) > 0 {
+		return nil, 0, fmt.Errorf("lookup failed: %s", strings.Join(errs, "; "))
+	}
+
+	// Exact matches first, then by lookup position, movies before series on a tie
+	wanted := normalizeTitle(name)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if ei, ej := candidates[i].exact(wanted, year), candidates[j].exact(wanted, year); ei != ej {
+			return ei
+		}
+		return candidates[i].rank < candidates[j].rank
+	})
+	for _, c := range candidates {
+		if c.exact(wanted, year) {
+			exact++
+		}
+	}
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, exact, nil
+}
+
+func (c botCandidate) exact(normalizedTitle, year string) bool {
+	return normalizeTitle(c.Card.Title) == normalizedTitle && (year == "" || strconv.Itoa(c.Card.Year) == year)
+}
+
+// throttleUpdates wraps send so progress is passed on at most once per interval;
+// the final update is always sent
+func throttleUpdates(interval time.Duration, send func(botUpdate)) func(botUpdate) {
+	var mu sync.Mutex
+	var last time.Time
+	return func(update botUpdate) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !update.Done && time.Since(last) < interval {
+			return
+		}
+		last = time.Now()
+		send(update)
+	}
+}
+
+// botUpdateText renders an update's message, steps and warnings as chat text
+func botUpdateText(update botUpdate) string {
+	text := update.Message
+	if !update.Done {
+		text += "…"
+	}
+	if steps := formatSteps(update.Steps); steps != "" {
+		text += "\n" + steps
+	}
+	for _, warning := range update.Warnings {
+		text += "\n⚠️ " + warning
+	}
+	return text
+}
+
+// formatSteps renders pipeline steps one per line for chat replies
+func formatSteps(steps []StepResult) string {
+	var b strings.Builder
+	for _, step := range steps {
+		switch step.Status {
+		case StepStatusOK:
+			fmt.Fprintf(&b, "✅ %s (%dms)\n", step.Name, step.DurationMs)
+		case StepStatusFailed:
+			fmt.Fprintf(&b, "❌ %s: %s\n", step.Name, step.Error)
+		default:
+			fmt.Fprintf(&b, "⏭️ %s\n", step.Name)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,201 @@
+// Package client is a Go client for the torrent API, for tools such as CLIs,
+// chat bots and home automation bridges.
+//
+//	c := client.New("http://localhost:8080", client.WithAPIKey("s3cret"))
+//	resp, err := c.AddTorrent(ctx, client.AddTorrentRequest{MagnetLink: magnet})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Version of this client package, sent in the User-Agent header
+const Version = "1.0.0"
+
+// Client calls the torrent API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+	userAgent  string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithAPIKey sends key as X-Api-Key on every request
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithHTTPClient replaces the default HTTP client (60s timeout)
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithRetries sets how many times a failed request is retried (default 2) and the
+// initial backoff, which doubles after every attempt (default 500ms)
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) { c.retries, c.backoff = retries, backoff }
+}
+
+// WithUserAgent prefixes the User-Agent, e.g. "my-bot/1.2"
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent + " " + c.userAgent }
+}
+
+// New returns a client for the API at baseURL, including any BASE_PATH
+// (e.g. "https://home.example.com/torrent-api")
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+		retries:    2,
+		backoff:    500 * time.Millisecond,
+		userAgent:  "torrent-api-client/" + Version,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is a non-2xx response from the API
+type Error struct {
+	StatusCode int
+	Code       string // machine-readable error code, e.g. "CONTENT_RATING_BLOCKED"
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("torrent api: %s (%d %s)", e.Message, e.StatusCode, e.Code)
+	}
+	return fmt.Sprintf("torrent api: %s (%d)", e.Message, e.StatusCode)
+}
+
+// IsCode reports whether err is an API error with the given code
+func IsCode(err error, code string) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// do sends a request and decodes the JSON response into out, also on error
+// statuses, since failed adds still report their steps. Idempotent requests are
+// retried on network errors and 502/503/504; others only when the connection
+// could not be made, so an add is never sent twice.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}, idempotent bool) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, target, payload)
+		retry := false
+		if err != nil {
+			retry = idempotent || isDialError(err)
+		} else if idempotent {
+			switch resp.StatusCode {
+			case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				retry = true
+			}
+		}
+
+		if !retry || attempt >= c.retries {
+			if err != nil {
+				return err
+			}
+			return decodeResponse(resp, out)
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, target string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.apiKey != "" {
+		req.Header.Set("X-Api-Key", c.apiKey)
+	}
+	return c.httpClient.Do(req)
+}
+
+func decodeResponse(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var envelope struct {
+		Message string `json:"message"`
+		Code    string `json:"code"`
+	}
+	if resp.StatusCode >= 300 {
+		json.Unmarshal(data, &envelope)
+		if out != nil {
+			json.Unmarshal(data, out)
+		}
+		if envelope.Message == "" {
+			envelope.Message = strings.TrimSpace(string(data))
+		}
+		return &Error{StatusCode: resp.StatusCode, Code: envelope.Code, Message: envelope.Message}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("torrent api: invalid response: %w", err)
+	}
+	return nil
+}
+
+// isDialError reports whether the request failed before reaching the server
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,409 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// AddTorrent adds a magnet to qBittorrent and its movie/series to Radarr/Sonarr.
+// On failure the response still carries the category and per-step results.
+func (c *Client) AddTorrent(ctx context.Context, req AddTorrentRequest) (*AddTorrentResponse, error) {
+	var resp AddTorrentResponse
+	err := c.do(ctx, http.MethodPost, "/api/torrent", nil, req, &resp, false)
+	return &resp, err
+}
+
+// AddTorrentBatch adds several magnets at once; each result says how its add went
+func (c *Client) AddTorrentBatch(ctx context.Context, req BatchAddRequest) (*BatchAddResponse, error) {
+	var resp BatchAddResponse
+	err := c.do(ctx, http.MethodPost, "/api/torrent/batch", nil, req, &resp, false)
+	return &resp, err
+}
+
+// Torrents lists the torrents in qBittorrent matching a state filter ("" for the
+// unfinished ones) and, if set, a category
+func (c *Client) Torrents(ctx context.Context, filter, category string) (*TorrentsResponse, error) {
+	query := url.Values{}
+	if filter != "" {
+		query.Set("filter", filter)
+	}
+	if category != "" {
+		query.Set("category", category)
+	}
+	var resp TorrentsResponse
+	err := c.do(ctx, http.MethodGet, "/api/torrents", query, nil, &resp, true)
+	return &resp, err
+}
+
+// Queue returns qBittorrent's downloads merged with the Radarr and Sonarr queues
+func (c *Client) Queue(ctx context.Context) (*QueueResponse, error) {
+	var resp QueueResponse
+	err := c.do(ctx, http.MethodGet, "/api/queue", nil, nil, &resp, true)
+	return &resp, err
+}
+
+// DeleteTorrent removes a torrent from qBittorrent and, with req.Library set,
+// unmonitors or removes its movie/series, undoing an add
+func (c *Client) DeleteTorrent(ctx context.Context, hash string, req TorrentDeleteRequest) (*TorrentActionResponse, error) {
+	var resp TorrentActionResponse
+	err := c.do(ctx, http.MethodDelete, "/api/torrent/"+url.PathEscape(hash), nil, req, &resp, false)
+	return &resp, err
+}
+
+// Undo rolls back an add with the UndoToken of its response, while it is valid
+func (c *Client) Undo(ctx context.Context, token string) (*TorrentActionResponse, error) {
+	var resp TorrentActionResponse
+	err := c.do(ctx, http.MethodPost, "/api/undo", nil, map[string]string{"token": token}, &resp, false)
+	return &resp, err
+}
+
+// Reannounce makes qBittorrent announce a torrent to its trackers now
+func (c *Client) Reannounce(ctx context.Context, hash string) (*TorrentActionResponse, error) {
+	var resp TorrentActionResponse
+	err := c.do(ctx, http.MethodPost, "/api/torrent/"+url.PathEscape(hash)+"/reannounce", nil, nil, &resp, true)
+	return &resp, err
+}
+
+// Trackers lists a torrent's trackers with their status
+func (c *Client) Trackers(ctx context.Context, hash string) (*TorrentActionResponse, error) {
+	var resp TorrentActionResponse
+	err := c.do(ctx, http.MethodGet, "/api/torrent/"+url.PathEscape(hash)+"/trackers", nil, nil, &resp, true)
+	return &resp, err
+}
+
+// AddTrackers adds trackers to a torrent, optionally reannouncing right away
+func (c *Client) AddTrackers(ctx context.Context, hash string, req TrackerEditRequest) (*TorrentActionResponse, error) {
+	var resp TorrentActionResponse
+	err := c.do(ctx, http.MethodPost, "/api/torrent/"+url.PathEscape(hash)+"/trackers", nil, req, &resp, true)
+	return &resp, err
+}
+
+// RemoveTrackers removes trackers from a torrent
+func (c *Client) RemoveTrackers(ctx context.Context, hash string, urls []string) (*TorrentActionResponse, error) {
+	var resp TorrentActionResponse
+	err := c.do(ctx, http.MethodDelete, "/api/torrent/"+url.PathEscape(hash)+"/trackers", nil, TrackerEditRequest{URLs: urls}, &resp, true)
+	return &resp, err
+}
+
+// AddMedia adds a movie or series to Radarr/Sonarr by name and starts a search
+func (c *Client) AddMedia(ctx context.Context, req AddMediaRequest) (*AddMediaResponse, error) {
+	var resp AddMediaResponse
+	err := c.do(ctx, http.MethodPost, "/api/media", nil, req, &resp, false)
+	return &resp, err
+}
+
+// ResolvePreview confirms or cancels an add made with AddMediaRequest.Preview
+func (c *Client) ResolvePreview(ctx context.Context, req ResolvePreviewRequest) (*AddMediaResponse, error) {
+	var resp AddMediaResponse
+	err := c.do(ctx, http.MethodPost, "/api/media", nil, req, &resp, false)
+	return &resp, err
+}
+
+// Search looks a title up in Radarr and Sonarr; mediaType is "movie", "tv" or
+// "" for both, and year may be empty
+func (c *Client) Search(ctx context.Context, q, mediaType, year string) (*SearchResponse, error) {
+	query := url.Values{"q": {q}}
+	if mediaType != "" {
+		query.Set("type", mediaType)
+	}
+	if year != "" {
+		query.Set("year", year)
+	}
+	var resp SearchResponse
+	err := c.do(ctx, http.MethodGet, "/api/search", query, nil, &resp, true)
+	return &resp, err
+}
+
+// MediaExists reports whether a title is already in Radarr or Sonarr
+func (c *Client) MediaExists(ctx context.Context, q MediaExistsQuery) (*MediaExistsResponse, error) {
+	query := url.Values{}
+	if q.TMDBID > 0 {
+		query.Set("tmdb_id", strconv.Itoa(q.TMDBID))
+	}
+	if q.TVDBID > 0 {
+		query.Set("tvdb_id", strconv.Itoa(q.TVDBID))
+	}
+	if q.Title != "" {
+		query.Set("title", q.Title)
+	}
+	if q.Year > 0 {
+		query.Set("year", strconv.Itoa(q.Year))
+	}
+	if q.Type != "" {
+		query.Set("type", q.Type)
+	}
+	var resp MediaExistsResponse
+	err := c.do(ctx, http.MethodGet, "/api/media", query, nil, &resp, true)
+	return &resp, err
+}
+
+// Parse runs name parsing and detection without adding anything
+func (c *Client) Parse(ctx context.Context, req ParseRequest) (*ParseResponse, error) {
+	var resp ParseResponse
+	err := c.do(ctx, http.MethodPost, "/api/parse", nil, req, &resp, true)
+	return &resp, err
+}
+
+// Scrape lists the magnets on a supported result page
+func (c *Client) Scrape(ctx context.Context, pageURL string) (*ScrapeResponse, error) {
+	var resp ScrapeResponse
+	err := c.do(ctx, http.MethodPost, "/api/scrape", nil, map[string]string{"url": pageURL}, &resp, true)
+	return &resp, err
+}
+
+// Variants groups the quality variants of each title and picks the one the
+// quality profile prefers; req.Add also adds the picks
+func (c *Client) Variants(ctx context.Context, req VariantsRequest) (*VariantsResponse, error) {
+	var resp VariantsResponse
+	err := c.do(ctx, http.MethodPost, "/api/variants", nil, req, &resp, !req.Add)
+	return &resp, err
+}
+
+// Jobs lists background work that hasn't finished, such as paced searches
+func (c *Client) Jobs(ctx context.Context) (*JobsResponse, error) {
+	var resp JobsResponse
+	err := c.do(ctx, http.MethodGet, "/api/jobs", nil, nil, &resp, true)
+	return &resp, err
+}
+
+// Job shows the progress of an add started with Async
+func (c *Client) Job(ctx context.Context, id string) (*JobResponse, error) {
+	var resp JobResponse
+	err := c.do(ctx, http.MethodGet, "/api/jobs/"+url.PathEscape(id), nil, nil, &resp, true)
+	return &resp, err
+}
+
+// Inflight lists the torrent adds being processed and the step each is in
+func (c *Client) Inflight(ctx context.Context) (*InflightResponse, error) {
+	var resp InflightResponse
+	err := c.do(ctx, http.MethodGet, "/api/inflight", nil, nil, &resp, true)
+	return &resp, err
+}
+
+// History pages through the add history, newest first
+func (c *Client) History(ctx context.Context, q HistoryQuery) (*HistoryResponse, error) {
+	query := url.Values{}
+	if q.Type != "" {
+		query.Set("type", q.Type)
+	}
+	if q.Status != "" {
+		query.Set("status", q.Status)
+	}
+	if !q.Since.IsZero() {
+		query.Set("since", q.Since.Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		query.Set("until", q.Until.Format(time.RFC3339))
+	}
+	if q.Page > 0 {
+		query.Set("page", strconv.Itoa(q.Page))
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	var resp HistoryResponse
+	err := c.do(ctx, http.MethodGet, "/api/history", query, nil, &resp, true)
+	return &resp, err
+}
+
+// ConfigOptions lists the Radarr and Sonarr quality profiles, root folders and
+// tags an add can pick from
+func (c *Client) ConfigOptions(ctx context.Context) (*ConfigOptionsResponse, error) {
+	var resp ConfigOptionsResponse
+	err := c.do(ctx, http.MethodGet, "/api/config/options", nil, nil, &resp, true)
+	return &resp, err
+}
+
+// Schedules lists the background workers
+func (c *Client) Schedules(ctx context.Context) (*SchedulesResponse, error) {
+	var resp SchedulesResponse
+	err := c.do(ctx, http.MethodGet, "/api/schedules", nil, nil, &resp, true)
+	return &resp, err
+}
+
+// UpdateSchedules changes the timezone and/or worker schedules
+func (c *Client) UpdateSchedules(ctx context.Context, req SchedulesRequest) (*SchedulesResponse, error) {
+	var resp SchedulesResponse
+	err := c.do(ctx, http.MethodPut, "/api/schedules", nil, req, &resp, true)
+	return &resp, err
+}
+
+// Maintenance returns the maintenance mode state
+func (c *Client) Maintenance(ctx context.Context) (*MaintenanceResponse, error) {
+	var resp MaintenanceResponse
+	err := c.do(ctx, http.MethodGet, "/api/admin/maintenance", nil, nil, &resp, true)
+	return &resp, err
+}
+
+// UpdateMaintenance pauses or resumes adds, globally or per key; needs an admin key
+func (c *Client) UpdateMaintenance(ctx context.Context, req MaintenanceRequest) (*MaintenanceResponse, error) {
+	var resp MaintenanceResponse
+	err := c.do(ctx, http.MethodPut, "/api/admin/maintenance", nil, req, &resp, true)
+	return &resp, err
+}
+
+// ArrKeys reports whether Radarr and Sonarr accept their API keys; needs an admin key
+func (c *Client) ArrKeys(ctx context.Context) (*ArrKeysResponse, error) {
+	var resp ArrKeysResponse
+	err := c.do(ctx, http.MethodGet, "/api/admin/arr-keys", nil, nil, &resp, true)
+	return &resp, err
+}
+
+// UpdateArrKeys replaces rotated Radarr/Sonarr API keys without a restart;
+// needs an admin key
+func (c *Client) UpdateArrKeys(ctx context.Context, req ArrKeysRequest) (*ArrKeysResponse, error) {
+	var resp ArrKeysResponse
+	err := c.do(ctx, http.MethodPut, "/api/admin/arr-keys", nil, req, &resp, true)
+	return &resp, err
+}
+
+// Reclassify re-runs extraction and detection over past torrent adds and
+// reports which would now be classified differently; nothing is changed.
+// Needs an admin key.
+func (c *Client) Reclassify(ctx context.Context, req ReclassifyRequest) (*ReclassifyResponse, error) {
+	var resp ReclassifyResponse
+	err := c.do(ctx, http.MethodPost, "/api/maintenance/reclassify", nil, req, &resp, true)
+	return &resp, err
+}
+
+// Retag adds or removes a Radarr/Sonarr tag on the library items added through
+// the service; it needs an admin key
+func (c *Client) Retag(ctx context.Context, req RetagRequest) (*RetagResponse, error) {
+	var resp RetagResponse
+	err := c.do(ctx, http.MethodPost, "/api/maintenance/retag", nil, req, &resp, true)
+	return &resp, err
+}
+
+// SeedingCategories returns the share limits by qBittorrent category
+func (c *Client) SeedingCategories(ctx context.Context) (*SeedingCategoriesResponse, error) {
+	var resp SeedingCategoriesResponse
+	err := c.do(ctx, http.MethodGet, "/api/seeding/categories", nil, nil, &resp, true)
+	return &resp, err
+}
+
+// UpdateSeedingCategories changes category share limits and applies them to the
+// torrents in those categories; a nil policy removes one. Needs an admin key.
+func (c *Client) UpdateSeedingCategories(ctx context.Context, changes map[string]*SeedingPolicy) (*SeedingCategoriesResponse, error) {
+	var resp SeedingCategoriesResponse
+	err := c.do(ctx, http.MethodPut, "/api/seeding/categories", nil, changes, &resp, true)
+	return &resp, err
+}
+
+// LibraryUpgrades lists library items below their quality cutoff
+func (c *Client) LibraryUpgrades(ctx context.Context, opts LibraryUpgradesOptions) (*LibraryUpgradesResponse, error) {
+	query := url.Values{}
+	if opts.Type != "" {
+		query.Set("type", opts.Type)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Suggest {
+		query.Set("suggest", "true")
+	}
+
+	var resp LibraryUpgradesResponse
+	err := c.do(ctx, http.MethodGet, "/api/library/upgrades", query, nil, &resp, true)
+	return &resp, err
+}
+
+// LibraryStats returns Radarr/Sonarr library counts and root folder disk usage;
+// refresh bypasses the server's cache
+func (c *Client) LibraryStats(ctx context.Context, refresh bool) (*LibraryStatsResponse, error) {
+	query := url.Values{}
+	if refresh {
+		query.Set("refresh", "true")
+	}
+	var resp LibraryStatsResponse
+	err := c.do(ctx, http.MethodGet, "/api/library/stats", query, nil, &resp, true)
+	return &resp, err
+}
+
+// Calendar returns the Radarr releases and Sonarr airings of the next days
+// (0 for the server's default of 7)
+func (c *Client) Calendar(ctx context.Context, days int) (*CalendarResponse, error) {
+	query := url.Values{}
+	if days > 0 {
+		query.Set("days", strconv.Itoa(days))
+	}
+	var resp CalendarResponse
+	err := c.do(ctx, http.MethodGet, "/api/calendar", query, nil, &resp, true)
+	return &resp, err
+}
+
+// ClientStats returns qBittorrent transfer statistics and torrent counts
+func (c *Client) ClientStats(ctx context.Context) (*ClientStatsResponse, error) {
+	var resp ClientStatsResponse
+	err := c.do(ctx, http.MethodGet, "/api/client/stats", nil, nil, &resp, true)
+	return &resp, err
+}
+
+// Stats returns add counts by type and source, failure codes, release groups
+// and the library add hit rate over the last days (0 for all history)
+func (c *Client) Stats(ctx context.Context, days int) (*StatsResponse, error) {
+	query := url.Values{}
+	if days > 0 {
+		query.Set("days", strconv.Itoa(days))
+	}
+	var resp StatsResponse
+	err := c.do(ctx, http.MethodGet, "/api/stats", query, nil, &resp, true)
+	return &resp, err
+}
+
+// TrackerStats returns add success, stall and completion rates per tracker
+// domain over the last days (0 for all history)
+func (c *Client) TrackerStats(ctx context.Context, days int) (*TrackerStatsResponse, error) {
+	query := url.Values{}
+	if days > 0 {
+		query.Set("days", strconv.Itoa(days))
+	}
+	var resp TrackerStatsResponse
+	err := c.do(ctx, http.MethodGet, "/api/stats/trackers", query, nil, &resp, true)
+	return &resp, err
+}
+
+// SelfTest checks qBittorrent access and the *arr download client mapping;
+// fix asks the server to correct the mapping when DOWNLOAD_CLIENT_AUTOFIX is on
+func (c *Client) SelfTest(ctx context.Context, fix bool) (*SelfTestResponse, error) {
+	method := http.MethodGet
+	if fix {
+		method = http.MethodPost
+	}
+
+	var resp SelfTestResponse
+	err := c.do(ctx, method, "/api/selftest", nil, nil, &resp, true)
+	return &resp, err
+}
+
+// Ready returns the readiness probe state. A not-ready service returns the
+// dependency details together with an *Error (503).
+func (c *Client) Ready(ctx context.Context) (*ReadinessResponse, error) {
+	var resp ReadinessResponse
+	err := c.do(ctx, http.MethodGet, "/health/ready", nil, nil, &resp, false)
+	return &resp, err
+}
+
+// Health checks that the service is up
+func (c *Client) Health(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/health/live", nil, nil, nil, false)
+}
+
+// ServerVersion returns the server's build and the qBittorrent, Radarr and
+// Sonarr versions it detected
+func (c *Client) ServerVersion(ctx context.Context) (*VersionResponse, error) {
+	var resp VersionResponse
+	err := c.do(ctx, http.MethodGet, "/api/version", nil, nil, &resp, true)
+	return &resp, err
+}
+
+// HealthReport pings the server's backends and returns each one's status,
+// latency and soft failures. When one is down it fails with 503 and still
+// returns the report.
+func (c *Client) HealthReport(ctx context.Context) (*HealthResponse, error) {
+	var resp HealthResponse
+	err := c.do(ctx, http.MethodGet, "/health", nil, nil, &resp, false)
+	return &resp, err
+}
@@ -0,0 +1,3 @@
+module github.com/s3nthilg0pal/chrome-extension-typescript-starter/api/torrent-api/client
+
+go 1.21
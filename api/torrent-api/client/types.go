@@ -0,0 +1,968 @@
+package client
+
+import "time"
+
+// Request and response types, mirroring the server's JSON
+
+type AddTorrentRequest struct {
+	MagnetLink string `json:"magnet_link"`
+	Type       string `json:"type,omitempty"`       // "movie" or "tv"; auto-detected when empty
+	SourceURL  string `json:"source_url,omitempty"` // Page the magnet was found on, if known
+	Strict     *bool  `json:"strict,omitempty"`     // Remove the torrent again if the library add fails
+	Force      bool   `json:"force,omitempty"`      // Add even if the same torrent or title failed before
+	Confirm    bool   `json:"confirm,omitempty"`    // Add even if the title is in the other library
+	Debug      bool   `json:"debug,omitempty"`      // Return the decision log; admin keys only
+	Async      bool   `json:"async,omitempty"`      // Answer at once with a job ID to poll with Client.Job
+	// Root folder path and quality profile ID from Client.ConfigOptions; the
+	// defaults are used when empty. Set Type too, as they differ per app.
+	RootFolder       string `json:"root_folder,omitempty"`
+	QualityProfileID int    `json:"quality_profile_id,omitempty"`
+}
+
+type AddTorrentResponse struct {
+	Success        bool               `json:"success"`
+	Message        string             `json:"message"`
+	Category       string             `json:"category,omitempty"`
+	MediaTitle     string             `json:"media_title,omitempty"`
+	AddedToLibrary bool               `json:"added_to_library"`
+	Code           string             `json:"code,omitempty"`
+	NonMedia       string             `json:"non_media,omitempty"`
+	RolledBack     bool               `json:"rolled_back,omitempty"`
+	Warnings       []string           `json:"warnings,omitempty"`
+	Steps          []StepResult       `json:"steps,omitempty"`
+	SeedingPolicy  string             `json:"seeding_policy,omitempty"`  // share limit rule applied, if any
+	Health         *TorrentHealth     `json:"health,omitempty"`          // tracker scrape when HEALTH_CHECK is on
+	Files          *FileCheck         `json:"files,omitempty"`           // samples skipped and split parts found
+	CompleteSeries bool               `json:"complete_series,omitempty"` // box set imported by the packimport worker
+	DownloadVia    string             `json:"download_via,omitempty"`    // "torrent" or "usenet"
+	Usenet         *UsenetGrab        `json:"usenet,omitempty"`          // NZB grabbed when the torrent was dead
+	Correction     *LookupCorrection  `json:"lookup_correction,omitempty"`
+	Release        *ReleaseStatus     `json:"release,omitempty"`    // e.g. a movie only in cinemas so far
+	Duplicate      *DuplicateEpisodes `json:"duplicate,omitempty"`  // episodes Sonarr already has, with DUPLICATE_EPISODES
+	UndoToken      string             `json:"undo_token,omitempty"` // for Client.Undo until UndoExpiry
+	UndoExpiry     *time.Time         `json:"undo_expires_at,omitempty"`
+	JobID          string             `json:"job_id,omitempty"` // async adds: the job to poll with Client.Job
+	Debug          *DebugLog          `json:"debug,omitempty"`  // decision log when the request set Debug
+}
+
+// ReleaseStatus is where the matched title is in its release
+type ReleaseStatus struct {
+	Status          string     `json:"status"` // e.g. "announced", "inCinemas", "released" or "upcoming"
+	InCinemas       *time.Time `json:"in_cinemas,omitempty"`
+	DigitalRelease  *time.Time `json:"digital_release,omitempty"`
+	PhysicalRelease *time.Time `json:"physical_release,omitempty"`
+	Message         string     `json:"message,omitempty"` // set when the download is likely a CAM or fake
+}
+
+// DuplicateEpisodes is what Sonarr already has of a TV torrent's episodes
+type DuplicateEpisodes struct {
+	Episodes []string `json:"episodes"` // e.g. "S02E05"
+	Quality  string   `json:"quality,omitempty"`
+	Policy   string   `json:"policy"`   // "warn", "skip", "download_only" or "replace"
+	Decision string   `json:"decision"` // "added", "skipped", "download_only" or "replace_on_finish"
+}
+
+// BatchAddItem is one magnet of a batch; an empty type uses the batch's
+type BatchAddItem struct {
+	MagnetLink string `json:"magnet_link"`
+	Type       string `json:"type,omitempty"`
+}
+
+type BatchAddRequest struct {
+	Items []BatchAddItem `json:"items"`
+	Type  string         `json:"type,omitempty"`
+}
+
+type BatchAddResult struct {
+	Index int    `json:"index"`
+	Name  string `json:"name,omitempty"`
+	AddTorrentResponse
+}
+
+type BatchAddResponse struct {
+	Success bool             `json:"success"` // every item was added
+	Message string           `json:"message"`
+	Added   int              `json:"added"`
+	Failed  int              `json:"failed"`
+	Results []BatchAddResult `json:"results,omitempty"`
+}
+
+// UsenetGrab is the NZB an add fell back to
+type UsenetGrab struct {
+	Title   string `json:"title"`
+	Indexer string `json:"indexer"`
+	Size    int64  `json:"size"`
+}
+
+// FileCheck is what the torrent's file list showed once its metadata arrived
+type FileCheck struct {
+	Files   int      `json:"files"`
+	Samples []string `json:"samples,omitempty"` // set to "do not download"
+	Parts   []string `json:"parts,omitempty"`   // files of a CD1/CD2 style split movie
+	// Largest video, and whether its name was matched instead of the torrent's
+	MainFile     string `json:"main_file,omitempty"`
+	UsedForMatch bool   `json:"used_for_match,omitempty"`
+}
+
+// TorrentHealth is the swarm size reported by a magnet's trackers
+type TorrentHealth struct {
+	Seeders   int             `json:"seeders"`
+	Leechers  int             `json:"leechers"`
+	Completed int             `json:"completed"`
+	Trackers  []TrackerScrape `json:"trackers"`
+}
+
+type TrackerScrape struct {
+	Tracker   string `json:"tracker"`
+	Seeders   int    `json:"seeders"`
+	Leechers  int    `json:"leechers"`
+	Completed int    `json:"completed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// StepResult is the outcome of one add pipeline step
+type StepResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"` // "ok", "failed" or "skipped"
+	Attempts   int    `json:"attempts,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+	Code       string `json:"code,omitempty"`
+}
+
+// LookupCorrection reports how the search term was changed to find a match
+type LookupCorrection struct {
+	Transform string `json:"transform"`
+	Term      string `json:"term"`
+	Original  string `json:"original"`
+}
+
+type AddMediaRequest struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"` // "movie" or "tv"
+	Year    string `json:"year,omitempty"`
+	TVDBID  int    `json:"tvdb_id,omitempty"` // The series meant (type "tv"); the server remembers it for the name
+	Confirm bool   `json:"confirm,omitempty"` // Add even if the household already watched it or it's in the other library
+	// Search the indexers once added; nil leaves the server default (true)
+	SearchOnAdd *bool `json:"search_on_add,omitempty"`
+	// Quality hint for movies, e.g. "1080p"
+	PreferredQuality string `json:"preferred_quality,omitempty"`
+	// Root folder path and quality profile ID from Client.ConfigOptions; the
+	// profile overrides PreferredQuality
+	RootFolder       string `json:"root_folder,omitempty"`
+	QualityProfileID int    `json:"quality_profile_id,omitempty"`
+	// Add without a search and return the releases it would find; answer with
+	// Client.ResolvePreview
+	Preview bool `json:"preview,omitempty"`
+}
+
+// ResolvePreviewRequest answers a preview returned by Client.AddMedia
+type ResolvePreviewRequest struct {
+	PreviewID string `json:"preview_id"`
+	Release   string `json:"release,omitempty"` // GUID of the release to grab; empty searches
+	Cancel    bool   `json:"cancel,omitempty"`  // Remove the title again
+}
+
+type AddMediaResponse struct {
+	Success    bool              `json:"success"`
+	Message    string            `json:"message"`
+	Code       string            `json:"code,omitempty"`
+	MediaTitle string            `json:"media_title,omitempty"`
+	MediaType  string            `json:"media_type,omitempty"`
+	MediaID    int               `json:"media_id,omitempty"`
+	Warnings   []string          `json:"warnings,omitempty"`
+	Correction *LookupCorrection `json:"lookup_correction,omitempty"`
+	SearchAt   *time.Time        `json:"search_at,omitempty"` // when the paced search runs (SEARCH_PACE)
+	Cached     bool              `json:"cached,omitempty"`    // the result of the same request made moments ago
+	Preview    *MediaPreview     `json:"preview,omitempty"`   // releases found for a Preview request
+}
+
+// MediaPreview is a title held in the library without a search until answered
+type MediaPreview struct {
+	ID        string           `json:"id"`
+	ExpiresAt time.Time        `json:"expires_at"`
+	Total     int              `json:"total"`
+	Approved  int              `json:"approved"`
+	Releases  []PreviewRelease `json:"releases"`
+	Error     string           `json:"error,omitempty"`
+}
+
+type PreviewRelease struct {
+	GUID       string   `json:"guid"`
+	Title      string   `json:"title"`
+	Indexer    string   `json:"indexer,omitempty"`
+	Protocol   string   `json:"protocol"`
+	Quality    string   `json:"quality"`
+	Size       int64    `json:"size"`
+	Seeders    *int     `json:"seeders,omitempty"`
+	Approved   bool     `json:"approved"`
+	Rejections []string `json:"rejections,omitempty"`
+}
+
+// SearchResult is a Radarr or Sonarr lookup result
+type SearchResult struct {
+	Type     string `json:"type"` // "movie" or "tv"
+	Title    string `json:"title"`
+	Year     int    `json:"year,omitempty"`
+	TMDBID   int    `json:"tmdb_id,omitempty"`
+	TVDBID   int    `json:"tvdb_id,omitempty"`
+	Poster   string `json:"poster,omitempty"`
+	Overview string `json:"overview,omitempty"`
+	Link     string `json:"link,omitempty"`
+	Exact    bool   `json:"exact,omitempty"`
+}
+
+type SearchResponse struct {
+	Success bool           `json:"success"`
+	Message string         `json:"message"`
+	Results []SearchResult `json:"results"`
+}
+
+// MediaExistsQuery looks a title up in the libraries by one or more of its
+// TMDB ID, TVDB ID or title; zero fields are left out
+type MediaExistsQuery struct {
+	TMDBID int
+	TVDBID int
+	Title  string
+	Year   int
+	Type   string // "movie", "tv" or "" for both; title lookups only
+}
+
+type MediaExistsResponse struct {
+	Success bool          `json:"success"`
+	Message string        `json:"message"`
+	Exists  bool          `json:"exists"`
+	Items   []LibraryItem `json:"items"`
+}
+
+// LibraryItem is a movie or series already in Radarr or Sonarr
+type LibraryItem struct {
+	Type             string `json:"type"` // "movie" or "tv"
+	ID               int    `json:"id"`
+	Title            string `json:"title"`
+	Year             int    `json:"year,omitempty"`
+	TMDBID           int    `json:"tmdb_id,omitempty"`
+	TVDBID           int    `json:"tvdb_id,omitempty"`
+	Monitored        bool   `json:"monitored"`
+	Status           string `json:"status,omitempty"`
+	OnDisk           bool   `json:"on_disk"` // movie file there, or every monitored aired episode
+	Quality          string `json:"quality,omitempty"`
+	EpisodeFileCount int    `json:"episode_file_count,omitempty"`
+	EpisodeCount     int    `json:"episode_count,omitempty"`
+	SizeOnDisk       int64  `json:"size_on_disk"`
+	Path             string `json:"path,omitempty"`
+}
+
+// DebugLog is the decision log of an add requested with Debug
+type DebugLog struct {
+	Decisions []string        `json:"decisions"`
+	Requests  []DebugExchange `json:"requests"`
+}
+
+// DebugExchange is one call the service made to qBittorrent, Radarr, Sonarr or the extractor
+type DebugExchange struct {
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+	Request    string `json:"request,omitempty"`
+	Status     int    `json:"status,omitempty"`
+	Response   string `json:"response,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+type ParseRequest struct {
+	Name       string `json:"name,omitempty"`
+	MagnetLink string `json:"magnet_link,omitempty"`
+	SourceURL  string `json:"source_url,omitempty"`
+	Health     bool   `json:"health,omitempty"`  // Scrape the magnet's trackers
+	Type       string `json:"type,omitempty"`    // "movie" or "tv"; dry runs only
+	DryRun     bool   `json:"dry_run,omitempty"` // Also run the extractor and Radarr/Sonarr lookup, adding nothing
+}
+
+// ParseDryRun is what an add would do up to the library add
+type ParseDryRun struct {
+	Extracted    *ExtractedMedia   `json:"extracted,omitempty"`
+	ExtractError string            `json:"extract_error,omitempty"`
+	Category     string            `json:"category"`
+	MediaType    string            `json:"media_type"` // "movie", "tv", "game", "software" or "book"
+	Error        string            `json:"error,omitempty"`
+	Code         string            `json:"code,omitempty"` // e.g. NON_MEDIA_REJECTED
+	Match        *SearchResult     `json:"match,omitempty"`
+	MatchError   string            `json:"match_error,omitempty"`
+	Correction   *LookupCorrection `json:"lookup_correction,omitempty"`
+}
+
+type ExtractedMedia struct {
+	OriginalInput string `json:"original_input"`
+	ExtractedName string `json:"extracted_name"`
+	Year          string `json:"year"`
+	MediaType     string `json:"media_type"`
+	Source        string `json:"source,omitempty"` // "extractor" or "local"
+}
+
+type ParseResponse struct {
+	SchemaVersion int            `json:"schema_version"`
+	Success       bool           `json:"success"`
+	Message       string         `json:"message"`
+	Name          string         `json:"name,omitempty"`
+	CleanedTitle  string         `json:"cleaned_title,omitempty"`
+	MovieInfo     *MovieInfo     `json:"movie_info,omitempty"`
+	Episode       *EpisodeInfo   `json:"episode,omitempty"`
+	Detection     ParseDetection `json:"detection"`
+	Health        *TorrentHealth `json:"health,omitempty"`
+	AudioLanguage string         `json:"audio_language,omitempty"` // PREFERRED_LANGUAGE profile the add would pick
+	DryRun        *ParseDryRun   `json:"dry_run,omitempty"`
+}
+
+type MovieInfo struct {
+	Title   string `json:"title"`
+	Year    string `json:"year,omitempty"`
+	Quality string `json:"quality,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Codec   string `json:"codec,omitempty"`
+	Audio   string `json:"audio,omitempty"`
+	Group   string `json:"group,omitempty"`
+
+	HDR      string `json:"hdr,omitempty"`       // e.g. "DV HDR10"
+	BitDepth int    `json:"bit_depth,omitempty"` // 10 or 12 when tagged
+
+	MultiAudio bool     `json:"multi_audio,omitempty"` // tagged MULTI or DUAL audio
+	Languages  []string `json:"languages,omitempty"`   // audio languages tagged, e.g. "French"
+}
+
+type EpisodeInfo struct {
+	Season          *int  `json:"season,omitempty"`
+	Episodes        []int `json:"episodes,omitempty"`
+	AbsoluteEpisode int   `json:"absolute_episode,omitempty"`
+	SeasonPack      bool  `json:"season_pack"`
+}
+
+type ParseDetection struct {
+	Category     string   `json:"category"`
+	TVScore      int      `json:"tv_score"`
+	MovieScore   int      `json:"movie_score"`
+	Reason       string   `json:"reason"`
+	TVRules      []string `json:"tv_rules,omitempty"`
+	MovieRules   []string `json:"movie_rules,omitempty"`
+	Defaulted    bool     `json:"defaulted,omitempty"` // no clear indicators; Category is the server's DETECT_DEFAULT
+	Anime        bool     `json:"anime"`
+	NonMedia     string   `json:"non_media,omitempty"`
+	NonMediaRule string   `json:"non_media_rule,omitempty"`
+}
+
+type ScrapeResponse struct {
+	Success  bool             `json:"success"`
+	Message  string           `json:"message"`
+	Site     string           `json:"site,omitempty"`
+	Releases []ScrapedRelease `json:"releases,omitempty"`
+}
+
+type ScrapedRelease struct {
+	Name       string `json:"name"`
+	MagnetLink string `json:"magnet_link"`
+	InfoHash   string `json:"info_hash"`
+	Quality    string `json:"quality,omitempty"`
+	Source     string `json:"source,omitempty"`
+	Codec      string `json:"codec,omitempty"`
+	HDR        string `json:"hdr,omitempty"`
+	Audio      string `json:"audio,omitempty"`
+}
+
+type VariantsRequest struct {
+	URL      string           `json:"url,omitempty"`      // result page to scrape
+	Releases []ScrapedRelease `json:"releases,omitempty"` // or releases found elsewhere
+	Type     string           `json:"type,omitempty"`     // "movie" or "tv"; detected per title when empty
+	Add      bool             `json:"add,omitempty"`      // add the picked variant of every title
+}
+
+type VariantsResponse struct {
+	Success bool           `json:"success"`
+	Message string         `json:"message"`
+	Site    string         `json:"site,omitempty"`
+	Groups  []VariantGroup `json:"groups"`
+}
+
+// VariantGroup is one title with its quality variants, best resolution first
+type VariantGroup struct {
+	Title      string              `json:"title"`
+	Year       string              `json:"year,omitempty"`
+	Type       string              `json:"type"`
+	Variants   []ScrapedRelease    `json:"variants"`
+	Picked     *int                `json:"picked,omitempty"` // index of the variant the quality profile prefers
+	PickReason string              `json:"pick_reason"`
+	Result     *AddTorrentResponse `json:"result,omitempty"`
+}
+
+type JobsResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Jobs    []Job  `json:"jobs"`
+}
+
+// Job is background work started by a request, such as a paced search
+type Job struct {
+	ID       string    `json:"id"`
+	Kind     string    `json:"kind"` // "add" or "search"
+	Title    string    `json:"title"`
+	App      string    `json:"app,omitempty"`
+	MediaID  int       `json:"media_id,omitempty"`
+	Priority string    `json:"priority,omitempty"` // "interactive" or "background", adds only
+	Status   string    `json:"status"`             // "pending" or "running"
+	QueuedAt time.Time `json:"queued_at"`
+	RunAt    time.Time `json:"run_at"`
+}
+
+type JobResponse struct {
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Job     *AsyncAddJob `json:"job,omitempty"`
+}
+
+// AsyncAddJob is the progress of an add started with Async
+type AsyncAddJob struct {
+	ID         string              `json:"id"`
+	Status     string              `json:"status"` // "running", "succeeded" or "failed"
+	Title      string              `json:"title"`
+	Stages     map[string]string   `json:"stages"` // extract, qbittorrent_add and library_add: "pending", "ok", "failed" or "skipped"
+	Steps      []StepResult        `json:"steps,omitempty"`
+	Result     *AddTorrentResponse `json:"result,omitempty"` // once finished
+	StartedAt  time.Time           `json:"started_at"`
+	FinishedAt *time.Time          `json:"finished_at,omitempty"`
+}
+
+type InflightResponse struct {
+	Success bool          `json:"success"`
+	Message string        `json:"message"`
+	Adds    []InflightAdd `json:"adds"`
+}
+
+// InflightAdd is a torrent add that hasn't finished and the step it is in
+type InflightAdd struct {
+	Name          string       `json:"name"`
+	APIKey        string       `json:"api_key,omitempty"`
+	Category      string       `json:"category,omitempty"`
+	Step          string       `json:"step"` // "waiting" for an ADD_CONCURRENCY slot, or a pipeline step
+	StepAttempt   int          `json:"step_attempt"`
+	StepElapsedMs int64        `json:"step_elapsed_ms"`
+	ElapsedMs     int64        `json:"elapsed_ms"`
+	Steps         []StepResult `json:"steps,omitempty"` // finished steps
+	StartedAt     time.Time    `json:"started_at"`
+}
+
+// HistoryQuery filters and pages History; zero fields are left out
+type HistoryQuery struct {
+	Type   string    // "movie", "tv" or "other"
+	Status string    // e.g. "added" or "failed"
+	Since  time.Time // inclusive
+	Until  time.Time // exclusive
+	Page   int
+	Limit  int
+}
+
+type HistoryResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Total   int             `json:"total"`
+	Page    int             `json:"page"`
+	Limit   int             `json:"limit"`
+	Records []HistoryRecord `json:"records"`
+}
+
+// HistoryRecord is one add in the server's history
+type HistoryRecord struct {
+	ID             int64      `json:"id"`
+	AddedAt        time.Time  `json:"added_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	Source         string     `json:"source"` // "torrent", "media", "rss" or "watch"
+	Name           string     `json:"name"`
+	MagnetLink     string     `json:"magnet_link,omitempty"`
+	InfoHash       string     `json:"info_hash,omitempty"`
+	ExtractedTitle string     `json:"extracted_title,omitempty"`
+	Category       string     `json:"category,omitempty"`
+	MediaType      string     `json:"media_type,omitempty"`
+	MediaTitle     string     `json:"media_title,omitempty"`
+	MediaID        int        `json:"media_id,omitempty"`
+	Status         string     `json:"status"`
+	Code           string     `json:"code,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	RolledBack     bool       `json:"rolled_back,omitempty"`
+	Feed           string     `json:"feed,omitempty"`
+	APIKey         string     `json:"api_key,omitempty"`
+}
+
+type ConfigOptionsResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Radarr  *ArrOptions `json:"radarr,omitempty"`
+	Sonarr  *ArrOptions `json:"sonarr,omitempty"`
+}
+
+// ArrOptions is what an add to Radarr or Sonarr can pick from
+type ArrOptions struct {
+	QualityProfiles []QualityProfileOption `json:"quality_profiles"`
+	RootFolders     []RootFolderOption     `json:"root_folders"`
+	Tags            []ArrTag               `json:"tags"`
+	Error           string                 `json:"error,omitempty"` // the app could not be queried
+}
+
+type QualityProfileOption struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Default bool   `json:"default,omitempty"`
+}
+
+type RootFolderOption struct {
+	ID         int    `json:"id"`
+	Path       string `json:"path"`
+	Accessible bool   `json:"accessible"`
+	FreeSpace  *int64 `json:"free_space,omitempty"`
+	Default    bool   `json:"default,omitempty"`
+}
+
+type ArrTag struct {
+	ID    int    `json:"id"`
+	Label string `json:"label"`
+}
+
+type SchedulesRequest struct {
+	Timezone  string            `json:"timezone,omitempty"`
+	Schedules map[string]string `json:"schedules,omitempty"` // Job name to cron expression; "" disables
+}
+
+type SchedulesResponse struct {
+	Success  bool               `json:"success"`
+	Message  string             `json:"message,omitempty"`
+	Timezone string             `json:"timezone"`
+	Jobs     []ScheduledJobInfo `json:"jobs"`
+}
+
+type ScheduledJobInfo struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Schedule    string     `json:"schedule"`
+	Enabled     bool       `json:"enabled"`
+	Running     bool       `json:"running"`
+	NextRun     *time.Time `json:"next_run,omitempty"`
+	LastRun     *time.Time `json:"last_run,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+type MaintenanceRequest struct {
+	Enabled      *bool           `json:"enabled,omitempty"`
+	Reason       *string         `json:"reason,omitempty"`
+	DisabledKeys map[string]bool `json:"disabled_keys,omitempty"` // Key name to disabled; false re-enables
+}
+
+type ReclassifyRequest struct {
+	IDs   []int64    `json:"ids,omitempty"`   // history IDs; default the latest torrent adds
+	Since *time.Time `json:"since,omitempty"` // only adds since then
+	Limit int        `json:"limit,omitempty"` // default 100, at most 1000
+	Local bool       `json:"local,omitempty"` // local extraction instead of the extractor
+	All   bool       `json:"all,omitempty"`   // report unchanged entries too
+}
+
+type ReclassifyResponse struct {
+	Success bool               `json:"success"`
+	Message string             `json:"message"`
+	Checked int                `json:"checked"`
+	Changed int                `json:"changed"`
+	Results []ReclassifyResult `json:"results,omitempty"`
+}
+
+// RetagRequest selects the movies and series to tag or untag; set Tag or Requester
+type RetagRequest struct {
+	Tag       string     `json:"tag,omitempty"`
+	Requester bool       `json:"requester,omitempty"` // each item's requester tag instead
+	Remove    bool       `json:"remove,omitempty"`
+	APIKey    string     `json:"api_key,omitempty"` // only adds made with this key
+	MediaType string     `json:"type,omitempty"`    // "movie" or "tv"
+	Since     *time.Time `json:"since,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+	DryRun    bool       `json:"dry_run,omitempty"`
+}
+
+type RetagResponse struct {
+	Success bool          `json:"success"`
+	Message string        `json:"message"`
+	Matched int           `json:"matched"`
+	Skipped int           `json:"skipped,omitempty"`
+	Changed int           `json:"changed"`
+	Results []RetagResult `json:"results,omitempty"`
+}
+
+// RetagResult is one tag change on a batch of movies or series
+type RetagResult struct {
+	Tag       string `json:"tag"`
+	MediaType string `json:"type"`
+	MediaIDs  []int  `json:"media_ids"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ReclassifyResult compares a past add's classification with the current rules'
+type ReclassifyResult struct {
+	ID          int64           `json:"id"`
+	Name        string          `json:"name"`
+	AddedAt     time.Time       `json:"added_at"`
+	OldType     string          `json:"old_type"` // "movie", "tv" or "non_media"
+	NewType     string          `json:"new_type"`
+	OldCategory string          `json:"old_category,omitempty"`
+	NewCategory string          `json:"new_category,omitempty"`
+	OldTitle    string          `json:"old_title,omitempty"`
+	NewTitle    string          `json:"new_title,omitempty"`
+	NewYear     string          `json:"new_year,omitempty"`
+	Decision    *ParseDetection `json:"decision,omitempty"`
+	Extractor   string          `json:"extractor,omitempty"` // media type the extractor returned
+	Changed     bool            `json:"changed"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// SeedingPolicy is a qBittorrent share limit; nil fields keep the global limit, -1 is no limit
+type SeedingPolicy struct {
+	RatioLimit       *float64 `json:"ratio_limit,omitempty"`
+	SeedingTimeLimit *int     `json:"seeding_time_limit,omitempty"` // minutes
+}
+
+type SeedingCategoriesResponse struct {
+	Success    bool                     `json:"success"`
+	Message    string                   `json:"message"`
+	Categories map[string]SeedingPolicy `json:"categories"`
+	Updated    map[string]int           `json:"updated,omitempty"` // torrents given the new limits, by category
+}
+
+type ArrKeysRequest struct {
+	Radarr string `json:"radarr,omitempty"`
+	Sonarr string `json:"sonarr,omitempty"`
+}
+
+// ArrKeyStatus is whether Radarr or Sonarr accepts its API key
+type ArrKeyStatus struct {
+	App     string `json:"app"`
+	Status  string `json:"status"` // "ok", "auth_expired" or "unavailable"
+	Message string `json:"message,omitempty"`
+}
+
+type ArrKeysResponse struct {
+	Success bool           `json:"success"`
+	Message string         `json:"message"`
+	Code    string         `json:"code,omitempty"`
+	Apps    []ArrKeyStatus `json:"apps,omitempty"`
+}
+
+type MaintenanceResponse struct {
+	Success      bool       `json:"success"`
+	Message      string     `json:"message,omitempty"`
+	Enabled      bool       `json:"enabled"`
+	Reason       string     `json:"reason,omitempty"`
+	Since        *time.Time `json:"since,omitempty"`
+	DisabledKeys []string   `json:"disabled_keys"`
+}
+
+// LibraryUpgradesOptions filters GET /api/library/upgrades
+type LibraryUpgradesOptions struct {
+	Type    string // "movie", "tv" or "all" (default)
+	Limit   int    // 1-500, default 50
+	Suggest bool   // include indexer releases for the first items
+}
+
+type LibraryUpgradesResponse struct {
+	Success       bool             `json:"success"`
+	Message       string           `json:"message"`
+	TotalMovies   int              `json:"total_movies"`
+	TotalEpisodes int              `json:"total_episodes"`
+	Upgrades      []LibraryUpgrade `json:"upgrades"`
+}
+
+type LibraryUpgrade struct {
+	Type           string              `json:"type"`
+	ID             int                 `json:"id"`
+	Title          string              `json:"title"`
+	Year           int                 `json:"year,omitempty"`
+	SeriesTitle    string              `json:"series_title,omitempty"`
+	SeasonNumber   int                 `json:"season_number,omitempty"`
+	EpisodeNumber  int                 `json:"episode_number,omitempty"`
+	CurrentQuality string              `json:"current_quality,omitempty"`
+	Suggestions    []UpgradeSuggestion `json:"suggestions,omitempty"`
+}
+
+type UpgradeSuggestion struct {
+	Title      string `json:"title"`
+	Quality    string `json:"quality"`
+	MagnetLink string `json:"magnet_link"`
+	Indexer    string `json:"indexer,omitempty"`
+	Seeders    int    `json:"seeders"`
+	Size       int64  `json:"size"`
+}
+
+type ClientStatsResponse struct {
+	Success           bool          `json:"success"`
+	Message           string        `json:"message"`
+	ConnectionStatus  string        `json:"connection_status,omitempty"`
+	DownloadSpeed     int64         `json:"download_speed"`
+	UploadSpeed       int64         `json:"upload_speed"`
+	SessionDownloaded int64         `json:"session_downloaded"`
+	SessionUploaded   int64         `json:"session_uploaded"`
+	AlltimeDownloaded int64         `json:"alltime_downloaded"`
+	AlltimeUploaded   int64         `json:"alltime_uploaded"`
+	GlobalRatio       string        `json:"global_ratio,omitempty"`
+	FreeSpace         int64         `json:"free_space"`
+	DHTNodes          int           `json:"dht_nodes"`
+	Torrents          TorrentCounts `json:"torrents"`
+}
+
+type LibraryStatsResponse struct {
+	Success     bool              `json:"success"`
+	Message     string            `json:"message"`
+	Movies      *MovieStats       `json:"movies,omitempty"`
+	Series      *SeriesStats      `json:"series,omitempty"`
+	RootFolders []RootFolderStats `json:"root_folders,omitempty"`
+	FetchedAt   *time.Time        `json:"fetched_at,omitempty"`
+}
+
+type CalendarResponse struct {
+	Success  bool           `json:"success"`
+	Message  string         `json:"message"`
+	Days     int            `json:"days,omitempty"`
+	Items    []CalendarItem `json:"items,omitempty"`
+	Warnings []string       `json:"warnings,omitempty"` // Radarr or Sonarr could not be reached
+}
+
+// CalendarItem is one movie release or episode airing
+type CalendarItem struct {
+	Type         string    `json:"type"` // "movie" or "episode"
+	Title        string    `json:"title"`
+	Year         int       `json:"year,omitempty"`
+	Episode      string    `json:"episode,omitempty"`
+	EpisodeTitle string    `json:"episode_title,omitempty"`
+	Release      string    `json:"release"` // "cinema", "physical", "digital" or "airing"
+	Date         time.Time `json:"date"`
+	HasFile      bool      `json:"has_file"`
+	MediaID      int       `json:"media_id"`
+	AddedHere    bool      `json:"added_here,omitempty"` // added through this service
+}
+
+// TrackerEditRequest adds or removes trackers of a torrent
+type TrackerEditRequest struct {
+	URLs       []string `json:"urls"`
+	Reannounce bool     `json:"reannounce,omitempty"`
+}
+
+// TorrentStatus is a torrent's download status in qBittorrent
+type TorrentStatus struct {
+	Name          string  `json:"name"`
+	Hash          string  `json:"hash"`
+	Category      string  `json:"category,omitempty"`
+	State         string  `json:"state"`         // e.g. "downloading" or "stalledDL"
+	Progress      float64 `json:"progress"`      // 0 to 1
+	ETA           *int64  `json:"eta,omitempty"` // seconds; nil when unknown
+	Size          int64   `json:"size"`
+	DownloadSpeed int64   `json:"download_speed"` // bytes/s
+}
+
+type TorrentsResponse struct {
+	Success  bool            `json:"success"`
+	Message  string          `json:"message"`
+	Torrents []TorrentStatus `json:"torrents"`
+}
+
+// QueueItem is a download with its qBittorrent progress and Radarr/Sonarr import status
+type QueueItem struct {
+	Hash          string   `json:"hash,omitempty"` // missing for Usenet downloads
+	Name          string   `json:"name"`
+	Type          string   `json:"type,omitempty"` // "movie" or "tv"
+	Title         string   `json:"title,omitempty"`
+	Year          int      `json:"year,omitempty"`
+	MediaID       int      `json:"media_id,omitempty"`
+	Episodes      []string `json:"episodes,omitempty"` // e.g. "S02E05"
+	State         string   `json:"state,omitempty"`
+	Progress      float64  `json:"progress"` // 0 to 1
+	ETA           *int64   `json:"eta,omitempty"`
+	Size          int64    `json:"size"`
+	DownloadSpeed int64    `json:"download_speed"`          // bytes/s
+	ImportStatus  string   `json:"import_status,omitempty"` // e.g. "downloading", "importPending" or "failedPending"
+	Messages      []string `json:"messages,omitempty"`
+}
+
+type QueueResponse struct {
+	Success  bool        `json:"success"`
+	Message  string      `json:"message"`
+	Items    []QueueItem `json:"items,omitempty"`
+	Warnings []string    `json:"warnings,omitempty"`
+}
+
+type TorrentDeleteRequest struct {
+	DeleteFiles bool   `json:"delete_files,omitempty"`
+	Library     string `json:"library,omitempty"` // "unmonitor" or "remove"; "" leaves the movie/series
+}
+
+type TorrentActionResponse struct {
+	Success  bool             `json:"success"`
+	Message  string           `json:"message"`
+	Hash     string           `json:"hash,omitempty"`
+	Trackers []TorrentTracker `json:"trackers,omitempty"`
+	Warnings []string         `json:"warnings,omitempty"`
+}
+
+// TorrentTracker is a tracker of a torrent in qBittorrent
+type TorrentTracker struct {
+	URL      string `json:"url"`
+	Status   string `json:"status"` // "working", "not_working", "not_contacted", "updating" or "disabled"
+	Tier     int    `json:"tier"`
+	Seeds    int    `json:"seeds"`
+	Leechers int    `json:"leechers"`
+	Message  string `json:"message,omitempty"`
+}
+
+// AddCounts is the outcome of a set of adds
+type AddCounts struct {
+	Adds        int     `json:"adds"`
+	Succeeded   int     `json:"succeeded"`
+	Failed      int     `json:"failed"`
+	SuccessRate float64 `json:"success_rate"`
+}
+
+// ReleaseGroupStats is the adds of torrents from one release group
+type ReleaseGroupStats struct {
+	Group string `json:"group"`
+	AddCounts
+}
+
+// LibraryAddStats is how many torrents classified as media made it into Radarr/Sonarr
+type LibraryAddStats struct {
+	Attempted int     `json:"attempted"`
+	Added     int     `json:"added"`
+	Pending   int     `json:"pending"`
+	HitRate   float64 `json:"hit_rate"`
+}
+
+type StatsResponse struct {
+	Success       bool                 `json:"success"`
+	Message       string               `json:"message"`
+	Total         AddCounts            `json:"total"`
+	ByType        map[string]AddCounts `json:"by_type,omitempty"`   // "movie", "tv" or "other"
+	BySource      map[string]AddCounts `json:"by_source,omitempty"` // "torrent", "media", "rss" or "watch"
+	FailureCodes  map[string]int       `json:"failure_codes,omitempty"`
+	ReleaseGroups []ReleaseGroupStats  `json:"release_groups,omitempty"`
+	LibraryAdd    LibraryAddStats      `json:"library_add"`
+}
+
+type TrackerStatsResponse struct {
+	Success  bool           `json:"success"`
+	Message  string         `json:"message"`
+	Trackers []TrackerStats `json:"trackers,omitempty"`
+}
+
+// TrackerStats is the add outcome of torrents announcing to one tracker domain
+type TrackerStats struct {
+	Domain         string     `json:"domain"` // "dht" for magnets without trackers
+	Adds           int        `json:"adds"`
+	Succeeded      int        `json:"succeeded"`
+	Failed         int        `json:"failed"`
+	Stalled        int        `json:"stalled"`
+	Completed      int        `json:"completed"`
+	SuccessRate    float64    `json:"success_rate"`
+	StallRate      float64    `json:"stall_rate"`
+	CompletionRate float64    `json:"completion_rate"`
+	LastAdd        *time.Time `json:"last_add,omitempty"`
+}
+
+type MovieStats struct {
+	Total      int   `json:"total"`
+	Monitored  int   `json:"monitored"`
+	WithFile   int   `json:"with_file"`
+	Missing    int   `json:"missing"`
+	SizeOnDisk int64 `json:"size_on_disk"`
+}
+
+type SeriesStats struct {
+	Total            int   `json:"total"`
+	Monitored        int   `json:"monitored"`
+	Continuing       int   `json:"continuing"`
+	Episodes         int   `json:"episodes"`
+	EpisodesWithFile int   `json:"episodes_with_file"`
+	MissingEpisodes  int   `json:"missing_episodes"`
+	SizeOnDisk       int64 `json:"size_on_disk"`
+}
+
+type RootFolderStats struct {
+	App         string `json:"app"`
+	Path        string `json:"path"`
+	Accessible  bool   `json:"accessible"`
+	FreeSpace   *int64 `json:"free_space,omitempty"`
+	Items       int    `json:"items"`
+	LibrarySize int64  `json:"library_size"`
+}
+
+type TorrentCounts struct {
+	Total       int `json:"total"`
+	Active      int `json:"active"`
+	Downloading int `json:"downloading"`
+	Seeding     int `json:"seeding"`
+	Paused      int `json:"paused"`
+	Stalled     int `json:"stalled"`
+	Errored     int `json:"errored"`
+}
+
+type SelfTestResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Checks  []SelfTestCheck `json:"checks"`
+}
+
+type SelfTestCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+	Fixed   bool   `json:"fixed,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Ready        bool                        `json:"ready"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// HealthResponse is GET /health: each backend's ping and soft failures over the server's window
+type HealthResponse struct {
+	Status        string                   `json:"status"` // "ok", "warn" or "down"
+	WindowSeconds int                      `json:"window_seconds"`
+	Backends      map[string]BackendHealth `json:"backends"`
+}
+
+// BackendHealth is a backend's ping result and its soft failures in the window, by kind
+type BackendHealth struct {
+	Status        string         `json:"status"`
+	LatencyMillis *int64         `json:"latency_ms,omitempty"`
+	LastError     string         `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time     `json:"last_error_at,omitempty"`
+	SoftFailures  int            `json:"soft_failures"`
+	Threshold     int            `json:"threshold,omitempty"`
+	Kinds         map[string]int `json:"kinds,omitempty"`
+}
+
+type DependencyStatus struct {
+	Ready   bool       `json:"ready"`
+	ReadyAt *time.Time `json:"ready_at,omitempty"`
+	AppVersion
+	LastError string `json:"last_error,omitempty"`
+}
+
+// AppVersion is the version a backend reported when it warmed up
+type AppVersion struct {
+	Version    string `json:"version,omitempty"`
+	APIVersion string `json:"api_version,omitempty"`
+}
+
+// VersionResponse is GET /api/version
+type VersionResponse struct {
+	Success  bool                  `json:"success"`
+	Message  string                `json:"message"`
+	Build    BuildInfo             `json:"build"`
+	Backends map[string]AppVersion `json:"backends,omitempty"`
+}
+
+// BuildInfo describes the server binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// TorrentCounts groups qBittorrent torrents by state
+type TorrentCounts struct {
+	Total       int `json:"total"`
+	Active      int `json:"active"` // currently transferring data
+	Downloading int `json:"downloading"`
+	Seeding     int `json:"seeding"`
+	Paused      int `json:"paused"`
+	Stalled     int `json:"stalled"`
+	Errored     int `json:"errored"`
+}
+
+type ClientStatsResponse struct {
+	Success           bool          `json:"success"`
+	Message           string        `json:"message"`
+	ConnectionStatus  string        `json:"connection_status,omitempty"`
+	DownloadSpeed     int64         `json:"download_speed"` // bytes/s
+	UploadSpeed       int64         `json:"upload_speed"`   // bytes/s
+	SessionDownloaded int64         `json:"session_downloaded"`
+	SessionUploaded   int64         `json:"session_uploaded"`
+	AlltimeDownloaded int64         `json:"alltime_downloaded"`
+	AlltimeUploaded   int64         `json:"alltime_uploaded"`
+	GlobalRatio       string        `json:"global_ratio,omitempty"`
+	FreeSpace         int64         `json:"free_space"`
+	DHTNodes          int           `json:"dht_nodes"`
+	Torrents          TorrentCounts `json:"torrents"`
+}
+
+// countTorrents buckets torrents by qBittorrent state (v4 "paused*" and v5 "stopped*" alike)
+func countTorrents(torrents map[string]QBTorrentState) TorrentCounts {
+	var counts TorrentCounts
+	for _, t := range torrents {
+		counts.Total++
+		if t.DownloadSpeed > 0 || t.UploadSpeed > 0 {
+			counts.Active++
+		}
+
+		switch t.State {
+		case "downloading", "forcedDL", "metaDL", "forcedMetaDL", "queuedDL", "checkingDL", "allocating":
+			counts.Downloading++
+		case "uploading", "forcedUP", "queuedUP", "checkingUP":
+			counts.Seeding++
+		case "pausedDL", "pausedUP", "stoppedDL", "stoppedUP":
+			counts.Paused++
+		case "stalledDL", "stalledUP":
+			counts.Stalled++
+		case "error", "missingFiles":
+			counts.Errored++
+		}
+	}
+	return counts
+}
+
+// ClientStats returns qBittorrent's global transfer stats and torrent counts
+func (h *TorrentHandler) ClientStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(ClientStatsResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+
+	info, err := h.qbClient.GetTransferInfo(r.Context())
+	if err != nil {
+		log.Printf("Error fetching transfer info: %v", err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(ClientStatsResponse{
+			Success: false,
+			Message: "Failed to fetch qBittorrent stats: " + err.Error(),
+		})
+		return
+	}
+
+	data, err := h.qbClient.GetMainData(r.Context())
+	if err != nil {
+		log.Printf("Error fetching sync data: %v", err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(ClientStatsResponse{
+			Success: false,
+			Message: "Failed to fetch qBittorrent stats: " + err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ClientStatsResponse{
+		Success:           true,
+		Message:           "OK",
+		ConnectionStatus:  info.ConnectionStatus,
+		DownloadSpeed:     info.DownloadSpeed,
+		UploadSpeed:       info.UploadSpeed,
+		SessionDownloaded: info.SessionDownloaded,
+		SessionUploaded:   info.SessionUploaded,
+		AlltimeDownloaded: data.ServerState.AlltimeDownloaded,
+		AlltimeUploaded:   data.ServerState.AlltimeUploaded,
+		GlobalRatio:       data.ServerState.GlobalRatio,
+		FreeSpace:         data.ServerState.FreeSpaceOnDisk,
+		DHTNodes:          info.DHTNodes,
+		Torrents:          countTorrents(data.Torrents),
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// coalescer runs one call per key at a time and remembers successful results
+// for a while. It makes concurrent or back-to-back adds of the same series share
+// a single Sonarr add, and gives a retried media add (e.g. from the extension
+// after a timeout) the first result instead of an "already exists" error.
+type coalescer[K comparable, V any] struct {
+	mu       sync.Mutex
+	inflight map[K]*coalescedCall[V]
+	recent   map[K]recentResult[V]
+}
+
+type coalescedCall[V any] struct {
+	done   chan struct{}
+	result V
+	err    error
+}
+
+type recentResult[V any] struct {
+	result  V
+	addedAt time.Time
+}
+
+func newCoalescer[K comparable, V any]() *coalescer[K, V] {
+	return &coalescer[K, V]{
+		inflight: make(map[K]*coalescedCall[V]),
+		recent:   make(map[K]recentResult[V]),
+	}
+}
+
+// Do runs fn once per key. Callers arriving while it is in flight, or within
+// ttl after it succeeded, get that result with shared=true. A zero ttl only
+// joins calls in flight.
+func (c *coalescer[K, V]) Do(key K, ttl time.Duration, fn func() (V, error)) (result V, shared bool, err error) {
+	c.mu.Lock()
+	for k, r := range c.recent {
+		if time.Since(r.addedAt) >= ttl {
+			delete(c.recent, k)
+		}
+	}
+	if r, ok := c.recent[key]; ok {
+		c.mu.Unlock()
+		return r.result, true, nil
+	}
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.result, true, call.err
+	}
+
+	call := &coalescedCall[V]{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	call.result, call.err = fn()
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil && ttl > 0 {
+		c.recent[key] = recentResult[V]{result: call.result, addedAt: time.Now()}
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.result, false, call.err
+}
+
+// mediaAddKey identifies an AddMedia request; repeats of it share one result
+type mediaAddKey struct {
+	apiKey, kind, name, year string
+	tvdbID                   int
+	preview, confirm         bool
+	rootFolder               string
+	qualityProfileID         int
+	preferredQuality         string
+}
+
+// mediaAddResult is what addMedia returns, kept for repeats
+type mediaAddResult struct {
+	resp *AddMediaResponse
+	card *MediaCard
+}
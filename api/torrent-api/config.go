@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// configNamePattern matches setting file names; Kubernetes also puts ..data and
+// timestamped directories in a mounted ConfigMap, which are skipped
+var configNamePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// ConfigDir applies settings from a mounted directory (e.g. a ConfigMap volume) where
+// each file is named after an environment variable and holds its value. File values
+// override the environment; removing a file restores the original environment value.
+type ConfigDir struct {
+	path    string
+	applied map[string]string  // current file values
+	origEnv map[string]*string // environment before a file overrode it; nil if unset
+}
+
+func NewConfigDir(path string) *ConfigDir {
+	return &ConfigDir{
+		path:    path,
+		applied: make(map[string]string),
+		origEnv: make(map[string]*string),
+	}
+}
+
+// read returns the settings currently in the directory
+func (c *ConfigDir) read() (map[string]string, error) {
+	entries, err := os.ReadDir(c.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config dir: %w", err)
+	}
+
+	values := make(map[string]string)
+	for _, entry := range entries {
+		if !configNamePattern.MatchString(entry.Name()) {
+			continue
+		}
+		// ConfigMap keys are symlinks into ..data, so follow them
+		path := filepath.Join(c.path, entry.Name())
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config %s: %w", entry.Name(), err)
+		}
+		values[entry.Name()] = strings.TrimRight(string(data), "\r\n")
+	}
+	return values, nil
+}
+
+// Apply sets the directory's settings in the environment and returns the names
+// that changed since the last call
+func (c *ConfigDir) Apply() ([]string, error) {
+	values, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for name, value := range values {
+		if old, ok := c.applied[name]; ok && old == value {
+			continue
+		}
+		if _, saved := c.origEnv[name]; !saved {
+			if orig, ok := os.LookupEnv(name); ok {
+				c.origEnv[name] = &orig
+			} else {
+				c.origEnv[name] = nil
+			}
+		}
+		os.Setenv(name, value)
+		c.applied[name] = value
+		changed = append(changed, name)
+	}
+
+	for name := range c.applied {
+		if _, ok := values[name]; ok {
+			continue
+		}
+		if orig := c.origEnv[name]; orig != nil {
+			os.Setenv(name, *orig)
+		} else {
+			os.Unsetenv(name)
+		}
+		delete(c.applied, name)
+		delete(c.origEnv, name)
+		changed = append(changed, name)
+	}
+	return changed, nil
+}
+
+// Watch polls the directory and calls onChange with the changed names.
+// ConfigMap updates swap a symlink atomically, so polling sees consistent contents.
+func (c *ConfigDir) Watch(ctx context.Context, interval time.Duration, onChange func(changed []string)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := c.Apply()
+			if err != nil {
+				log.Printf("Warning: %v", err)
+				continue
+			}
+			if len(changed) > 0 {
+				onChange(changed)
+			}
+		}
+	}
+}
+
+// reloadableSettings are read into HandlerConfig and take effect without a restart
+var reloadableSettings = map[string]bool{
+	"INDEXER_SEARCH":                 true,
+	"NON_MEDIA_POLICY":               true,
+	"NON_MEDIA_CATEGORY":             true,
+	"DETECT_DEFAULT":                 true,
+	"DETECT_MIN_TV_SCORE":            true,
+	"DETECT_MIN_MOVIE_SCORE":         true,
+	"WATCHED_REQUIRE_CONFIRM":        true,
+	"SONARR_MONITOR_AIRING":          true,
+	"SONARR_MONITOR_ENDED":           true,
+	"ARR_QBITTORRENT_URL":            true,
+	"DOWNLOAD_CLIENT_AUTOFIX":        true,
+	"STRICT_LIBRARY_ADD":             true,
+	"ADD_ORDER":                      true,
+	"DEGRADATION":                    true,
+	"RECONCILE_REMOVE_TORRENTS":      true,
+	"SEEDING_POLICIES":               true,
+	"HEALTH_CHECK":                   true,
+	"HEALTH_MIN_SEEDERS":             true,
+	"NZB_FALLBACK":                   true,
+	"PREVIOUS_FAILURE_REQUIRE_FORCE": true,
+	"FILE_CHECK_WAIT":                true,
+	"COMPLETE_SERIES_CATEGORY":       true,
+	"LIBRARY_STATS_TTL":              true,
+	"MEDIA_ADD_CACHE_TTL":            true,
+	"REQUESTER_TAGS":                 true,
+	"REQUESTER_TAG_PREFIX":           true,
+	"PATH_MAPPINGS":                  true,
+	"SEARCH_PACE":                    true,
+	"ADD_CONCURRENCY":                true,
+	"PREFERRED_LANGUAGE":             true,
+	"SOFT_FAILURE_WINDOW":            true,
+	"SOFT_FAILURE_WARN":              true,
+	"UNDO_WINDOW":                    true,
+	"DUPLICATE_EPISODES":             true,
+}
+
+// loadHandlerConfig reads and validates the handler settings from the environment
+func loadHandlerConfig() (HandlerConfig, error) {
+	config := HandlerConfig{
+		IndexerSearch:    os.Getenv("INDEXER_SEARCH") == "true",
+		NonMediaPolicy:   os.Getenv("NON_MEDIA_POLICY"),
+		NonMediaCategory: os.Getenv("NON_MEDIA_CATEGORY"),
+
+		WatchedRequireConfirm: os.Getenv("WATCHED_REQUIRE_CONFIRM") == "true",
+
+		MonitorAiring: os.Getenv("SONARR_MONITOR_AIRING"),
+		MonitorEnded:  os.Getenv("SONARR_MONITOR_ENDED"),
+
+		ArrQBittorrentURL:     os.Getenv("ARR_QBITTORRENT_URL"),
+		DownloadClientAutoFix: os.Getenv("DOWNLOAD_CLIENT_AUTOFIX") == "true",
+		StrictLibraryAdd:      os.Getenv("STRICT_LIBRARY_ADD") == "true",
+		AddOrder:              os.Getenv("ADD_ORDER"),
+
+		ReconcileRemoveTorrents: os.Getenv("RECONCILE_REMOVE_TORRENTS") == "true",
+
+		NZBFallback:                 os.Getenv("NZB_FALLBACK") == "true",
+		PreviousFailureRequireForce: os.Getenv("PREVIOUS_FAILURE_REQUIRE_FORCE") == "true",
+
+		CompleteSeriesCategory: os.Getenv("COMPLETE_SERIES_CATEGORY"),
+
+		RequesterTags:      os.Getenv("REQUESTER_TAGS") == "true",
+		RequesterTagPrefix: os.Getenv("REQUESTER_TAG_PREFIX"),
+	}
+	if config.ArrQBittorrentURL == "" {
+		config.ArrQBittorrentURL = os.Getenv("QBITTORRENT_URL")
+	}
+	if config.RequesterTagPrefix == "" {
+		config.RequesterTagPrefix = "req-"
+	}
+	if config.CompleteSeriesCategory == "" {
+		config.CompleteSeriesCategory = "sonarr-complete"
+	}
+	if config.MonitorAiring == "" {
+		config.MonitorAiring = MonitorFutureLatestSeason
+	}
+	if config.MonitorEnded == "" {
+		config.MonitorEnded = "all"
+	}
+	for _, monitor := range []string{config.MonitorAiring, config.MonitorEnded} {
+		if !sonarrMonitorOptions[monitor] {
+			return config, fmt.Errorf("invalid Sonarr monitor option: %s", monitor)
+		}
+	}
+	seeding, err := parseSeedingPolicies(os.Getenv("SEEDING_POLICIES"))
+	if err != nil {
+		return config, err
+	}
+	config.Seeding = seeding
+	degradation, err := parseDegradation(os.Getenv("DEGRADATION"))
+	if err != nil {
+		return config, err
+	}
+	config.Degradation = degradation
+	pathMappings, err := parsePathMappings(os.Getenv("PATH_MAPPINGS"))
+	if err != nil {
+		return config, err
+	}
+	config.PathMappings = pathMappings
+	config.HealthCheck = os.Getenv("HEALTH_CHECK")
+	switch config.HealthCheck {
+	case "", HealthCheckWarn, HealthCheckReject:
+	default:
+		return config, fmt.Errorf("invalid HEALTH_CHECK: %s", config.HealthCheck)
+	}
+	config.HealthMinSeeders = 1
+	if value := os.Getenv("HEALTH_MIN_SEEDERS"); value != "" {
+		minSeeders, err := strconv.Atoi(value)
+		if err != nil || minSeeders < 0 {
+			return config, fmt.Errorf("invalid HEALTH_MIN_SEEDERS: %s", value)
+		}
+		config.HealthMinSeeders = minSeeders
+	}
+	config.FileCheckWait = 10 * time.Second
+	if value := os.Getenv("FILE_CHECK_WAIT"); value != "" {
+		wait, err := time.ParseDuration(value)
+		if err != nil || wait < 0 {
+			return config, fmt.Errorf("invalid FILE_CHECK_WAIT: %s", value)
+		}
+		config.FileCheckWait = wait
+	}
+	config.MediaAddCacheTTL = 10 * time.Minute
+	if value := os.Getenv("MEDIA_ADD_CACHE_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return config, fmt.Errorf("invalid MEDIA_ADD_CACHE_TTL: %s", value)
+		}
+		config.MediaAddCacheTTL = ttl
+	}
+	config.LibraryStatsTTL = 5 * time.Minute
+	if value := os.Getenv("LIBRARY_STATS_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return config, fmt.Errorf("invalid LIBRARY_STATS_TTL: %s", value)
+		}
+		config.LibraryStatsTTL = ttl
+	}
+	if value := os.Getenv("SEARCH_PACE"); value != "" {
+		pace, err := time.ParseDuration(value)
+		if err != nil || pace < 0 {
+			return config, fmt.Errorf("invalid SEARCH_PACE: %s", value)
+		}
+		config.SearchPace = pace
+	}
+	if value := os.Getenv("PREFERRED_LANGUAGE"); value != "" {
+		for _, language := range languageNames {
+			if language != "" && strings.EqualFold(language, value) {
+				config.PreferredLanguage = language
+			}
+		}
+		if config.PreferredLanguage == "" {
+			return config, fmt.Errorf("invalid PREFERRED_LANGUAGE: %s", value)
+		}
+	}
+	config.SoftFailureWindow = time.Hour
+	if value := os.Getenv("SOFT_FAILURE_WINDOW"); value != "" {
+		window, err := time.ParseDuration(value)
+		if err != nil || window < time.Minute {
+			return config, fmt.Errorf("invalid SOFT_FAILURE_WINDOW: %s", value)
+		}
+		config.SoftFailureWindow = window
+	}
+	config.UndoWindow = defaultUndoWindow
+	if value := os.Getenv("UNDO_WINDOW"); value != "" {
+		window, err := time.ParseDuration(value)
+		if err != nil || window < 0 {
+			return config, fmt.Errorf("invalid UNDO_WINDOW: %s", value)
+		}
+		config.UndoWindow = window
+	}
+	softFailureWarn, err := parseSoftFailureWarn(os.Getenv("SOFT_FAILURE_WARN"))
+	if err != nil {
+		return config, err
+	}
+	config.SoftFailureWarn = softFailureWarn
+	addConcurrency, err := parseAddConcurrency(os.Getenv("ADD_CONCURRENCY"))
+	if err != nil {
+		return config, err
+	}
+	config.AddConcurrency = addConcurrency
+	switch config.AddOrder {
+	case "":
+		config.AddOrder = AddOrderTorrentFirst
+	case AddOrderTorrentFirst, AddOrderLibraryFirst:
+	default:
+		return config, fmt.Errorf("invalid ADD_ORDER: %s", config.AddOrder)
+	}
+	config.DuplicateEpisodes = os.Getenv("DUPLICATE_EPISODES")
+	switch config.DuplicateEpisodes {
+	case "", DuplicatePolicyWarn, DuplicatePolicySkip, DuplicatePolicyDownloadOnly, DuplicatePolicyReplace:
+	default:
+		return config, fmt.Errorf("invalid DUPLICATE_EPISODES: %s", config.DuplicateEpisodes)
+	}
+	config.Detector.Default = os.Getenv("DETECT_DEFAULT")
+	switch config.Detector.Default {
+	case "", "radarr", "sonarr", DetectDefaultRefuse:
+	default:
+		return config, fmt.Errorf("invalid DETECT_DEFAULT: %s", config.Detector.Default)
+	}
+	for name, score := range map[string]*int{"DETECT_MIN_TV_SCORE": &config.Detector.MinTVScore, "DETECT_MIN_MOVIE_SCORE": &config.Detector.MinMovieScore} {
+		if value := os.Getenv(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return config, fmt.Errorf("invalid %s: %s", name, value)
+			}
+			*score = n
+		}
+	}
+	switch config.NonMediaPolicy {
+	case "":
+		config.NonMediaPolicy = NonMediaPolicyCategory
+	case NonMediaPolicyCategory, NonMediaPolicyReject, NonMediaPolicyDownloadOnly:
+	default:
+		return config, fmt.Errorf("invalid NON_MEDIA_POLICY: %s", config.NonMediaPolicy)
+	}
+	return config, nil
+}
+
+// ReloadConfig re-reads the handler settings after a config change. Invalid
+// settings keep the running config; other settings need a restart.
+func (h *TorrentHandler) ReloadConfig(changed []string) {
+	var reload, restart []string
+	for _, name := range changed {
+		if reloadableSettings[name] {
+			reload = append(reload, name)
+		} else {
+			restart = append(restart, name)
+		}
+	}
+	if len(restart) > 0 {
+		log.Printf("Warning: config changed for %s; restart to apply", strings.Join(restart, ", "))
+	}
+	if len(reload) == 0 {
+		return
+	}
+
+	config, err := loadHandlerConfig()
+	if err != nil {
+		log.Printf("Error: config reload rejected, keeping current settings: %v", err)
+		return
+	}
+	h.config.Store(&config)
+	log.Printf("Config reloaded: %s", strings.Join(reload, ", "))
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// configBundleEnv holds every backend's settings in one JSON document, plain or
+// base64-encoded, for platforms where many env vars are awkward
+const configBundleEnv = "TORRENT_API_CONFIG"
+
+// configBundleSections maps each backend section's fields to the env var they set.
+// The "env" section takes any other known setting by its env name.
+var configBundleSections = map[string]map[string]string{
+	"qbittorrent": {"url": "QBITTORRENT_URL", "username": "QBITTORRENT_USERNAME", "password": "QBITTORRENT_PASSWORD"},
+	"radarr":      {"url": "RADARR_URL", "api_key": "RADARR_API_KEY", "root_folder": "RADARR_ROOT_FOLDER", "quality_profile": "RADARR_QUALITY_PROFILE"},
+	"sonarr":      {"url": "SONARR_URL", "api_key": "SONARR_API_KEY", "root_folder": "SONARR_ROOT_FOLDER", "quality_profile": "SONARR_QUALITY_PROFILE"},
+	"extractor":   {"url": "NAME_EXTRACTOR_URL"},
+	"prowlarr":    {"url": "PROWLARR_URL", "api_key": "PROWLARR_API_KEY"},
+	"tautulli":    {"url": "TAUTULLI_URL", "api_key": "TAUTULLI_API_KEY"},
+}
+
+// applyConfigBundle sets the settings in TORRENT_API_CONFIG that aren't already
+// set on their own, so individual env vars (and *_FILE secrets) take precedence;
+// an empty one, as a copied .env leaves, doesn't count. It returns every problem
+// with the document at once.
+func applyConfigBundle() error {
+	raw := strings.TrimSpace(os.Getenv(configBundleEnv))
+	if raw == "" {
+		return nil
+	}
+	values, err := parseConfigBundle(raw)
+	if err != nil {
+		return err
+	}
+
+	var applied []string
+	for name, value := range values {
+		if os.Getenv(name) != "" || os.Getenv(name+"_FILE") != "" {
+			continue
+		}
+		os.Setenv(name, value)
+		applied = append(applied, name)
+	}
+	sort.Strings(applied)
+	log.Printf("Applied %d settings from %s: %s", len(applied), configBundleEnv, strings.Join(applied, ", "))
+	return nil
+}
+
+// parseConfigBundle decodes the document into env var values
+func parseConfigBundle(raw string) (map[string]string, error) {
+	data := []byte(raw)
+	if !strings.HasPrefix(raw, "{") {
+		decoded, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s is neither a JSON object nor base64: %w", configBundleEnv, err)
+		}
+		data = decoded
+	}
+
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return nil, fmt.Errorf("%s is not a JSON object: %w", configBundleEnv, err)
+	}
+
+	known := make(map[string]bool)
+	for _, name := range append(append([]string{}, envSettings...), envSecrets...) {
+		known[name] = true
+	}
+
+	var errs []error
+	values := make(map[string]string)
+	for _, section := range sortedKeys(sections) {
+		fields := configBundleSections[section]
+		if fields == nil && section != "env" {
+			var names []string
+			for name := range configBundleSections {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			errs = append(errs, fmt.Errorf("%s: unknown section (use %s or env)", section, strings.Join(names, ", ")))
+			continue
+		}
+		var entries map[string]json.RawMessage
+		if err := json.Unmarshal(sections[section], &entries); err != nil {
+			errs = append(errs, fmt.Errorf("%s: must be an object", section))
+			continue
+		}
+		for _, field := range sortedKeys(entries) {
+			name := fields[field]
+			if section == "env" {
+				if known[field] || strings.HasPrefix(field, "SCHEDULE_") {
+					name = field
+				}
+			}
+			if name == "" {
+				if section == "env" {
+					errs = append(errs, fmt.Errorf("env.%s: unknown setting", field))
+				} else {
+					var names []string
+					for name := range fields {
+						names = append(names, name)
+					}
+					sort.Strings(names)
+					errs = append(errs, fmt.Errorf("%s.%s: unknown field (use %s)", section, field, strings.Join(names, ", ")))
+				}
+				continue
+			}
+			value, err := configBundleValue(entries[field])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s.%s: %w", section, field, err))
+				continue
+			}
+			if _, dup := values[name]; dup {
+				errs = append(errs, fmt.Errorf("%s.%s: %s is set twice", section, field, name))
+				continue
+			}
+			values[name] = value
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid %s:\n%w", configBundleEnv, errors.Join(errs...))
+	}
+	return values, nil
+}
+
+// configBundleValue turns a string, number or boolean into a setting's value
+func configBundleValue(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return n.String(), nil
+	}
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return fmt.Sprint(b), nil
+	}
+	return "", errors.New("must be a string, number or boolean")
+}
+
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// QualityProfileOption is a quality profile offered by /api/config/options
+type QualityProfileOption struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Default bool   `json:"default,omitempty"` // used when the request doesn't pick one
+}
+
+// RootFolderOption is a root folder offered by /api/config/options
+type RootFolderOption struct {
+	ID         int    `json:"id"`
+	Path       string `json:"path"`
+	Accessible bool   `json:"accessible"`
+	FreeSpace  *int64 `json:"free_space,omitempty"`
+	Default    bool   `json:"default,omitempty"`
+}
+
+// ArrOptions is what an add to Radarr or Sonarr can pick from
+type ArrOptions struct {
+	QualityProfiles []QualityProfileOption `json:"quality_profiles"`
+	RootFolders     []RootFolderOption     `json:"root_folders"`
+	Tags            []ArrTag               `json:"tags"`
+	Error           string                 `json:"error,omitempty"` // the app could not be queried
+}
+
+type ConfigOptionsResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Radarr  *ArrOptions `json:"radarr,omitempty"`
+	Sonarr  *ArrOptions `json:"sonarr,omitempty"`
+}
+
+// radarrOptions lists Radarr's quality profiles, root folders and tags
+func (h *TorrentHandler) radarrOptions(ctx context.Context) (*ArrOptions, error) {
+	profiles, err := h.radarrClient.GetQualityProfiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	folders, err := h.radarrClient.GetRootFolders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := h.radarrClient.GetTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &ArrOptions{Tags: append([]ArrTag{}, tags...)}
+	ids, names := make([]int, len(profiles)), make([]string, len(profiles))
+	for i, profile := range profiles {
+		ids[i], names[i] = profile.ID, profile.Name
+	}
+	options.QualityProfiles = qualityProfileOptions(h.radarrClient.defaults, "Radarr", ids, names)
+	paths := make([]string, len(folders))
+	options.RootFolders = make([]RootFolderOption, len(folders))
+	for i, folder := range folders {
+		paths[i] = folder.Path
+		options.RootFolders[i] = RootFolderOption{ID: folder.ID, Path: folder.Path, Accessible: folder.Accessible, FreeSpace: folder.FreeSpace}
+	}
+	markDefaultRootFolder(h.radarrClient.defaults, "Radarr", paths, options.RootFolders)
+	return options, nil
+}
+
+// sonarrOptions lists Sonarr's quality profiles, root folders and tags
+func (h *TorrentHandler) sonarrOptions(ctx context.Context) (*ArrOptions, error) {
+	profiles, err := h.sonarrClient.GetQualityProfiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	folders, err := h.sonarrClient.GetRootFolders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := h.sonarrClient.GetTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &ArrOptions{Tags: append([]ArrTag{}, tags...)}
+	ids, names := make([]int, len(profiles)), make([]string, len(profiles))
+	for i, profile := range profiles {
+		ids[i], names[i] = profile.ID, profile.Name
+	}
+	options.QualityProfiles = qualityProfileOptions(h.sonarrClient.defaults, "Sonarr", ids, names)
+	paths := make([]string, len(folders))
+	options.RootFolders = make([]RootFolderOption, len(folders))
+	for i, folder := range folders {
+		paths[i] = folder.Path
+		options.RootFolders[i] = RootFolderOption{ID: folder.ID, Path: folder.Path, Accessible: folder.Accessible, FreeSpace: folder.FreeSpace}
+	}
+	markDefaultRootFolder(h.sonarrClient.defaults, "Sonarr", paths, options.RootFolders)
+	return options, nil
+}
+
+// qualityProfileOptions marks the profile the defaults pick, if it exists
+func qualityProfileOptions(defaults ArrDefaults, app string, ids []int, names []string) []QualityProfileOption {
+	options := make([]QualityProfileOption, len(ids))
+	for i := range ids {
+		options[i] = QualityProfileOption{ID: ids[i], Name: names[i]}
+	}
+	if len(ids) == 0 {
+		return options
+	}
+	if id, err := defaults.qualityProfileID(app, ids, names); err == nil {
+		for i := range options {
+			options[i].Default = options[i].ID == id
+		}
+	}
+	return options
+}
+
+// markDefaultRootFolder marks the folder the defaults pick, if it exists
+func markDefaultRootFolder(defaults ArrDefaults, app string, paths []string, options []RootFolderOption) {
+	if len(paths) == 0 {
+		return
+	}
+	if i, err := defaults.rootFolderIndex(app, paths); err == nil {
+		options[i].Default = true
+	}
+}
+
+// ConfigOptions lists the Radarr and Sonarr quality profiles, root folders and
+// tags, with the defaults marked, so the extension can offer them as dropdowns
+// and send its pick as quality_profile_id and root_folder
+func (h *TorrentHandler) ConfigOptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(ConfigOptionsResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+
+	resp := ConfigOptionsResponse{Success: true, Message: "OK"}
+	var err error
+	if resp.Radarr, err = h.radarrOptions(r.Context()); err != nil {
+		log.Printf("Error listing Radarr options: %v", err)
+		resp.Radarr = &ArrOptions{Error: err.Error()}
+	}
+	if resp.Sonarr, err = h.sonarrOptions(r.Context()); err != nil {
+		log.Printf("Error listing Sonarr options: %v", err)
+		resp.Sonarr = &ArrOptions{Error: err.Error()}
+	}
+
+	// Half the options are still worth showing
+	if resp.Radarr.Error != "" && resp.Sonarr.Error != "" {
+		w.WriteHeader(http.StatusBadGateway)
+		resp.Success, resp.Message = false, "Radarr and Sonarr could not be queried"
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Lookup transformations tried, in order, when a search term finds nothing
+const (
+	TransformWithoutYear   = "without_year"
+	TransformStripSubtitle = "strip_subtitle"
+	TransformFixOCR        = "fix_ocr"
+	TransformDropWords     = "drop_trailing_words"
+)
+
+// maxLookupAttempts bounds how many *arr lookups a single match may cost
+const maxLookupAttempts = 8
+
+// LookupCorrection reports which transformation of the search term found the match
+type LookupCorrection struct {
+	Transform string `json:"transform"`
+	Term      string `json:"term"`
+	Original  string `json:"original"`
+}
+
+type lookupVariant struct {
+	transform string
+	term      string
+}
+
+var (
+	trailingYearPattern = regexp.MustCompile(`^(.*?)\s*\(?((?:19|20)\d{2})\)?$`)
+	subtitlePattern     = regexp.MustCompile(`\s*(:|\s-\s|\s–\s).*$`)
+	mixedWordPattern    = regexp.MustCompile(`\b\w*[a-zA-Z]\w*\b`)
+	ocrLetterReplacer   = strings.NewReplacer("rn", "m", "vv", "w", "|", "l")
+	// "1" is as often a misread "l" as an "i", so both are tried
+	ocrDigitReplacers = []*strings.Replacer{
+		strings.NewReplacer("0", "o", "1", "i", "5", "s"),
+		strings.NewReplacer("0", "o", "1", "l", "5", "s"),
+	}
+	lookupStopWords = map[string]bool{"the": true, "a": true, "an": true, "of": true, "and": true}
+)
+
+// lookupVariants returns progressively looser search terms for a term that
+// found nothing: without the year, without a subtitle, with OCR-style errors
+// fixed, then with trailing words dropped one at a time. At least two words and
+// half of the name are kept, so a long title isn't cut down to a common word.
+func lookupVariants(term string) []lookupVariant {
+	name, year := term, ""
+	if m := trailingYearPattern.FindStringSubmatch(term); m != nil && m[1] != "" {
+		name, year = strings.TrimSpace(m[1]), m[2]
+	}
+	withYear := func(s string) string {
+		if year == "" {
+			return s
+		}
+		return s + " " + year
+	}
+
+	var variants []lookupVariant
+	seen := map[string]bool{strings.ToLower(term): true}
+	add := func(transform, candidate string) {
+		candidate = strings.Join(strings.Fields(candidate), " ")
+		key := strings.ToLower(candidate)
+		if len(candidate) < 2 || seen[key] {
+			return
+		}
+		seen[key] = true
+		variants = append(variants, lookupVariant{transform: transform, term: candidate})
+	}
+
+	if year != "" {
+		add(TransformWithoutYear, name)
+	}
+
+	if stripped := subtitlePattern.ReplaceAllString(name, ""); stripped != "" && stripped != name {
+		add(TransformStripSubtitle, withYear(stripped))
+		add(TransformStripSubtitle, stripped)
+	}
+
+	for _, replacer := range ocrDigitReplacers {
+		fixed := mixedWordPattern.ReplaceAllStringFunc(name, replacer.Replace)
+		fixed = ocrLetterReplacer.Replace(fixed)
+		if fixed != name {
+			add(TransformFixOCR, withYear(fixed))
+		}
+	}
+
+	words := strings.Fields(name)
+	for n := len(words) - 1; n >= max(2, (len(words)+1)/2); n-- {
+		kept := strings.TrimRight(strings.Join(words[:n], " "), " :-–,")
+		if !lookupStopWords[strings.ToLower(kept)] {
+			add(TransformDropWords, withYear(kept))
+		}
+	}
+
+	if len(variants) > maxLookupAttempts-1 {
+		variants = variants[:maxLookupAttempts-1]
+	}
+	return variants
+}
+
+// searchWithCorrections runs search for term and, while it finds nothing, for each
+// lookup variant. It returns the correction that matched, nil if the original did,
+// and stops at the first search error.
+func searchWithCorrections(term string, search func(term string) (bool, error)) (*LookupCorrection, error) {
+	found, err := search(term)
+	if err != nil || found {
+		return nil, err
+	}
+
+	for _, variant := range lookupVariants(term) {
+		found, err := search(variant.term)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			log.Printf("Lookup for %q matched after %s: %q", term, variant.transform, variant.term)
+			return &LookupCorrection{Transform: variant.transform, Term: variant.term, Original: term}, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request and response bodies are cut off after this many bytes
+const debugBodyLimit = 4096
+
+// Credentials in form fields, query strings and JSON that aren't loaded secrets,
+// e.g. a tracker passkey inside a magnet link
+var (
+	debugSecretPattern = regexp.MustCompile(`(?i)((?:password|passkey|authkey|torrent_pass|apikey|api_key|token)(?:"\s*:\s*"|=))[^"&\s]*`)
+	// Private trackers' per-user key as a path segment of the announce URL
+	debugPasskeyPathPattern = regexp.MustCompile(`(?i)(://[^/\s"]+/)[0-9a-z]{24,}(/|\b)`)
+)
+
+// DebugLog is the decision log a `debug: true` add returns
+type DebugLog struct {
+	mu        sync.Mutex
+	Decisions []string        `json:"decisions"`
+	Requests  []DebugExchange `json:"requests"` // Radarr, Sonarr, qBittorrent and extractor calls, in order
+}
+
+// DebugExchange is one downstream HTTP call
+type DebugExchange struct {
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+	Request    string `json:"request,omitempty"`
+	Status     int    `json:"status,omitempty"`
+	Response   string `json:"response,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+type debugLogKey struct{}
+
+// withDebugLog returns a context that collects decisions and downstream calls
+func withDebugLog(ctx context.Context) (context.Context, *DebugLog) {
+	debug := &DebugLog{Decisions: []string{}, Requests: []DebugExchange{}}
+	return context.WithValue(ctx, debugLogKey{}, debug), debug
+}
+
+func debugLogFromContext(ctx context.Context) *DebugLog {
+	debug, _ := ctx.Value(debugLogKey{}).(*DebugLog)
+	return debug
+}
+
+// debugf records a decision when the request asked for debug output
+func debugf(ctx context.Context, format string, args ...interface{}) {
+	debug := debugLogFromContext(ctx)
+	if debug == nil {
+		return
+	}
+	debug.mu.Lock()
+	debug.Decisions = append(debug.Decisions, redactDebug(fmt.Sprintf(format, args...)))
+	debug.mu.Unlock()
+}
+
+func redactDebug(s string) string {
+	s = debugSecretPattern.ReplaceAllString(secretRedactor.Redact(s), "${1}REDACTED")
+	return debugPasskeyPathPattern.ReplaceAllString(s, "${1}REDACTED${2}")
+}
+
+// capture runs the request through next and records it with both bodies
+func (d *DebugLog) capture(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	exchange := DebugExchange{Method: req.Method, URL: redactDebug(req.URL.String())}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(body, debugBodyLimit))
+			body.Close()
+			exchange.Request = string(data)
+			// Decoded, so magnet links in qBittorrent forms are readable and redactable
+			if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+				if decoded, err := url.QueryUnescape(exchange.Request); err == nil {
+					exchange.Request = decoded
+				}
+			}
+			exchange.Request = redactDebug(exchange.Request)
+		}
+	}
+
+	start := time.Now()
+	resp, err := next(req)
+	exchange.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		exchange.Error = redactDebug(err.Error())
+	} else {
+		// Put the peeked bytes back for the caller
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, debugBodyLimit))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+		exchange.Status = resp.StatusCode
+		exchange.Response = redactDebug(string(data))
+	}
+
+	d.mu.Lock()
+	d.Requests = append(d.Requests, exchange)
+	d.mu.Unlock()
+	return resp, err
+}
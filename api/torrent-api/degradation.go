@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Dependencies the pipeline can lose
+const (
+	DependencyExtractor   = "extractor"
+	DependencyRadarr      = "radarr"
+	DependencySonarr      = "sonarr"
+	DependencyTautulli    = "tautulli"
+	DependencyQBittorrent = "qbittorrent"
+)
+
+// What an add does when a dependency is down
+const (
+	DegradeFallback = "fallback" // extractor: use local extraction
+	DegradeSkip     = "skip"     // carry on without it
+	DegradeRetry    = "retry"    // radarr/sonarr: add the torrent, retry the library add later
+	DegradeFail     = "fail"     // fail the add with DEPENDENCY_UNAVAILABLE
+)
+
+// Allowed modes per dependency; the first is the default
+var degradationModes = map[string][]string{
+	DependencyExtractor:   {DegradeFallback, DegradeSkip, DegradeFail},
+	DependencyRadarr:      {DegradeSkip, DegradeRetry, DegradeFail},
+	DependencySonarr:      {DegradeSkip, DegradeRetry, DegradeFail},
+	DependencyTautulli:    {DegradeSkip, DegradeFail},
+	DependencyQBittorrent: {DegradeFail},
+}
+
+// Library adds waiting for Radarr/Sonarr are given up after this many retries
+const maxLibraryRetries = 36
+
+// DegradationPolicies maps dependencies to their DEGRADATION mode
+type DegradationPolicies map[string]string
+
+// parseDegradation parses DEGRADATION, e.g. "radarr=retry,sonarr=retry,extractor=fail"
+func parseDegradation(spec string) (DegradationPolicies, error) {
+	policies := make(DegradationPolicies)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		dependency, mode, ok := strings.Cut(entry, "=")
+		dependency, mode = strings.TrimSpace(dependency), strings.TrimSpace(mode)
+		modes, known := degradationModes[dependency]
+		if !ok || !known {
+			return nil, fmt.Errorf("invalid DEGRADATION entry %q: use dependency=mode with one of extractor, radarr, sonarr, tautulli, qbittorrent", entry)
+		}
+		valid := false
+		for _, allowed := range modes {
+			valid = valid || allowed == mode
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid DEGRADATION mode %q for %s: use %s", mode, dependency, strings.Join(modes, ", "))
+		}
+		policies[dependency] = mode
+	}
+	return policies, nil
+}
+
+// For returns the mode for dependency
+func (d DegradationPolicies) For(dependency string) string {
+	if mode, ok := d[dependency]; ok {
+		return mode
+	}
+	return degradationModes[dependency][0]
+}
+
+// isUnavailable reports whether err means the service could not be reached or
+// is failing, as opposed to answering that e.g. nothing matched
+func isUnavailable(err error) bool {
+	var timeout *StepTimeoutError
+	var netErr net.Error
+	var urlErr *url.Error
+	var statusErr *ArrStatusError
+	switch {
+	case err == nil:
+		return false
+	case errors.As(err, &timeout), errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.As(err, &netErr), errors.As(err, &urlErr):
+		return true
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode >= 500
+	}
+	return false
+}
+
+// degrade applies the dependency's DEGRADATION mode to a failed step. It returns
+// the error to fail the add with, or nil to carry on; retry marks the add for the
+// libraryretry worker.
+func (h *TorrentHandler) degrade(p *AddPipeline, dependency, step string, err error) error {
+	if !isUnavailable(err) {
+		return nil
+	}
+	mode := h.cfg().Degradation.For(dependency)
+	// Unless DEGRADATION says otherwise, Radarr/Sonarr being down is taken as brief
+	// when its root folders and quality profiles are cached
+	retryWith := ""
+	if _, explicit := h.cfg().Degradation[dependency]; !explicit && mode == DegradeSkip {
+		if rootFolder, qualityProfile, ok := h.cachedArrDefaults(dependency); ok {
+			mode, retryWith = DegradeRetry, fmt.Sprintf(" with %s and %s", rootFolder, qualityProfile)
+		}
+	}
+
+	switch mode {
+	case DegradeFail:
+		return &PipelineError{Step: step, Err: newAPIError(ErrCodeDependencyUnavailable, "%s is unavailable: %v", dependency, err)}
+	case DegradeRetry:
+		if h.history == nil {
+			log.Printf("Warning: %s=retry needs HISTORY_FILE or in-memory history, skipping the library add", dependency)
+			return nil
+		}
+		p.LibraryRetry = true
+		p.Warnings = append(p.Warnings, fmt.Sprintf("%s is unavailable; the library add will be retried%s", dependency, retryWith))
+	}
+	return nil
+}
+
+// cachedArrDefaults returns the root folder and quality profile the failover
+// cache has for the *arr dependency
+func (h *TorrentHandler) cachedArrDefaults(dependency string) (rootFolder, qualityProfile string, ok bool) {
+	switch dependency {
+	case DependencyRadarr:
+		return h.radarrClient.cachedDefaults()
+	case DependencySonarr:
+		return h.sonarrClient.cachedDefaults()
+	}
+	return "", "", false
+}
+
+// arrDependency names the *arr app the add goes to
+func arrDependency(p *AddPipeline) string {
+	if p.IsMovie {
+		return DependencyRadarr
+	}
+	return DependencySonarr
+}
+
+// RetryLibraryAdds is the libraryretry worker: it matches and adds the torrents
+// whose library add was deferred while Radarr/Sonarr was down
+func (h *TorrentHandler) RetryLibraryAdds(ctx context.Context) error {
+	if h.history == nil {
+		return nil
+	}
+
+	for _, record := range h.history.List() {
+		if !record.LibraryRetry {
+			continue
+		}
+		p := &AddPipeline{
+			TorrentName: record.Name,
+			Category:    record.Category,
+			IsMovie:     record.MediaType == "movie",
+			StartedAt:   time.Now(),
+		}
+		err := h.retryLibraryAdd(ctx, p)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		updateErr := h.history.Update(record.ID, func(r *HistoryRecord) {
+			switch {
+			case err == nil:
+				r.LibraryRetry = false
+				r.MediaTitle, r.MediaID = p.MediaTitle, p.MediaID
+				r.Code, r.Error = "", ""
+				log.Printf("Library retry: added %s", p.MediaTitle)
+			case isUnavailable(err) && r.LibraryRetries+1 < maxLibraryRetries:
+				r.LibraryRetries++
+			default:
+				r.LibraryRetry = false
+				r.Code = errorCode(err)
+				r.Error = "library add failed: " + err.Error()
+				log.Printf("Library retry: giving up on %s: %v", record.Name, err)
+			}
+		})
+		if updateErr != nil {
+			log.Printf("Warning: could not update history: %v", updateErr)
+		}
+	}
+	return nil
+}
+
+// retryLibraryAdd runs extract, match and library add for one deferred record
+func (h *TorrentHandler) retryLibraryAdd(ctx context.Context, p *AddPipeline) error {
+	if err := h.stepExtract(p)(ctx); err != nil {
+		return err
+	}
+	if err := h.stepMatch(p)(ctx); err != nil {
+		return err
+	}
+	err := h.stepLibraryAdd(p)(ctx)
+	if err != nil && (strings.Contains(err.Error(), "already") || strings.Contains(err.Error(), "exists")) {
+		return nil
+	}
+	return err
+}
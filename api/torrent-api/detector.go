@@ -26,6 +26,13 @@ var tvPatterns = []*regexp.Regexp{
 	regexp.MustCompile(`(?i)Mini[.-]?Series`),         // Mini-Series
 }
 
+// Complete series patterns - box sets of every season rather than a single one
+var completeSeriesPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)Complete[ ._-]*(Series|Collection|Box[ ._-]*Set)`), // Complete Series, Complete Box Set
+	regexp.MustCompile(`(?i)\bS0?1[ ._]?-[ ._]?S?\d{1,2}\b`),                   // S01-S05, S1-5
+	regexp.MustCompile(`(?i)\bSeasons?[ ._]*0?1[ ._]*(-|to)[ ._]*\d{1,2}\b`),   // Seasons 1-5, Season 1 to 8
+}
+
 // Movie patterns - these indicate a movie
 var moviePatterns = []*regexp.Regexp{
 	regexp.MustCompile(`(?i)(19|20)\d{2}.*?(720p|1080p|2160p|4K|BluRay|BDRip|HDRip|WEBRip|DVDR)`), // Year + quality
@@ -46,6 +53,58 @@ var moviePatterns = []*regexp.Regexp{
 	regexp.MustCompile(`(?i)Theatrical`),       // Theatrical
 }
 
+// Anime trackers and index sites - releases from these are almost always anime series
+var animeHosts = []string{
+	"nyaa.si",
+	"nyaa.tracker.wf",
+	"sukebei.nyaa.si",
+	"animetosho.org",
+	"anidex.info",
+	"tokyotosho.info",
+	"anirena.com",
+}
+
+var (
+	infoHashPattern     = regexp.MustCompile(`(?i)urn:btih:([A-Za-z0-9]+)`)
+	animeEpisodePattern = regexp.MustCompile(`\s-\s(\d{1,4})(?:v\d)?(?:\s|$|\[|\()`) // " - 05 ", absolute numbering
+	animeGroupPattern   = regexp.MustCompile(`^\s*\[[^\]]*\]\s*`)                    // [SubsPlease] prefix
+)
+
+// Non-media kinds, each with the patterns that identify it
+var nonMediaPatterns = []struct {
+	kind     string
+	patterns []*regexp.Regexp
+}{
+	{"game", []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\b(FitGirl|DODI|ElAmigos|KaOs|GOG|CODEX|SKIDROW|PLAZA|EMPRESS|TENOKE|RUNE|CPY|RELOADED|PROPHET|DARKSiDERS|Razor1911|FLT|HOODLUM)\b`), // Game repackers/groups
+		regexp.MustCompile(`(?i)\b(NSW|NSP|XCI|PS[345]|XBOX(ONE|360)?)\b`),                                                                                           // Console dumps
+	}},
+	{"software", []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\b(keygen|activator|patch(ed)?\s*only|pre-?activated)\b`),               // Cracks
+		regexp.MustCompile(`(?i)\.(iso|exe|msi|dmg|pkg|apk|img)$`),                                      // Installers
+		regexp.MustCompile(`(?i)\b(Adobe|Microsoft\s*Office|Windows\s*Server|macOS|Autodesk|MATLAB)\b`), // Common software
+	}},
+	{"book", []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\b(epub|mobi|azw3?|pdf|cbz|cbr|djvu|e-?books?|audiobooks?|m4b)\b`), // Ebook/audiobook formats
+	}},
+}
+
+// Words that are also film titles ("Serial", "Cracked", "Windows 10"): software
+// only next to a softwareMarkerPattern or x64 with another of them, and never
+// in a titleYearPattern name
+var weakSoftwarePattern = regexp.MustCompile(`(?i)\b(serial|crack(ed)?|Windows\s*(7|8|10|11)|x64|x86)\b`)
+
+var archPattern = regexp.MustCompile(`(?i)\b(x64|x86)\b`)
+
+// A version number or an installer
+var softwareMarkerPattern = regexp.MustCompile(`(?i)\bv\d+(\.\d+)+\b|\b\d+\.\d+\.\d+\b|\b(iso|exe|msi|dmg|pkg|setup|installer)\b`)
+
+// Title words followed by a year, as films are named
+var titleYearPattern = regexp.MustCompile(`^[A-Za-z][\w'&-]*([ ._]+[A-Za-z][\w'&-]*)*[ ._]*[(\[]?(19|20)\d{2}\b`)
+
+// Any of these means a video release, whatever else the name contains
+var videoReleasePattern = regexp.MustCompile(`(?i)\b(480p|720p|1080p|2160p|4K|x264|x265|HEVC|H\.?26[45]|XviD|WEB-?DL|WEBRip|BluRay|BDRip|HDTV|DVDRip|S\d{1,2}E\d{1,2})\b`)
+
 // extractNameFromMagnet extracts the display name from a magnet link
 func extractNameFromMagnet(magnetLink string) string {
 	// Parse the magnet URI
@@ -69,53 +128,207 @@ func extractNameFromMagnet(magnetLink string) string {
 	return magnetLink
 }
 
+// isCompleteSeries reports whether a TV torrent is a complete series box set
+func isCompleteSeries(name string) bool {
+	for _, pattern := range completeSeriesPatterns {
+		if pattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractInfoHash returns the lowercased btih info hash from a magnet link
+func extractInfoHash(magnetLink string) string {
+	matches := infoHashPattern.FindStringSubmatch(magnetLink)
+	if len(matches) < 2 {
+		return ""
+	}
+	return strings.ToLower(matches[1])
+}
+
+// isAnimeSource reports whether the source page or any of the magnet's trackers is an anime site
+func isAnimeSource(magnetLink, sourceURL string) bool {
+	hosts := []string{}
+	if u, err := url.Parse(sourceURL); err == nil && sourceURL != "" {
+		hosts = append(hosts, u.Hostname())
+	}
+	if u, err := url.Parse(magnetLink); err == nil {
+		for _, tr := range u.Query()["tr"] {
+			if tu, err := url.Parse(tr); err == nil {
+				hosts = append(hosts, tu.Hostname())
+			}
+		}
+	}
+
+	for _, host := range hosts {
+		host = strings.ToLower(host)
+		for _, anime := range animeHosts {
+			if host == anime || strings.HasSuffix(host, "."+anime) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cleanAnimeName strips fansub conventions ([Group] prefixes, " - 05" absolute
+// episode numbers and trailing tags) so the series name can be looked up
+func cleanAnimeName(name string) string {
+	name = regexp.MustCompile(`(?i)\.(mkv|avi|mp4)$`).ReplaceAllString(name, "")
+	name = strings.ReplaceAll(name, "_", " ")
+	name = animeGroupPattern.ReplaceAllString(name, "")
+
+	// Cut at the absolute episode number and everything after
+	if loc := animeEpisodePattern.FindStringIndex(name); loc != nil {
+		name = name[:loc[0]]
+	}
+
+	// Remaining bracketed/parenthesized tags: [1080p], (BD 1080p), [ABCD1234]
+	name = regexp.MustCompile(`\[.*?\]|\(.*?\)`).ReplaceAllString(name, "")
+
+	return cleanSeriesName(name)
+}
+
 // detectCategory analyzes the magnet link and determines if it's a movie or TV show
 func detectCategory(magnetLink string) string {
-	name := extractNameFromMagnet(magnetLink)
+	return detectCategoryWithHint(magnetLink, false)
+}
+
+// detectCategoryWithHint is detectCategory with a bias toward Sonarr for anime sources
+func detectCategoryWithHint(magnetLink string, anime bool) string {
+	return explainCategory(extractNameFromMagnet(magnetLink), anime).Category
+}
+
+// CategoryDecision records how a torrent name was categorized and which patterns fired
+type CategoryDecision struct {
+	Category   string   `json:"category"` // "" when undetermined and DETECT_DEFAULT=refuse
+	TVScore    int      `json:"tv_score"`
+	MovieScore int      `json:"movie_score"`
+	Reason     string   `json:"reason"`
+	TVRules    []string `json:"tv_rules,omitempty"`
+	MovieRules []string `json:"movie_rules,omitempty"`
+	Defaulted  bool     `json:"defaulted,omitempty"` // no clear indicators; Category is DETECT_DEFAULT
+}
+
+// DETECT_DEFAULT values besides the categories
+const DetectDefaultRefuse = "refuse"
+
+// DetectorSettings tune the detector for a deployment; the zero value is the
+// built-in behaviour
+type DetectorSettings struct {
+	// Category for names without clear indicators: "radarr" (default), "sonarr"
+	// or "refuse" to fail the add unless the extractor or request gives a type
+	Default string
+	// Patterns a side needs to win on score alone (default 1); fewer count as
+	// no clear indicators
+	MinTVScore    int
+	MinMovieScore int
+}
+
+// explainCategory scores a torrent name against the TV and movie patterns with
+// the built-in settings
+func explainCategory(name string, anime bool) CategoryDecision {
+	return DetectorSettings{}.explain(name, anime)
+}
+
+// explain scores a torrent name against the TV and movie patterns
+func (s DetectorSettings) explain(name string, anime bool) CategoryDecision {
+	// Absolute episode numbering from an anime tracker is definitive
+	if anime && animeEpisodePattern.MatchString(name) {
+		return CategoryDecision{Category: "sonarr", Reason: "anime absolute episode number"}
+	}
+
 	name = strings.ToLower(name)
+	d := CategoryDecision{}
 
 	// First check for TV patterns (more specific)
-	tvScore := 0
 	for _, pattern := range tvPatterns {
 		if pattern.MatchString(name) {
-			tvScore++
+			d.TVScore++
+			d.TVRules = append(d.TVRules, pattern.String())
 		}
 	}
 
 	// Then check for movie patterns
-	movieScore := 0
 	for _, pattern := range moviePatterns {
 		if pattern.MatchString(name) {
-			movieScore++
+			d.MovieScore++
+			d.MovieRules = append(d.MovieRules, pattern.String())
 		}
 	}
 
+	// Anime sources are overwhelmingly series
+	if anime {
+		d.TVScore += 2
+	}
+
 	// If we have strong TV indicators, it's likely a TV show
 	// TV patterns like S01E01 are very specific
-	if tvScore > 0 {
+	if d.TVScore > 0 {
 		// Check if it has a season/episode pattern which is definitive
 		seasonEpisode := regexp.MustCompile(`(?i)S\d{1,2}E\d{1,2}`)
 		if seasonEpisode.MatchString(name) {
-			return "sonarr"
+			d.Category, d.Reason = "sonarr", "season/episode number"
+			return d
 		}
 		// Season pattern is also very indicative
 		seasonPattern := regexp.MustCompile(`(?i)(Season\s*\d+|\.S\d{1,2}\.)`)
 		if seasonPattern.MatchString(name) {
-			return "sonarr"
+			d.Category, d.Reason = "sonarr", "season number"
+			return d
 		}
 	}
 
 	// Compare scores
-	if tvScore > movieScore {
-		return "sonarr"
+	if d.TVScore > d.MovieScore && d.TVScore >= max(s.MinTVScore, 1) {
+		d.Category, d.Reason = "sonarr", "more TV than movie patterns"
+		return d
+	}
+	if d.MovieScore > d.TVScore && d.MovieScore >= max(s.MinMovieScore, 1) {
+		d.Category, d.Reason = "radarr", "more movie than TV patterns"
+		return d
 	}
-	if movieScore > tvScore {
-		return "radarr"
+
+	// If we can't determine, default to radarr (movies) unless configured
+	// otherwise. Most single releases without season indicators are movies.
+	d.Defaulted = true
+	switch s.Default {
+	case "sonarr":
+		d.Category, d.Reason = "sonarr", "no clear indicators, defaulting to TV"
+	case DetectDefaultRefuse:
+		d.Category, d.Reason = "", "no clear indicators"
+	default:
+		d.Category, d.Reason = "radarr", "no clear indicators, defaulting to movie"
 	}
+	return d
+}
 
-	// If we can't determine, default to radarr (movies)
-	// This is because most single releases without season indicators are movies
-	return "radarr"
+// classifyNonMedia returns "game", "software" or "book" for torrents that are
+// obviously not movies or TV, or "" for anything that may be video
+func classifyNonMedia(name string) string {
+	kind, _ := explainNonMedia(name)
+	return kind
+}
+
+// explainNonMedia is classifyNonMedia that also returns the pattern that fired
+func explainNonMedia(name string) (string, string) {
+	if videoReleasePattern.MatchString(name) {
+		return "", ""
+	}
+	for _, kind := range nonMediaPatterns {
+		for _, pattern := range kind.patterns {
+			if pattern.MatchString(name) {
+				return kind.kind, pattern.String()
+			}
+		}
+	}
+	if weak := weakSoftwarePattern.FindAllString(name, -1); len(weak) > 0 && !titleYearPattern.MatchString(name) {
+		if softwareMarkerPattern.MatchString(name) || (len(weak) > 1 && archPattern.MatchString(name)) {
+			return "software", weakSoftwarePattern.String()
+		}
+	}
+	return "", ""
 }
 
 // isValidMagnetLink checks if the string is a valid magnet link
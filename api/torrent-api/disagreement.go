@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// EventDetectionDisagreement is posted to DISAGREEMENT_WEBHOOK_URL
+const EventDetectionDisagreement = "detection_disagreement"
+
+// URLs, e-mail addresses and bracketed domains that trackers stamp into names;
+// bare "word.tld" is left alone since dotted names are full of them (Man.in.Black)
+var identifyingTokenPattern = regexp.MustCompile(`(?i)(https?://\S+|www\.\S+|\b[\w+]+@[\w-]+(\.[\w-]+)+|\[[^\]]*\.(com|org|net|to|se|me|cc|io|ws|xyz|re|ru|info|club|co|tv|pw|lol)\b[^\]]*\])`)
+
+var repeatedSeparatorPattern = regexp.MustCompile(`[\s._\-\[\]()]{2,}`)
+
+// DetectionDisagreement is one case where the detector and the extractor picked
+// different categories, anonymized for a correction dataset. It carries no
+// magnet, infohash, source page or requester.
+type DetectionDisagreement struct {
+	Name      string              `json:"name"` // torrent name with sites, URLs and secrets removed
+	Anime     bool                `json:"anime"`
+	Detector  CategoryDecision    `json:"detector"`
+	Extractor string              `json:"extractor"` // media type the extractor returned
+	Outcome   DisagreementOutcome `json:"outcome"`
+}
+
+// DisagreementOutcome is what the add ended up doing
+type DisagreementOutcome struct {
+	Category  string `json:"category"`
+	Matched   bool   `json:"matched"`              // Radarr/Sonarr found the title
+	MediaType string `json:"media_type,omitempty"` // of the match: "movie" or "tv"
+	Success   bool   `json:"success"`
+	Code      string `json:"code,omitempty"`
+}
+
+// redactReleaseName strips what could identify the tracker or the user from a
+// torrent name while keeping the words the detection rules look at
+func redactReleaseName(name string) string {
+	name = secretRedactor.Redact(name)
+	name = identifyingTokenPattern.ReplaceAllString(name, " ")
+	name = activeSitePatterns().sites.ReplaceAllString(name, " ")
+	name = repeatedSeparatorPattern.ReplaceAllStringFunc(name, func(s string) string {
+		return s[:1]
+	})
+	return strings.Trim(name, " ._-")
+}
+
+// reportDisagreement posts the add to DISAGREEMENT_WEBHOOK_URL when detection
+// and extraction disagreed. It runs as a pipeline complete hook.
+func (h *TorrentHandler) reportDisagreement(p *AddPipeline, err error) {
+	if h.disagreements == nil || p.Disagreement == nil {
+		return
+	}
+
+	report := *p.Disagreement
+	report.Name = redactReleaseName(p.TorrentName)
+	report.Outcome = DisagreementOutcome{
+		Category: p.Category,
+		Matched:  p.MovieMatch != nil || p.SeriesMatch != nil,
+		Success:  err == nil,
+		Code:     errorCode(err),
+	}
+	switch {
+	case p.MovieMatch != nil:
+		report.Outcome.MediaType = "movie"
+	case p.SeriesMatch != nil:
+		report.Outcome.MediaType = "tv"
+	}
+
+	// The add's request may be gone by the time the endpoint answers
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := h.disagreements.Notify(ctx, EventDetectionDisagreement, "Detector and extractor disagreed", report); err != nil {
+			log.Printf("Warning: could not report detection disagreement: %v", err)
+		}
+	}()
+}
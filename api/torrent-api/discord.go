@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const discordAPIURL = "https://discord.com/api/v10"
+
+// discordInteractionsPath receives slash commands; Discord signs these requests
+// itself, so the route is exempt from API key auth
+const discordInteractionsPath = "/api/discord/interactions"
+
+// Replies are edited at most this often while an add is in progress,
+// staying well inside Discord's per-channel rate limit
+const discordEditInterval = 1500 * time.Millisecond
+
+// DiscordBot adds movies, series and magnet links posted in one Discord channel.
+// Messages are polled over the REST API; slash commands arrive at
+// discordInteractionsPath when the application's public key is configured.
+type DiscordBot struct {
+	baseURL       string
+	token         string
+	applicationID string
+	guildID       string
+	channelID     string
+	publicKey     ed25519.PublicKey
+	handler       *TorrentHandler
+	httpClient    *http.Client
+
+	lastMessageID string // newest channel message seen by Poll
+}
+
+type DiscordUser struct {
+	ID  string `json:"id"`
+	Bot bool   `json:"bot"`
+}
+
+type DiscordMessage struct {
+	ID      string      `json:"id"`
+	Content string      `json:"content"`
+	Author  DiscordUser `json:"author"`
+}
+
+type DiscordEmbed struct {
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	URL         string                 `json:"url,omitempty"`
+	Color       int                    `json:"color,omitempty"`
+	Thumbnail   *DiscordEmbedThumbnail `json:"thumbnail,omitempty"`
+	Footer      *DiscordEmbedFooter    `json:"footer,omitempty"`
+}
+
+type DiscordEmbedThumbnail struct {
+	URL string `json:"url"`
+}
+
+type DiscordEmbedFooter struct {
+	Text string `json:"text"`
+}
+
+type discordMessagePayload struct {
+	Content          string                   `json:"content"`
+	Embeds           []DiscordEmbed           `json:"embeds"`
+	MessageReference *discordMessageReference `json:"message_reference,omitempty"`
+}
+
+type discordMessageReference struct {
+	MessageID string `json:"message_id"`
+}
+
+// Interaction types and callback types
+const (
+	discordInteractionPing               = 1
+	discordInteractionApplicationCommand = 2
+	discordCallbackPong                  = 1
+	discordCallbackMessage               = 4
+	discordCallbackDeferredMessage       = 5
+)
+
+type discordInteraction struct {
+	Type      int    `json:"type"`
+	Token     string `json:"token"`
+	ChannelID string `json:"channel_id"`
+	Data      struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string          `json:"name"`
+			Value json.RawMessage `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+}
+
+// option returns a string command option, or ""
+func (i *discordInteraction) option(name string) string {
+	for _, opt := range i.Data.Options {
+		if opt.Name != name {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(opt.Value, &value); err != nil {
+			return strings.Trim(string(opt.Value), `"`)
+		}
+		return value
+	}
+	return ""
+}
+
+type discordCommand struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Options     []discordCommandOption `json:"options"`
+}
+
+type discordCommandOption struct {
+	Type        int    `json:"type"` // 3 is a string
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+// NewDiscordBot creates a bot for channelID. publicKey (hex) enables slash commands;
+// guildID registers them for one server only, which applies instantly.
+func NewDiscordBot(token, applicationID, publicKey, channelID, guildID string, handler *TorrentHandler) (*DiscordBot, error) {
+	if channelID == "" {
+		return nil, fmt.Errorf("DISCORD_CHANNEL_ID is required")
+	}
+
+	bot := &DiscordBot{
+		baseURL:       discordAPIURL,
+		token:         token,
+		applicationID: applicationID,
+		guildID:       guildID,
+		channelID:     channelID,
+		handler:       handler,
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: newTracingTransport(),
+		},
+	}
+
+	if publicKey != "" {
+		key, err := hex.DecodeString(publicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid DISCORD_PUBLIC_KEY")
+		}
+		if applicationID == "" {
+			return nil, fmt.Errorf("DISCORD_APPLICATION_ID is required for slash commands")
+		}
+		bot.publicKey = key
+	}
+	return bot, nil
+}
+
+// doRequest performs an authenticated Discord API request
+func (b *DiscordBot) doRequest(ctx context.Context, method, endpoint string, body interface{}, result interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL+endpoint, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bot "+b.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("discord request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// RegisterCommands creates or updates the /add-movie and /add-tv slash commands
+func (b *DiscordBot) RegisterCommands(ctx context.Context) error {
+	nameOption := func(description string) []discordCommandOption {
+		return []discordCommandOption{
+			{Type: 3, Name: "name", Description: description, Required: true},
+			{Type: 3, Name: "year", Description: "Release year, to pick between remakes"},
+		}
+	}
+	commands := []discordCommand{
+		{Name: BotCommandAddMovie, Description: "Add a movie to Radarr and search for it", Options: nameOption("Movie title")},
+		{Name: BotCommandAddTV, Description: "Add a series to Sonarr and search for it", Options: nameOption("Series title")},
+	}
+
+	endpoint := "/applications/" + b.applicationID + "/commands"
+	if b.guildID != "" {
+		endpoint = "/applications/" + b.applicationID + "/guilds/" + b.guildID + "/commands"
+	}
+	if err := b.doRequest(ctx, http.MethodPut, endpoint, commands, nil); err != nil {
+		return fmt.Errorf("failed to register Discord commands: %w", err)
+	}
+	return nil
+}
+
+// Poll reads new channel messages every interval and handles commands and
+// magnet links in them, until ctx is done. Messages from before the start are ignored.
+func (b *DiscordBot) Poll(ctx context.Context, interval time.Duration) {
+	endpoint := "/channels/" + b.channelID + "/messages"
+
+	for b.lastMessageID == "" {
+		var latest []DiscordMessage
+		err := b.doRequest(ctx, http.MethodGet, endpoint+"?limit=1", nil, &latest)
+		if err == nil && len(latest) == 0 {
+			b.lastMessageID = "0"
+		} else if err == nil {
+			b.lastMessageID = latest[0].ID
+		} else {
+			log.Printf("Warning: could not read Discord channel: %v", err)
+		}
+		if b.lastMessageID == "" && !sleepContext(ctx, interval) {
+			return
+		}
+	}
+
+	for sleepContext(ctx, interval) {
+		var messages []DiscordMessage
+		if err := b.doRequest(ctx, http.MethodGet, endpoint+"?limit=50&after="+b.lastMessageID, nil, &messages); err != nil {
+			log.Printf("Warning: could not read Discord channel: %v", err)
+			continue
+		}
+
+		// Newest first
+		for i := len(messages) - 1; i >= 0; i-- {
+			msg := messages[i]
+			b.lastMessageID = msg.ID
+			if msg.Author.Bot {
+				continue
+			}
+			if req, ok := parseBotMessage(msg.Content); ok {
+				go b.handleMessage(ctx, msg, req)
+			}
+		}
+	}
+}
+
+// sleepContext waits for d and reports false if ctx ended first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// handleMessage replies to a message and keeps editing the reply as the add progresses
+func (b *DiscordBot) handleMessage(ctx context.Context, msg DiscordMessage, req botRequest) {
+	endpoint := "/channels/" + b.channelID + "/messages"
+
+	var reply DiscordMessage
+	err := b.doRequest(ctx, http.MethodPost, endpoint, discordMessagePayload{
+		Content:          "Working on it…",
+		Embeds:           []DiscordEmbed{},
+		MessageReference: &discordMessageReference{MessageID: msg.ID},
+	}, &reply)
+	if err != nil {
+		log.Printf("Warning: could not reply on Discord: %v", err)
+		return
+	}
+
+	b.run(ctx, req, func(payload discordMessagePayload) error {
+		return b.doRequest(ctx, http.MethodPatch, endpoint+"/"+reply.ID, payload, nil)
+	})
+}
+
+// run executes a bot request and sends progress through edit, throttled to
+// discordEditInterval
+func (b *DiscordBot) run(ctx context.Context, req botRequest, edit func(discordMessagePayload) error) {
+	b.handler.runBotRequest(ctx, req, throttleUpdates(discordEditInterval, func(update botUpdate) {
+		if err := edit(discordPayload(update)); err != nil {
+			log.Printf("Warning: could not update Discord reply: %v", err)
+		}
+	}))
+}
+
+// discordPayload renders an update as message text plus the match card embed
+func discordPayload(update botUpdate) discordMessagePayload {
+	payload := discordMessagePayload{Content: truncate(botUpdateText(update), 2000), Embeds: []DiscordEmbed{}}
+	if card := update.Card; card != nil {
+		embed := DiscordEmbed{
+			Title:       card.Heading(),
+			Description: truncate(card.Overview, 350),
+			URL:         card.Link,
+			Color:       0x5865f2, // blurple while in progress
+			Footer:      &DiscordEmbedFooter{Text: map[string]string{"movie": "Radarr", "tv": "Sonarr"}[card.Type]},
+		}
+		if card.Poster != "" {
+			embed.Thumbnail = &DiscordEmbedThumbnail{URL: card.Poster}
+		}
+		if update.Done && update.Success {
+			embed.Color = 0x57f287
+		} else if update.Done {
+			embed.Color = 0xed4245
+		}
+		payload.Embeds = append(payload.Embeds, embed)
+	}
+	return payload
+}
+
+// verify checks the Ed25519 signature Discord puts on interaction requests
+func (b *DiscordBot) verify(r *http.Request, body []byte) bool {
+	signature, err := hex.DecodeString(r.Header.Get("X-Signature-Ed25519"))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return false
+	}
+	timestamp := r.Header.Get("X-Signature-Timestamp")
+	if ts, err := strconv.ParseInt(timestamp, 10, 64); err != nil || time.Since(time.Unix(ts, 0)).Abs() > 5*time.Minute {
+		return false
+	}
+	return ed25519.Verify(b.publicKey, append([]byte(timestamp), body...), signature)
+}
+
+// Interactions receives Discord slash commands. It acknowledges at once and
+// edits the deferred reply as the add progresses.
+func (b *DiscordBot) Interactions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil || b.publicKey == nil || !b.verify(r, body) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var interaction discordInteraction
+	if err := json.Unmarshal(body, &interaction); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch interaction.Type {
+	case discordInteractionPing:
+		json.NewEncoder(w).Encode(map[string]int{"type": discordCallbackPong})
+		return
+	case discordInteractionApplicationCommand:
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	name := interaction.Data.Name
+	if (name != BotCommandAddMovie && name != BotCommandAddTV) || interaction.ChannelID != b.channelID {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"type": discordCallbackMessage,
+			"data": map[string]interface{}{
+				"content": "This command only works in <#" + b.channelID + ">",
+				"flags":   64, // only visible to the user
+			},
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]int{"type": discordCallbackDeferredMessage})
+
+	// The interaction token stays valid for 15 minutes, independent of this request
+	req := newBotMediaRequest(name, interaction.option("name"), interaction.option("year"))
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+		endpoint := "/webhooks/" + b.applicationID + "/" + interaction.Token + "/messages/@original"
+		b.run(ctx, req, func(payload discordMessagePayload) error {
+			return b.doRequest(ctx, http.MethodPatch, endpoint, payload, nil)
+		})
+	}()
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// arrDownloadClients is the download client API shared by Radarr and Sonarr
+type arrDownloadClients interface {
+	GetDownloadClients(ctx context.Context) ([]ArrDownloadClient, error)
+	GetDownloadClientSchema(ctx context.Context, implementation string) (*ArrDownloadClient, error)
+	SaveDownloadClient(ctx context.Context, client ArrDownloadClient) error
+}
+
+// SelfTestCheck is the outcome of one startup/self-test check
+type SelfTestCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+	Fixed   bool   `json:"fixed,omitempty"` // the problem was corrected automatically
+}
+
+type SelfTestResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Checks  []SelfTestCheck `json:"checks"`
+}
+
+// qbDownloadClientTarget is how the *arr apps reach qBittorrent, which may
+// differ from how this service reaches it (e.g. another Docker network)
+type qbDownloadClientTarget struct {
+	Host     string
+	Port     int
+	UseSSL   bool
+	URLBase  string
+	Username string
+	Password string
+}
+
+// parseQBTarget builds the target from a qBittorrent URL like http://qbittorrent:8080/qb
+func parseQBTarget(rawURL, username, password string) (*qbDownloadClientTarget, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid qBittorrent URL %q", rawURL)
+	}
+
+	target := &qbDownloadClientTarget{
+		Host:     u.Hostname(),
+		UseSSL:   u.Scheme == "https",
+		URLBase:  strings.TrimSuffix(u.Path, "/"),
+		Username: username,
+		Password: password,
+		Port:     80,
+	}
+	if target.UseSSL {
+		target.Port = 443
+	}
+	if p := u.Port(); p != "" {
+		if target.Port, err = strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("invalid port in qBittorrent URL %q", rawURL)
+		}
+	}
+	return target, nil
+}
+
+// verifyDownloadClient checks that an *arr app has an enabled qBittorrent download
+// client using category (in categoryField), so completed downloads get imported.
+// With fix set, it enables/re-categorizes an existing qBittorrent client or creates one.
+func verifyDownloadClient(ctx context.Context, arr arrDownloadClients, service, categoryField, category string, target *qbDownloadClientTarget, fix bool) SelfTestCheck {
+	check := SelfTestCheck{Name: service + "_download_client"}
+
+	clients, err := arr.GetDownloadClients(ctx)
+	if err != nil {
+		check.Message = fmt.Sprintf("could not list %s download clients: %v", service, err)
+		return check
+	}
+
+	var candidate *ArrDownloadClient
+	for i := range clients {
+		client := &clients[i]
+		if client.Implementation != "QBittorrent" {
+			continue
+		}
+		if client.Enable && fmt.Sprint(client.Field(categoryField)) == category {
+			check.OK = true
+			check.Message = fmt.Sprintf("qBittorrent client %q uses category %q", client.Name, category)
+			return check
+		}
+		if candidate == nil {
+			candidate = client
+		}
+	}
+
+	if candidate != nil {
+		check.Message = fmt.Sprintf("qBittorrent client %q has category %q (enabled: %t), expected %q", candidate.Name, fmt.Sprint(candidate.Field(categoryField)), candidate.Enable, category)
+	} else {
+		check.Message = fmt.Sprintf("%s has no qBittorrent download client; torrents added here will not be imported", service)
+	}
+	if !fix {
+		return check
+	}
+
+	if candidate == nil {
+		if target == nil {
+			check.Message += "; cannot create one without the qBittorrent URL"
+			return check
+		}
+		schema, err := arr.GetDownloadClientSchema(ctx, "QBittorrent")
+		if err != nil {
+			check.Message += fmt.Sprintf("; could not create one: %v", err)
+			return check
+		}
+		candidate = schema
+		candidate.Name = "qBittorrent"
+		candidate.Priority = 1
+		candidate.SetField("host", target.Host)
+		candidate.SetField("port", target.Port)
+		candidate.SetField("useSsl", target.UseSSL)
+		candidate.SetField("urlBase", target.URLBase)
+		candidate.SetField("username", target.Username)
+		candidate.SetField("password", target.Password)
+	}
+	candidate.Enable = true
+	candidate.SetField(categoryField, category)
+	if candidate.Tags == nil {
+		candidate.Tags = []int{}
+	}
+
+	if err := arr.SaveDownloadClient(ctx, *candidate); err != nil {
+		check.Message += fmt.Sprintf("; fix failed: %v", err)
+		return check
+	}
+
+	check.OK = true
+	check.Fixed = true
+	check.Message = fmt.Sprintf("qBittorrent client %q now uses category %q", candidate.Name, category)
+	return check
+}
+
+// runSelfTest checks qBittorrent access, the *arr download client mappings and
+// the download path as the *arr apps see it
+func (h *TorrentHandler) runSelfTest(ctx context.Context, fix bool) []SelfTestCheck {
+	qbCheck := SelfTestCheck{Name: "qbittorrent_login", OK: true, Message: "logged in"}
+	if err := h.qbClient.Login(ctx); err != nil {
+		qbCheck.OK = false
+		qbCheck.Message = err.Error()
+	}
+
+	var target *qbDownloadClientTarget
+	if h.cfg().ArrQBittorrentURL != "" {
+		var err error
+		target, err = parseQBTarget(h.cfg().ArrQBittorrentURL, h.qbClient.username, h.qbClient.password)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	// The categories must match the ones detectCategory assigns
+	return []SelfTestCheck{
+		qbCheck,
+		verifyDownloadClient(ctx, h.radarrClient, "radarr", "movieCategory", "radarr", target, fix),
+		verifyDownloadClient(ctx, h.sonarrClient, "sonarr", "tvCategory", "sonarr", target, fix),
+		h.checkPathMapping(ctx),
+	}
+}
+
+// VerifyDownloadClients runs the self-test once at startup and logs problems
+func (h *TorrentHandler) VerifyDownloadClients(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	for _, check := range h.runSelfTest(ctx, h.cfg().DownloadClientAutoFix) {
+		if check.OK {
+			log.Printf("Self-test %s: ok - %s", check.Name, check.Message)
+		} else {
+			log.Printf("Warning: self-test %s failed - %s", check.Name, check.Message)
+		}
+	}
+}
+
+// SelfTest verifies qBittorrent access and the *arr download client mapping.
+// POST applies fixes when DOWNLOAD_CLIENT_AUTOFIX is enabled.
+func (h *TorrentHandler) SelfTest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET and POST requests
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(SelfTestResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET or POST.",
+		})
+		return
+	}
+
+	fix := r.Method == http.MethodPost && h.cfg().DownloadClientAutoFix
+	// A fix rewrites the Radarr/Sonarr download client, with the qBittorrent password
+	if key := apiKeyFromContext(r.Context()); fix && key != nil && !key.Admin {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(SelfTestResponse{
+			Success: false,
+			Message: "Only ADMIN_KEYS can fix the download client setup",
+		})
+		return
+	}
+	checks := h.runSelfTest(r.Context(), fix)
+
+	success := true
+	for _, check := range checks {
+		success = success && check.OK
+	}
+
+	message := "All checks passed"
+	status := http.StatusOK
+	if !success {
+		message = "Some checks failed"
+		status = http.StatusServiceUnavailable
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(SelfTestResponse{
+		Success: success,
+		Message: message,
+		Checks:  checks,
+	})
+}
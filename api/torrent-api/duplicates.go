@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Policies for DUPLICATE_EPISODES, when Sonarr already has the episodes of a
+// TV torrent at equal or better quality
+const (
+	DuplicatePolicyWarn         = "warn"          // add as usual with a warning
+	DuplicatePolicySkip         = "skip"          // refuse the add with DUPLICATE_EPISODE unless forced
+	DuplicatePolicyDownloadOnly = "download_only" // download without a category, so Sonarr doesn't import it
+	DuplicatePolicyReplace      = "replace"       // delete Sonarr's files once the download finishes, so it is imported in their place
+)
+
+// DuplicateEpisodes is what Sonarr already has of a TV torrent's episodes
+type DuplicateEpisodes struct {
+	Episodes []string `json:"episodes"`          // e.g. "S02E05"
+	Quality  string   `json:"quality,omitempty"` // Sonarr's quality of the lowest existing file, e.g. "WEBDL-1080p"
+	Policy   string   `json:"policy"`
+	Decision string   `json:"decision"` // "added", "skipped", "download_only" or "replace_on_finish"
+
+	fileIDs []int
+}
+
+// checksDuplicates reports whether DUPLICATE_EPISODES applies to the add: a TV
+// torrent naming its season, other than a complete series pack
+func (h *TorrentHandler) checksDuplicates(p *AddPipeline) bool {
+	if h.cfg().DuplicateEpisodes == "" || p.IsMovie || p.NonMedia != "" || p.Category == "" || p.CompleteSeries {
+		return false
+	}
+	info := parseEpisodeInfo(p.TorrentName, p.Anime)
+	return info != nil && info.Season != nil
+}
+
+// stepDuplicateCheck looks up the matched series in the Sonarr library and,
+// when every episode of the torrent already has a file at its quality or
+// better, applies DUPLICATE_EPISODES
+func (h *TorrentHandler) stepDuplicateCheck(p *AddPipeline) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		duplicate, err := h.findDuplicateEpisodes(ctx, p)
+		if err != nil || duplicate == nil {
+			return err
+		}
+
+		policy := h.cfg().DuplicateEpisodes
+		duplicate.Policy = policy
+		p.Duplicate = duplicate
+		have := fmt.Sprintf("Sonarr already has %s", strings.Join(duplicate.Episodes, ", "))
+		if duplicate.Quality != "" {
+			have += " in " + duplicate.Quality
+		}
+		switch {
+		case policy == DuplicatePolicySkip && !p.Request.Force:
+			duplicate.Decision = "skipped"
+			return newAPIError(ErrCodeDuplicateEpisode, "%s; resend with force to add anyway", have)
+		case policy == DuplicatePolicyDownloadOnly:
+			duplicate.Decision = "download_only"
+			p.Category = ""
+			p.Warnings = append(p.Warnings, have+"; downloading without a category so it is not imported")
+		case policy == DuplicatePolicyReplace:
+			duplicate.Decision = "replace_on_finish"
+			p.Warnings = append(p.Warnings, have+"; the existing files are deleted once the download finishes, so it replaces them")
+		default:
+			duplicate.Decision = "added"
+			p.Warnings = append(p.Warnings, have)
+		}
+		debugf(ctx, "Duplicate episodes %v (%s), policy %s: %s", duplicate.Episodes, duplicate.Quality, policy, duplicate.Decision)
+		return nil
+	}
+}
+
+// findDuplicateEpisodes returns the torrent's episodes Sonarr has files of at
+// the release's resolution or better, or nil when any of them is missing or
+// worse. A season pack needs every episode of the season.
+func (h *TorrentHandler) findDuplicateEpisodes(ctx context.Context, p *AddPipeline) (*DuplicateEpisodes, error) {
+	info := parseEpisodeInfo(p.TorrentName, p.Anime)
+	if p.SeriesMatch == nil || info == nil || info.Season == nil {
+		return nil, nil
+	}
+	library, err := h.sonarrClient.FindSeriesByTVDBID(ctx, p.SeriesMatch.TVDBID)
+	if err != nil || len(library) == 0 {
+		return nil, err
+	}
+	episodes, err := h.sonarrClient.GetSeasonEpisodes(ctx, library[0].ID, *info.Season)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[int]bool, len(info.Episodes))
+	for _, episode := range info.Episodes {
+		wanted[episode] = true
+	}
+	resolution := qualityResolutions[ExtractMovieInfo(p.TorrentName).Quality]
+	duplicate := &DuplicateEpisodes{}
+	lowest := 0
+	for _, episode := range episodes {
+		if !info.SeasonPack && !wanted[episode.EpisodeNumber] {
+			continue
+		}
+		if !episode.HasFile || episode.EpisodeFile == nil || episode.EpisodeFile.Quality.Quality.Resolution < resolution {
+			return nil, nil
+		}
+		delete(wanted, episode.EpisodeNumber)
+		duplicate.Episodes = append(duplicate.Episodes, fmt.Sprintf("S%02dE%02d", episode.SeasonNumber, episode.EpisodeNumber))
+		duplicate.fileIDs = append(duplicate.fileIDs, episode.EpisodeFile.ID)
+		if quality := episode.EpisodeFile.Quality.Quality; lowest == 0 || quality.Resolution < lowest {
+			lowest, duplicate.Quality = quality.Resolution, quality.Name
+		}
+	}
+	// Episodes Sonarr doesn't know yet are new
+	if len(wanted) > 0 || len(duplicate.Episodes) == 0 {
+		return nil, nil
+	}
+	return duplicate, nil
+}
+
+// replacedEpisodeFiles returns the Sonarr files a replace add deletes once its
+// download finishes, each once even when a multi-episode file covers several
+func replacedEpisodeFiles(p *AddPipeline) []int {
+	if p.Duplicate == nil || p.Duplicate.Decision != "replace_on_finish" {
+		return nil
+	}
+	var ids []int
+	seen := make(map[int]bool)
+	for _, id := range p.Duplicate.fileIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// replaceEpisodeFiles deletes the Sonarr files a finished download replaces, so
+// Sonarr imports it instead of rejecting it as no upgrade. Deleting only once the
+// download is complete keeps the old files when the torrent dies or the add is
+// undone. It returns the files it could not delete, to try again on the next run.
+func (h *TorrentHandler) replaceEpisodeFiles(ctx context.Context, record HistoryRecord) []int {
+	var failed []int
+	for _, id := range record.ReplaceEpisodeFiles {
+		if err := h.sonarrClient.DeleteEpisodeFile(ctx, id); err != nil {
+			log.Printf("Warning: could not delete episode file %d replaced by %s: %v", id, record.Name, err)
+			failed = append(failed, id)
+		}
+	}
+	if len(failed) < len(record.ReplaceEpisodeFiles) {
+		log.Printf("Deleted %d episode files replaced by %s", len(record.ReplaceEpisodeFiles)-len(failed), record.Name)
+	}
+	return failed
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// envSettings are the plain settings shown in the startup summary; SCHEDULE_*
+// overrides are shown too
+var envSettings = []string{
+	"PORT", "USER_AGENT", "LISTEN_ADDRS", "LISTEN_REUSEPORT", "LISTEN_SOCKET_MODE", "BASE_PATH", "TRUSTED_PROXIES", "CONFIG_DIR", "CONFIG_RELOAD_INTERVAL",
+	"ADMIN_KEYS", "KEY_MEDIA_TYPES", "PROXY_AUTH_HEADER", "PROXY_AUTH_USERS", "REQUESTER_TAGS", "REQUESTER_TAG_PREFIX",
+	"MAINTENANCE_MODE", "MAINTENANCE_REASON", "DISABLED_API_KEYS",
+	"QBITTORRENT_URL", "QBITTORRENT_SID_FILE", "ARR_QBITTORRENT_URL", "DOWNLOAD_CLIENT_AUTOFIX", "PATH_MAPPINGS",
+	"RADARR_URL", "RADARR_ROOT_FOLDER", "RADARR_QUALITY_PROFILE",
+	"SONARR_URL", "SONARR_ROOT_FOLDER", "SONARR_QUALITY_PROFILE",
+	"SONARR_MONITOR_AIRING", "SONARR_MONITOR_ENDED", "SONARR_PATH_CONFLICT", "DUPLICATE_EPISODES",
+	"ARR_CACHE_FILE", "SERIES_CHOICES_FILE", "STRICT_LIBRARY_ADD", "UNDO_WINDOW", "SEARCH_PACE", "MEDIA_ADD_CACHE_TTL", "SOFT_FAILURE_WINDOW", "SOFT_FAILURE_WARN", "PREFERRED_LANGUAGE",
+	"NAME_EXTRACTOR_URL", "NAME_EXTRACTOR_HEDGE_DELAY", "DEGRADATION",
+	"ADD_ORDER", "ADD_CONCURRENCY", "INDEXER_SEARCH", "NON_MEDIA_POLICY", "NON_MEDIA_CATEGORY", "DETECT_DEFAULT", "DETECT_MIN_TV_SCORE", "DETECT_MIN_MOVIE_SCORE", "COMPLETE_SERIES_CATEGORY",
+	"FILE_CHECK_WAIT", "HEALTH_CHECK", "HEALTH_MIN_SEEDERS", "SEEDING_POLICIES",
+	"NZB_FALLBACK", "PROWLARR_URL", "PREVIOUS_FAILURE_REQUIRE_FORCE",
+	"TAUTULLI_URL", "WATCHED_REQUIRE_CONFIRM",
+	"HISTORY_FILE", "HISTORY_MAX_RECORDS", "RECONCILE_REMOVE_TORRENTS", "LIBRARY_STATS_TTL", "RSS_FEEDS", "WATCH_DIR", "SCHEDULE_TIMEZONE",
+	"NOTIFY_WEBHOOK_URL", "DISAGREEMENT_WEBHOOK_URL",
+	"SITE_LIST_URL", "SITE_LIST_PUBLIC_KEY", "SITE_LIST_CACHE",
+	"DISCORD_APPLICATION_ID", "DISCORD_PUBLIC_KEY", "DISCORD_GUILD_ID", "DISCORD_CHANNEL_ID", "TELEGRAM_CHAT_IDS",
+	"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_SERVICE_NAME",
+	"SECRETS_AGE_FILE", "SECRETS_AGE_IDENTITY_FILE", "VAULT_ADDR", "VAULT_SECRET_PATH",
+}
+
+// envSecrets are resolved through the SecretStore and only shown as set or not
+var envSecrets = []string{
+	"API_KEYS", "QBITTORRENT_USERNAME", "QBITTORRENT_PASSWORD", "QBITTORRENT_SID",
+	"RADARR_API_KEY", "SONARR_API_KEY", "PROWLARR_API_KEY", "TAUTULLI_API_KEY",
+	"DISCORD_BOT_TOKEN", "TELEGRAM_BOT_TOKEN", "VAULT_TOKEN",
+}
+
+// envURLs must be absolute http(s) URLs when set
+var envURLs = []string{
+	"QBITTORRENT_URL", "ARR_QBITTORRENT_URL", "RADARR_URL", "SONARR_URL", "NAME_EXTRACTOR_URL",
+	"PROWLARR_URL", "TAUTULLI_URL", "NOTIFY_WEBHOOK_URL", "DISAGREEMENT_WEBHOOK_URL", "SITE_LIST_URL",
+	"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "VAULT_ADDR",
+}
+
+// envTokenURLs carry their token in the URL, as Discord and Slack webhooks do in
+// the path; only their host is shown and they are masked in the logs
+var envTokenURLs = []string{"NOTIFY_WEBHOOK_URL", "DISAGREEMENT_WEBHOOK_URL"}
+
+// envDefaults are shown for settings left unset whose default is worth knowing
+var envDefaults = map[string]string{
+	"PORT":                "8080",
+	"USER_AGENT":          userAgent,
+	"NAME_EXTRACTOR_URL":  "http://localhost:8000",
+	"HISTORY_MAX_RECORDS": "10000",
+}
+
+// normalizeEnv trims whitespace around known settings, which a copied .env line
+// easily carries and which otherwise surfaces as a bad URL or a failed login
+// deep inside a request
+func normalizeEnv() {
+	names := append(append([]string{}, envSettings...), envSecrets...)
+	for _, name := range append(names, scheduleSettings()...) {
+		for _, name := range []string{name, name + "_FILE"} {
+			value, ok := os.LookupEnv(name)
+			if !ok || strings.TrimSpace(value) == value {
+				continue
+			}
+			log.Printf("Warning: %s has leading or trailing whitespace; ignoring it", name)
+			os.Setenv(name, strings.TrimSpace(value))
+		}
+	}
+}
+
+// scheduleSettings returns the SCHEDULE_* worker schedule overrides that are set
+func scheduleSettings() []string {
+	var names []string
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(name, "SCHEDULE_") && name != "SCHEDULE_TIMEZONE" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkEnvConfig validates the URLs and the settings that only work together,
+// returning every problem at once. Webhook URLs are masked in the logs from here on.
+func checkEnvConfig(secrets *SecretStore) error {
+	for _, name := range envTokenURLs {
+		secretRedactor.Add(os.Getenv(name))
+	}
+
+	var errs []error
+	for _, name := range envURLs {
+		if err := checkURLSetting(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	has := func(name string) bool {
+		value, err := secrets.Lookup(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		return value != ""
+	}
+	set := func(name string) bool {
+		return os.Getenv(name) != ""
+	}
+
+	if !set("QBITTORRENT_URL") {
+		errs = append(errs, errors.New("QBITTORRENT_URL is required"))
+	}
+	if !has("QBITTORRENT_SID") && !set("QBITTORRENT_SID_FILE") && (!has("QBITTORRENT_USERNAME") || !has("QBITTORRENT_PASSWORD")) {
+		log.Printf("Warning: QBITTORRENT_USERNAME/QBITTORRENT_PASSWORD are not set; qBittorrent logins only work if it bypasses auth for this host")
+	}
+	for _, app := range []string{"RADARR", "SONARR", "TAUTULLI", "PROWLARR"} {
+		urlSet, keySet := set(app+"_URL"), has(app+"_API_KEY")
+		switch {
+		case urlSet && !keySet:
+			errs = append(errs, fmt.Errorf("%s_URL is set without %s_API_KEY", app, app))
+		case keySet && !urlSet:
+			log.Printf("Warning: %s_API_KEY is set without %s_URL; it is unused", app, app)
+		}
+	}
+	if os.Getenv("NZB_FALLBACK") == "true" && !set("PROWLARR_URL") {
+		errs = append(errs, errors.New("NZB_FALLBACK needs PROWLARR_URL"))
+	}
+	if set("SECRETS_AGE_FILE") != set("SECRETS_AGE_IDENTITY_FILE") {
+		errs = append(errs, errors.New("SECRETS_AGE_FILE and SECRETS_AGE_IDENTITY_FILE must be set together"))
+	}
+	if set("DISCORD_APPLICATION_ID") && !has("DISCORD_BOT_TOKEN") {
+		log.Printf("Warning: DISCORD_APPLICATION_ID is set without DISCORD_BOT_TOKEN; the Discord bot is off")
+	}
+	if set("TELEGRAM_CHAT_IDS") && !has("TELEGRAM_BOT_TOKEN") {
+		log.Printf("Warning: TELEGRAM_CHAT_IDS is set without TELEGRAM_BOT_TOKEN; the Telegram bot is off")
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkURLSetting requires an http(s) scheme and a host if the setting is set
+func checkURLSetting(name string) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("%s is not a valid URL: %w", name, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%s must start with http:// or https://, got %q", name, u.Redacted())
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%s has no host: %q", name, u.Redacted())
+	}
+	return nil
+}
+
+// logEffectiveConfig logs the settings in use, with secrets, passwords in URLs
+// and webhook URLs masked
+func logEffectiveConfig(secrets *SecretStore) {
+	tokenURLs := make(map[string]bool)
+	for _, name := range envTokenURLs {
+		tokenURLs[name] = true
+	}
+
+	var lines []string
+	for _, name := range append(append([]string{}, envSettings...), scheduleSettings()...) {
+		value := os.Getenv(name)
+		if value == "" {
+			def, ok := envDefaults[name]
+			if !ok {
+				continue
+			}
+			value = def + " (default)"
+		} else if tokenURLs[name] {
+			value = "****"
+			if u, err := url.Parse(os.Getenv(name)); err == nil && u.Host != "" {
+				value = u.Scheme + "://" + u.Host + "/****"
+			}
+		} else if u, err := url.Parse(value); err == nil && u.User != nil {
+			value = u.Redacted()
+		}
+		lines = append(lines, fmt.Sprintf("  %s=%s", name, value))
+	}
+	for _, name := range envSecrets {
+		if value, _ := secrets.Lookup(name); value != "" {
+			lines = append(lines, fmt.Sprintf("  %s=****", name))
+		}
+	}
+	log.Printf("Effective configuration:\n%s", strings.Join(lines, "\n"))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error codes returned to API callers alongside the human-readable message
+const (
+	ErrCodeRootFolderInaccessible = "ROOT_FOLDER_INACCESSIBLE"
+	ErrCodeNonMediaRejected       = "NON_MEDIA_REJECTED"
+	ErrCodeAlreadyWatched         = "ALREADY_WATCHED"
+	ErrCodeContentRatingBlocked   = "CONTENT_RATING_BLOCKED"
+	ErrCodeLibraryAddFailed       = "LIBRARY_ADD_FAILED"
+	ErrCodeNoLibraryMatch         = "NO_LIBRARY_MATCH"
+	ErrCodePathConflict           = "PATH_CONFLICT"
+	ErrCodeDependencyUnavailable  = "DEPENDENCY_UNAVAILABLE"
+	ErrCodeNoSeeders              = "TORRENT_NO_SEEDERS"
+	ErrCodePreviouslyFailed       = "PREVIOUSLY_FAILED"
+	ErrCodeMaintenance            = "MAINTENANCE_MODE"
+	ErrCodeKeyDisabled            = "API_KEY_DISABLED"
+	ErrCodeMediaTypeNotAllowed    = "MEDIA_TYPE_NOT_ALLOWED"
+	ErrCodeAddInterrupted         = "ADD_INTERRUPTED"
+	ErrCodeAuthExpired            = "AUTH_EXPIRED"
+	ErrCodeCrossLibraryDuplicate  = "CROSS_LIBRARY_DUPLICATE"
+	ErrCodePreviewNotFound        = "PREVIEW_NOT_FOUND"
+	ErrCodeMediaTypeUndetermined  = "MEDIA_TYPE_UNDETERMINED"
+	ErrCodeDuplicateEpisode       = "DUPLICATE_EPISODE"
+)
+
+// APIError is an error with a stable code the extension can act on
+type APIError struct {
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+func newAPIError(code, format string, args ...interface{}) *APIError {
+	return &APIError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// errorCode returns the APIError code wrapped in err, or "" if there is none
+func errorCode(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
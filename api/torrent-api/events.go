@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Event types on GET /api/events
+const (
+	EventTorrentAdded      = "torrent_added"
+	EventDownloadCompleted = "download_completed" // qBittorrent finished the torrent
+	EventImportCompleted   = "import_completed"   // Radarr/Sonarr imported it (webhook)
+	EventImportFailed      = "import_failed"      // the import needs manual interaction (webhook)
+)
+
+// Events kept for clients that reconnect with Last-Event-ID
+const eventStreamBacklog = 200
+
+// Event is one add or download event. Events of an add are linked by hash and
+// history_id.
+type Event struct {
+	ID         int64     `json:"id"`
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	Hash       string    `json:"hash,omitempty"` // hex info hash
+	Name       string    `json:"name,omitempty"` // torrent name
+	MediaType  string    `json:"media_type,omitempty"`
+	MediaTitle string    `json:"media_title,omitempty"`
+	HistoryID  int64     `json:"history_id,omitempty"`
+	Message    string    `json:"message,omitempty"`
+
+	apiKey string // name of the key that made the add
+}
+
+// EventStream numbers events and fans them out to subscribers; a slow
+// subscriber misses events rather than blocking the add
+type EventStream struct {
+	mu          sync.Mutex
+	nextID      int64
+	backlog     []Event
+	subscribers map[chan Event]struct{}
+}
+
+func NewEventStream() *EventStream {
+	return &EventStream{nextID: 1, subscribers: make(map[chan Event]struct{})}
+}
+
+func (s *EventStream) Publish(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event.ID, event.Time = s.nextID, time.Now()
+	s.nextID++
+	s.backlog = append(s.backlog, event)
+	if len(s.backlog) > eventStreamBacklog {
+		s.backlog = s.backlog[len(s.backlog)-eventStreamBacklog:]
+	}
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Subscribe returns the kept events after afterID and a channel of new ones;
+// call the returned func to stop
+func (s *EventStream) Subscribe(afterID int64) ([]Event, <-chan Event, func()) {
+	ch := make(chan Event, logSubscriberBuffer)
+	s.mu.Lock()
+	var missed []Event
+	for _, event := range s.backlog {
+		if event.ID > afterID {
+			missed = append(missed, event)
+		}
+	}
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	return missed, ch, func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}
+}
+
+// Listening reports whether any client is subscribed
+func (s *EventStream) Listening() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subscribers) > 0
+}
+
+// publishRecordEvent publishes an event about a history record's torrent
+func (h *TorrentHandler) publishRecordEvent(eventType string, record HistoryRecord, message string) {
+	hash := ""
+	if record.InfoHash != "" {
+		hash = infoHashHex("magnet:?xt=urn:btih:" + record.InfoHash)
+	}
+	h.events.Publish(Event{
+		Type:       eventType,
+		Hash:       hash,
+		Name:       record.Name,
+		MediaType:  record.MediaType,
+		MediaTitle: record.MediaTitle,
+		HistoryID:  record.ID,
+		Message:    message,
+		apiKey:     record.APIKey,
+	})
+}
+
+// publishAdded publishes a successful torrent add
+func (h *TorrentHandler) publishAdded(p *AddPipeline) {
+	mediaType := p.mediaKind()
+	if mediaType != "movie" && mediaType != "tv" {
+		mediaType = ""
+	}
+	h.events.Publish(Event{
+		Type:       EventTorrentAdded,
+		Hash:       infoHashHex(p.Request.MagnetLink),
+		Name:       p.TorrentName,
+		MediaType:  mediaType,
+		MediaTitle: p.MediaTitle,
+		HistoryID:  p.HistoryID,
+		apiKey:     p.APIKey,
+	})
+}
+
+// WatchDownloads checks added torrents for completion while a client listens
+// to /api/events or a replace add waits to delete Sonarr's files, which the
+// half-hourly trackerstats run is too slow for
+func (h *TorrentHandler) WatchDownloads(ctx context.Context) error {
+	if !h.events.Listening() && !h.replacesPending() {
+		return nil
+	}
+	return h.TrackTorrentProgress(ctx)
+}
+
+// replacesPending reports whether an active add still has Sonarr files to replace
+func (h *TorrentHandler) replacesPending() bool {
+	if h.history == nil {
+		return false
+	}
+	for _, record := range h.history.List() {
+		if record.Active() && len(record.ReplaceEpisodeFiles) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Events streams add, download and import events as server-sent events, so the
+// extension updates without polling. A reconnecting client gets the events it
+// missed after Last-Event-ID (or ?after=). Keys that aren't admins only get
+// events of their own adds.
+func (h *TorrentHandler) Events(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+
+	var afterID int64
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = r.URL.Query().Get("after")
+	}
+	if last != "" {
+		id, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || id < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{
+				Success: false,
+				Message: "Last-Event-ID must be a non-negative number",
+			})
+			return
+		}
+		afterID = id
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Success: false,
+			Message: "Streaming is not supported",
+		})
+		return
+	}
+
+	// Without Last-Event-ID only new events are sent
+	missed, events, unsubscribe := h.events.Subscribe(afterID)
+	defer unsubscribe()
+	if last == "" {
+		missed = nil
+	}
+	key := apiKeyFromContext(r.Context())
+	visible := func(event Event) bool {
+		return key == nil || key.Admin || event.apiKey == key.Name
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx would hold events back
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range missed {
+		if visible(event) {
+			writeEvent(w, event)
+		}
+	}
+	flusher.Flush()
+
+	// Keeps proxies from closing an idle stream
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case event := <-events:
+			if !visible(event) {
+				continue
+			}
+			writeEvent(w, event)
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event Event) {
+	data, _ := json.Marshal(event)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
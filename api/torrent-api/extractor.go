@@ -1,18 +1,24 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
 type NameExtractorClient struct {
 	baseURL    string
 	httpClient *http.Client
+	hedgeDelay time.Duration // initial delay before the local fallback fires; 0 disables hedging
+	latency    *latencyTracker
 }
 
 type ExtractedMedia struct {
@@ -20,22 +26,128 @@ type ExtractedMedia struct {
 	ExtractedName string `json:"extracted_name"`
 	Year          string `json:"year"`
 	MediaType     string `json:"media_type"`
+	Source        string `json:"source,omitempty"` // "extractor" or "local"
+
+	fallback string // why a hedged call used local extraction: a soft failure kind
 }
 
-func NewNameExtractorClient(baseURL string) *NameExtractorClient {
+// Bounds for the adaptive hedge delay
+const (
+	minHedgeDelay     = 300 * time.Millisecond
+	maxHedgeDelay     = 5 * time.Second
+	minLatencySamples = 20
+)
+
+func NewNameExtractorClient(baseURL string, hedgeDelay time.Duration) *NameExtractorClient {
 	return &NameExtractorClient{
 		baseURL: strings.TrimSuffix(baseURL, "/"),
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout:   10 * time.Second,
+			Transport: newTracingTransport(),
 		},
+		hedgeDelay: hedgeDelay,
+		latency:    newLatencyTracker(100),
 	}
 }
 
+// HedgeDelay returns how long to wait for the extractor before using the local fallback.
+// Once enough samples exist it follows the p90 extractor latency.
+func (c *NameExtractorClient) HedgeDelay() time.Duration {
+	if c.latency.Count() < minLatencySamples {
+		return c.hedgeDelay
+	}
+
+	delay := c.latency.Percentile(90)
+	if delay < minHedgeDelay {
+		delay = minHedgeDelay
+	}
+	if delay > maxHedgeDelay {
+		delay = maxHedgeDelay
+	}
+	return delay
+}
+
+type extractResult struct {
+	media *ExtractedMedia
+	err   error
+}
+
+// ExtractNameHedged calls the extractor but falls back to local extraction when
+// it is slower than the hedge delay or fails, whichever happens first. anime
+// is the source hint the local rules use.
+func (c *NameExtractorClient) ExtractNameHedged(ctx context.Context, torrentName string, anime bool) (*ExtractedMedia, error) {
+	if c.hedgeDelay <= 0 {
+		return c.ExtractName(ctx, torrentName)
+	}
+
+	// The remote call outlives a hedged win so its latency is still recorded
+	remote := make(chan extractResult, 1)
+	go func() {
+		media, err := c.ExtractName(context.WithoutCancel(ctx), torrentName)
+		remote <- extractResult{media, err}
+	}()
+
+	delay := c.HedgeDelay()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var fallback string
+	select {
+	case res := <-remote:
+		if res.err == nil {
+			return res.media, nil
+		}
+		log.Printf("Extractor failed, using local extraction: %v", res.err)
+		fallback = softFailureKind(res.err)
+	case <-timer.C:
+		log.Printf("Extractor slower than %s, using local extraction", delay)
+		fallback = SoftFailureTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	media := localExtractName(torrentName, anime)
+	media.fallback = fallback
+	return media, nil
+}
+
+// localExtractName derives the media name, year and type with the built-in
+// rules. Names without clear indicators are cleaned as movies but get no type,
+// leaving the category to DETECT_DEFAULT. Anime sources count toward TV, as
+// in detection.
+func localExtractName(torrentName string, anime bool) *ExtractedMedia {
+	media := &ExtractedMedia{
+		OriginalInput: torrentName,
+		Source:        "local",
+	}
+
+	decision := explainCategory(extractNameFromMagnet("magnet:?dn="+url.QueryEscape(torrentName)), anime)
+	if decision.Category == "sonarr" {
+		media.ExtractedName = cleanSeriesName(torrentName)
+		media.MediaType = "tv"
+		return media
+	}
+
+	info := ExtractMovieInfo(torrentName)
+	media.ExtractedName = strings.TrimSpace(strings.TrimSuffix(info.Title, info.Year))
+	media.Year = info.Year
+	if !decision.Defaulted {
+		media.MediaType = "movie"
+	}
+	return media
+}
+
 // ExtractName calls the external API to extract movie/series name from torrent name
-func (c *NameExtractorClient) ExtractName(torrentName string) (*ExtractedMedia, error) {
+func (c *NameExtractorClient) ExtractName(ctx context.Context, torrentName string) (*ExtractedMedia, error) {
+	start := time.Now()
 	endpoint := fmt.Sprintf("%s/extract?q=%s", c.baseURL, url.QueryEscape(torrentName))
 
-	resp, err := c.httpClient.Get(endpoint)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to call name extractor API: %w", err)
 	}
@@ -56,5 +168,81 @@ func (c *NameExtractorClient) ExtractName(torrentName string) (*ExtractedMedia,
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
+	c.latency.Record(time.Since(start))
+	result.Source = "extractor"
 	return &result, nil
 }
+
+// Ping checks the extractor answers an extraction, without counting its latency
+// toward the hedge delay
+func (c *NameExtractorClient) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/extract?q=ping", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call name extractor API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("name extractor API error: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// latencyTracker keeps a fixed window of recent durations
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	full    bool
+}
+
+func newLatencyTracker(size int) *latencyTracker {
+	return &latencyTracker{samples: make([]time.Duration, size)}
+}
+
+func (t *latencyTracker) Record(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.samples[t.next] = d
+	t.next = (t.next + 1) % len(t.samples)
+	if t.next == 0 {
+		t.full = true
+	}
+}
+
+func (t *latencyTracker) Count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.full {
+		return len(t.samples)
+	}
+	return t.next
+}
+
+// Percentile returns the p-th percentile (0-100) of the window, or 0 if empty
+func (t *latencyTracker) Percentile(p int) time.Duration {
+	t.mu.Lock()
+	n := t.next
+	if t.full {
+		n = len(t.samples)
+	}
+	sorted := make([]time.Duration, n)
+	copy(sorted, t.samples[:n])
+	t.mu.Unlock()
+
+	if n == 0 {
+		return 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := (p*n+99)/100 - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A file check that outlives the add keeps waiting for metadata this long
+const backgroundFileCheckTimeout = 10 * time.Minute
+
+var (
+	videoFilePattern  = regexp.MustCompile(`(?i)\.(mkv|mp4|m4v|avi|wmv|mpe?g|ts|m2ts|divx|mov)$`)
+	sampleFilePattern = regexp.MustCompile(`(?i)(^|[/. _\-\[(])sample([/. _\-\])]|$)`)
+	// CD1/CD2, Disc 1, Part.2 ... at the end of a split movie's file name
+	partFilePattern = regexp.MustCompile(`(?i)(?:^|[ ._\-\[(])(?:cd|disc|disk|dvd|part|pt)[ ._-]?([1-9])(?:[ ._\-\])]|$)`)
+)
+
+// FileCheck is what the torrent's file list showed once its metadata arrived
+type FileCheck struct {
+	Files   int      `json:"files"`
+	Samples []string `json:"samples,omitempty"` // set to "do not download"
+	Parts   []string `json:"parts,omitempty"`   // files of a CD1/CD2 style split movie
+	// Largest video that isn't a sample, and whether its name was matched instead of the torrent's
+	MainFile     string `json:"main_file,omitempty"`
+	UsedForMatch bool   `json:"used_for_match,omitempty"`
+}
+
+// infoHashHex returns the magnet's info hash in the hex form qBittorrent uses
+func infoHashHex(magnetLink string) string {
+	hash, err := infoHashBytes(magnetLink)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(hash)
+}
+
+// classifyFiles finds the main video, sample videos (named sample and much
+// smaller than the main video) and, among the other videos, the parts of a
+// split movie
+func classifyFiles(files []QBFile) (main string, samples, parts []QBFile) {
+	var largest int64
+	for _, file := range files {
+		if videoFilePattern.MatchString(file.Name) && file.Size > largest {
+			main, largest = file.Name, file.Size
+		}
+	}
+
+	numbers := make(map[string]bool)
+	for _, file := range files {
+		if !videoFilePattern.MatchString(file.Name) {
+			continue
+		}
+		if sampleFilePattern.MatchString(file.Name) && file.Size < largest/4 {
+			samples = append(samples, file)
+			continue
+		}
+		if m := partFilePattern.FindStringSubmatch(path.Base(file.Name)); m != nil {
+			numbers[m[1]] = true
+			parts = append(parts, file)
+		}
+	}
+	// A single "Part 2" is a title, not a split
+	if len(numbers) < 2 {
+		parts = nil
+	}
+	return main, samples, parts
+}
+
+// checkFiles waits for the torrent's file list, polling every interval until ctx is
+// done, then skips sample files and reports split movies
+func (h *TorrentHandler) checkFiles(ctx context.Context, hash string, isMovie bool, interval time.Duration) (*FileCheck, error) {
+	var files []QBFile
+	for {
+		// qBittorrent answers 404 until it has registered a just-added torrent
+		var err error
+		if files, err = h.qbClient.GetFiles(ctx, hash); err == nil && len(files) > 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("metadata not received: %w", ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+
+	check := &FileCheck{Files: len(files)}
+	main, samples, parts := classifyFiles(files)
+	check.MainFile = main
+	if len(samples) > 0 {
+		indexes := make([]int, len(samples))
+		for i, file := range samples {
+			indexes[i] = file.Index
+			check.Samples = append(check.Samples, file.Name)
+		}
+		if err := h.qbClient.SetFilePriority(ctx, hash, indexes, 0); err != nil {
+			return check, err
+		}
+		log.Printf("Skipping %d sample files of %s", len(samples), hash)
+	}
+	if isMovie {
+		for _, file := range parts {
+			check.Parts = append(check.Parts, file.Name)
+		}
+		sort.Strings(check.Parts)
+	}
+	return check, nil
+}
+
+// splitWarning explains why a split movie may need a manual import
+func splitWarning(check *FileCheck) string {
+	if len(check.Parts) == 0 {
+		return ""
+	}
+	names := make([]string, len(check.Parts))
+	for i, name := range check.Parts {
+		names[i] = path.Base(name)
+	}
+	return fmt.Sprintf("Release is split into %d parts (%s); Radarr may not import it without joining them", len(names), strings.Join(names, ", "))
+}
+
+func (h *TorrentHandler) stepFileCheck(p *AddPipeline, hash string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		wait := h.cfg().FileCheckWait
+		waitCtx, cancel := context.WithTimeout(ctx, wait)
+		defer cancel()
+
+		check, err := h.checkFiles(waitCtx, hash, p.IsMovie, time.Second)
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			// Slow metadata shouldn't hold up the add; finish the check on our own
+			go h.checkFilesLater(hash, p.TorrentName, p.IsMovie)
+			return fmt.Errorf("metadata not received within %s, checking in the background", wait)
+		}
+		p.Files = check
+		if err != nil {
+			return err
+		}
+		if warning := splitWarning(check); warning != "" {
+			p.Warnings = append(p.Warnings, warning)
+		}
+		return nil
+	}
+}
+
+// stepFileExtract extracts the media name from the main video file's name and
+// uses it for the match when it says more than the torrent name
+func (h *TorrentHandler) stepFileExtract(p *AddPipeline) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		name := path.Base(p.Files.MainFile)
+		name = strings.TrimSuffix(name, path.Ext(name))
+		// Obfuscated names ("a1b2c3d4e5.mkv") and the torrent's own name add nothing
+		if !strings.ContainsAny(name, " ._-") || normalizeTitle(name) == normalizeTitle(p.TorrentName) {
+			return nil
+		}
+
+		extractedMedia, err := h.extractName(ctx, name, p.Anime)
+		if err != nil {
+			return err
+		}
+		if !preferFileExtraction(p.TorrentName, name, p.Extracted, extractedMedia) {
+			debugf(ctx, "Kept the torrent name's extraction over %q (%s) from file %s", extractedMedia.ExtractedName, extractedMedia.Year, p.Files.MainFile)
+			return nil
+		}
+
+		log.Printf("Extracted media from file %s: %s (%s)", name, extractedMedia.ExtractedName, extractedMedia.Year)
+		debugf(ctx, "Extracted %q (%s) from file %s instead of the torrent name", extractedMedia.ExtractedName, extractedMedia.Year, p.Files.MainFile)
+		p.Extracted = extractedMedia
+		p.Files.UsedForMatch = true
+		return nil
+	}
+}
+
+// preferFileExtraction reports whether the file name's extraction should be
+// matched instead of the torrent name's: it must have found a title and add
+// something the torrent name lacks, a year or an SxxEyy episode. Otherwise a
+// season pack would be matched by its first episode's title.
+func preferFileExtraction(torrentName, fileName string, fromName, fromFile *ExtractedMedia) bool {
+	if fromFile == nil || strings.TrimSpace(fromFile.ExtractedName) == "" {
+		return false
+	}
+	if fromName == nil || strings.TrimSpace(fromName.ExtractedName) == "" {
+		return true
+	}
+	if fromFile.Year != "" && fromName.Year == "" {
+		return true
+	}
+	return seasonEpisodePattern.MatchString(fileName) && !seasonEpisodePattern.MatchString(torrentName)
+}
+
+// checkFilesLater finishes a file check after the add returned; findings are only logged
+func (h *TorrentHandler) checkFilesLater(hash, name string, isMovie bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), backgroundFileCheckTimeout)
+	defer cancel()
+
+	check, err := h.checkFiles(ctx, hash, isMovie, 5*time.Second)
+	if err != nil {
+		log.Printf("Warning: could not check files of %s: %v", name, err)
+		return
+	}
+	if warning := splitWarning(check); warning != "" {
+		log.Printf("Warning: %s: %s", name, warning)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+// Each case extracts both names locally and checks which extraction the add
+// matches, and the title it gets when the file's wins
+func TestPreferFileExtraction(t *testing.T) {
+	tests := []struct {
+		torrent, file string
+		wantFile      bool
+		wantTitle     string // of the file, when it is used
+	}{
+		// Generic pack names: the file adds the year
+		{"Top.Movies.Vol.3", "The.Matrix.1999.1080p.BluRay.x264", true, "The Matrix"},
+		{"www.Site.org - Collection", "Heat (1995) 720p", true, "Heat"},
+		// Season packs: an episode title adds nothing
+		{"Show.S01.1080p.WEB-DL", "01 - Pilot", false, ""},
+		{"Show.Season.2.720p", "Episode Two", false, ""},
+		// The file adds the episode
+		{"Show.Complete.1080p", "Show.S01E01.1080p", true, "Show"},
+		// The torrent name already has the year
+		{"Inception.2010.1080p.BluRay", "inception-sample-cut", false, ""},
+		{"Inception.2010.1080p.BluRay", "Inception.2010.1080p", false, ""},
+		// Neither has a year: keep the torrent name
+		{"Some.Movie.1080p.WEB", "some.other.title", false, ""},
+	}
+	for _, tt := range tests {
+		fromName, fromFile := localExtractName(tt.torrent, false), localExtractName(tt.file, false)
+		got := preferFileExtraction(tt.torrent, tt.file, fromName, fromFile)
+		if got != tt.wantFile {
+			t.Errorf("%q / %q: preferred file = %v, want %v (torrent %q %s, file %q %s)",
+				tt.torrent, tt.file, got, tt.wantFile,
+				fromName.ExtractedName, fromName.Year, fromFile.ExtractedName, fromFile.Year)
+			continue
+		}
+		if got && fromFile.ExtractedName != tt.wantTitle {
+			t.Errorf("%q / %q: matched %q, want %q", tt.torrent, tt.file, fromFile.ExtractedName, tt.wantTitle)
+		}
+	}
+}
+
+func TestPreferFileExtractionWithoutTitles(t *testing.T) {
+	file := &ExtractedMedia{ExtractedName: "Heat", Year: "1995"}
+	if !preferFileExtraction("x", "Heat 1995", nil, file) {
+		t.Error("a file title should be used when the torrent name gave none")
+	}
+	if !preferFileExtraction("x", "Heat 1995", &ExtractedMedia{}, file) {
+		t.Error("a file title should be used when the torrent name's title is empty")
+	}
+	if preferFileExtraction("Heat 1995", "x", file, &ExtractedMedia{Year: "1995"}) {
+		t.Error("a file extraction without a title should never be used")
+	}
+}
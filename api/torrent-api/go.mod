@@ -2,4 +2,10 @@ module torrent-api
 
 go 1.21
 
-require github.com/joho/godotenv v1.5.1
+require (
+	filippo.io/age v1.2.0
+	github.com/joho/godotenv v1.5.1
+	golang.org/x/sys v0.21.0
+)
+
+require golang.org/x/crypto v0.24.0 // indirect
@@ -1,54 +1,288 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// HandlerConfig holds deployment settings that change handler behaviour
+type HandlerConfig struct {
+	IndexerSearch    bool   // Allow searching the *arr indexers for releases
+	NonMediaPolicy   string // "category", "reject" or "download_only" for games/software/books
+	NonMediaCategory string // qBittorrent category for the "category" policy; "" uses the kind
+	Detector         DetectorSettings
+	// Refuse AddMedia for already watched titles unless the request sets confirm
+	WatchedRequireConfirm bool
+	// Sonarr monitor option for new series that are still airing / have ended
+	MonitorAiring string
+	MonitorEnded  string
+	// qBittorrent URL as seen from Radarr/Sonarr, used to create their download client
+	ArrQBittorrentURL string
+	// Create/update the *arr qBittorrent download client when the self-test finds it missing
+	DownloadClientAutoFix bool
+	// Remove the torrent from qBittorrent when the library add fails (per-request "strict" overrides)
+	StrictLibraryAdd bool
+	// "torrent_first" adds to qBittorrent before Radarr/Sonarr; "library_first" matches
+	// and adds to the library first, so a failed match never starts a download
+	AddOrder string
+	// What an add does when the extractor, Radarr, Sonarr, Tautulli or qBittorrent is down
+	Degradation DegradationPolicies
+	// Remove the torrent when reconcile finds its library item deleted upstream
+	ReconcileRemoveTorrents bool
+	// qBittorrent share limits by tracker
+	Seeding SeedingPolicies
+	// Scrape trackers before adding: "" (off), "warn" or "reject" below HealthMinSeeders
+	HealthCheck      string
+	HealthMinSeeders int
+	// Grab an NZB through Prowlarr instead when the health check finds too few seeders
+	NZBFallback bool
+	// Refuse torrent adds whose infohash or title failed before unless the request sets force
+	PreviousFailureRequireForce bool
+	// How long an add waits for the file list to skip samples and spot split movies; 0 disables
+	FileCheckWait time.Duration
+	// qBittorrent category for complete series packs, which are imported by the packimport worker
+	CompleteSeriesCategory string
+	// How long /api/library/stats serves cached counts
+	LibraryStatsTTL time.Duration
+	// Tag added movies/series with the requesting API key's name, e.g. "req-alice"
+	RequesterTags      bool
+	RequesterTagPrefix string
+	// qBittorrent folders as Radarr/Sonarr see them, for imports and the self-test
+	PathMappings PathMappings
+	// Minimum time between the searches of AddMedia adds; 0 searches as part of the add
+	SearchPace time.Duration
+	// Concurrent torrent adds per priority ("interactive", "background")
+	AddConcurrency map[string]int
+	// How long a repeated AddMedia request of the same title gets the first result
+	MediaAddCacheTTL time.Duration
+	// Audio language of the household, e.g. "French": multi-audio releases and
+	// releases tagged with it get the Radarr/Sonarr profile for it, if there is one
+	PreferredLanguage string
+	// Rolling window of the soft failures /health reports, and the count per
+	// backend at which it warns
+	SoftFailureWindow time.Duration
+	SoftFailureWarn   map[string]int
+	// How long the undo_token of a torrent add can roll it back; 0 issues none
+	UndoWindow time.Duration
+	// What a TV add does when Sonarr already has its episodes at equal or better
+	// quality: "" (don't check), "warn", "skip", "download_only" or "replace"
+	DuplicateEpisodes string
+}
+
+// Orders for ADD_ORDER
+const (
+	AddOrderTorrentFirst = "torrent_first"
+	AddOrderLibraryFirst = "library_first"
+)
+
+// Policies for torrents classified as non-media
+const (
+	NonMediaPolicyCategory     = "category"
+	NonMediaPolicyReject       = "reject"
+	NonMediaPolicyDownloadOnly = "download_only"
 )
 
 type TorrentHandler struct {
+	config          atomic.Pointer[HandlerConfig] // swapped on config reload, read through cfg()
 	qbClient        *QBittorrentClient
 	radarrClient    *RadarrClient
 	sonarrClient    *SonarrClient
 	extractorClient *NameExtractorClient
+	scraperClient   *ScraperClient
+	pipeline        *PipelineRunner
+	seriesCoalescer *coalescer[int, *SonarrSeries] // by TVDB ID
+	mediaAdds       *coalescer[mediaAddKey, mediaAddResult]
+	scheduler       *Scheduler
+	tautulliClient  *TautulliClient // nil when watch history is not configured
+	prowlarrClient  *ProwlarrClient // nil when Usenet fallback is not configured
+	history         *HistoryStore
+	notifier        *Notifier // nil when notifications are not configured
+	disagreements   *Notifier // DISAGREEMENT_WEBHOOK_URL, nil unless opted in
+	readiness       *Readiness
+	maintenance     *Maintenance
+	searches        *SearchPacer
+	adds            *AddQueue
+	asyncAdds       *AsyncAdds
+	previews        *MediaPreviews
+	softFailures    *SoftFailures
+	upstreamErrors  *UpstreamErrors
+	healthPings     *HealthPings
+	undoTokens      *UndoTokens
+	events          *EventStream
+	seriesChoices   *SeriesChoices
+	apiKeys         []*APIKey // from API_KEYS, for adds the reaper retries
+
+	libraryStatsCache libraryStatsCache
 }
 
 type AddTorrentRequest struct {
 	MagnetLink   string `json:"magnet_link"`
 	Type         string `json:"type,omitempty"`           // "movie" or "tv" - optional, will auto-detect if not provided
 	AddToLibrary bool   `json:"add_to_library,omitempty"` // Whether to add to Radarr/Sonarr library (default: true)
+	SourceURL    string `json:"source_url,omitempty"`     // Page the magnet was found on, if known
+	Strict       *bool  `json:"strict,omitempty"`         // Remove the torrent again if the library add fails; defaults to STRICT_LIBRARY_ADD
+	Force        bool   `json:"force,omitempty"`          // Add even if the same torrent or title failed before
+	Confirm      bool   `json:"confirm,omitempty"`        // Add even if the title is in the other library (movie vs series)
+	Debug        bool   `json:"debug,omitempty"`          // Return the decision log and downstream calls; admin keys only
+	Async        bool   `json:"async,omitempty"`          // Answer at once with a job ID to poll at /api/jobs/{id}
+	// Radarr/Sonarr root folder path and quality profile ID for the library add,
+	// from /api/config/options; the configured defaults when empty
+	RootFolder       string `json:"root_folder,omitempty"`
+	QualityProfileID int    `json:"quality_profile_id,omitempty"`
+
+	Feed      string `json:"-"` // RSS feed that auto-grabbed the torrent; set internally
+	WatchFile string `json:"-"` // WATCH_DIR file the torrent was dropped as; set internally
+	// Contents of a dropped .torrent file, uploaded to qBittorrent in place of
+	// MagnetLink; never journaled, as its trackers can carry a passkey
+	TorrentFile []byte `json:"-"`
+}
+
+// arrChoice is the root folder and quality profile the request picked
+func (r AddTorrentRequest) arrChoice() ArrChoice {
+	return ArrChoice{RootFolder: r.RootFolder, QualityProfileID: r.QualityProfileID}
 }
 
 type AddTorrentResponse struct {
-	Success        bool   `json:"success"`
-	Message        string `json:"message"`
-	Category       string `json:"category,omitempty"`
-	MediaTitle     string `json:"media_title,omitempty"`
-	AddedToLibrary bool   `json:"added_to_library"`
+	Success        bool           `json:"success"`
+	Message        string         `json:"message"`
+	Category       string         `json:"category,omitempty"`
+	MediaTitle     string         `json:"media_title,omitempty"`
+	AddedToLibrary bool           `json:"added_to_library"`
+	Code           string         `json:"code,omitempty"`        // Machine-readable error code on failure
+	NonMedia       string         `json:"non_media,omitempty"`   // "game", "software" or "book" when not a movie/TV torrent
+	RolledBack     bool           `json:"rolled_back,omitempty"` // The torrent was removed again after a failed library add
+	Warnings       []string       `json:"warnings,omitempty"`
+	Steps          []StepResult   `json:"steps,omitempty"`           // Per-step outcome and timing
+	SeedingPolicy  string         `json:"seeding_policy,omitempty"`  // Share limit rule applied: tracker domain, "private" or "public"
+	Health         *TorrentHealth `json:"health,omitempty"`          // Tracker scrape result when HEALTH_CHECK is on
+	Files          *FileCheck     `json:"files,omitempty"`           // Samples skipped and split parts found in the file list
+	CompleteSeries bool           `json:"complete_series,omitempty"` // A box set of every season, imported once finished
+	DownloadVia    string         `json:"download_via,omitempty"`    // "torrent", or "usenet" when an NZB was grabbed instead
+	Usenet         *UsenetGrab    `json:"usenet,omitempty"`          // The NZB grabbed when the torrent was dead
+
+	Correction *LookupCorrection  `json:"lookup_correction,omitempty"` // How the search term was changed to find a match
+	Release    *ReleaseStatus     `json:"release,omitempty"`           // The matched title's release status, e.g. only in cinemas
+	Duplicate  *DuplicateEpisodes `json:"duplicate,omitempty"`         // Episodes Sonarr already has, and what DUPLICATE_EPISODES did
+	UndoToken  string             `json:"undo_token,omitempty"`        // POST it to /api/undo before undo_expires_at to roll the add back
+	UndoExpiry *time.Time         `json:"undo_expires_at,omitempty"`
+	Debug      *DebugLog          `json:"debug,omitempty"`  // Decision log when the request set debug
+	JobID      string             `json:"job_id,omitempty"` // Async adds: the job to poll at /api/jobs/{id}
 }
 
 type AddMediaRequest struct {
-	Name string `json:"name"`           // Name of the movie or TV show
-	Type string `json:"type"`           // "movie" or "tv"
-	Year string `json:"year,omitempty"` // Optional year to improve search accuracy
+	Name    string `json:"name"`              // Name of the movie or TV show
+	Type    string `json:"type"`              // "movie" or "tv"
+	Year    string `json:"year,omitempty"`    // Optional year to improve search accuracy
+	TVDBID  int    `json:"tvdb_id,omitempty"` // The series meant, e.g. picked from /api/search; remembered for the name
+	Confirm bool   `json:"confirm,omitempty"` // Add even if the household already watched it or it's in the other library
+	// Search the indexers once added (default true); searches are paced by SEARCH_PACE
+	SearchOnAdd *bool `json:"search_on_add,omitempty"`
+	// Quality hint such as "1080p": picks the nearest Radarr quality profile and,
+	// with INDEXER_SEARCH, the release grabbed by the search
+	PreferredQuality string `json:"preferred_quality,omitempty"`
+	// Root folder path and quality profile ID from /api/config/options; the
+	// profile overrides preferred_quality
+	RootFolder       string `json:"root_folder,omitempty"`
+	QualityProfileID int    `json:"quality_profile_id,omitempty"`
+	// Add without a search and list the releases the search would find; a
+	// request with the returned preview_id then confirms or cancels the add
+	Preview   bool   `json:"preview,omitempty"`
+	PreviewID string `json:"preview_id,omitempty"`
+	Release   string `json:"release,omitempty"` // GUID of the preview release to grab; empty searches
+	Cancel    bool   `json:"cancel,omitempty"`  // Remove the previewed title again
+}
+
+// arrChoice is the root folder and quality profile the request picked
+func (r AddMediaRequest) arrChoice() ArrChoice {
+	return ArrChoice{RootFolder: r.RootFolder, QualityProfileID: r.QualityProfileID}
+}
+
+// searchOnAdd reports whether the added title should be searched for
+func (r AddMediaRequest) searchOnAdd() bool {
+	return r.SearchOnAdd == nil || *r.SearchOnAdd
 }
 
 type AddMediaResponse struct {
-	Success    bool   `json:"success"`
-	Message    string `json:"message"`
-	MediaTitle string `json:"media_title,omitempty"`
-	MediaType  string `json:"media_type,omitempty"`
-	MediaID    int    `json:"media_id,omitempty"`
+	Success    bool     `json:"success"`
+	Message    string   `json:"message"`
+	Code       string   `json:"code,omitempty"` // Machine-readable error code on failure
+	MediaTitle string   `json:"media_title,omitempty"`
+	MediaType  string   `json:"media_type,omitempty"`
+	MediaID    int      `json:"media_id,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+
+	Correction *LookupCorrection `json:"lookup_correction,omitempty"` // How the search term was changed to find a match
+	SearchAt   *time.Time        `json:"search_at,omitempty"`         // When the paced indexer search runs
+	Cached     bool              `json:"cached,omitempty"`            // The result of the same request made moments ago
+	Preview    *MediaPreview     `json:"preview,omitempty"`           // Releases found for a "preview": true request
 }
 
-func NewTorrentHandler(qbClient *QBittorrentClient, radarrClient *RadarrClient, sonarrClient *SonarrClient, extractorClient *NameExtractorClient) *TorrentHandler {
-	return &TorrentHandler{
+type ScrapeRequest struct {
+	URL string `json:"url"` // Result page on a supported site (YTS, EZTV, Nyaa)
+}
+
+type ScrapeResponse struct {
+	Success  bool             `json:"success"`
+	Message  string           `json:"message"`
+	Site     string           `json:"site,omitempty"`
+	Releases []ScrapedRelease `json:"releases,omitempty"`
+}
+
+type SchedulesRequest struct {
+	Timezone  string            `json:"timezone,omitempty"`  // IANA name, e.g. "Europe/Berlin"
+	Schedules map[string]string `json:"schedules,omitempty"` // Job name to cron expression; "" disables
+}
+
+type SchedulesResponse struct {
+	Success  bool               `json:"success"`
+	Message  string             `json:"message,omitempty"`
+	Timezone string             `json:"timezone"`
+	Jobs     []ScheduledJobInfo `json:"jobs"`
+}
+
+func NewTorrentHandler(qbClient *QBittorrentClient, radarrClient *RadarrClient, sonarrClient *SonarrClient, extractorClient *NameExtractorClient, scraperClient *ScraperClient, scheduler *Scheduler, tautulliClient *TautulliClient, history *HistoryStore, notifier *Notifier, config HandlerConfig) *TorrentHandler {
+	h := &TorrentHandler{
 		qbClient:        qbClient,
 		radarrClient:    radarrClient,
 		sonarrClient:    sonarrClient,
 		extractorClient: extractorClient,
+		scraperClient:   scraperClient,
+		pipeline:        NewPipelineRunner(),
+		seriesCoalescer: newCoalescer[int, *SonarrSeries](),
+		mediaAdds:       newCoalescer[mediaAddKey, mediaAddResult](),
+		scheduler:       scheduler,
+		tautulliClient:  tautulliClient,
+		history:         history,
+		notifier:        notifier,
+		readiness:       NewReadiness("qbittorrent", "radarr", "sonarr"),
+		maintenance:     NewMaintenance(),
+		searches:        NewSearchPacer(),
+		adds:            NewAddQueue(),
+		asyncAdds:       NewAsyncAdds(),
+		previews:        NewMediaPreviews(),
+		softFailures:    NewSoftFailures(),
+		upstreamErrors:  NewUpstreamErrors(),
+		healthPings:     NewHealthPings(),
+		undoTokens:      NewUndoTokens(),
+		events:          NewEventStream(),
+		seriesChoices:   NewSeriesChoices(),
 	}
+	h.config.Store(&config)
+	h.pipeline.OnComplete(h.recordPipeline)
+	h.pipeline.OnComplete(h.reportDisagreement)
+	return h
+}
+
+// cfg returns the current settings
+func (h *TorrentHandler) cfg() HandlerConfig {
+	return *h.config.Load()
 }
 
 func (h *TorrentHandler) AddTorrent(w http.ResponseWriter, r *http.Request) {
@@ -75,173 +309,147 @@ func (h *TorrentHandler) AddTorrent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate magnet link
-	if req.MagnetLink == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(AddTorrentResponse{
-			Success: false,
-			Message: "Magnet link is required",
-		})
-		return
-	}
+	h.addTorrent(w, r, req)
+}
 
-	if !isValidMagnetLink(req.MagnetLink) {
+// addTorrent validates an add request, runs the pipeline and writes the response
+func (h *TorrentHandler) addTorrent(w http.ResponseWriter, r *http.Request, req AddTorrentRequest) {
+	if err := validateAddRequest(req); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(AddTorrentResponse{
 			Success: false,
-			Message: "Invalid magnet link format",
+			Message: err.Error(),
 		})
 		return
 	}
 
-	// Determine category
-	var category string
-	var isMovie bool
-	if req.Type != "" {
-		// User specified type
-		switch req.Type {
-		case "movie":
-			category = "radarr"
-			isMovie = true
-		case "tv", "series":
-			category = "sonarr"
-			isMovie = false
-		default:
-			w.WriteHeader(http.StatusBadRequest)
+	// Debug output shows downstream payloads, so it is for admins only
+	ctx := r.Context()
+	var debug *DebugLog
+	if req.Debug {
+		if key := apiKeyFromContext(ctx); key != nil && !key.Admin {
+			w.WriteHeader(http.StatusForbidden)
 			json.NewEncoder(w).Encode(AddTorrentResponse{
 				Success: false,
-				Message: "Invalid type. Use 'movie' or 'tv'",
+				Message: "Only ADMIN_KEYS can request debug output",
 			})
 			return
 		}
-	} else {
-		// Auto-detect type from magnet link
-		category = detectCategory(req.MagnetLink)
-		isMovie = category == "radarr"
+		ctx, debug = withDebugLog(ctx)
 	}
 
-	log.Printf("Adding torrent with category: %s", category)
+	// Slow adds can run in the background, polled through /api/jobs/{id}
+	if req.Async {
+		job := h.startAsyncAdd(ctx, req, debug)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(AddTorrentResponse{
+			Success: true,
+			Message: "Add started; poll /api/jobs/" + job.ID + " for its progress",
+			JobID:   job.ID,
+		})
+		return
+	}
 
-	// Extract media name using the extractor API
-	torrentName := extractNameFromMagnet(req.MagnetLink)
-	extractedMedia, err := h.extractorClient.ExtractName(torrentName)
+	p, err := h.runAddPipeline(ctx, req, nil)
+	status, resp := addTorrentResult(p, err, debug)
+	if err == nil {
+		resp.UndoToken, resp.UndoExpiry = h.issueUndo(p)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// addTorrentResult is the status and response of a finished add
+func addTorrentResult(p *AddPipeline, err error, debug *DebugLog) (int, AddTorrentResponse) {
 	if err != nil {
-		log.Printf("Warning: could not extract media name: %v", err)
-		// Continue anyway, we can still add to qBittorrent
-	} else {
-		log.Printf("Extracted media: %s (%s) - Type: %s", extractedMedia.ExtractedName, extractedMedia.Year, extractedMedia.MediaType)
-
-		// Use extractor's media type if user didn't specify
-		if req.Type == "" && extractedMedia.MediaType != "" {
-			if extractedMedia.MediaType == "movie" {
-				category = "radarr"
-				isMovie = true
-			} else if extractedMedia.MediaType == "tv" || extractedMedia.MediaType == "series" {
-				category = "sonarr"
-				isMovie = false
-			}
-			log.Printf("Updated category based on extractor: %s", category)
+		return addErrorStatus(err), AddTorrentResponse{
+			Success:    false,
+			Message:    "Failed to add torrent: " + err.Error(),
+			Category:   p.Category,
+			Code:       errorCode(err),
+			NonMedia:   p.NonMedia,
+			RolledBack: p.RolledBack,
+			Steps:      p.Steps,
+			Duplicate:  p.Duplicate,
+			Debug:      debug,
 		}
 	}
 
-	// Ensure category exists in qBittorrent
-	if err := h.qbClient.EnsureCategory(category); err != nil {
-		log.Printf("Warning: could not ensure category exists: %v", err)
+	// Success response
+	message, downloadVia := p.summary()
+	return http.StatusOK, AddTorrentResponse{
+		Success:        true,
+		Message:        message,
+		Category:       p.Category,
+		MediaTitle:     p.MediaTitle,
+		AddedToLibrary: p.AddedToLibrary,
+		NonMedia:       p.NonMedia,
+		Warnings:       p.Warnings,
+		Steps:          p.Steps,
+		SeedingPolicy:  p.SeedingPolicy,
+		Health:         p.Health,
+		Files:          p.Files,
+		CompleteSeries: p.CompleteSeries,
+		DownloadVia:    downloadVia,
+		Usenet:         p.Usenet,
+		Correction:     p.Correction,
+		Release:        p.Release,
+		Duplicate:      p.Duplicate,
+		Debug:          debug,
 	}
+}
 
-	// Add torrent to qBittorrent
-	if err := h.qbClient.AddTorrent(req.MagnetLink, category); err != nil {
-		log.Printf("Error adding torrent: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(AddTorrentResponse{
-			Success: false,
-			Message: "Failed to add torrent: " + err.Error(),
-		})
-		return
+// validateAddRequest checks the magnet link and the user-specified type
+func validateAddRequest(req AddTorrentRequest) error {
+	if req.MagnetLink == "" {
+		return errors.New("Magnet link is required")
 	}
-
-	// Add to Radarr or Sonarr library
-	var mediaTitle string
-	addedToLibrary := false
-
-	// Default to adding to library unless explicitly disabled
-	shouldAddToLibrary := true
-	// Only try to add to library if we successfully extracted the media name
-	if extractedMedia == nil {
-		shouldAddToLibrary = false
-		log.Printf("Skipping library add - could not extract media name")
-	}
-
-	if shouldAddToLibrary {
-		if isMovie {
-			log.Printf("Adding movie to Radarr: %s", extractedMedia.ExtractedName)
-			movie, err := h.radarrClient.AddMovieFromMagnet(req.MagnetLink, extractedMedia)
-			if err != nil {
-				// Check if movie already exists (common case)
-				if strings.Contains(err.Error(), "already") || strings.Contains(err.Error(), "exists") {
-					log.Printf("Movie already exists in Radarr: %v", err)
-					mediaTitle = extractedMedia.ExtractedName
-					addedToLibrary = false
-				} else {
-					log.Printf("Warning: could not add movie to Radarr: %v", err)
-					mediaTitle = extractedMedia.ExtractedName
-				}
-			} else {
-				log.Printf("Movie added to Radarr: %s", movie.Title)
-				mediaTitle = movie.Title
-				addedToLibrary = true
-			}
-		} else {
-			log.Printf("Adding series to Sonarr: %s", extractedMedia.ExtractedName)
-			series, err := h.sonarrClient.AddSeriesFromMagnet(req.MagnetLink, extractedMedia)
-			if err != nil {
-				// Check if series already exists (common case)
-				if strings.Contains(err.Error(), "already") || strings.Contains(err.Error(), "exists") {
-					log.Printf("Series already exists in Sonarr: %v", err)
-					mediaTitle = extractedMedia.ExtractedName
-					addedToLibrary = false
-				} else {
-					log.Printf("Warning: could not add series to Sonarr: %v", err)
-					mediaTitle = extractedMedia.ExtractedName
-				}
-			} else {
-				log.Printf("Series added to Sonarr: %s", series.Title)
-				mediaTitle = series.Title
-				addedToLibrary = true
-			}
-		}
+	if !isValidMagnetLink(req.MagnetLink) {
+		return errors.New("Invalid magnet link format")
+	}
+	switch req.Type {
+	case "", "movie", "tv", "series":
+		return nil
 	}
+	return errors.New("Invalid type. Use 'movie' or 'tv'")
+}
 
-	// Success response
-	message := "Torrent added to qBittorrent"
-	if addedToLibrary {
-		if isMovie {
+// summary describes a successful add and how the download is made
+func (p *AddPipeline) summary() (message, downloadVia string) {
+	message = "Torrent added to qBittorrent"
+	downloadVia = "torrent"
+	if p.Usenet != nil {
+		message = "Torrent has no seeders, NZB grabbed from " + p.Usenet.Indexer
+		downloadVia = "usenet"
+	}
+	if p.AddedToLibrary {
+		if p.IsMovie {
 			message += " and movie added to Radarr"
 		} else {
 			message += " and series added to Sonarr"
 		}
 	}
-
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(AddTorrentResponse{
-		Success:        true,
-		Message:        message,
-		Category:       category,
-		MediaTitle:     mediaTitle,
-		AddedToLibrary: addedToLibrary,
-	})
+	if p.NonMedia != "" {
+		message = "Torrent added to qBittorrent as " + p.NonMedia
+	}
+	return message, downloadVia
 }
 
-// AddMedia handles adding a movie or TV show to Radarr/Sonarr by name
+// AddMedia handles adding a movie or TV show to Radarr/Sonarr by name (POST),
+// or checking whether one is already there (GET)
 func (h *TorrentHandler) AddMedia(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Only accept POST requests
-	if r.Method != http.MethodPost {
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodGet:
+		h.mediaExists(w, r)
+		return
+	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		json.NewEncoder(w).Encode(AddMediaResponse{
 			Success: false,
-			Message: "Method not allowed. Use POST.",
+			Message: "Method not allowed. Use GET or POST.",
 		})
 		return
 	}
@@ -257,6 +465,18 @@ func (h *TorrentHandler) AddMedia(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A preview_id answers an earlier preview instead
+	if req.PreviewID != "" {
+		resp, err := h.resolvePreview(r.Context(), req)
+		if err != nil {
+			w.WriteHeader(mediaErrorStatus(err))
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
 	// Validate required fields
 	if req.Name == "" {
 		w.WriteHeader(http.StatusBadRequest)
@@ -287,6 +507,87 @@ func (h *TorrentHandler) AddMedia(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.TVDBID != 0 && mediaType == "movie" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(AddMediaResponse{
+			Success: false,
+			Message: "tvdb_id is only for type tv",
+		})
+		return
+	}
+
+	if _, err := parseQualityHint(req.PreferredQuality); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(AddMediaResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	resp, _, err := h.addMedia(r.Context(), req, mediaType)
+	if err != nil {
+		w.WriteHeader(mediaErrorStatus(err))
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// addMedia looks up a movie ("movie") or series ("tv"/"series") by name and adds
+// the best match. The response describes failures too; the card is set once a
+// match was found.
+func (h *TorrentHandler) addMedia(ctx context.Context, req AddMediaRequest, mediaType string) (*AddMediaResponse, *MediaCard, error) {
+	// Don't bother looking up titles that can't be added
+	if err := h.checkAddsAllowed(ctx); err != nil {
+		return &AddMediaResponse{
+			Success: false,
+			Message: "Failed to add media: " + err.Error(),
+			Code:    errorCode(err),
+		}, nil, err
+	}
+	kind := "tv"
+	if mediaType == "movie" {
+		kind = "movie"
+	}
+	if err := checkKeyMediaType(ctx, kind); err != nil {
+		return &AddMediaResponse{
+			Success: false,
+			Message: err.Error(),
+			Code:    errorCode(err),
+		}, nil, err
+	}
+
+	// A retried request gets the first one's result
+	cacheKey := mediaAddKey{
+		apiKey:           keyName(ctx),
+		kind:             kind,
+		name:             normalizeTitle(req.Name),
+		year:             req.Year,
+		tvdbID:           req.TVDBID,
+		preview:          req.Preview,
+		confirm:          req.Confirm,
+		rootFolder:       req.RootFolder,
+		qualityProfileID: req.QualityProfileID,
+		preferredQuality: req.PreferredQuality,
+	}
+	result, shared, err := h.mediaAdds.Do(cacheKey, h.cfg().MediaAddCacheTTL, func() (mediaAddResult, error) {
+		resp, card, err := h.lookupAndAddMedia(ctx, req, mediaType)
+		return mediaAddResult{resp: resp, card: card}, err
+	})
+	resp := result.resp
+	if shared && resp != nil {
+		log.Printf("Repeated media add of %s; returning the earlier result", req.Name)
+		copied := *resp
+		copied.Cached = true
+		resp = &copied
+	}
+	return resp, result.card, err
+}
+
+// lookupAndAddMedia looks up and adds the title of an AddMedia request, once the
+// request passed the checks of addMedia
+func (h *TorrentHandler) lookupAndAddMedia(ctx context.Context, req AddMediaRequest, mediaType string) (*AddMediaResponse, *MediaCard, error) {
 	// Build search term
 	searchTerm := req.Name
 	if req.Year != "" {
@@ -296,48 +597,457 @@ func (h *TorrentHandler) AddMedia(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Adding media: %s (type: %s)", searchTerm, mediaType)
 
 	if mediaType == "movie" {
-		// Add movie to Radarr
-		movie, err := h.radarrClient.AddMovieByName(searchTerm)
+		// Look up the movie in Radarr
+		match, err := h.radarrClient.MatchMovieByName(ctx, searchTerm)
 		if err != nil {
 			log.Printf("Error adding movie to Radarr: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(AddMediaResponse{
+			return &AddMediaResponse{
 				Success: false,
 				Message: "Failed to add movie: " + err.Error(),
-			})
-			return
+				Code:    errorCode(err),
+			}, nil, err
+		}
+		resolution, _ := parseQualityHint(req.PreferredQuality)
+		resp, err := h.addMovieMatch(ctx, searchTerm, match, req.arrChoice(), req.Confirm, req.searchOnAdd() && !req.Preview, resolution)
+		if err == nil && req.Preview {
+			resp.Preview = h.previewReleases(ctx, resp, 0)
+			resp.Message = "Movie added to Radarr without a search; answer the preview to search"
 		}
+		return resp, movieCard(match), err
+	}
 
-		log.Printf("Movie added to Radarr: %s (ID: %d)", movie.Title, movie.ID)
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(AddMediaResponse{
-			Success:    true,
-			Message:    "Movie added to Radarr",
-			MediaTitle: movie.Title,
-			MediaType:  "movie",
-			MediaID:    movie.ID,
-		})
+	// Look up the series in Sonarr: the one given by tvdb_id, which is remembered
+	// for the name, or else the one picked for the name before
+	var match *SonarrSearchResult
+	var err error
+	if req.TVDBID != 0 {
+		if match, err = h.sonarrClient.LookupSeriesByTVDBID(ctx, req.TVDBID); err == nil {
+			h.seriesChoices.Remember(req.Name, match)
+		}
+	} else if match = h.rememberedSeries(ctx, req.Name, req.Year); match == nil {
+		match, err = h.sonarrClient.MatchSeriesByName(ctx, searchTerm)
+	}
+	if err != nil {
+		log.Printf("Error adding series to Sonarr: %v", err)
+		return &AddMediaResponse{
+			Success: false,
+			Message: "Failed to add series: " + err.Error(),
+			Code:    errorCode(err),
+		}, nil, err
+	}
+	resp, err := h.addSeriesMatch(ctx, searchTerm, match, req.arrChoice(), req.Confirm, req.searchOnAdd() && !req.Preview)
+	if err == nil && req.Preview {
+		resp.Preview = h.previewReleases(ctx, resp, previewSeason(match))
+		resp.Message = "Series added to Sonarr without a search; answer the preview to search"
+	}
+	return resp, seriesCard(match), err
+}
+
+// keyName returns the name of the requesting API key, or "" without keys
+func keyName(ctx context.Context) string {
+	if key := apiKeyFromContext(ctx); key != nil {
+		return key.Name
+	}
+	return ""
+}
+
+// keyMaxRating returns the certification limit of the requesting API key, if any
+func keyMaxRating(ctx context.Context) string {
+	if key := apiKeyFromContext(ctx); key != nil {
+		return key.MaxRating
+	}
+	return ""
+}
+
+// addMovieMatch applies the rating and watch history checks to a Radarr lookup
+// result and adds it, with a search when search is set. name is the term it was
+// found by; a preferred resolution (0 for none) picks the quality profile and,
+// with INDEXER_SEARCH, the release to grab.
+func (h *TorrentHandler) addMovieMatch(ctx context.Context, name string, match *RadarrSearchResult, choice ArrChoice, confirm, search bool, resolution int) (*AddMediaResponse, error) {
+	err := h.checkAddsAllowed(ctx)
+	if err == nil {
+		// Restricted keys only add titles up to their certification limit
+		err = checkRating(match.Title, match.Certification, keyMaxRating(ctx))
+	}
+	var warnings []string
+	if err == nil {
+		warnings, err = h.checkWatched(ctx, match.Title, match.Year, true, confirm)
+	}
+	if err == nil {
+		var duplicates []string
+		duplicates, err = h.checkCrossLibrary(ctx, match.Title, match.Year, true, confirm)
+		warnings = append(warnings, duplicates...)
+	}
+	if err != nil {
+		log.Printf("Error adding movie to Radarr: %v", err)
+		return &AddMediaResponse{
+			Success:  false,
+			Message:  "Failed to add movie: " + err.Error(),
+			Code:     errorCode(err),
+			Warnings: warnings,
+		}, err
+	}
+
+	// Add movie to Radarr; with SEARCH_PACE or a release to pick the search is
+	// triggered separately
+	pace := h.cfg().SearchPace
+	pickRelease := search && resolution > 0 && h.cfg().IndexerSearch
+	movie, err := h.radarrClient.AddMatchedMovie(ctx, match, choice, search && pace == 0 && !pickRelease, resolution, "")
+	if err != nil {
+		log.Printf("Error adding movie to Radarr: %v", err)
+		return &AddMediaResponse{
+			Success:  false,
+			Message:  "Failed to add movie: " + err.Error(),
+			Code:     errorCode(err),
+			Warnings: warnings,
+		}, err
+	}
+
+	log.Printf("Movie added to Radarr: %s (ID: %d)", movie.Title, movie.ID)
+	var searchAt *time.Time
+	switch {
+	case pickRelease:
+		at := h.grabAfterAdd(movie.ID, movie.Title, resolution)
+		searchAt = &at
+	case search && pace > 0:
+		at := h.searchAfterAdd(true, movie.ID, movie.Title)
+		searchAt = &at
+	}
+	if err := h.tagRequester(ctx, true, movie.ID); err != nil {
+		log.Printf("Warning: could not tag requester: %v", err)
+		warnings = append(warnings, "Could not tag the movie with the requester: "+err.Error())
+	}
+	h.recordHistory(HistoryRecord{
+		Source:     "media",
+		Name:       name,
+		MediaType:  "movie",
+		MediaTitle: movie.Title,
+		MediaID:    movie.ID,
+		Status:     HistoryStatusAdded,
+	})
+	return &AddMediaResponse{
+		Success:    true,
+		Message:    "Movie added to Radarr",
+		MediaTitle: movie.Title,
+		MediaType:  "movie",
+		MediaID:    movie.ID,
+		Warnings:   warnings,
+		Correction: match.Correction,
+		SearchAt:   searchAt,
+	}, nil
+}
+
+// addSeriesMatch applies the rating and watch history checks to a Sonarr lookup
+// result and adds it, with a search for missing episodes when search is set
+func (h *TorrentHandler) addSeriesMatch(ctx context.Context, name string, match *SonarrSearchResult, choice ArrChoice, confirm, search bool) (*AddMediaResponse, error) {
+	err := h.checkAddsAllowed(ctx)
+	if err == nil {
+		// Restricted keys only add titles up to their certification limit
+		err = checkRating(match.Title, match.Certification, keyMaxRating(ctx))
+	}
+	var warnings []string
+	if err == nil {
+		warnings, err = h.checkWatched(ctx, match.Title, match.Year, false, confirm)
+	}
+	if err == nil {
+		var duplicates []string
+		duplicates, err = h.checkCrossLibrary(ctx, match.Title, match.Year, false, confirm)
+		warnings = append(warnings, duplicates...)
+	}
+	if err != nil {
+		log.Printf("Error adding series to Sonarr: %v", err)
+		return &AddMediaResponse{
+			Success:  false,
+			Message:  "Failed to add series: " + err.Error(),
+			Code:     errorCode(err),
+			Warnings: warnings,
+		}, err
+	}
+
+	// Add series to Sonarr; with SEARCH_PACE the search is triggered separately
+	pace := h.cfg().SearchPace
+	series, err := h.sonarrClient.AddMatchedSeries(ctx, match, choice, "standard", h.seriesMonitor(match), search && pace == 0, "")
+	if err != nil {
+		log.Printf("Error adding series to Sonarr: %v", err)
+		return &AddMediaResponse{
+			Success:  false,
+			Message:  "Failed to add series: " + err.Error(),
+			Code:     errorCode(err),
+			Warnings: warnings,
+		}, err
+	}
+
+	log.Printf("Series added to Sonarr: %s (ID: %d)", series.Title, series.ID)
+	var searchAt *time.Time
+	if search && pace > 0 {
+		at := h.searchAfterAdd(false, series.ID, series.Title)
+		searchAt = &at
+	}
+	if err := h.tagRequester(ctx, false, series.ID); err != nil {
+		log.Printf("Warning: could not tag requester: %v", err)
+		warnings = append(warnings, "Could not tag the series with the requester: "+err.Error())
+	}
+	h.recordHistory(HistoryRecord{
+		Source:     "media",
+		Name:       name,
+		MediaType:  "tv",
+		MediaTitle: series.Title,
+		MediaID:    series.ID,
+		Status:     HistoryStatusAdded,
+	})
+	return &AddMediaResponse{
+		Success:    true,
+		Message:    "Series added to Sonarr",
+		MediaTitle: series.Title,
+		MediaType:  "tv",
+		MediaID:    series.ID,
+		Warnings:   warnings,
+		Correction: match.Correction,
+		SearchAt:   searchAt,
+	}, nil
+}
+
+// seriesMonitor picks the Sonarr monitor option for a new series, so adding a late
+// episode of a long-running show doesn't monitor years of back episodes
+func (h *TorrentHandler) seriesMonitor(series *SonarrSearchResult) string {
+	monitor := h.cfg().MonitorEnded
+	if series.Airing() {
+		monitor = h.cfg().MonitorAiring
+	}
+	log.Printf("Series %s is %s, monitoring %s", series.Title, series.Status, monitor)
+	return monitor
+}
+
+// checkWatched returns a warning if the household already watched the title.
+// With WatchedRequireConfirm set, an unconfirmed request also gets an ALREADY_WATCHED error.
+func (h *TorrentHandler) checkWatched(ctx context.Context, title string, year int, isMovie, confirmed bool) ([]string, error) {
+	if h.tautulliClient == nil {
+		return nil, nil
+	}
+
+	record, err := h.tautulliClient.FindWatched(ctx, title, year, isMovie)
+	if err != nil {
+		log.Printf("Warning: could not check watch history: %v", err)
+		return nil, nil
+	}
+	if record == nil {
+		return nil, nil
+	}
+
+	warning := watchedWarning(record)
+	if h.cfg().WatchedRequireConfirm && !confirmed {
+		return []string{warning}, newAPIError(ErrCodeAlreadyWatched, "%s; resend with confirm to add anyway", warning)
+	}
+	return []string{warning}, nil
+}
+
+// checkCrossLibrary returns a warning if a movie's title and year are already in
+// Sonarr, or a series' in Radarr, e.g. a mini-series added as a movie before. An
+// unconfirmed request also gets a CROSS_LIBRARY_DUPLICATE error, so the title
+// isn't split across both libraries by accident. A year of 0 matches any year.
+func (h *TorrentHandler) checkCrossLibrary(ctx context.Context, title string, year int, isMovie, confirmed bool) ([]string, error) {
+	wanted := normalizeTitle(title)
+	if wanted == "" {
+		return nil, nil
+	}
+	sameYear := func(other int) bool {
+		return year == 0 || other == 0 || other == year
+	}
+
+	var warning string
+	if isMovie {
+		series, err := h.sonarrClient.GetAllSeries(ctx)
+		if err != nil {
+			log.Printf("Warning: could not check Sonarr for %s: %v", title, err)
+			return nil, nil
+		}
+		for _, s := range series {
+			if normalizeTitle(s.Title) == wanted && sameYear(s.Year) {
+				warning = fmt.Sprintf("%s (%d) is already in Sonarr as a series", s.Title, s.Year)
+				break
+			}
+		}
 	} else {
-		// Add series to Sonarr
-		series, err := h.sonarrClient.AddSeriesByName(searchTerm)
+		movies, err := h.radarrClient.GetMovies(ctx)
 		if err != nil {
-			log.Printf("Error adding series to Sonarr: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(AddMediaResponse{
+			log.Printf("Warning: could not check Radarr for %s: %v", title, err)
+			return nil, nil
+		}
+		for _, m := range movies {
+			if normalizeTitle(m.Title) == wanted && sameYear(m.Year) {
+				warning = fmt.Sprintf("%s (%d) is already in Radarr as a movie", m.Title, m.Year)
+				break
+			}
+		}
+	}
+	if warning == "" {
+		return nil, nil
+	}
+
+	if !confirmed {
+		return []string{warning}, newAPIError(ErrCodeCrossLibraryDuplicate, "%s; resend with confirm to add anyway", warning)
+	}
+	return []string{warning}, nil
+}
+
+// Scrape returns all magnets and quality variants found on a supported result page
+func (h *TorrentHandler) Scrape(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(ScrapeResponse{
+			Success: false,
+			Message: "Method not allowed. Use POST.",
+		})
+		return
+	}
+
+	// Parse request body
+	var req ScrapeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ScrapeResponse{
+			Success: false,
+			Message: "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if req.URL == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ScrapeResponse{
+			Success: false,
+			Message: "URL is required",
+		})
+		return
+	}
+
+	if _, err := findSiteScraper(req.URL); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ScrapeResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	site, releases, err := h.scraperClient.Scrape(req.URL)
+	if err != nil {
+		log.Printf("Error scraping %s: %v", req.URL, err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(ScrapeResponse{
+			Success: false,
+			Message: "Failed to scrape page: " + err.Error(),
+			Site:    site,
+		})
+		return
+	}
+
+	log.Printf("Scraped %d releases from %s (%s)", len(releases), req.URL, site)
+
+	message := "No magnet links found on page"
+	if len(releases) > 0 {
+		message = "Found releases on page"
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ScrapeResponse{
+		Success:  true,
+		Message:  message,
+		Site:     site,
+		Releases: releases,
+	})
+}
+
+// mediaErrorStatus maps a library add error to an HTTP status
+func mediaErrorStatus(err error) int {
+	switch errorCode(err) {
+	case ErrCodeRootFolderInaccessible, ErrCodeMaintenance:
+		return http.StatusServiceUnavailable
+	case ErrCodeAlreadyWatched, ErrCodePathConflict, ErrCodeCrossLibraryDuplicate:
+		return http.StatusConflict
+	case ErrCodeContentRatingBlocked, ErrCodeKeyDisabled, ErrCodeMediaTypeNotAllowed:
+		return http.StatusForbidden
+	case ErrCodeAuthExpired:
+		return http.StatusBadGateway
+	case ErrCodePreviewNotFound:
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// Schedules lists background workers with their next run times (GET) or updates them (PUT)
+func (h *TorrentHandler) Schedules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if key := apiKeyFromContext(r.Context()); key != nil && !key.Admin {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(SchedulesResponse{
 				Success: false,
-				Message: "Failed to add series: " + err.Error(),
+				Message: "Only ADMIN_KEYS can change schedules",
 			})
 			return
 		}
 
-		log.Printf("Series added to Sonarr: %s (ID: %d)", series.Title, series.ID)
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(AddMediaResponse{
-			Success:    true,
-			Message:    "Series added to Sonarr",
-			MediaTitle: series.Title,
-			MediaType:  "tv",
-			MediaID:    series.ID,
+		var req SchedulesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(SchedulesResponse{
+				Success: false,
+				Message: "Invalid request body: " + err.Error(),
+			})
+			return
+		}
+
+		if err := h.scheduler.Update(req.Timezone, req.Schedules); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(SchedulesResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		if req.Timezone != "" {
+			log.Printf("Schedule timezone set to %s", req.Timezone)
+		}
+		for name, expr := range req.Schedules {
+			log.Printf("Schedule for %s set to %q", name, expr)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(SchedulesResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET or PUT.",
 		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SchedulesResponse{
+		Success:  true,
+		Timezone: h.scheduler.Timezone(),
+		Jobs:     h.scheduler.List(),
+	})
+}
+
+// addErrorStatus maps a torrent add pipeline error to an HTTP status
+func addErrorStatus(err error) int {
+	switch errorCode(err) {
+	case ErrCodeNonMediaRejected, ErrCodeNoSeeders, ErrCodeNoLibraryMatch, ErrCodeMediaTypeUndetermined:
+		return http.StatusUnprocessableEntity
+	case ErrCodeContentRatingBlocked, ErrCodeKeyDisabled, ErrCodeMediaTypeNotAllowed:
+		return http.StatusForbidden
+	case ErrCodePreviouslyFailed, ErrCodePathConflict, ErrCodeCrossLibraryDuplicate, ErrCodeDuplicateEpisode:
+		return http.StatusConflict
+	case ErrCodeLibraryAddFailed, ErrCodeAuthExpired:
+		return http.StatusBadGateway
+	case ErrCodeRootFolderInaccessible, ErrCodeMaintenance, ErrCodeDependencyUnavailable:
+		return http.StatusServiceUnavailable
 	}
+	return http.StatusInternalServerError
 }
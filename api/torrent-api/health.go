@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Torrent health check modes for HEALTH_CHECK
+const (
+	HealthCheckWarn   = "warn"
+	HealthCheckReject = "reject"
+)
+
+// Each tracker gets this long to answer a scrape
+const trackerScrapeTimeout = 3 * time.Second
+
+// BEP 15 magic constant for the UDP tracker connect request
+const udpTrackerProtocolID = 0x41727101980
+
+var scrapeHTTPClient = &http.Client{
+	Timeout:   trackerScrapeTimeout,
+	Transport: newTracingTransport(),
+}
+
+// TorrentHealth is the swarm size reported by a magnet's trackers
+type TorrentHealth struct {
+	Seeders   int             `json:"seeders"`  // Highest count any tracker reported
+	Leechers  int             `json:"leechers"` // From the same tracker as seeders
+	Completed int             `json:"completed"`
+	Trackers  []TrackerScrape `json:"trackers"`
+}
+
+// TrackerScrape is one tracker's answer
+type TrackerScrape struct {
+	Tracker   string `json:"tracker"`
+	Seeders   int    `json:"seeders"`
+	Leechers  int    `json:"leechers"`
+	Completed int    `json:"completed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// infoHashBytes decodes a magnet's btih hash, hex or base32
+func infoHashBytes(magnetLink string) ([]byte, error) {
+	return decodeInfoHash(extractInfoHash(magnetLink))
+}
+
+// decodeInfoHash decodes a hex or base32 info hash
+func decodeInfoHash(hash string) ([]byte, error) {
+	switch len(hash) {
+	case 40:
+		return hex.DecodeString(hash)
+	case 32:
+		return base32.StdEncoding.DecodeString(strings.ToUpper(hash))
+	}
+	return nil, fmt.Errorf("no valid info hash")
+}
+
+// scrapeTorrentHealth asks every HTTP and UDP tracker in the magnet link for its
+// swarm size. DHT-only magnets can't be checked. It fails only when no tracker answered.
+func scrapeTorrentHealth(ctx context.Context, magnetLink string) (*TorrentHealth, error) {
+	hash, err := infoHashBytes(magnetLink)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(magnetLink)
+	if err != nil {
+		return nil, fmt.Errorf("invalid magnet link: %w", err)
+	}
+	trackers := u.Query()["tr"]
+	if len(trackers) == 0 {
+		return nil, fmt.Errorf("magnet link has no trackers to scrape")
+	}
+
+	results := make([]TrackerScrape, len(trackers))
+	var wg sync.WaitGroup
+	for i, tracker := range trackers {
+		wg.Add(1)
+		go func(i int, tracker string) {
+			defer wg.Done()
+			scrapeCtx, cancel := context.WithTimeout(ctx, trackerScrapeTimeout)
+			defer cancel()
+
+			results[i] = TrackerScrape{Tracker: tracker}
+			if err := scrapeTracker(scrapeCtx, tracker, hash, &results[i]); err != nil {
+				results[i].Error = err.Error()
+			}
+		}(i, tracker)
+	}
+	wg.Wait()
+
+	health := &TorrentHealth{Trackers: results}
+	answered := 0
+	for _, result := range results {
+		if result.Error != "" {
+			continue
+		}
+		answered++
+		if answered == 1 || result.Seeders > health.Seeders {
+			health.Seeders, health.Leechers = result.Seeders, result.Leechers
+		}
+		if result.Completed > health.Completed {
+			health.Completed = result.Completed
+		}
+	}
+	if answered == 0 {
+		return health, fmt.Errorf("none of %d trackers answered the scrape", len(trackers))
+	}
+	return health, nil
+}
+
+func scrapeTracker(ctx context.Context, tracker string, hash []byte, result *TrackerScrape) error {
+	u, err := url.Parse(tracker)
+	if err != nil {
+		return fmt.Errorf("invalid tracker URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return scrapeHTTPTracker(ctx, u, hash, result)
+	case "udp":
+		return scrapeUDPTracker(ctx, u.Host, hash, result)
+	}
+	return fmt.Errorf("unsupported tracker scheme %q", u.Scheme)
+}
+
+// scrapeHTTPTracker uses the scrape convention: the announce URL with "announce"
+// in its last path segment replaced by "scrape"
+func scrapeHTTPTracker(ctx context.Context, u *url.URL, hash []byte, result *TrackerScrape) error {
+	dir, last := "", u.Path
+	if i := strings.LastIndex(u.Path, "/"); i >= 0 {
+		dir, last = u.Path[:i+1], u.Path[i+1:]
+	}
+	if !strings.HasPrefix(last, "announce") {
+		return fmt.Errorf("tracker does not support scrape")
+	}
+
+	scrapeURL := *u
+	scrapeURL.Path = dir + "scrape" + strings.TrimPrefix(last, "announce")
+	query := "info_hash=" + url.QueryEscape(string(hash))
+	if u.RawQuery != "" {
+		// Keep passkeys and the like
+		query = u.RawQuery + "&" + query
+	}
+	scrapeURL.RawQuery = query
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scrapeURL.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := scrapeHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("scrape failed with status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	decoded, _, err := bdecode(body)
+	if err != nil {
+		return fmt.Errorf("invalid scrape response: %w", err)
+	}
+	root, _ := decoded.(map[string]interface{})
+	if reason, ok := root["failure reason"].(string); ok {
+		return fmt.Errorf("tracker: %s", reason)
+	}
+	files, _ := root["files"].(map[string]interface{})
+	stats, ok := files[string(hash)].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("tracker does not know the torrent")
+	}
+	result.Seeders, _ = stats["complete"].(int)
+	result.Leechers, _ = stats["incomplete"].(int)
+	result.Completed, _ = stats["downloaded"].(int)
+	return nil
+}
+
+// scrapeUDPTracker runs the BEP 15 connect and scrape exchange
+func scrapeUDPTracker(ctx context.Context, host string, hash []byte, result *TrackerScrape) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", host)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	exchange := func(request []byte, action uint32, minSize int) ([]byte, error) {
+		transaction := make([]byte, 4)
+		if _, err := rand.Read(transaction); err != nil {
+			return nil, err
+		}
+		copy(request[12:16], transaction)
+		if _, err := conn.Write(request); err != nil {
+			return nil, fmt.Errorf("failed to send: %w", err)
+		}
+
+		response := make([]byte, 1024)
+		n, err := conn.Read(response)
+		if err != nil {
+			return nil, fmt.Errorf("no response: %w", err)
+		}
+		response = response[:n]
+		if n < 8 || !bytes.Equal(response[4:8], transaction) {
+			return nil, fmt.Errorf("unexpected response")
+		}
+		if got := binary.BigEndian.Uint32(response[:4]); got != action {
+			if got == 3 {
+				return nil, fmt.Errorf("tracker: %s", response[8:])
+			}
+			return nil, fmt.Errorf("unexpected action %d", got)
+		}
+		if n < minSize {
+			return nil, fmt.Errorf("short response")
+		}
+		return response, nil
+	}
+
+	connect := make([]byte, 16)
+	binary.BigEndian.PutUint64(connect[:8], udpTrackerProtocolID)
+	binary.BigEndian.PutUint32(connect[8:12], 0)
+	response, err := exchange(connect, 0, 16)
+	if err != nil {
+		return err
+	}
+
+	scrape := make([]byte, 16+len(hash))
+	copy(scrape[:8], response[8:16]) // connection ID
+	binary.BigEndian.PutUint32(scrape[8:12], 2)
+	copy(scrape[16:], hash)
+	response, err = exchange(scrape, 2, 20)
+	if err != nil {
+		return err
+	}
+
+	result.Seeders = int(binary.BigEndian.Uint32(response[8:12]))
+	result.Completed = int(binary.BigEndian.Uint32(response[12:16]))
+	result.Leechers = int(binary.BigEndian.Uint32(response[16:20]))
+	return nil
+}
+
+// bdecode decodes one bencoded value: int, string, []interface{} or
+// map[string]interface{}. It returns the rest of the input.
+func bdecode(data []byte) (interface{}, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("unexpected end of data")
+	}
+
+	switch c := data[0]; {
+	case c == 'i':
+		end := bytes.IndexByte(data, 'e')
+		if end < 0 {
+			return nil, nil, fmt.Errorf("unterminated integer")
+		}
+		n, err := strconv.Atoi(string(data[1:end]))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid integer: %w", err)
+		}
+		return n, data[end+1:], nil
+
+	case c == 'l':
+		var list []interface{}
+		rest := data[1:]
+		for len(rest) > 0 && rest[0] != 'e' {
+			var value interface{}
+			var err error
+			if value, rest, err = bdecode(rest); err != nil {
+				return nil, nil, err
+			}
+			list = append(list, value)
+		}
+		if len(rest) == 0 {
+			return nil, nil, fmt.Errorf("unterminated list")
+		}
+		return list, rest[1:], nil
+
+	case c == 'd':
+		dict := make(map[string]interface{})
+		rest := data[1:]
+		for len(rest) > 0 && rest[0] != 'e' {
+			key, afterKey, err := bdecode(rest)
+			if err != nil {
+				return nil, nil, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("dictionary key is not a string")
+			}
+			var value interface{}
+			if value, rest, err = bdecode(afterKey); err != nil {
+				return nil, nil, err
+			}
+			dict[name] = value
+		}
+		if len(rest) == 0 {
+			return nil, nil, fmt.Errorf("unterminated dictionary")
+		}
+		return dict, rest[1:], nil
+
+	case c >= '0' && c <= '9':
+		colon := bytes.IndexByte(data, ':')
+		if colon < 0 {
+			return nil, nil, fmt.Errorf("invalid string length")
+		}
+		length, err := strconv.Atoi(string(data[:colon]))
+		if err != nil || length < 0 || colon+1+length > len(data) {
+			return nil, nil, fmt.Errorf("invalid string length")
+		}
+		return string(data[colon+1 : colon+1+length]), data[colon+1+length:], nil
+	}
+	return nil, nil, fmt.Errorf("unexpected byte %q", data[0])
+}
+
+// checkHealth scrapes the magnet's trackers and applies HEALTH_CHECK: "reject"
+// fails with TORRENT_NO_SEEDERS below HEALTH_MIN_SEEDERS, "warn" adds a warning.
+// Trackers that can't be reached never block an add.
+func (h *TorrentHandler) checkHealth(ctx context.Context, p *AddPipeline) error {
+	health, err := scrapeTorrentHealth(ctx, p.Request.MagnetLink)
+	p.Health = health
+	if err != nil {
+		p.Warnings = append(p.Warnings, "Could not check torrent health: "+err.Error())
+		return err
+	}
+
+	config := h.cfg()
+	if health.Seeders >= config.HealthMinSeeders {
+		return nil
+	}
+	if config.HealthCheck == HealthCheckReject {
+		return newAPIError(ErrCodeNoSeeders, "torrent has %d seeders, at least %d required", health.Seeders, config.HealthMinSeeders)
+	}
+	p.Warnings = append(p.Warnings, fmt.Sprintf("Torrent has only %d seeders and may never finish", health.Seeders))
+	return nil
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// History record statuses
+const (
+	HistoryStatusAdded           = "added"
+	HistoryStatusGrabbed         = "grabbed"  // Radarr/Sonarr grabbed a release (webhook)
+	HistoryStatusImported        = "imported" // Radarr/Sonarr imported the download (webhook)
+	HistoryStatusFailed          = "failed"
+	HistoryStatusRemovedUpstream = "removed_upstream" // deleted from Radarr/Sonarr after we added it
+	HistoryStatusProcessing      = "processing"       // add still running, kept alive by its heartbeat
+	HistoryStatusDeleted         = "deleted"          // torrent and library item removed with DELETE /api/torrent/{hash}
+)
+
+// HistoryRecord is one add handled by this service
+type HistoryRecord struct {
+	ID             int64      `json:"id"`
+	AddedAt        time.Time  `json:"added_at"`
+	Source         string     `json:"source"` // "torrent", "media", "rss" or "watch"
+	Name           string     `json:"name"`   // torrent name or requested title
+	MagnetLink     string     `json:"magnet_link,omitempty"`
+	InfoHash       string     `json:"info_hash,omitempty"`
+	ExtractedTitle string     `json:"extracted_title,omitempty"` // what the extractor made of the name
+	Category       string     `json:"category,omitempty"`
+	MediaType      string     `json:"media_type,omitempty"` // "movie" or "tv"
+	MediaTitle     string     `json:"media_title,omitempty"`
+	MediaID        int        `json:"media_id,omitempty"` // Radarr movie / Sonarr series ID we added
+	Status         string     `json:"status"`
+	Code           string     `json:"code,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	RolledBack     bool       `json:"rolled_back,omitempty"`
+	AutoGrabbed    bool       `json:"auto_grabbed,omitempty"` // added by the rss worker for a wanted movie
+	Feed           string     `json:"feed,omitempty"`
+	RemovedAt      *time.Time `json:"removed_at,omitempty"`
+	TorrentRemoved bool       `json:"torrent_removed,omitempty"`
+	Release        string     `json:"release,omitempty"` // last release grabbed by Radarr/Sonarr
+	GrabbedAt      *time.Time `json:"grabbed_at,omitempty"`
+	ImportedAt     *time.Time `json:"imported_at,omitempty"`
+	CompleteSeries bool       `json:"complete_series,omitempty"` // imported by the packimport worker
+	Usenet         string     `json:"usenet,omitempty"`          // NZB grabbed instead of the dead torrent
+	LibraryRetry   bool       `json:"library_retry,omitempty"`   // library add waits for Radarr/Sonarr (libraryretry worker)
+	LibraryRetries int        `json:"library_retries,omitempty"`
+	Trackers       []string   `json:"trackers,omitempty"`     // tracker domains of the magnet
+	StalledAt      *time.Time `json:"stalled_at,omitempty"`   // seen stalled by the trackerstats worker
+	CompletedAt    *time.Time `json:"completed_at,omitempty"` // seen finished by the trackerstats worker
+	// Sonarr episode files to delete once the download finishes (DUPLICATE_EPISODES=replace)
+	ReplaceEpisodeFiles []int `json:"replace_episode_files,omitempty"`
+	// Set while processing, so the reaper can retry an add cut short by a crash
+	HeartbeatAt *time.Time         `json:"heartbeat_at,omitempty"`
+	Request     *AddTorrentRequest `json:"request,omitempty"`
+	APIKey      string             `json:"api_key,omitempty"`  // name of the key that asked for the add
+	Attempts    int                `json:"attempts,omitempty"` // runs of the add, counting reaper retries
+	FinishedAt  *time.Time         `json:"finished_at,omitempty"`
+}
+
+// Active reports whether the record's item is still expected in the library
+func (r HistoryRecord) Active() bool {
+	switch r.Status {
+	case HistoryStatusAdded, HistoryStatusGrabbed, HistoryStatusImported:
+		return true
+	}
+	return false
+}
+
+// Default HISTORY_MAX_RECORDS
+const defaultHistoryMaxRecords = 10000
+
+// HistoryStore keeps the add history in memory, persisted as JSON to path when set
+type HistoryStore struct {
+	mu         sync.Mutex
+	path       string
+	records    []HistoryRecord
+	nextID     int64
+	maxRecords int // oldest finished records beyond this are dropped; 0 keeps all
+}
+
+// NewHistoryStore loads the history from path; an empty path keeps it in memory
+// only. It keeps at most maxRecords records, 0 for no limit.
+func NewHistoryStore(path string, maxRecords int) (*HistoryStore, error) {
+	s := &HistoryStore{path: path, nextID: 1, maxRecords: maxRecords}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.records); err != nil {
+		return nil, fmt.Errorf("failed to parse history %s: %w", path, err)
+	}
+	for _, record := range s.records {
+		if record.ID >= s.nextID {
+			s.nextID = record.ID + 1
+		}
+	}
+	s.prune()
+	return s, nil
+}
+
+// Add stores a new record and returns its ID
+func (s *HistoryStore) Add(record HistoryRecord) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record.ID = s.nextID
+	s.nextID++
+	if record.AddedAt.IsZero() {
+		record.AddedAt = time.Now()
+	}
+	s.records = append(s.records, record)
+	s.prune()
+	return record.ID, s.save()
+}
+
+// prune drops the oldest records beyond maxRecords; adds still processing are
+// kept for the reaper. Callers hold s.mu.
+func (s *HistoryStore) prune() {
+	excess := len(s.records) - s.maxRecords
+	if s.maxRecords <= 0 || excess <= 0 {
+		return
+	}
+	kept := s.records[:0]
+	for _, record := range s.records {
+		if excess > 0 && record.Status != HistoryStatusProcessing {
+			excess--
+			continue
+		}
+		kept = append(kept, record)
+	}
+	s.records = kept
+}
+
+// Update applies fn to the record with the given ID
+func (s *HistoryStore) Update(id int64, fn func(record *HistoryRecord)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.records {
+		if s.records[i].ID == id {
+			fn(&s.records[i])
+			return s.save()
+		}
+	}
+	return fmt.Errorf("history record %d not found", id)
+}
+
+// Heartbeat marks a processing add as alive. Only this process's heartbeats
+// count, so it is kept in memory and not saved.
+func (s *HistoryStore) Heartbeat(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.records {
+		if s.records[i].ID == id && s.records[i].Status == HistoryStatusProcessing {
+			now := time.Now()
+			s.records[i].HeartbeatAt = &now
+			return
+		}
+	}
+}
+
+// List returns a copy of all finished records, oldest first
+func (s *HistoryStore) List() []HistoryRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]HistoryRecord, 0, len(s.records))
+	for _, record := range s.records {
+		if record.Status != HistoryStatusProcessing {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// Unfinished returns a copy of the records of adds still processing, oldest first
+func (s *HistoryStore) Unfinished() []HistoryRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []HistoryRecord
+	for _, record := range s.records {
+		if record.Status == HistoryStatusProcessing {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// refusedAdd reports whether an add failed with code only because it waits for
+// the caller to resend it with force or confirm
+func refusedAdd(code string) bool {
+	return code == ErrCodePreviouslyFailed || code == ErrCodeCrossLibraryDuplicate
+}
+
+// PreviousFailure returns the failed record when the latest attempt at infoHash,
+// or else at title, failed; nil when there is none or a later attempt succeeded
+func (s *HistoryStore) PreviousFailure(infoHash, title string) *HistoryRecord {
+	records := s.List()
+	latest := func(match func(record HistoryRecord) bool) *HistoryRecord {
+		for i := len(records) - 1; i >= 0; i-- {
+			// Refused adds are not attempts of their own, nor are crashed ones
+			if refusedAdd(records[i].Code) || records[i].Code == ErrCodeAddInterrupted || !match(records[i]) {
+				continue
+			}
+			if records[i].Status == HistoryStatusFailed {
+				return &records[i]
+			}
+			return nil
+		}
+		return nil
+	}
+
+	if infoHash != "" {
+		if record := latest(func(record HistoryRecord) bool { return record.InfoHash == infoHash }); record != nil {
+			return record
+		}
+	}
+	if title = normalizeTitle(title); title != "" {
+		return latest(func(record HistoryRecord) bool { return normalizeTitle(record.MediaTitle) == title })
+	}
+	return nil
+}
+
+// LatestAdded returns the latest successful add of the torrent with the given
+// hex info hash, or nil
+func (s *HistoryStore) LatestAdded(hash string) *HistoryRecord {
+	records := s.List()
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Status == HistoryStatusFailed || records[i].InfoHash == "" {
+			continue
+		}
+		// Base32 magnets are recorded as given
+		if b, err := decodeInfoHash(records[i].InfoHash); err == nil && hex.EncodeToString(b) == hash {
+			return &records[i]
+		}
+	}
+	return nil
+}
+
+// save writes the history atomically; callers hold s.mu
+func (s *HistoryStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s.records)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".history-*")
+	if err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	return nil
+}
+
+// recordPipeline is a pipeline completion hook that records every torrent add
+func (h *TorrentHandler) recordPipeline(p *AddPipeline, err error) {
+	finishedAt := p.StartedAt.Add(p.Duration)
+	record := HistoryRecord{
+		AddedAt:    p.StartedAt,
+		Source:     "torrent",
+		Name:       p.TorrentName,
+		MagnetLink: p.Request.MagnetLink,
+		InfoHash:   extractInfoHash(p.Request.MagnetLink),
+		Trackers:   trackerDomains(p.Request.MagnetLink),
+		Category:   p.Category,
+		MediaTitle: p.MediaTitle,
+		MediaID:    p.MediaID,
+		Status:     HistoryStatusAdded,
+		RolledBack: p.RolledBack,
+
+		CompleteSeries: p.CompleteSeries,
+		LibraryRetry:   p.LibraryRetry && err == nil,
+		APIKey:         p.APIKey,
+		Attempts:       p.Attempt,
+		FinishedAt:     &finishedAt,
+	}
+	if p.Extracted != nil {
+		record.ExtractedTitle = p.Extracted.ExtractedName
+	}
+	if p.Usenet != nil {
+		record.Usenet = p.Usenet.Title
+	}
+	if p.Request.Feed != "" {
+		record.Source = "rss"
+		record.AutoGrabbed = true
+		record.Feed = p.Request.Feed
+	}
+	if p.Request.WatchFile != "" {
+		record.Source = "watch"
+	}
+	if p.NonMedia == "" && p.Category != "" {
+		record.MediaType = "tv"
+		if p.IsMovie {
+			record.MediaType = "movie"
+		}
+	}
+	if err != nil {
+		record.Status = HistoryStatusFailed
+		record.Code = errorCode(err)
+		record.Error = err.Error()
+	} else {
+		record.ReplaceEpisodeFiles = replacedEpisodeFiles(p)
+	}
+
+	// A journaled add's processing record becomes the final one
+	if p.HistoryID != 0 && h.history != nil {
+		if err := h.history.Update(p.HistoryID, func(r *HistoryRecord) {
+			record.ID = r.ID
+			*r = record
+		}); err != nil {
+			log.Printf("Warning: could not record history: %v", err)
+		}
+		return
+	}
+	h.recordHistory(record)
+}
+
+// recordHistory stores a record when history is enabled, logging failures
+func (h *TorrentHandler) recordHistory(record HistoryRecord) {
+	if h.history == nil {
+		return
+	}
+	if _, err := h.history.Add(record); err != nil {
+		log.Printf("Warning: could not record history: %v", err)
+	}
+}
+
+// Page size limits of GET /api/history
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+// HistoryFilter selects records for GET /api/history; zero fields match all
+type HistoryFilter struct {
+	MediaType string // "movie", "tv", or "other" for non-media and unmatched adds
+	Status    string
+	Since     time.Time
+	Until     time.Time // exclusive
+	APIKey    string
+}
+
+func (f HistoryFilter) match(record HistoryRecord) bool {
+	switch {
+	case f.MediaType == "other" && record.MediaType != "":
+		return false
+	case f.MediaType != "" && f.MediaType != "other" && record.MediaType != f.MediaType:
+		return false
+	case f.Status != "" && record.Status != f.Status:
+		return false
+	case !f.Since.IsZero() && record.AddedAt.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !record.AddedAt.Before(f.Until):
+		return false
+	case f.APIKey != "" && record.APIKey != f.APIKey:
+		return false
+	}
+	return true
+}
+
+// parseHistoryTime parses an RFC 3339 time or a date; a date as the end of a
+// range includes that whole day
+func parseHistoryTime(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, use 2024-05-01 or 2024-05-01T10:00:00Z", value)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// parseHistoryQuery reads the filter and page of GET /api/history
+func parseHistoryQuery(query url.Values) (filter HistoryFilter, page, limit int, err error) {
+	switch filter.MediaType = query.Get("type"); filter.MediaType {
+	case "", "movie", "tv", "other":
+	case "series":
+		filter.MediaType = "tv"
+	default:
+		return filter, 0, 0, fmt.Errorf("invalid type, use 'movie', 'tv' or 'other'")
+	}
+	filter.Status = query.Get("status")
+	if v := query.Get("since"); v != "" {
+		if filter.Since, err = parseHistoryTime(v, false); err != nil {
+			return filter, 0, 0, err
+		}
+	}
+	if v := query.Get("until"); v != "" {
+		if filter.Until, err = parseHistoryTime(v, true); err != nil {
+			return filter, 0, 0, err
+		}
+	}
+
+	page, limit = 1, defaultHistoryLimit
+	if v := query.Get("page"); v != "" {
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			return filter, 0, 0, fmt.Errorf("invalid page, use 1 or more")
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxHistoryLimit {
+			return filter, 0, 0, fmt.Errorf("invalid limit, use 1-%d", maxHistoryLimit)
+		}
+	}
+	return filter, page, limit, nil
+}
+
+type HistoryResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Total   int             `json:"total"` // records matching the filter, on all pages
+	Page    int             `json:"page"`
+	Limit   int             `json:"limit"`
+	Records []HistoryRecord `json:"records"`
+}
+
+// History pages through the finished add history, newest first, filtered by
+// ?type=, ?status= and a ?since=/?until= range. Keys that aren't admins only
+// see their own adds.
+func (h *TorrentHandler) History(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(HistoryResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+
+	filter, page, limit, err := parseHistoryQuery(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(HistoryResponse{
+			Success: false,
+			Message: "Invalid query: " + err.Error(),
+		})
+		return
+	}
+	if key := apiKeyFromContext(r.Context()); key != nil && !key.Admin {
+		filter.APIKey = key.Name
+	}
+
+	var records []HistoryRecord
+	if h.history != nil {
+		all := h.history.List()
+		for i := len(all) - 1; i >= 0; i-- {
+			if filter.match(all[i]) {
+				records = append(records, all[i])
+			}
+		}
+	}
+	total := len(records)
+	start := min((page-1)*limit, total)
+	end := min(start+limit, total)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(HistoryResponse{
+		Success: true,
+		Message: "OK",
+		Total:   total,
+		Page:    page,
+		Limit:   limit,
+		Records: append([]HistoryRecord{}, records[start:end]...),
+	})
+}
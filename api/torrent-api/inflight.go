@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// StepWaiting is the step of an add waiting for an ADD_CONCURRENCY slot
+const StepWaiting = "waiting"
+
+// InflightAdd is a torrent add that hasn't finished, as GET /api/inflight shows it
+type InflightAdd struct {
+	Name          string       `json:"name"`
+	APIKey        string       `json:"api_key,omitempty"`
+	Category      string       `json:"category,omitempty"`
+	Step          string       `json:"step"`            // step running now, or "waiting" for a slot
+	StepAttempt   int          `json:"step_attempt"`    // retries count up from 1
+	StepElapsedMs int64        `json:"step_elapsed_ms"` // in the current step
+	ElapsedMs     int64        `json:"elapsed_ms"`      // since the add started
+	Steps         []StepResult `json:"steps,omitempty"` // the steps it finished
+	StartedAt     time.Time    `json:"started_at"`
+	stepStartedAt time.Time
+}
+
+type InflightResponse struct {
+	Success bool          `json:"success"`
+	Message string        `json:"message"`
+	Adds    []InflightAdd `json:"adds"`
+}
+
+// InflightAdds tracks the step every running add is in
+type InflightAdds struct {
+	mu   sync.Mutex
+	adds map[*AddPipeline]*InflightAdd
+}
+
+func NewInflightAdds() *InflightAdds {
+	return &InflightAdds{adds: make(map[*AddPipeline]*InflightAdd)}
+}
+
+// Track lists p as waiting until the returned func is called
+func (a *InflightAdds) Track(p *AddPipeline) func() {
+	a.mu.Lock()
+	a.adds[p] = &InflightAdd{
+		Name:          p.TorrentName,
+		APIKey:        p.APIKey,
+		Step:          StepWaiting,
+		StepAttempt:   1,
+		StartedAt:     p.StartedAt,
+		stepStartedAt: time.Now(),
+	}
+	a.mu.Unlock()
+
+	return func() {
+		a.mu.Lock()
+		delete(a.adds, p)
+		a.mu.Unlock()
+	}
+}
+
+// step records that p started an attempt at a step
+func (a *InflightAdds) step(p *AddPipeline, name string, attempt int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if add, ok := a.adds[p]; ok {
+		add.Step, add.StepAttempt, add.Category = name, attempt, p.Category
+		if attempt == 1 {
+			add.stepStartedAt = time.Now()
+		}
+	}
+}
+
+// finished records a step result of p
+func (a *InflightAdds) finished(p *AddPipeline, result StepResult) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if add, ok := a.adds[p]; ok {
+		add.Steps = append(add.Steps, result)
+		add.Category = p.Category
+	}
+}
+
+// List returns the adds, longest running first
+func (a *InflightAdds) List() []InflightAdd {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	adds := make([]InflightAdd, 0, len(a.adds))
+	for _, add := range a.adds {
+		copied := *add
+		copied.Steps = append([]StepResult(nil), add.Steps...)
+		copied.StepElapsedMs = now.Sub(add.stepStartedAt).Milliseconds()
+		copied.ElapsedMs = now.Sub(add.StartedAt).Milliseconds()
+		adds = append(adds, copied)
+	}
+	sort.Slice(adds, func(i, j int) bool { return adds[i].StartedAt.Before(adds[j].StartedAt) })
+	return adds
+}
+
+// Inflight lists the torrent adds being processed with the step each is in, to
+// tell a hung extractor from a slow Sonarr
+func (h *TorrentHandler) Inflight(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(InflightResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(InflightResponse{
+		Success: true,
+		Message: "OK",
+		Adds:    h.pipeline.inflight.List(),
+	})
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Each service probe gets this long during init
+const initProbeTimeout = 10 * time.Second
+
+// initSetting is one value `torrent-api init` asks for
+type initSetting struct {
+	name   string
+	prompt string
+	value  string
+	secret bool // kept out of -config-dir and not echoed as a default
+}
+
+// initSession holds the answers and prints progress
+type initSession struct {
+	settings    []*initSetting
+	interactive bool
+	in          *bufio.Reader
+	out         io.Writer
+	failures    int
+}
+
+// runInit writes a starter .env: it asks for the service URLs and credentials
+// (or takes them from flags), probes each service, lets the user pick the
+// Radarr/Sonarr root folder and quality profile, and verifies the result.
+func runInit(args []string) error {
+	s := &initSession{
+		settings: []*initSetting{
+			{name: "PORT", prompt: "Port to listen on", value: "8080"},
+			{name: "QBITTORRENT_URL", prompt: "qBittorrent WebUI URL", value: "http://localhost:8080"},
+			{name: "QBITTORRENT_USERNAME", prompt: "qBittorrent username", value: "admin"},
+			{name: "QBITTORRENT_PASSWORD", prompt: "qBittorrent password", secret: true},
+			{name: "RADARR_URL", prompt: "Radarr URL", value: "http://localhost:7878"},
+			{name: "RADARR_API_KEY", prompt: "Radarr API key (Settings > General)", secret: true},
+			{name: "RADARR_ROOT_FOLDER", prompt: "Radarr root folder"},
+			{name: "RADARR_QUALITY_PROFILE", prompt: "Radarr quality profile"},
+			{name: "SONARR_URL", prompt: "Sonarr URL", value: "http://localhost:8989"},
+			{name: "SONARR_API_KEY", prompt: "Sonarr API key (Settings > General)", secret: true},
+			{name: "SONARR_ROOT_FOLDER", prompt: "Sonarr root folder"},
+			{name: "SONARR_QUALITY_PROFILE", prompt: "Sonarr quality profile"},
+			{name: "NAME_EXTRACTOR_URL", prompt: "Name extractor URL", value: "http://localhost:8000"},
+			{name: "API_KEYS", prompt: "Client API keys, name:key comma separated (empty for an open API)", secret: true},
+		},
+		in:  bufio.NewReader(os.Stdin),
+		out: os.Stdout,
+	}
+
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	envPath := flags.String("env", ".env", "file to write")
+	configDir := flags.String("config-dir", "", "also write the non-secret settings to this directory, one file per setting (CONFIG_DIR layout)")
+	force := flags.Bool("force", false, "overwrite existing files")
+	yes := flags.Bool("yes", false, "don't ask; take flags, defaults and the first root folder and quality profile")
+	for _, setting := range s.settings {
+		flags.StringVar(&setting.value, strings.ReplaceAll(strings.ToLower(setting.name), "_", "-"), setting.value, setting.prompt)
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[strings.ReplaceAll(strings.ToUpper(f.Name), "-", "_")] = true
+	})
+
+	if _, err := os.Stat(*envPath); err == nil && !*force {
+		return fmt.Errorf("%s already exists; use -force to overwrite it", *envPath)
+	}
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		s.interactive = !*yes
+	}
+
+	fmt.Fprintln(s.out, "torrent-api setup. Press Enter to keep the value in brackets.")
+
+	s.section("qBittorrent")
+	s.ask(given, "QBITTORRENT_URL", "QBITTORRENT_USERNAME", "QBITTORRENT_PASSWORD")
+	s.probeQBittorrent()
+
+	s.section("Radarr")
+	s.ask(given, "RADARR_URL", "RADARR_API_KEY")
+	s.setupRadarr(given)
+
+	s.section("Sonarr")
+	s.ask(given, "SONARR_URL", "SONARR_API_KEY")
+	s.setupSonarr(given)
+
+	s.section("Name extractor")
+	s.ask(given, "NAME_EXTRACTOR_URL")
+	s.probeExtractor()
+
+	s.section("Server")
+	s.ask(given, "PORT", "API_KEYS")
+	if _, err := parseAPIKeys(s.get("API_KEYS")); err != nil {
+		s.fail("API_KEYS: %v", err)
+	}
+
+	if err := s.write(*envPath, *configDir); err != nil {
+		return err
+	}
+	if s.failures > 0 {
+		return fmt.Errorf("%d check(s) failed; fix them in %s and start the server", s.failures, *envPath)
+	}
+	fmt.Fprintln(s.out, "\nAll checks passed. Start the server with: torrent-api")
+	return nil
+}
+
+func (s *initSession) setting(name string) *initSetting {
+	for _, setting := range s.settings {
+		if setting.name == name {
+			return setting
+		}
+	}
+	panic("unknown init setting " + name)
+}
+
+func (s *initSession) get(name string) string {
+	return s.setting(name).value
+}
+
+func (s *initSession) section(title string) {
+	fmt.Fprintf(s.out, "\n== %s ==\n", title)
+}
+
+func (s *initSession) ok(format string, args ...interface{}) {
+	fmt.Fprintf(s.out, "  ok: %s\n", fmt.Sprintf(format, args...))
+}
+
+func (s *initSession) fail(format string, args ...interface{}) {
+	s.failures++
+	fmt.Fprintf(s.out, "  FAILED: %s\n", fmt.Sprintf(format, args...))
+}
+
+// ask prompts for settings not given as flags
+func (s *initSession) ask(given map[string]bool, names ...string) {
+	for _, name := range names {
+		setting := s.setting(name)
+		if !s.interactive || given[name] {
+			continue
+		}
+		current := setting.value
+		if setting.secret && current != "" {
+			current = "set"
+		}
+		if answer := s.readLine(fmt.Sprintf("%s [%s]: ", setting.prompt, current)); answer != "" {
+			setting.value = answer
+		}
+	}
+}
+
+func (s *initSession) readLine(prompt string) string {
+	fmt.Fprint(s.out, prompt)
+	line, _ := s.in.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// choose lets the user pick one of options by number. A preset value
+// must be one of them; otherwise the first is the default.
+func (s *initSession) choose(name string, preset bool, options, labels []string) {
+	setting := s.setting(name)
+	if len(options) == 0 {
+		return
+	}
+	if preset {
+		for _, option := range options {
+			if strings.EqualFold(strings.TrimSuffix(option, "/"), strings.TrimSuffix(setting.value, "/")) {
+				setting.value = option
+				s.ok("%s: %s", setting.prompt, option)
+				return
+			}
+		}
+		s.fail("%s %q not found, available: %s", setting.prompt, setting.value, strings.Join(options, ", "))
+		return
+	}
+
+	choice := 0
+	if s.interactive && len(options) > 1 {
+		fmt.Fprintf(s.out, "  %ss:\n", setting.prompt)
+		for i, label := range labels {
+			fmt.Fprintf(s.out, "    %d) %s\n", i+1, label)
+		}
+		for {
+			answer := s.readLine(fmt.Sprintf("%s [1]: ", setting.prompt))
+			if answer == "" {
+				break
+			}
+			if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+				choice = n - 1
+				break
+			}
+			fmt.Fprintf(s.out, "  Enter a number from 1 to %d\n", len(options))
+		}
+	}
+	setting.value = options[choice]
+	s.ok("%s: %s", setting.prompt, setting.value)
+}
+
+func (s *initSession) probeQBittorrent() {
+	ctx, cancel := context.WithTimeout(context.Background(), initProbeTimeout)
+	defer cancel()
+	client := NewQBittorrentClient(s.get("QBITTORRENT_URL"), s.get("QBITTORRENT_USERNAME"), s.get("QBITTORRENT_PASSWORD"))
+	if err := client.Login(ctx); err != nil {
+		s.fail("qBittorrent login: %v", err)
+		return
+	}
+	s.ok("logged in to qBittorrent")
+}
+
+func (s *initSession) setupRadarr(given map[string]bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), initProbeTimeout)
+	defer cancel()
+	client := NewRadarrClient(s.get("RADARR_URL"), s.get("RADARR_API_KEY"))
+	status, err := client.GetSystemStatus(ctx)
+	if err != nil {
+		s.fail("Radarr: %v", err)
+		return
+	}
+	if !strings.EqualFold(status.AppName, "Radarr") {
+		s.fail("%s answered at RADARR_URL, not Radarr", status.AppName)
+		return
+	}
+	s.ok("%s %s", status.AppName, status.Version)
+	// Redirects and URL bases found while probing are saved
+	s.setting("RADARR_URL").value = client.baseURL.Get()
+
+	folders, err := client.GetRootFolders(ctx)
+	if err != nil || len(folders) == 0 {
+		s.fail("Radarr root folders: none found (%v); add one under Settings > Media Management", err)
+	} else {
+		options, labels := make([]string, len(folders)), make([]string, len(folders))
+		for i, folder := range folders {
+			options[i], labels[i] = folder.Path, rootFolderLabel(folder.Path, folder.FreeSpace)
+		}
+		s.choose("RADARR_ROOT_FOLDER", given["RADARR_ROOT_FOLDER"], options, labels)
+		for _, folder := range folders {
+			if folder.Path == s.get("RADARR_ROOT_FOLDER") {
+				if err := folder.Validate(); err != nil {
+					s.fail("%v", err)
+				}
+			}
+		}
+	}
+
+	profiles, err := client.GetQualityProfiles(ctx)
+	if err != nil || len(profiles) == 0 {
+		s.fail("Radarr quality profiles: none found (%v)", err)
+		return
+	}
+	names := make([]string, len(profiles))
+	for i, profile := range profiles {
+		names[i] = profile.Name
+	}
+	s.choose("RADARR_QUALITY_PROFILE", given["RADARR_QUALITY_PROFILE"], names, names)
+}
+
+func (s *initSession) setupSonarr(given map[string]bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), initProbeTimeout)
+	defer cancel()
+	client := NewSonarrClient(s.get("SONARR_URL"), s.get("SONARR_API_KEY"))
+	status, err := client.GetSystemStatus(ctx)
+	if err != nil {
+		s.fail("Sonarr: %v", err)
+		return
+	}
+	if !strings.EqualFold(status.AppName, "Sonarr") {
+		s.fail("%s answered at SONARR_URL, not Sonarr", status.AppName)
+		return
+	}
+	s.ok("%s %s", status.AppName, status.Version)
+	s.setting("SONARR_URL").value = client.baseURL.Get()
+
+	folders, err := client.GetRootFolders(ctx)
+	if err != nil || len(folders) == 0 {
+		s.fail("Sonarr root folders: none found (%v); add one under Settings > Media Management", err)
+	} else {
+		options, labels := make([]string, len(folders)), make([]string, len(folders))
+		for i, folder := range folders {
+			options[i], labels[i] = folder.Path, rootFolderLabel(folder.Path, folder.FreeSpace)
+		}
+		s.choose("SONARR_ROOT_FOLDER", given["SONARR_ROOT_FOLDER"], options, labels)
+		for _, folder := range folders {
+			if folder.Path == s.get("SONARR_ROOT_FOLDER") {
+				if err := folder.Validate(); err != nil {
+					s.fail("%v", err)
+				}
+			}
+		}
+	}
+
+	profiles, err := client.GetQualityProfiles(ctx)
+	if err != nil || len(profiles) == 0 {
+		s.fail("Sonarr quality profiles: none found (%v)", err)
+		return
+	}
+	names := make([]string, len(profiles))
+	for i, profile := range profiles {
+		names[i] = profile.Name
+	}
+	s.choose("SONARR_QUALITY_PROFILE", given["SONARR_QUALITY_PROFILE"], names, names)
+}
+
+func (s *initSession) probeExtractor() {
+	ctx, cancel := context.WithTimeout(context.Background(), initProbeTimeout)
+	defer cancel()
+	client := NewNameExtractorClient(s.get("NAME_EXTRACTOR_URL"), 0)
+	if _, err := client.ExtractName(ctx, "Big.Buck.Bunny.2008.1080p.BluRay.x264"); err != nil {
+		// Adds fall back to local extraction, so this isn't fatal
+		fmt.Fprintf(s.out, "  warning: name extractor not reachable, local extraction will be used: %v\n", err)
+		return
+	}
+	s.ok("name extractor answered")
+}
+
+func rootFolderLabel(path string, freeSpace *int64) string {
+	if freeSpace == nil {
+		return path
+	}
+	return fmt.Sprintf("%s (%.1f GB free)", path, float64(*freeSpace)/1e9)
+}
+
+// write saves the .env and, with configDir, the non-secret settings as files
+func (s *initSession) write(envPath, configDir string) error {
+	var env strings.Builder
+	fmt.Fprintf(&env, "# Written by torrent-api init on %s; see .env.example for every setting\n", time.Now().Format("2006-01-02"))
+	if configDir != "" {
+		if err := os.MkdirAll(configDir, 0o755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", configDir, err)
+		}
+		fmt.Fprintf(&env, "CONFIG_DIR=%s\n", envValue(configDir))
+	}
+	for _, setting := range s.settings {
+		if configDir != "" && !setting.secret {
+			if err := os.WriteFile(filepath.Join(configDir, setting.name), []byte(setting.value+"\n"), 0o644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", setting.name, err)
+			}
+			continue
+		}
+		fmt.Fprintf(&env, "%s=%s\n", setting.name, envValue(setting.value))
+	}
+
+	// Holds credentials
+	if err := os.WriteFile(envPath, []byte(env.String()), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", envPath, err)
+	}
+	fmt.Fprintf(s.out, "\nWrote %s\n", envPath)
+	if configDir != "" {
+		fmt.Fprintf(s.out, "Wrote settings to %s\n", configDir)
+	}
+	return nil
+}
+
+// envValue quotes values godotenv would otherwise cut at a space or #
+func envValue(value string) string {
+	if strings.ContainsAny(value, " \t#\"'\\") {
+		return strconv.Quote(value)
+	}
+	return value
+}
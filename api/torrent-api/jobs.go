@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of job
+const (
+	JobKindSearch = "search" // a paced Radarr/Sonarr search after an add
+	JobKindAdd    = "add"    // a torrent add waiting for or holding an ADD_CONCURRENCY slot
+)
+
+// Add priorities; each has its own ADD_CONCURRENCY budget, and background adds
+// don't start while interactive ones are waiting
+const (
+	PriorityInteractive = "interactive" // someone is waiting: the extension, share, chat bots
+	PriorityBackground  = "background"  // RSS auto-grab and other unattended adds
+)
+
+// addPriorities in the order waiting adds are started
+var addPriorities = []string{PriorityInteractive, PriorityBackground}
+
+// Job statuses
+const (
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	// Only async adds (see asyncadd.go) stay listed once finished
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// Job is background work started by a request, listed by /api/jobs
+type Job struct {
+	ID       string    `json:"id"`
+	Kind     string    `json:"kind"`
+	Title    string    `json:"title"`
+	App      string    `json:"app,omitempty"` // "radarr" or "sonarr"
+	MediaID  int       `json:"media_id,omitempty"`
+	Priority string    `json:"priority,omitempty"` // adds only
+	Status   string    `json:"status"`
+	QueuedAt time.Time `json:"queued_at"`
+	RunAt    time.Time `json:"run_at"` // when it started or is due; zero while an add waits
+}
+
+type JobsResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Jobs    []Job  `json:"jobs"`
+}
+
+// SearchPacer spreads Radarr/Sonarr search commands at least SEARCH_PACE apart,
+// so importing a list doesn't fire a search per title at once and get the
+// indexers' API keys banned. Pending searches are lost on restart.
+type SearchPacer struct {
+	mu     sync.Mutex
+	next   time.Time // earliest time the next search may run
+	nextID int64
+	jobs   map[string]*Job
+}
+
+func NewSearchPacer() *SearchPacer {
+	return &SearchPacer{jobs: make(map[string]*Job)}
+}
+
+// Schedule runs search once pace has passed since the previous scheduled
+// search and returns when that is
+func (p *SearchPacer) Schedule(pace time.Duration, job Job, search func(ctx context.Context) error) time.Time {
+	p.mu.Lock()
+	now := time.Now()
+	runAt := now
+	if p.next.After(now) {
+		runAt = p.next
+	}
+	p.next = runAt.Add(pace)
+	p.nextID++
+	job.ID = fmt.Sprintf("search-%d", p.nextID)
+	job.Kind, job.Status, job.QueuedAt, job.RunAt = JobKindSearch, JobStatusPending, now, runAt
+	p.jobs[job.ID] = &job
+	p.mu.Unlock()
+
+	time.AfterFunc(runAt.Sub(now), func() {
+		p.run(job.ID, job.Title, search)
+	})
+	return runAt
+}
+
+func (p *SearchPacer) run(id, title string, search func(ctx context.Context) error) {
+	p.mu.Lock()
+	p.jobs[id].Status = JobStatusRunning
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := search(ctx); err != nil {
+		log.Printf("Warning: search for %s failed: %v", title, err)
+	} else {
+		log.Printf("Search started for %s", title)
+	}
+
+	p.mu.Lock()
+	delete(p.jobs, id)
+	p.mu.Unlock()
+}
+
+// Jobs returns the searches not finished yet, soonest first
+func (p *SearchPacer) Jobs() []Job {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	jobs := make([]Job, 0, len(p.jobs))
+	for _, job := range p.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].RunAt.Before(jobs[j].RunAt)
+	})
+	return jobs
+}
+
+type jobPriorityKey struct{}
+
+// withJobPriority marks the adds made with ctx as priority
+func withJobPriority(ctx context.Context, priority string) context.Context {
+	return context.WithValue(ctx, jobPriorityKey{}, priority)
+}
+
+// jobPriority is the priority of adds made with ctx, interactive by default
+func jobPriority(ctx context.Context) string {
+	if priority, ok := ctx.Value(jobPriorityKey{}).(string); ok {
+		return priority
+	}
+	return PriorityInteractive
+}
+
+// AddQueue bounds how many torrent adds of each priority run at once, so a
+// burst of background adds can't delay someone clicking the extension button
+type AddQueue struct {
+	mu      sync.Mutex
+	limits  map[string]int
+	running map[string]int
+	waiting map[string][]*queuedAdd
+	nextID  int64
+	jobs    map[string]*Job
+}
+
+type queuedAdd struct {
+	job   *Job
+	ready chan struct{} // closed when the add may start
+}
+
+func NewAddQueue() *AddQueue {
+	return &AddQueue{
+		running: make(map[string]int),
+		waiting: make(map[string][]*queuedAdd),
+		jobs:    make(map[string]*Job),
+	}
+}
+
+// Acquire waits for a slot of priority under limits (priority to maximum
+// concurrent adds, from ADD_CONCURRENCY) and returns the function that frees it
+func (q *AddQueue) Acquire(ctx context.Context, priority string, limits map[string]int, title string) (func(), error) {
+	q.mu.Lock()
+	q.limits = limits
+	q.nextID++
+	now := time.Now()
+	add := &queuedAdd{
+		job: &Job{
+			ID:       fmt.Sprintf("add-%d", q.nextID),
+			Kind:     JobKindAdd,
+			Title:    title,
+			Priority: priority,
+			Status:   JobStatusPending,
+			QueuedAt: now,
+		},
+		ready: make(chan struct{}),
+	}
+	q.jobs[add.job.ID] = add.job
+	q.waiting[priority] = append(q.waiting[priority], add)
+	q.dispatch()
+	q.mu.Unlock()
+
+	release := func() {
+		q.mu.Lock()
+		q.running[priority]--
+		delete(q.jobs, add.job.ID)
+		q.dispatch()
+		q.mu.Unlock()
+	}
+
+	select {
+	case <-add.ready:
+		return release, nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case <-add.ready:
+		// Started just as the caller gave up
+		q.running[priority]--
+	default:
+		waiting := q.waiting[priority]
+		for i := range waiting {
+			if waiting[i] == add {
+				q.waiting[priority] = append(waiting[:i:i], waiting[i+1:]...)
+				break
+			}
+		}
+	}
+	delete(q.jobs, add.job.ID)
+	q.dispatch()
+	return nil, ctx.Err()
+}
+
+// dispatch starts waiting adds while their priority has free slots; q.mu is held
+func (q *AddQueue) dispatch() {
+	for _, priority := range addPriorities {
+		for len(q.waiting[priority]) > 0 && q.running[priority] < max(q.limits[priority], 1) {
+			add := q.waiting[priority][0]
+			q.waiting[priority] = q.waiting[priority][1:]
+			q.running[priority]++
+			add.job.Status, add.job.RunAt = JobStatusRunning, time.Now()
+			close(add.ready)
+		}
+		// Background adds wait for every interactive one
+		if len(q.waiting[priority]) > 0 {
+			return
+		}
+	}
+}
+
+// Jobs returns the running and waiting adds, interactive first, oldest first
+func (q *AddQueue) Jobs() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, *job)
+	}
+	rank := make(map[string]int, len(addPriorities))
+	for i, priority := range addPriorities {
+		rank[priority] = i
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].Priority != jobs[j].Priority {
+			return rank[jobs[i].Priority] < rank[jobs[j].Priority]
+		}
+		return jobs[i].QueuedAt.Before(jobs[j].QueuedAt)
+	})
+	return jobs
+}
+
+// parseAddConcurrency parses ADD_CONCURRENCY, comma-separated "priority=n"
+// entries such as "interactive=4,background=1"; unlisted priorities keep those defaults
+func parseAddConcurrency(spec string) (map[string]int, error) {
+	limits := map[string]int{PriorityInteractive: 4, PriorityBackground: 1}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		priority, value, _ := strings.Cut(entry, "=")
+		priority = strings.TrimSpace(priority)
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if _, ok := limits[priority]; !ok || err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid ADD_CONCURRENCY entry %q: use interactive=n or background=n with n at least 1", entry)
+		}
+		limits[priority] = n
+	}
+	return limits, nil
+}
+
+// searchAfterAdd schedules the search for a movie or series added without one,
+// paced by SEARCH_PACE; it returns when the search will run
+func (h *TorrentHandler) searchAfterAdd(isMovie bool, mediaID int, title string) time.Time {
+	job := Job{Title: title, App: "sonarr", MediaID: mediaID}
+	search := func(ctx context.Context) error {
+		return h.sonarrClient.TriggerSearch(ctx, mediaID)
+	}
+	if isMovie {
+		job.App = "radarr"
+		search = func(ctx context.Context) error {
+			return h.radarrClient.TriggerSearch(ctx, mediaID)
+		}
+	}
+	return h.searches.Schedule(h.cfg().SearchPace, job, search)
+}
+
+// grabAfterAdd schedules an indexer search for a new movie that grabs the best
+// release of the preferred resolution, or leaves the choice to Radarr if there
+// is none. Like other searches it is paced by SEARCH_PACE.
+func (h *TorrentHandler) grabAfterAdd(movieID int, title string, resolution int) time.Time {
+	job := Job{Title: title, App: "radarr", MediaID: movieID}
+	return h.searches.Schedule(h.cfg().SearchPace, job, func(ctx context.Context) error {
+		releases, err := h.radarrClient.SearchReleases(ctx, movieID)
+		if err != nil {
+			return err
+		}
+		release := preferredRelease(releases, resolution)
+		if release == nil {
+			log.Printf("No %dp release of %s; letting Radarr pick", resolution, title)
+			return h.radarrClient.TriggerSearch(ctx, movieID)
+		}
+		log.Printf("Grabbing %s for %s", release.Title, title)
+		return h.radarrClient.GrabRelease(ctx, release)
+	})
+}
+
+// Jobs lists work that hasn't finished: queued and running adds, then paced searches
+func (h *TorrentHandler) Jobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(JobsResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(JobsResponse{
+		Success: true,
+		Message: "OK",
+		Jobs:    append(h.adds.Jobs(), h.searches.Jobs()...),
+	})
+}
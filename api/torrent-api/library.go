@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// LibraryUpgrade is a library item whose file is below its quality cutoff
+type LibraryUpgrade struct {
+	Type           string              `json:"type"` // "movie" or "episode"
+	ID             int                 `json:"id"`
+	Title          string              `json:"title"`
+	Year           int                 `json:"year,omitempty"`
+	SeriesTitle    string              `json:"series_title,omitempty"`
+	SeasonNumber   int                 `json:"season_number,omitempty"`
+	EpisodeNumber  int                 `json:"episode_number,omitempty"`
+	CurrentQuality string              `json:"current_quality,omitempty"`
+	Suggestions    []UpgradeSuggestion `json:"suggestions,omitempty"`
+}
+
+type LibraryUpgradesResponse struct {
+	Success       bool             `json:"success"`
+	Message       string           `json:"message"`
+	TotalMovies   int              `json:"total_movies"`
+	TotalEpisodes int              `json:"total_episodes"`
+	Upgrades      []LibraryUpgrade `json:"upgrades"`
+}
+
+// Indexer searches are slow and rate limited, so only a few items get suggestions per call
+const (
+	maxUpgradeSuggestionItems = 5
+	upgradeSuggestionsPerItem = 3
+)
+
+// LibraryUpgrades lists movies/episodes below their quality cutoff, optionally
+// with indexer releases (as magnets) that would upgrade them
+func (h *TorrentHandler) LibraryUpgrades(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(LibraryUpgradesResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+
+	query := r.URL.Query()
+	mediaType := query.Get("type")
+	if mediaType == "" {
+		mediaType = "all"
+	}
+	if mediaType != "all" && mediaType != "movie" && mediaType != "tv" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(LibraryUpgradesResponse{
+			Success: false,
+			Message: "Invalid type. Use 'movie', 'tv' or 'all'",
+		})
+		return
+	}
+
+	limit := 50
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 500 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(LibraryUpgradesResponse{
+				Success: false,
+				Message: "Invalid limit. Use 1-500",
+			})
+			return
+		}
+		limit = n
+	}
+
+	suggest := query.Get("suggest") == "true"
+	if suggest && !h.cfg().IndexerSearch {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(LibraryUpgradesResponse{
+			Success: false,
+			Message: "Upgrade suggestions require INDEXER_SEARCH=true",
+		})
+		return
+	}
+
+	ctx := r.Context()
+	resp := LibraryUpgradesResponse{Success: true, Message: "OK", Upgrades: []LibraryUpgrade{}}
+
+	if mediaType == "all" || mediaType == "movie" {
+		movies, total, err := h.radarrClient.GetCutoffUnmet(ctx, limit)
+		if err != nil {
+			log.Printf("Error fetching Radarr cutoff unmet: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			json.NewEncoder(w).Encode(LibraryUpgradesResponse{
+				Success: false,
+				Message: "Failed to query Radarr: " + err.Error(),
+			})
+			return
+		}
+		resp.TotalMovies = total
+
+		for _, movie := range movies {
+			upgrade := LibraryUpgrade{
+				Type:  "movie",
+				ID:    movie.ID,
+				Title: movie.Title,
+				Year:  movie.Year,
+			}
+			if movie.MovieFile != nil {
+				upgrade.CurrentQuality = movie.MovieFile.Quality.Quality.Name
+			}
+			resp.Upgrades = append(resp.Upgrades, upgrade)
+		}
+	}
+
+	if mediaType == "all" || mediaType == "tv" {
+		episodes, total, err := h.sonarrClient.GetCutoffUnmet(ctx, limit)
+		if err != nil {
+			log.Printf("Error fetching Sonarr cutoff unmet: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			json.NewEncoder(w).Encode(LibraryUpgradesResponse{
+				Success: false,
+				Message: "Failed to query Sonarr: " + err.Error(),
+			})
+			return
+		}
+		resp.TotalEpisodes = total
+
+		for _, episode := range episodes {
+			upgrade := LibraryUpgrade{
+				Type:          "episode",
+				ID:            episode.ID,
+				Title:         episode.Title,
+				SeasonNumber:  episode.SeasonNumber,
+				EpisodeNumber: episode.EpisodeNumber,
+			}
+			if episode.Series != nil {
+				upgrade.SeriesTitle = episode.Series.Title
+				upgrade.Year = episode.Series.Year
+			}
+			if episode.EpisodeFile != nil {
+				upgrade.CurrentQuality = episode.EpisodeFile.Quality.Quality.Name
+			}
+			resp.Upgrades = append(resp.Upgrades, upgrade)
+		}
+	}
+
+	if suggest {
+		for i := range resp.Upgrades {
+			if i >= maxUpgradeSuggestionItems {
+				break
+			}
+			upgrade := &resp.Upgrades[i]
+
+			var releases []ArrRelease
+			var err error
+			if upgrade.Type == "movie" {
+				releases, err = h.radarrClient.SearchReleases(ctx, upgrade.ID)
+			} else {
+				releases, err = h.sonarrClient.SearchReleases(ctx, upgrade.ID)
+			}
+			if err != nil {
+				log.Printf("Warning: release search failed for %s %d: %v", upgrade.Type, upgrade.ID, err)
+				continue
+			}
+			upgrade.Suggestions = torrentUpgradeSuggestions(releases, upgradeSuggestionsPerItem)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
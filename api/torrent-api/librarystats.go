@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type MovieStats struct {
+	Total      int   `json:"total"`
+	Monitored  int   `json:"monitored"`
+	WithFile   int   `json:"with_file"`
+	Missing    int   `json:"missing"` // monitored, released and without a file
+	SizeOnDisk int64 `json:"size_on_disk"`
+}
+
+type SeriesStats struct {
+	Total            int   `json:"total"`
+	Monitored        int   `json:"monitored"`
+	Continuing       int   `json:"continuing"`
+	Episodes         int   `json:"episodes"`
+	EpisodesWithFile int   `json:"episodes_with_file"`
+	MissingEpisodes  int   `json:"missing_episodes"` // aired, monitored and without a file
+	SizeOnDisk       int64 `json:"size_on_disk"`
+}
+
+// RootFolderStats is the disk usage of one Radarr or Sonarr root folder
+type RootFolderStats struct {
+	App         string `json:"app"` // "radarr" or "sonarr"
+	Path        string `json:"path"`
+	Accessible  bool   `json:"accessible"`
+	FreeSpace   *int64 `json:"free_space,omitempty"`
+	Items       int    `json:"items"`        // movies or series stored in the folder
+	LibrarySize int64  `json:"library_size"` // bytes their files take
+}
+
+type LibraryStatsResponse struct {
+	Success     bool              `json:"success"`
+	Message     string            `json:"message"`
+	Movies      *MovieStats       `json:"movies,omitempty"`
+	Series      *SeriesStats      `json:"series,omitempty"`
+	RootFolders []RootFolderStats `json:"root_folders,omitempty"`
+	FetchedAt   *time.Time        `json:"fetched_at,omitempty"`
+}
+
+// libraryStatsCache keeps the last stats for LIBRARY_STATS_TTL, since listing
+// the whole library is heavy on large Radarr/Sonarr instances
+type libraryStatsCache struct {
+	mu        sync.Mutex
+	stats     *LibraryStatsResponse
+	fetchedAt time.Time
+}
+
+// LibraryStats returns movie, series and disk usage counts from Radarr and Sonarr
+func (h *TorrentHandler) LibraryStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(LibraryStatsResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+
+	stats, err := h.libraryStats(r.Context(), r.URL.Query().Get("refresh") == "true")
+	if err != nil {
+		log.Printf("Error fetching library stats: %v", err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(LibraryStatsResponse{
+			Success: false,
+			Message: "Failed to fetch library stats: " + err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
+
+// libraryStats returns the cached stats, fetching them when stale or on refresh.
+// Concurrent callers wait for one fetch.
+func (h *TorrentHandler) libraryStats(ctx context.Context, refresh bool) (*LibraryStatsResponse, error) {
+	cache := &h.libraryStatsCache
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if !refresh && cache.stats != nil && time.Since(cache.fetchedAt) < h.cfg().LibraryStatsTTL {
+		return cache.stats, nil
+	}
+
+	stats, err := h.fetchLibraryStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cache.stats, cache.fetchedAt = stats, *stats.FetchedAt
+	return stats, nil
+}
+
+func (h *TorrentHandler) fetchLibraryStats(ctx context.Context) (*LibraryStatsResponse, error) {
+	movies, err := h.radarrClient.GetMovies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("radarr: %w", err)
+	}
+	radarrFolders, err := h.radarrClient.GetRootFolders(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("radarr: %w", err)
+	}
+	series, err := h.sonarrClient.GetAllSeries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("sonarr: %w", err)
+	}
+	sonarrFolders, err := h.sonarrClient.GetRootFolders(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("sonarr: %w", err)
+	}
+
+	now := time.Now()
+	stats := &LibraryStatsResponse{
+		Success:     true,
+		Message:     "OK",
+		Movies:      &MovieStats{},
+		Series:      &SeriesStats{},
+		RootFolders: []RootFolderStats{},
+		FetchedAt:   &now,
+	}
+
+	radarrStart := len(stats.RootFolders)
+	for _, folder := range radarrFolders {
+		stats.RootFolders = append(stats.RootFolders, RootFolderStats{App: "radarr", Path: folder.Path, Accessible: folder.Accessible, FreeSpace: folder.FreeSpace})
+	}
+	for _, movie := range movies {
+		stats.Movies.Total++
+		stats.Movies.SizeOnDisk += movie.SizeOnDisk
+		if movie.Monitored {
+			stats.Movies.Monitored++
+		}
+		if movie.HasFile {
+			stats.Movies.WithFile++
+		} else if movie.Monitored && movie.IsAvailable {
+			stats.Movies.Missing++
+		}
+		if folder := rootFolderFor(stats.RootFolders[radarrStart:], movie.Path); folder != nil {
+			folder.Items++
+			folder.LibrarySize += movie.SizeOnDisk
+		}
+	}
+
+	sonarrStart := len(stats.RootFolders)
+	for _, folder := range sonarrFolders {
+		stats.RootFolders = append(stats.RootFolders, RootFolderStats{App: "sonarr", Path: folder.Path, Accessible: folder.Accessible, FreeSpace: folder.FreeSpace})
+	}
+	for _, s := range series {
+		stats.Series.Total++
+		stats.Series.Episodes += s.Statistics.TotalEpisodeCount
+		stats.Series.EpisodesWithFile += s.Statistics.EpisodeFileCount
+		stats.Series.SizeOnDisk += s.Statistics.SizeOnDisk
+		if s.Monitored {
+			stats.Series.Monitored++
+			if missing := s.Statistics.EpisodeCount - s.Statistics.EpisodeFileCount; missing > 0 {
+				stats.Series.MissingEpisodes += missing
+			}
+		}
+		if s.Status == "continuing" {
+			stats.Series.Continuing++
+		}
+		if folder := rootFolderFor(stats.RootFolders[sonarrStart:], s.Path); folder != nil {
+			folder.Items++
+			folder.LibrarySize += s.Statistics.SizeOnDisk
+		}
+	}
+
+	return stats, nil
+}
+
+// rootFolderFor returns the folder holding path, the longest match when folders nest
+func rootFolderFor(folders []RootFolderStats, path string) *RootFolderStats {
+	var match *RootFolderStats
+	for i := range folders {
+		root := strings.TrimSuffix(folders[i].Path, "/")
+		if !strings.HasPrefix(path, root+"/") {
+			continue
+		}
+		if match == nil || len(folders[i].Path) > len(match.Path) {
+			match = &folders[i]
+		}
+	}
+	return match
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ListenAddr is one LISTEN_ADDRS entry: a TCP address, or a Unix socket path
+type ListenAddr struct {
+	Network string // "tcp" (dual-stack), "tcp4", "tcp6" or "unix"
+	Address string
+}
+
+func (a ListenAddr) String() string {
+	if a.Network == "tcp" {
+		return a.Address
+	}
+	return a.Network + ":" + a.Address
+}
+
+// parseListenAddrs parses LISTEN_ADDRS, comma-separated entries such as
+// ":8080", "100.64.0.1:8080", "[::1]:8080", "tcp6:[::]:8080" or
+// "unix:/run/torrent-api.sock". Empty means ":" + port on all interfaces.
+func parseListenAddrs(spec, port string) ([]ListenAddr, error) {
+	var addrs []ListenAddr
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		addr := ListenAddr{Network: "tcp", Address: entry}
+		if network, rest, ok := strings.Cut(entry, ":"); ok {
+			switch network {
+			case "unix", "tcp", "tcp4", "tcp6":
+				addr = ListenAddr{Network: network, Address: rest}
+			}
+		}
+
+		if addr.Network == "unix" {
+			if addr.Address == "" {
+				return nil, fmt.Errorf("invalid LISTEN_ADDRS entry %q: unix needs a socket path", entry)
+			}
+		} else if _, p, err := net.SplitHostPort(addr.Address); err != nil {
+			return nil, fmt.Errorf("invalid LISTEN_ADDRS entry %q: %v", entry, err)
+		} else if n, err := strconv.Atoi(p); err != nil || n < 0 || n > 65535 {
+			return nil, fmt.Errorf("invalid LISTEN_ADDRS entry %q: bad port %q", entry, p)
+		}
+		addrs = append(addrs, addr)
+	}
+
+	if len(addrs) == 0 {
+		addrs = append(addrs, ListenAddr{Network: "tcp", Address: ":" + port})
+	}
+	return addrs, nil
+}
+
+// ListenOptions are the socket options of every listener
+type ListenOptions struct {
+	ReusePort  bool        // SO_REUSEPORT, so a new instance can bind before the old one exits
+	SocketMode fs.FileMode // permissions of Unix sockets, e.g. 0660 for a proxy in the same group
+}
+
+// listenAll opens every address; on failure the ones already open are closed
+func listenAll(ctx context.Context, addrs []ListenAddr, opts ListenOptions) ([]net.Listener, error) {
+	lc := net.ListenConfig{}
+	if opts.ReusePort {
+		lc.Control = reusePortControl
+	}
+
+	var listeners []net.Listener
+	for _, addr := range addrs {
+		l, err := listenOne(ctx, lc, addr, opts)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+func listenOne(ctx context.Context, lc net.ListenConfig, addr ListenAddr, opts ListenOptions) (net.Listener, error) {
+	if addr.Network != "unix" {
+		return lc.Listen(ctx, addr.Network, addr.Address)
+	}
+
+	// A socket left behind by an unclean exit would make the bind fail
+	if info, err := os.Lstat(addr.Address); err == nil && info.Mode()&fs.ModeSocket != 0 {
+		if err := os.Remove(addr.Address); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", addr.Address)
+	if err != nil {
+		return nil, err
+	}
+	if opts.SocketMode != 0 {
+		if err := os.Chmod(addr.Address, opts.SocketMode); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// serveAll serves handler on every listener and returns the first serve error
+func serveAll(listeners []net.Listener, handler http.Handler) error {
+	srv := &http.Server{Handler: handler}
+	errc := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			errc <- srv.Serve(l)
+		}(l)
+	}
+	return <-errc
+}
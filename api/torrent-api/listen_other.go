@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// reusePortControl fails where SO_REUSEPORT isn't available
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	return errors.New("LISTEN_REUSEPORT is not supported on this platform")
+}
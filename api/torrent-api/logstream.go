@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log levels, most severe last
+const (
+	LogLevelInfo    = "info"
+	LogLevelWarning = "warning"
+	LogLevelError   = "error"
+)
+
+var logLevelRank = map[string]int{LogLevelInfo: 0, LogLevelWarning: 1, LogLevelError: 2}
+
+// Lines kept for clients that connect later
+const logStreamBacklog = 500
+
+// A slow client misses lines rather than blocking logging
+const logSubscriberBuffer = 256
+
+// Date and time the standard logger puts in front of every line
+var logTimestampPattern = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d+)? `)
+
+var accessLogPattern = regexp.MustCompile(`^(GET|HEAD|POST|PUT|PATCH|DELETE|OPTIONS) /`)
+
+// Facilities are picked by the first keyword a line contains
+var logFacilities = []struct {
+	facility string
+	keywords []string
+}{
+	{"pipeline", []string{"step ", "pipeline", "rolled back"}},
+	{"qbittorrent", []string{"qbittorrent", "torrent"}},
+	{"radarr", []string{"radarr", "movie"}},
+	{"sonarr", []string{"sonarr", "series", "episode"}},
+	{"scheduler", []string{"scheduled job", "reconcile", "packimport", "rss"}},
+	{"bot", []string{"discord", "telegram"}},
+	{"config", []string{"config", "secret"}},
+}
+
+// LogEntry is one log line with the level and facility read from its text
+type LogEntry struct {
+	Time     time.Time `json:"time"`
+	Level    string    `json:"level"`
+	Facility string    `json:"facility"`
+	Message  string    `json:"message"`
+}
+
+// LogStream keeps the latest log lines and fans new ones out to subscribers.
+// It sits behind the redacting writer, so it only sees masked secrets.
+type LogStream struct {
+	mu          sync.Mutex
+	backlog     []LogEntry
+	subscribers map[chan LogEntry]struct{}
+}
+
+var logStream = NewLogStream()
+
+func NewLogStream() *LogStream {
+	return &LogStream{subscribers: make(map[chan LogEntry]struct{})}
+}
+
+func (s *LogStream) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if line != "" {
+			s.publish(parseLogLine(line, time.Now()))
+		}
+	}
+	return len(p), nil
+}
+
+func (s *LogStream) publish(entry LogEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backlog = append(s.backlog, entry)
+	if len(s.backlog) > logStreamBacklog {
+		s.backlog = s.backlog[len(s.backlog)-logStreamBacklog:]
+	}
+	for ch := range s.subscribers {
+		select {
+		case ch <- entry:
+		default:
+		}
+	}
+}
+
+// Subscribe returns the last n lines and a channel of new ones; call the
+// returned func to stop
+func (s *LogStream) Subscribe(n int) ([]LogEntry, <-chan LogEntry, func()) {
+	ch := make(chan LogEntry, logSubscriberBuffer)
+	s.mu.Lock()
+	if n > len(s.backlog) {
+		n = len(s.backlog)
+	}
+	backlog := append([]LogEntry(nil), s.backlog[len(s.backlog)-n:]...)
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	return backlog, ch, func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}
+}
+
+// parseLogLine derives level and facility from the line's wording
+func parseLogLine(line string, now time.Time) LogEntry {
+	message := logTimestampPattern.ReplaceAllString(line, "")
+	entry := LogEntry{Time: now, Level: LogLevelInfo, Facility: "app", Message: message}
+
+	switch {
+	case strings.HasPrefix(message, "Error"), strings.HasPrefix(message, "Failed"):
+		entry.Level = LogLevelError
+	case strings.HasPrefix(message, "Warning"):
+		entry.Level = LogLevelWarning
+	}
+
+	if accessLogPattern.MatchString(message) {
+		entry.Facility = "http"
+		return entry
+	}
+	lower := strings.ToLower(message)
+	for _, f := range logFacilities {
+		for _, keyword := range f.keywords {
+			if strings.Contains(lower, keyword) {
+				entry.Facility = f.facility
+				return entry
+			}
+		}
+	}
+	return entry
+}
+
+// logFilter selects entries at or above a level and in any of the facilities
+type logFilter struct {
+	minLevel   int
+	facilities map[string]bool // nil for all
+}
+
+func (f logFilter) match(entry LogEntry) bool {
+	if logLevelRank[entry.Level] < f.minLevel {
+		return false
+	}
+	return f.facilities == nil || f.facilities[entry.Facility]
+}
+
+// LogsStream tails the log as server-sent events for the admin UI.
+// ?level= sets the minimum level, ?facility= a comma-separated list and
+// ?backlog= how many earlier lines to send first (default 100).
+func (h *TorrentHandler) LogsStream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+	if key := apiKeyFromContext(r.Context()); key != nil && !key.Admin {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Success: false,
+			Message: "Only ADMIN_KEYS can read the logs",
+		})
+		return
+	}
+
+	query := r.URL.Query()
+	var filter logFilter
+	if level := query.Get("level"); level != "" {
+		rank, ok := logLevelRank[level]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{
+				Success: false,
+				Message: "level must be info, warning or error",
+			})
+			return
+		}
+		filter.minLevel = rank
+	}
+	if facilities := query.Get("facility"); facilities != "" {
+		filter.facilities = make(map[string]bool)
+		for _, facility := range strings.Split(facilities, ",") {
+			filter.facilities[strings.TrimSpace(facility)] = true
+		}
+	}
+	backlogSize := 100
+	if value := query.Get("backlog"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrorResponse{
+				Success: false,
+				Message: "backlog must be a non-negative number",
+			})
+			return
+		}
+		backlogSize = n
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Success: false,
+			Message: "Streaming is not supported",
+		})
+		return
+	}
+
+	backlog, entries, unsubscribe := logStream.Subscribe(logStreamBacklog)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx would hold events back
+	w.WriteHeader(http.StatusOK)
+
+	// The backlog is filtered before it is cut, so ?backlog=20&level=error sends 20 errors
+	var earlier []LogEntry
+	for _, entry := range backlog {
+		if filter.match(entry) {
+			earlier = append(earlier, entry)
+		}
+	}
+	if len(earlier) > backlogSize {
+		earlier = earlier[len(earlier)-backlogSize:]
+	}
+	for _, entry := range earlier {
+		writeLogEvent(w, entry)
+	}
+	flusher.Flush()
+
+	// Keeps proxies from closing an idle stream
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case entry := <-entries:
+			if !filter.match(entry) {
+				continue
+			}
+			writeLogEvent(w, entry)
+		}
+		flusher.Flush()
+	}
+}
+
+func writeLogEvent(w http.ResponseWriter, entry LogEntry) {
+	data, _ := json.Marshal(entry)
+	fmt.Fprintf(w, "event: log\ndata: %s\n\n", data)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// magnetPage is the page shown in the browser tab the OS opens for a magnet:
+// link: first a form confirming the add, then its outcome
+var magnetPage = template.Must(template.New("magnet").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Heading}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 36em; margin: 3em auto; padding: 0 1em; }
+h1 { font-size: 1.4em; color: {{if .Confirm}}inherit{{else if .Success}}#1a7f37{{else}}#cf222e{{end}}; }
+.muted { color: #666; }
+button { font-size: 1em; padding: 0.4em 1.2em; }
+</style>
+</head>
+<body>
+<h1>{{.Heading}}</h1>
+{{with .Name}}<p><strong>{{.}}</strong></p>{{end}}
+{{if .Confirm}}<form method="post">
+<input type="hidden" name="uri" value="{{.URI}}">
+<input type="hidden" name="type" value="{{.Type}}">
+<button type="submit">Add torrent</button>
+</form>
+<p class="muted">Close this tab to leave it.</p>
+{{else}}<p>{{.Message}}</p>
+{{with .MediaTitle}}<p class="muted">Matched {{.}}</p>{{end}}
+{{with .Category}}<p class="muted">Category: {{.}}</p>{{end}}
+{{if .Warnings}}<ul>{{range .Warnings}}<li>{{.}}</li>{{end}}</ul>{{end}}
+<p class="muted">You can close this tab.</p>
+{{end}}</body>
+</html>
+`))
+
+// magnetPageData is what magnetPage shows
+type magnetPageData struct {
+	Confirm    bool // ask before adding URI
+	URI        string
+	Type       string
+	Success    bool
+	Name       string
+	Message    string
+	MediaTitle string
+	Category   string
+	Warnings   []string
+}
+
+// Heading is the page's title
+func (d magnetPageData) Heading() string {
+	switch {
+	case d.Confirm:
+		return "Add this torrent?"
+	case d.Success:
+		return "Torrent added"
+	}
+	return "Torrent not added"
+}
+
+// Magnet lets the server be registered as the magnet: protocol handler of a
+// browser or OS (see README). A GET of ?uri= answers with a page asking to
+// confirm the add, and the page's POST back adds it, so merely opening a link
+// never adds anything. ?type= works like the type field of /api/torrent.
+func (h *TorrentHandler) Magnet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeMagnetPage(w, http.StatusMethodNotAllowed, magnetPageData{Message: "Method not allowed. Use GET or POST."})
+		return
+	}
+	// The confirmation comes from the page itself; another site posting the form
+	// would add torrents with the key in the registered URL
+	if r.Method == http.MethodPost && r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		writeMagnetPage(w, http.StatusForbidden, magnetPageData{Message: "Cross-site requests can't add torrents. Confirm the add on this server's page."})
+		return
+	}
+
+	req := AddTorrentRequest{
+		MagnetLink: r.FormValue("uri"),
+		Type:       r.FormValue("type"),
+	}
+	if err := validateAddRequest(req); err != nil {
+		writeMagnetPage(w, http.StatusBadRequest, magnetPageData{Message: err.Error()})
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeMagnetPage(w, http.StatusOK, magnetPageData{
+			Confirm: true,
+			URI:     req.MagnetLink,
+			Type:    req.Type,
+			Name:    extractNameFromMagnet(req.MagnetLink),
+		})
+		return
+	}
+
+	p, err := h.runAddPipeline(r.Context(), req, nil)
+	if err != nil {
+		writeMagnetPage(w, addErrorStatus(err), magnetPageData{
+			Name:     p.TorrentName,
+			Message:  "Failed to add torrent: " + err.Error(),
+			Category: p.Category,
+		})
+		return
+	}
+
+	message, _ := p.summary()
+	writeMagnetPage(w, http.StatusOK, magnetPageData{
+		Success:    true,
+		Name:       p.TorrentName,
+		Message:    message,
+		MediaTitle: p.MediaTitle,
+		Category:   p.Category,
+		Warnings:   p.Warnings,
+	})
+}
+
+func writeMagnetPage(w http.ResponseWriter, status int, data magnetPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// Framed by another site, the confirm button could be clicked unseen
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	magnetPage.Execute(w, data)
+}
@@ -1,60 +1,476 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Embedded zone database; the alpine image has none
 
 	"github.com/joho/godotenv"
 )
 
 func main() {
+	// torrent-api init writes a starter .env
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		if err := runInit(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "init: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	// torrent-api simulate replays recorded torrent names against fake services
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		if err := runSimulate(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "simulate: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Load .env file if it exists
 	godotenv.Load()
 
+	// Settings bundled in TORRENT_API_CONFIG fill in what isn't set on its own
+	if err := applyConfigBundle(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Settings from a mounted ConfigMap directory override the environment
+	var configDir *ConfigDir
+	if dir := os.Getenv("CONFIG_DIR"); dir != "" {
+		configDir = NewConfigDir(dir)
+		if _, err := configDir.Apply(); err != nil {
+			log.Fatalf("Failed to load CONFIG_DIR: %v", err)
+		}
+	}
+
+	// Stray whitespace from a copied .env line is trimmed with a warning
+	normalizeEnv()
+	loadUserAgent()
+
 	// Get configuration from environment
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
+	// Several addresses (e.g. only the tailscale interface) or a Unix socket for a proxy
+	listenAddrs, err := parseListenAddrs(os.Getenv("LISTEN_ADDRS"), port)
+	if err != nil {
+		log.Fatalf("Invalid LISTEN_ADDRS: %v", err)
+	}
+	listenOpts := ListenOptions{ReusePort: os.Getenv("LISTEN_REUSEPORT") == "true"}
+	if v := os.Getenv("LISTEN_SOCKET_MODE"); v != "" {
+		mode, err := strconv.ParseUint(v, 8, 32)
+		if err != nil || mode > 0o777 {
+			log.Fatalf("Invalid LISTEN_SOCKET_MODE %q: use octal permissions such as 0660", v)
+		}
+		listenOpts.SocketMode = fs.FileMode(mode)
+	}
+
+	// Mask every loaded secret in log output, which /api/logs/stream tails too
+	log.SetOutput(&redactingWriter{w: io.MultiWriter(os.Stderr, logStream), redactor: secretRedactor})
+
+	// Credentials may come from env, *_FILE secrets, an age file or Vault
+	secrets, err := LoadSecretStore()
+	if err != nil {
+		log.Fatalf("Failed to load secrets: %v", err)
+	}
+
+	// Bad URLs and half-configured services fail here rather than on the first request
+	if err := checkEnvConfig(secrets); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	logEffectiveConfig(secrets)
+	mustSecret := func(name string) string {
+		value, err := secrets.Get(name)
+		if err != nil {
+			log.Fatalf("Failed to load %s: %v", name, err)
+		}
+		return value
+	}
+	// Resolved like a secret, but left readable in the logs: masking a common
+	// username like "admin" would mangle every log line containing the word
+	mustCredential := func(name string) string {
+		value, err := secrets.Lookup(name)
+		if err != nil {
+			log.Fatalf("Failed to load %s: %v", name, err)
+		}
+		return value
+	}
+
+	// Optional client API keys; without them the API is open as before
+	apiKeys, err := parseAPIKeys(mustSecret("API_KEYS"))
+	if err != nil {
+		log.Fatalf("Invalid API_KEYS: %v", err)
+	}
+
+	if err := markAdminKeys(apiKeys, os.Getenv("ADMIN_KEYS")); err != nil {
+		log.Fatalf("Invalid ADMIN_KEYS: %v", err)
+	}
+
+	if err := markKeyMediaTypes(apiKeys, os.Getenv("KEY_MEDIA_TYPES")); err != nil {
+		log.Fatalf("Invalid KEY_MEDIA_TYPES: %v", err)
+	}
+
+	// Optional OTLP tracing, using the standard OpenTelemetry env vars
+	traceEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if traceEndpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			traceEndpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+		}
+	}
+	if traceEndpoint != "" {
+		serviceName := os.Getenv("OTEL_SERVICE_NAME")
+		if serviceName == "" {
+			serviceName = "torrent-api"
+		}
+		tracer = NewTracer(traceEndpoint, serviceName)
+		go tracer.Run(context.Background(), 5*time.Second)
+		log.Printf("Exporting traces to %s", traceEndpoint)
+	}
+
 	// Initialize qBittorrent client
 	qbClient := NewQBittorrentClient(
 		os.Getenv("QBITTORRENT_URL"),
-		os.Getenv("QBITTORRENT_USERNAME"),
-		os.Getenv("QBITTORRENT_PASSWORD"),
+		mustCredential("QBITTORRENT_USERNAME"),
+		mustSecret("QBITTORRENT_PASSWORD"),
 	)
 
+	// A pre-authenticated session replaces the login when the WebUI sits behind proxy auth
+	if sidFile := os.Getenv("QBITTORRENT_SID_FILE"); sidFile != "" {
+		qbClient.UseSession("", sidFile)
+	} else if sid := mustSecret("QBITTORRENT_SID"); sid != "" {
+		qbClient.UseSession(sid, "")
+	}
+
 	// Initialize Radarr client
 	radarrClient := NewRadarrClient(
 		os.Getenv("RADARR_URL"),
-		os.Getenv("RADARR_API_KEY"),
+		mustSecret("RADARR_API_KEY"),
 	)
+	radarrClient.SetDefaults(ArrDefaults{
+		RootFolder:     os.Getenv("RADARR_ROOT_FOLDER"),
+		QualityProfile: os.Getenv("RADARR_QUALITY_PROFILE"),
+	})
 
 	// Initialize Sonarr client
 	sonarrClient := NewSonarrClient(
 		os.Getenv("SONARR_URL"),
-		os.Getenv("SONARR_API_KEY"),
+		mustSecret("SONARR_API_KEY"),
 	)
+	sonarrClient.SetDefaults(ArrDefaults{
+		RootFolder:     os.Getenv("SONARR_ROOT_FOLDER"),
+		QualityProfile: os.Getenv("SONARR_QUALITY_PROFILE"),
+	})
+	if err := sonarrClient.SetPathConflict(os.Getenv("SONARR_PATH_CONFLICT")); err != nil {
+		log.Fatalf("Invalid SONARR_PATH_CONFLICT: %v", err)
+	}
+
+	// Last known root folders and quality profiles, for adds while an *arr is briefly down
+	if cacheFile := os.Getenv("ARR_CACHE_FILE"); cacheFile != "" {
+		if err := arrCache.Load(cacheFile); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
 
 	// Initialize name extractor client
 	extractorURL := os.Getenv("NAME_EXTRACTOR_URL")
 	if extractorURL == "" {
 		extractorURL = "http://localhost:8000"
 	}
-	extractorClient := NewNameExtractorClient(extractorURL)
+	// Fall back to local extraction when the extractor is slower than this
+	hedgeDelay := 1500 * time.Millisecond
+	if v := os.Getenv("NAME_EXTRACTOR_HEDGE_DELAY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid NAME_EXTRACTOR_HEDGE_DELAY: %v", err)
+		}
+		hedgeDelay = d
+	}
+	extractorClient := NewNameExtractorClient(extractorURL, hedgeDelay)
+
+	// Initialize result page scraper
+	scraperClient := NewScraperClient()
+
+	// Background workers run on cron schedules in this timezone
+	scheduleLoc := time.Local
+	if tz := os.Getenv("SCHEDULE_TIMEZONE"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			log.Fatalf("Invalid SCHEDULE_TIMEZONE: %v", err)
+		}
+		scheduleLoc = loc
+	}
+	scheduler := NewScheduler(scheduleLoc)
+
+	// Optional Tautulli for watch history warnings
+	var tautulliClient *TautulliClient
+	if tautulliURL := os.Getenv("TAUTULLI_URL"); tautulliURL != "" {
+		tautulliClient = NewTautulliClient(tautulliURL, mustSecret("TAUTULLI_API_KEY"))
+	}
+
+	// Add history, persisted when HISTORY_FILE is set
+	historyMax := defaultHistoryMaxRecords
+	if value := os.Getenv("HISTORY_MAX_RECORDS"); value != "" {
+		if historyMax, err = strconv.Atoi(value); err != nil || historyMax < 0 {
+			log.Fatalf("Invalid HISTORY_MAX_RECORDS %q: use a number of records, or 0 for no limit", value)
+		}
+	}
+	history, err := NewHistoryStore(os.Getenv("HISTORY_FILE"), historyMax)
+	if err != nil {
+		log.Fatalf("Failed to load history: %v", err)
+	}
+
+	// Optional webhook for events like library items deleted upstream
+	var notifier *Notifier
+	if notifyURL := os.Getenv("NOTIFY_WEBHOOK_URL"); notifyURL != "" {
+		notifier = NewNotifier(notifyURL)
+	}
+
+	config, err := loadHandlerConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	// Create handler
-	handler := NewTorrentHandler(qbClient, radarrClient, sonarrClient, extractorClient)
+	handler := NewTorrentHandler(qbClient, radarrClient, sonarrClient, extractorClient, scraperClient, scheduler, tautulliClient, history, notifier, config)
+
+	// Series picked for ambiguous titles, reused by later adds of the same title
+	if choicesFile := os.Getenv("SERIES_CHOICES_FILE"); choicesFile != "" {
+		if err := handler.seriesChoices.Load(choicesFile); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	// Optional Prowlarr for grabbing an NZB when a torrent is dead (NZB_FALLBACK)
+	if prowlarrURL := os.Getenv("PROWLARR_URL"); prowlarrURL != "" {
+		handler.prowlarrClient = NewProwlarrClient(prowlarrURL, mustSecret("PROWLARR_API_KEY"))
+	}
+
+	// Opt-in: detector/extractor disagreements, anonymized, for improving the rules
+	if disagreementURL := os.Getenv("DISAGREEMENT_WEBHOOK_URL"); disagreementURL != "" {
+		handler.disagreements = NewNotifier(disagreementURL)
+	}
+
+	// Adds can start paused, e.g. across restarts during a storage migration
+	handler.maintenance.SetKeys(apiKeys)
+	handler.apiKeys = apiKeys
+	if err := handler.maintenance.Update(parseMaintenanceEnv(os.Getenv("MAINTENANCE_MODE"), os.Getenv("MAINTENANCE_REASON"), os.Getenv("DISABLED_API_KEYS"))); err != nil {
+		log.Fatalf("Invalid DISABLED_API_KEYS: %v", err)
+	}
+
+	// Background workers
+	if err := scheduler.Register("libraryretry", "Add torrents to Radarr/Sonarr whose library add waited for them to come back", scheduleFromEnv("libraryretry", "@every 10m"), handler.RetryLibraryAdds); err != nil {
+		log.Fatalf("Invalid libraryretry schedule: %v", err)
+	}
+	if err := scheduler.Register("reaper", "Retry adds cut short by a crash or restart, or mark them failed", scheduleFromEnv("reaper", "@every 5m"), handler.ReapDeadAdds); err != nil {
+		log.Fatalf("Invalid reaper schedule: %v", err)
+	}
+	if err := scheduler.Register("reconcile", "Mark history items deleted in Radarr/Sonarr as removed", scheduleFromEnv("reconcile", "@every 6h"), handler.ReconcileLibrary); err != nil {
+		log.Fatalf("Invalid reconcile schedule: %v", err)
+	}
+	// Off by default; e.g. SCHEDULE_QBKEEPALIVE="@every 15m" keeps the first add of the day fast
+	if err := scheduler.Register("qbkeepalive", "Keep the qBittorrent session alive, logging in again when it expired", scheduleFromEnv("qbkeepalive", ""), qbClient.KeepAlive); err != nil {
+		log.Fatalf("Invalid qbkeepalive schedule: %v", err)
+	}
+	if err := scheduler.Register("packimport", "Import finished complete series packs into Sonarr", scheduleFromEnv("packimport", "@every 10m"), handler.ImportCompletePacks); err != nil {
+		log.Fatalf("Invalid packimport schedule: %v", err)
+	}
+	if err := scheduler.Register("trackerstats", "Record when added torrents finish or stall, for tracker statistics", scheduleFromEnv("trackerstats", "@every 30m"), handler.TrackTorrentProgress); err != nil {
+		log.Fatalf("Invalid trackerstats schedule: %v", err)
+	}
+	// Only does work while someone listens to /api/events or a replace add waits
+	if err := scheduler.Register("downloadevents", "Check added torrents for completion while /api/events has listeners or a replace add waits", scheduleFromEnv("downloadevents", "@every 1m"), handler.WatchDownloads); err != nil {
+		log.Fatalf("Invalid downloadevents schedule: %v", err)
+	}
+	// Site and release group names for title cleanup, kept fresh from a remote list
+	if siteListURL := os.Getenv("SITE_LIST_URL"); siteListURL != "" {
+		siteList, err := NewSiteListUpdater(siteListURL, os.Getenv("SITE_LIST_PUBLIC_KEY"), os.Getenv("SITE_LIST_CACHE"))
+		if err != nil {
+			log.Fatalf("Invalid site list configuration: %v", err)
+		}
+		if os.Getenv("SITE_LIST_PUBLIC_KEY") == "" {
+			log.Printf("Warning: SITE_LIST_PUBLIC_KEY is not set, the site list is not signature checked")
+		}
+		if err := siteList.LoadCache(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		if err := scheduler.Register("sitelist", "Refresh torrent site and release group names", scheduleFromEnv("sitelist", "@daily"), siteList.Refresh); err != nil {
+			log.Fatalf("Invalid sitelist schedule: %v", err)
+		}
+		go func() {
+			if err := siteList.Refresh(context.Background()); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}()
+	}
+	feeds, err := parseRSSFeeds(os.Getenv("RSS_FEEDS"))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if len(feeds) > 0 {
+		rss := NewRSSWatcher(feeds, handler)
+		if err := scheduler.Register("rss", "Grab feed releases of movies missing in Radarr", scheduleFromEnv("rss", "@every 15m"), rss.Poll); err != nil {
+			log.Fatalf("Invalid rss schedule: %v", err)
+		}
+	}
+	if dir := os.Getenv("WATCH_DIR"); dir != "" {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			log.Fatalf("WATCH_DIR %s is not a directory", dir)
+		}
+		watch := NewWatchFolder(dir, handler)
+		if err := scheduler.Register("watchdir", "Add .torrent and .magnet files dropped in WATCH_DIR", scheduleFromEnv("watchdir", "@every 1m"), watch.Poll); err != nil {
+			log.Fatalf("Invalid watchdir schedule: %v", err)
+		}
+		log.Printf("Watching %s for .torrent and .magnet files", dir)
+	}
 
 	// Setup routes
 	http.HandleFunc("/api/torrent", handler.AddTorrent)
+	http.HandleFunc("/api/torrent/batch", handler.AddTorrentBatch)
+	http.HandleFunc("/api/torrent/", handler.TorrentByHash)
+	http.HandleFunc("/api/undo", handler.Undo)
+	http.HandleFunc("/api/torrents", handler.ListTorrents)
+	http.HandleFunc("/api/queue", handler.Queue)
 	http.HandleFunc("/api/media", handler.AddMedia)
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/api/search", handler.Search)
+	http.HandleFunc("/api/share", handler.Share)
+	http.HandleFunc("/magnet", handler.Magnet)
+	http.HandleFunc("/api/scrape", handler.Scrape)
+	http.HandleFunc("/api/variants", handler.Variants)
+	http.HandleFunc("/api/schedules", handler.Schedules)
+	http.HandleFunc("/api/jobs", handler.Jobs)
+	http.HandleFunc("/api/jobs/", handler.JobByID)
+	http.HandleFunc("/api/inflight", handler.Inflight)
+	http.HandleFunc("/api/history", handler.History)
+	http.HandleFunc("/api/config/options", handler.ConfigOptions)
+	http.HandleFunc("/api/library/upgrades", handler.LibraryUpgrades)
+	http.HandleFunc("/api/library/stats", handler.LibraryStats)
+	http.HandleFunc("/api/calendar", handler.Calendar)
+	http.HandleFunc("/api/seeding/categories", handler.SeedingCategories)
+	http.HandleFunc("/api/client/stats", handler.ClientStats)
+	http.HandleFunc("/api/stats", handler.Stats)
+	http.HandleFunc("/api/stats/trackers", handler.TrackerStats)
+	http.HandleFunc("/api/proxy/", handler.Proxy)
+	http.HandleFunc("/api/selftest", handler.SelfTest)
+	http.HandleFunc("/api/parse", handler.Parse)
+	http.HandleFunc("/api/webhook/radarr", handler.RadarrWebhook)
+	http.HandleFunc("/api/webhook/sonarr", handler.SonarrWebhook)
+	http.HandleFunc("/api/admin/maintenance", handler.Maintenance)
+	http.HandleFunc("/api/admin/arr-keys", handler.ArrKeys)
+	http.HandleFunc("/api/maintenance/reclassify", handler.Reclassify)
+	http.HandleFunc("/api/maintenance/retag", handler.Retag)
+	http.HandleFunc("/api/logs/stream", handler.LogsStream)
+	http.HandleFunc("/api/events", handler.Events)
+
+	// Optional Discord bot for adds from a chat channel
+	if discordToken := mustSecret("DISCORD_BOT_TOKEN"); discordToken != "" {
+		discordBot, err := NewDiscordBot(
+			discordToken,
+			os.Getenv("DISCORD_APPLICATION_ID"),
+			os.Getenv("DISCORD_PUBLIC_KEY"),
+			os.Getenv("DISCORD_CHANNEL_ID"),
+			os.Getenv("DISCORD_GUILD_ID"),
+			handler,
+		)
+		if err != nil {
+			log.Fatalf("Invalid Discord configuration: %v", err)
+		}
+		if os.Getenv("DISCORD_PUBLIC_KEY") != "" {
+			http.HandleFunc(discordInteractionsPath, discordBot.Interactions)
+			go func() {
+				if err := discordBot.RegisterCommands(context.Background()); err != nil {
+					log.Printf("Warning: %v", err)
+				}
+			}()
+		}
+		go discordBot.Poll(context.Background(), 3*time.Second)
+	}
+
+	// Optional Telegram bot for adds from allowed chats
+	if telegramToken := mustSecret("TELEGRAM_BOT_TOKEN"); telegramToken != "" {
+		chats, err := parseTelegramChats(os.Getenv("TELEGRAM_CHAT_IDS"))
+		if err != nil {
+			log.Fatalf("Invalid TELEGRAM_CHAT_IDS: %v", err)
+		}
+		go NewTelegramBot(telegramToken, chats, handler).Poll(context.Background())
+	}
+	http.HandleFunc("/health", handler.Health)
+	http.HandleFunc("/health/live", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
+	http.HandleFunc("/health/ready", handler.Ready)
+	http.HandleFunc("/api/version", handler.Version)
+	http.HandleFunc(openAPIPath, handler.OpenAPI)
+	http.HandleFunc(docsPath, handler.Docs)
+
+	scheduler.Start(context.Background())
+
+	// Adds the last run didn't finish are retried right away
+	go func() {
+		if err := handler.ReapDeadAdds(context.Background()); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}()
+
+	// Check the *arr apps will import what we add, without delaying startup
+	go handler.VerifyDownloadClients(context.Background())
+
+	// /health/ready turns 200 once qBittorrent and the *arr apps have answered
+	go handler.WarmUp(context.Background(), 5*time.Second)
 
-	log.Printf("Server starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	// Reload settings when the mounted config changes
+	if configDir != nil {
+		interval := 30 * time.Second
+		if v := os.Getenv("CONFIG_RELOAD_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Invalid CONFIG_RELOAD_INTERVAL: %s", v)
+			}
+			interval = d
+		}
+		go configDir.Watch(context.Background(), interval, handler.ReloadConfig)
+	}
+
+	// Serving under a sub-path behind a reverse proxy
+	basePath := normalizeBasePath(os.Getenv("BASE_PATH"))
+	trustedProxies, err = parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	// Users authenticated by a fronting proxy such as Authelia act as API keys
+	proxyAuth, err := parseProxyAuth(os.Getenv("PROXY_AUTH_HEADER"), os.Getenv("PROXY_AUTH_USERS"), apiKeys)
+	if err != nil {
+		log.Fatalf("Invalid proxy auth settings: %v", err)
+	}
+	if proxyAuth != nil && len(trustedProxies) == 0 {
+		log.Printf("Warning: PROXY_AUTH_HEADER is set without TRUSTED_PROXIES; only Unix socket connections can use it")
+	}
+
+	var server http.Handler = http.DefaultServeMux
+	server = authMiddleware(apiKeys, proxyAuth, server)
+	server = tracingMiddleware(server)
+	server = basePathMiddleware(basePath, server)
+	server = accessLogMiddleware(server)
+
+	listeners, err := listenAll(context.Background(), listenAddrs, listenOpts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	listenNames := make([]string, len(listenAddrs))
+	for i, addr := range listenAddrs {
+		listenNames[i] = addr.String()
+	}
+	log.Printf("Server %s starting on %s (base path %q)", version, strings.Join(listenNames, ", "), basePath+"/")
+	log.Fatal(serveAll(listeners, server))
 }
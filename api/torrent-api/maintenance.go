@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaintenanceState is what /api/admin/maintenance reports
+type MaintenanceState struct {
+	Enabled      bool       `json:"enabled"`          // All adds are refused
+	Reason       string     `json:"reason,omitempty"` // Shown to callers whose add is refused
+	Since        *time.Time `json:"since,omitempty"`
+	DisabledKeys []string   `json:"disabled_keys"` // API key names whose adds are refused
+}
+
+type MaintenanceRequest struct {
+	Enabled      *bool           `json:"enabled,omitempty"`
+	Reason       *string         `json:"reason,omitempty"`
+	DisabledKeys map[string]bool `json:"disabled_keys,omitempty"` // Key name to disabled; false re-enables
+}
+
+type MaintenanceResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	MaintenanceState
+}
+
+// Maintenance is the kill switch for adds. Status, history and the other
+// read-only endpoints keep working while it is on.
+type Maintenance struct {
+	mu       sync.RWMutex
+	enabled  bool
+	reason   string
+	since    time.Time
+	disabled map[string]bool
+	keys     map[string]bool // known API key names, nil when keys are not configured
+}
+
+func NewMaintenance() *Maintenance {
+	return &Maintenance{disabled: make(map[string]bool)}
+}
+
+// SetKeys sets the API key names that can be disabled
+func (m *Maintenance) SetKeys(keys []*APIKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keys = make(map[string]bool, len(keys))
+	for _, key := range keys {
+		m.keys[key.Name] = true
+	}
+}
+
+// Update applies the set fields of req, rejecting unknown key names
+func (m *Maintenance) Update(req MaintenanceRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name := range req.DisabledKeys {
+		if !m.keys[name] {
+			return fmt.Errorf("unknown API key %q", name)
+		}
+	}
+
+	if req.Enabled != nil {
+		if *req.Enabled && !m.enabled {
+			m.since = time.Now()
+		}
+		m.enabled = *req.Enabled
+	}
+	if req.Reason != nil {
+		m.reason = *req.Reason
+	}
+	for name, disabled := range req.DisabledKeys {
+		if disabled {
+			m.disabled[name] = true
+		} else {
+			delete(m.disabled, name)
+		}
+	}
+	return nil
+}
+
+func (m *Maintenance) State() MaintenanceState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state := MaintenanceState{Enabled: m.enabled, Reason: m.reason, DisabledKeys: []string{}}
+	if m.enabled {
+		since := m.since
+		state.Since = &since
+	}
+	for name := range m.disabled {
+		state.DisabledKeys = append(state.DisabledKeys, name)
+	}
+	sort.Strings(state.DisabledKeys)
+	return state
+}
+
+// Check returns an error when adds are paused globally or for key (nil for
+// requests without one, e.g. chat bots and background workers)
+func (m *Maintenance) Check(key *APIKey) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.enabled {
+		message := "adds are paused for maintenance"
+		if m.reason != "" {
+			message += ": " + m.reason
+		}
+		return newAPIError(ErrCodeMaintenance, "%s", message)
+	}
+	if key != nil && m.disabled[key.Name] {
+		return newAPIError(ErrCodeKeyDisabled, "adds are disabled for API key %s", key.Name)
+	}
+	return nil
+}
+
+// parseMaintenanceEnv builds the startup state from MAINTENANCE_MODE,
+// MAINTENANCE_REASON and the comma-separated DISABLED_API_KEYS
+func parseMaintenanceEnv(mode, reason, disabledKeys string) MaintenanceRequest {
+	enabled := mode == "true"
+	req := MaintenanceRequest{Enabled: &enabled, Reason: &reason, DisabledKeys: make(map[string]bool)}
+	for _, name := range strings.Split(disabledKeys, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			req.DisabledKeys[name] = true
+		}
+	}
+	return req
+}
+
+// checkAddsAllowed refuses adds during maintenance and for disabled keys
+func (h *TorrentHandler) checkAddsAllowed(ctx context.Context) error {
+	return h.maintenance.Check(apiKeyFromContext(ctx))
+}
+
+// Maintenance reports the kill switch state and, for admin keys, changes it
+func (h *TorrentHandler) Maintenance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if key := apiKeyFromContext(r.Context()); key != nil && !key.Admin {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(MaintenanceResponse{
+				Success: false,
+				Message: "Only ADMIN_KEYS can change maintenance mode",
+			})
+			return
+		}
+
+		var req MaintenanceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(MaintenanceResponse{
+				Success: false,
+				Message: "Invalid request body: " + err.Error(),
+			})
+			return
+		}
+
+		if err := h.maintenance.Update(req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(MaintenanceResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		state := h.maintenance.State()
+		log.Printf("Maintenance mode: %t, disabled keys: %v", state.Enabled, state.DisabledKeys)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(MaintenanceResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET or PUT.",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MaintenanceResponse{
+		Success:          true,
+		MaintenanceState: h.maintenance.State(),
+	})
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// LibraryItem is a movie or series GET /api/media found in Radarr or Sonarr
+type LibraryItem struct {
+	Type      string `json:"type"` // "movie" or "tv"
+	ID        int    `json:"id"`
+	Title     string `json:"title"`
+	Year      int    `json:"year,omitempty"`
+	TMDBID    int    `json:"tmdb_id,omitempty"`
+	TVDBID    int    `json:"tvdb_id,omitempty"`
+	Monitored bool   `json:"monitored"`
+	Status    string `json:"status,omitempty"` // series: "continuing", "ended", ...
+	// The movie file is there, or every monitored aired episode has one
+	OnDisk           bool   `json:"on_disk"`
+	Quality          string `json:"quality,omitempty"` // of the movie file
+	EpisodeFileCount int    `json:"episode_file_count,omitempty"`
+	EpisodeCount     int    `json:"episode_count,omitempty"`
+	SizeOnDisk       int64  `json:"size_on_disk"`
+	Path             string `json:"path,omitempty"`
+}
+
+func movieLibraryItem(movie RadarrLibraryMovie) LibraryItem {
+	item := LibraryItem{
+		Type:       "movie",
+		ID:         movie.ID,
+		Title:      movie.Title,
+		Year:       movie.Year,
+		TMDBID:     movie.TMDBID,
+		Monitored:  movie.Monitored,
+		OnDisk:     movie.HasFile,
+		SizeOnDisk: movie.SizeOnDisk,
+		Path:       movie.Path,
+	}
+	if movie.MovieFile != nil {
+		item.Quality = movie.MovieFile.Quality.Quality.Name
+	}
+	return item
+}
+
+func seriesLibraryItem(series SonarrLibrarySeries) LibraryItem {
+	stats := series.Statistics
+	return LibraryItem{
+		Type:             "tv",
+		ID:               series.ID,
+		Title:            series.Title,
+		Year:             series.Year,
+		TVDBID:           series.TVDBID,
+		Monitored:        series.Monitored,
+		Status:           series.Status,
+		OnDisk:           stats.EpisodeCount > 0 && stats.EpisodeFileCount >= stats.EpisodeCount,
+		EpisodeFileCount: stats.EpisodeFileCount,
+		EpisodeCount:     stats.EpisodeCount,
+		SizeOnDisk:       stats.SizeOnDisk,
+		Path:             series.Path,
+	}
+}
+
+type MediaExistsResponse struct {
+	Success bool          `json:"success"`
+	Message string        `json:"message"`
+	Exists  bool          `json:"exists"`
+	Items   []LibraryItem `json:"items"`
+}
+
+// mediaExists answers GET /api/media: whether a title is already in Radarr or
+// Sonarr, by ?tmdb_id=, ?tvdb_id= or ?title= (with ?year= and ?type=)
+func (h *TorrentHandler) mediaExists(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var tmdbID, tvdbID, year int
+	var err error
+	for _, param := range []struct {
+		name string
+		v    *int
+	}{{"tmdb_id", &tmdbID}, {"tvdb_id", &tvdbID}, {"year", &year}} {
+		if s := query.Get(param.name); s != "" {
+			if *param.v, err = strconv.Atoi(s); err != nil || *param.v <= 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(MediaExistsResponse{
+					Success: false,
+					Message: "Invalid " + param.name + ": " + s,
+				})
+				return
+			}
+		}
+	}
+	title := strings.TrimSpace(query.Get("title"))
+	if tmdbID == 0 && tvdbID == 0 && title == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(MediaExistsResponse{
+			Success: false,
+			Message: "tmdb_id, tvdb_id or title is required",
+		})
+		return
+	}
+	mediaType := query.Get("type")
+	switch mediaType {
+	case "", "all":
+		mediaType = ""
+	case "movie", "tv":
+	case "series":
+		mediaType = "tv"
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(MediaExistsResponse{
+			Success: false,
+			Message: "Invalid type. Use 'movie', 'tv' or 'all'",
+		})
+		return
+	}
+
+	items, err := h.findInLibrary(r.Context(), tmdbID, tvdbID, title, year, mediaType)
+	if err != nil {
+		log.Printf("Error checking the library: %v", err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(MediaExistsResponse{
+			Success: false,
+			Message: "Failed to check the library: " + err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(MediaExistsResponse{
+		Success: true,
+		Message: "OK",
+		Exists:  len(items) > 0,
+		Items:   items,
+	})
+}
+
+// findInLibrary looks a title up in the libraries: IDs in the app they belong
+// to, a title (and year, if given; 0 matches any) in both unless mediaType
+// narrows it
+func (h *TorrentHandler) findInLibrary(ctx context.Context, tmdbID, tvdbID int, title string, year int, mediaType string) ([]LibraryItem, error) {
+	items := []LibraryItem{}
+	seen := make(map[string]bool)
+	add := func(item LibraryItem) {
+		key := item.Type + strconv.Itoa(item.ID)
+		if !seen[key] {
+			seen[key] = true
+			items = append(items, item)
+		}
+	}
+
+	if tmdbID != 0 {
+		movies, err := h.radarrClient.FindMoviesByTMDBID(ctx, tmdbID)
+		if err != nil {
+			return nil, err
+		}
+		for _, movie := range movies {
+			add(movieLibraryItem(movie))
+		}
+	}
+	if tvdbID != 0 {
+		series, err := h.sonarrClient.FindSeriesByTVDBID(ctx, tvdbID)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range series {
+			add(seriesLibraryItem(s))
+		}
+	}
+	if title == "" {
+		return items, nil
+	}
+
+	wanted := normalizeTitle(title)
+	sameYear := func(other int) bool {
+		return year == 0 || other == 0 || other == year
+	}
+	if mediaType != "tv" {
+		movies, err := h.radarrClient.GetMovies(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, movie := range movies {
+			if normalizeTitle(movie.Title) == wanted && sameYear(movie.Year) {
+				add(movieLibraryItem(movie))
+			}
+		}
+	}
+	if mediaType != "movie" {
+		series, err := h.sonarrClient.GetAllSeries(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range series {
+			if normalizeTitle(s.Title) == wanted && sameYear(s.Year) {
+				add(seriesLibraryItem(s))
+			}
+		}
+	}
+	return items, nil
+}
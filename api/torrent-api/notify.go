@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Notification events
+const (
+	EventLibraryItemRemoved = "library_item_removed"
+)
+
+// Notification is the JSON body posted to the notification webhook
+type Notification struct {
+	Event   string      `json:"event"`
+	Message string      `json:"message"`
+	Time    time.Time   `json:"time"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Notifier posts events to a webhook (e.g. ntfy, Apprise or Home Assistant).
+// A nil Notifier drops everything.
+type Notifier struct {
+	url        string
+	httpClient *http.Client
+}
+
+func NewNotifier(url string) *Notifier {
+	return &Notifier{
+		url: url,
+		httpClient: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: newTracingTransport(),
+		},
+	}
+}
+
+// Notify posts an event to the webhook
+func (n *Notifier) Notify(ctx context.Context, event, message string, data interface{}) error {
+	if n == nil {
+		return nil
+	}
+
+	body, err := json.Marshal(Notification{Event: event, Message: message, Time: time.Now(), Data: data})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to send notification: status %d", resp.StatusCode)
+	}
+	return nil
+}
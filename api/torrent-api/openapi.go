@@ -0,0 +1,299 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// openAPIPath and docsPath serve the OpenAPI document and Swagger UI
+const (
+	openAPIPath = "/api/openapi.json"
+	docsPath    = "/docs"
+)
+
+//go:embed openapi_docs.html
+var docsPage []byte
+
+// openAPIOperation is one documented route. Its request and response schemas are
+// generated from the structs' json tags, so the document can't drift from the code.
+type openAPIOperation struct {
+	Method   string
+	Path     string // "{name}" segments are path parameters
+	Summary  string
+	Query    []string // optional query parameters
+	Request  interface{}
+	Response interface{}
+	Public   bool   // reachable without an API key
+	Produces string // response media type when it isn't JSON, e.g. text/event-stream
+}
+
+var openAPIOperations = []openAPIOperation{
+	{Method: http.MethodPost, Path: "/api/torrent", Summary: "Add a magnet to qBittorrent and its movie or series to Radarr/Sonarr", Request: AddTorrentRequest{}, Response: AddTorrentResponse{}},
+	{Method: http.MethodPost, Path: "/api/torrent/batch", Summary: "Add several magnets", Request: BatchAddRequest{}, Response: BatchAddResponse{}},
+	{Method: http.MethodDelete, Path: "/api/torrent/{hash}", Summary: "Remove a torrent and, on request, its library item", Request: TorrentDeleteRequest{}, Response: TorrentActionResponse{}},
+	{Method: http.MethodPost, Path: "/api/torrent/{hash}/reannounce", Summary: "Announce a torrent to its trackers now", Response: TorrentActionResponse{}},
+	{Method: http.MethodGet, Path: "/api/torrent/{hash}/trackers", Summary: "A torrent's trackers", Response: TorrentActionResponse{}},
+	{Method: http.MethodPost, Path: "/api/torrent/{hash}/trackers", Summary: "Add trackers to a torrent", Request: TrackerEditRequest{}, Response: TorrentActionResponse{}},
+	{Method: http.MethodDelete, Path: "/api/torrent/{hash}/trackers", Summary: "Remove trackers from a torrent", Request: TrackerEditRequest{}, Response: TorrentActionResponse{}},
+	{Method: http.MethodPost, Path: "/api/undo", Summary: "Roll back a torrent add with its undo_token", Request: UndoRequest{}, Response: TorrentActionResponse{}},
+	{Method: http.MethodGet, Path: "/api/torrents", Summary: "List qBittorrent torrents", Query: []string{"category", "filter"}, Response: TorrentsResponse{}},
+	{Method: http.MethodGet, Path: "/api/queue", Summary: "Downloads with their qBittorrent progress and import status", Response: QueueResponse{}},
+	{Method: http.MethodPost, Path: "/api/media", Summary: "Add a movie or series to Radarr/Sonarr by title", Request: AddMediaRequest{}, Response: AddMediaResponse{}},
+	{Method: http.MethodGet, Path: "/api/media", Summary: "Whether a title is already in Radarr or Sonarr", Query: []string{"tmdb_id", "tvdb_id", "title", "year", "type"}, Response: MediaExistsResponse{}},
+	{Method: http.MethodGet, Path: "/api/search", Summary: "Look up movies or series by title", Query: []string{"q", "type", "year"}, Response: SearchResponse{}},
+	{Method: http.MethodPost, Path: "/api/parse", Summary: "Classify a torrent name without adding it", Request: ParseRequest{}, Response: ParseResponse{}},
+	{Method: http.MethodPost, Path: "/api/share", Summary: "Add the magnet in shared text (JSON or a Web Share Target form post)", Request: ShareRequest{}, Response: AddTorrentResponse{}},
+	{Method: http.MethodGet, Path: "/magnet", Summary: "HTML page confirming the add of the magnet in ?uri=, for the magnet: protocol handler", Query: []string{"uri", "type"}, Produces: "text/html"},
+	{Method: http.MethodPost, Path: "/magnet", Summary: "Add the magnet confirmed on the /magnet page (form fields uri and type)", Produces: "text/html"},
+	{Method: http.MethodPost, Path: "/api/scrape", Summary: "List the releases on a YTS, EZTV or Nyaa page", Request: ScrapeRequest{}, Response: ScrapeResponse{}},
+	{Method: http.MethodPost, Path: "/api/variants", Summary: "Group releases of the same title by quality", Request: VariantsRequest{}, Response: VariantsResponse{}},
+	{Method: http.MethodGet, Path: "/api/jobs", Summary: "Scheduled and running jobs", Response: JobsResponse{}},
+	{Method: http.MethodGet, Path: "/api/jobs/{id}", Summary: "An async add's job", Response: JobResponse{}},
+	{Method: http.MethodGet, Path: "/api/inflight", Summary: "Adds in progress", Response: InflightResponse{}},
+	{Method: http.MethodGet, Path: "/api/history", Summary: "Finished adds, newest first", Query: []string{"type", "status", "since", "until", "page", "limit"}, Response: HistoryResponse{}},
+	{Method: http.MethodGet, Path: "/api/config/options", Summary: "Radarr/Sonarr root folders and quality profiles", Response: ConfigOptionsResponse{}},
+	{Method: http.MethodGet, Path: "/api/library/upgrades", Summary: "Library items below their quality cutoff", Response: LibraryUpgradesResponse{}},
+	{Method: http.MethodGet, Path: "/api/library/stats", Summary: "Library size and counts", Query: []string{"refresh"}, Response: LibraryStatsResponse{}},
+	{Method: http.MethodGet, Path: "/api/calendar", Summary: "Upcoming releases of library items", Query: []string{"days"}, Response: CalendarResponse{}},
+	{Method: http.MethodGet, Path: "/api/seeding/categories", Summary: "Share limits by category", Response: SeedingCategoriesResponse{}},
+	{Method: http.MethodPut, Path: "/api/seeding/categories", Summary: "Change share limits by category and apply them to its torrents", Request: map[string]*SeedingPolicy{}, Response: SeedingCategoriesResponse{}},
+	{Method: http.MethodGet, Path: "/api/client/stats", Summary: "qBittorrent transfer stats and torrent counts", Response: ClientStatsResponse{}},
+	{Method: http.MethodGet, Path: "/api/stats", Summary: "Add counts, failure codes and release groups", Query: []string{"days"}, Response: StatsResponse{}},
+	{Method: http.MethodGet, Path: "/api/stats/trackers", Summary: "Add counts by tracker", Query: []string{"days"}, Response: TrackerStatsResponse{}},
+	{Method: http.MethodGet, Path: "/api/schedules", Summary: "Add schedules", Response: SchedulesResponse{}},
+	{Method: http.MethodPut, Path: "/api/schedules", Summary: "Replace the add schedules", Request: SchedulesRequest{}, Response: SchedulesResponse{}},
+	{Method: http.MethodGet, Path: "/api/admin/maintenance", Summary: "Maintenance mode", Response: MaintenanceResponse{}},
+	{Method: http.MethodPut, Path: "/api/admin/maintenance", Summary: "Pause or resume adds", Request: MaintenanceRequest{}, Response: MaintenanceResponse{}},
+	{Method: http.MethodGet, Path: "/api/admin/arr-keys", Summary: "Whether Radarr and Sonarr accept their API keys", Response: ArrKeysResponse{}},
+	{Method: http.MethodPut, Path: "/api/admin/arr-keys", Summary: "Replace the Radarr/Sonarr API keys", Request: ArrKeysRequest{}, Response: ArrKeysResponse{}},
+	{Method: http.MethodPost, Path: "/api/maintenance/reclassify", Summary: "Re-run detection on past adds", Request: ReclassifyRequest{}, Response: ReclassifyResponse{}},
+	{Method: http.MethodPost, Path: "/api/maintenance/retag", Summary: "Tag or untag added items in Radarr/Sonarr", Request: RetagRequest{}, Response: RetagResponse{}},
+	{Method: http.MethodGet, Path: "/api/selftest", Summary: "Check qBittorrent access and the Radarr/Sonarr download client setup", Response: SelfTestResponse{}},
+	{Method: http.MethodPost, Path: "/api/selftest", Summary: "Run the self-test and fix what DOWNLOAD_CLIENT_AUTOFIX allows", Response: SelfTestResponse{}},
+	{Method: http.MethodGet, Path: "/api/proxy/{service}/{path}", Summary: "Forward an allowed Radarr, Sonarr or qBittorrent API call; path may hold slashes", Produces: "application/json"},
+	{Method: http.MethodPost, Path: "/api/proxy/{service}/{path}", Summary: "Forward an allowed Radarr, Sonarr or qBittorrent API call; path may hold slashes", Produces: "application/json"},
+	{Method: http.MethodGet, Path: "/api/events", Summary: "Add, download and import events as server-sent events; Last-Event-ID resumes", Query: []string{"after"}, Produces: "text/event-stream"},
+	{Method: http.MethodGet, Path: "/api/logs/stream", Summary: "Tail the log as server-sent events", Query: []string{"level", "facility", "backlog"}, Produces: "text/event-stream"},
+	{Method: http.MethodGet, Path: "/api/version", Summary: "Build info and backend versions", Response: VersionResponse{}},
+	{Method: http.MethodGet, Path: "/health", Summary: "Ping qBittorrent, Radarr, Sonarr and the extractor", Response: HealthResponse{}, Public: true},
+	{Method: http.MethodGet, Path: "/health/live", Summary: "Liveness probe: the process is serving", Public: true, Produces: "text/plain"},
+	{Method: http.MethodGet, Path: "/health/ready", Summary: "Readiness probe: the dependencies have warmed up", Response: ReadinessResponse{}, Public: true},
+}
+
+var openAPIPathParam = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// OpenAPI serves the OpenAPI 3 document of openAPIOperations, with the server
+// URL under BASE_PATH so Swagger UI's requests go through the same prefix
+func (h *TorrentHandler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(openAPIDocument(requestBasePath(r)))
+}
+
+// Docs serves Swagger UI for the OpenAPI document
+func (h *TorrentHandler) Docs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
+
+// isDocsPath reports whether path is the OpenAPI document or Swagger UI, which
+// are served without an API key
+func isDocsPath(path string) bool {
+	return path == openAPIPath || path == docsPath
+}
+
+// requestBasePath returns the prefix basePathMiddleware stripped from the request, or ""
+func requestBasePath(r *http.Request) string {
+	original, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(original.Path, r.URL.Path)
+}
+
+// openAPIDocument builds the document for a server under basePath
+func openAPIDocument(basePath string) map[string]interface{} {
+	schemas := openAPISchemas{}
+	paths := make(map[string]map[string]interface{})
+	for _, op := range openAPIOperations {
+		var responses map[string]interface{}
+		if op.Produces == "" {
+			schema := schemas.schema(reflect.TypeOf(op.Response))
+			responses = map[string]interface{}{
+				"200":     openAPIResponse("OK", "application/json", schema),
+				"default": openAPIResponse("Failure: the same body with success false and, for adds, a code", "application/json", schema),
+			}
+		} else {
+			// Pages, streams and raw upstream bodies; failures before they start are JSON
+			failure := openAPIResponse("Failure", "application/json", schemas.schema(reflect.TypeOf(ErrorResponse{})))
+			if op.Produces == "text/html" {
+				failure = openAPIResponse("Failure", op.Produces, map[string]interface{}{"type": "string"})
+			}
+			responses = map[string]interface{}{
+				"200":     openAPIResponse("OK", op.Produces, map[string]interface{}{"type": "string"}),
+				"default": failure,
+			}
+		}
+		operation := map[string]interface{}{
+			"summary":   op.Summary,
+			"responses": responses,
+		}
+		if op.Public {
+			operation["security"] = []interface{}{}
+		}
+		var params []interface{}
+		for _, match := range openAPIPathParam.FindAllStringSubmatch(op.Path, -1) {
+			params = append(params, map[string]interface{}{"name": match[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}})
+		}
+		for _, name := range op.Query {
+			params = append(params, map[string]interface{}{"name": name, "in": "query", "schema": map[string]interface{}{"type": "string"}})
+		}
+		if params != nil {
+			operation["parameters"] = params
+		}
+		if op.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": op.Method != http.MethodDelete,
+				"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": schemas.schema(reflect.TypeOf(op.Request))}},
+			}
+		}
+		if paths[op.Path] == nil {
+			paths[op.Path] = make(map[string]interface{})
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = operation
+	}
+
+	server := basePath
+	if server == "" {
+		server = "/"
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]interface{}{"title": "Torrent API", "version": version},
+		"servers": []interface{}{map[string]interface{}{"url": server}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-Api-Key"},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"apiKey": []string{}}},
+	}
+}
+
+func openAPIResponse(description, mediaType string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content":     map[string]interface{}{mediaType: map[string]interface{}{"schema": schema}},
+	}
+}
+
+// openAPISchemas collects the component schemas of the named structs met so far
+type openAPISchemas map[string]interface{}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns the schema of t, as a $ref to a component for named structs
+func (s openAPISchemas) schema(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Pointer:
+		return s.schema(t.Elem())
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		if _, ok := s[t.Name()]; !ok {
+			s[t.Name()] = nil // recursive types refer to themselves
+			s[t.Name()] = s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return s.object(t)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	}
+	// Interfaces and raw JSON may hold anything
+	return map[string]interface{}{}
+}
+
+// object describes a struct's fields the way encoding/json marshals them:
+// named by their json tags, embedded structs inlined, and fields without
+// omitempty required
+func (s openAPISchemas) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	s.fields(t, properties, &required)
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if required != nil {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (s openAPISchemas) fields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				s.fields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.schema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ImportCompletePacks imports finished complete series packs through Sonarr's
+// manual import, mapped to the series the add matched
+func (h *TorrentHandler) ImportCompletePacks(ctx context.Context) error {
+	if h.history == nil {
+		return nil
+	}
+
+	imported := 0
+	for _, record := range h.history.List() {
+		if !record.CompleteSeries || record.Status != HistoryStatusAdded || record.MediaID == 0 {
+			continue
+		}
+		raw, err := decodeInfoHash(record.InfoHash)
+		if err != nil {
+			continue
+		}
+		hash := hex.EncodeToString(raw)
+
+		torrent, err := h.qbClient.GetTorrent(ctx, hash)
+		if err != nil {
+			return fmt.Errorf("failed to get torrent %s: %w", hash, err)
+		}
+		if torrent == nil || torrent.Progress < 1 {
+			continue
+		}
+
+		if err := h.importPack(ctx, record, torrent); err != nil {
+			log.Printf("Warning: could not import %s: %v", torrent.Name, err)
+			continue
+		}
+		imported++
+	}
+
+	if imported > 0 {
+		log.Printf("Pack import: %d complete series packs imported", imported)
+	}
+	return nil
+}
+
+func (h *TorrentHandler) importPack(ctx context.Context, record HistoryRecord, torrent *QBTorrentInfo) error {
+	contentPath := h.cfg().PathMappings.Map(torrent.ContentPath)
+	items, err := h.sonarrClient.GetManualImport(ctx, contentPath, record.MediaID)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+
+	var importable []SonarrManualImportItem
+	for _, item := range items {
+		if len(item.Rejections) == 0 && len(item.Episodes) > 0 {
+			importable = append(importable, item)
+		}
+	}
+	if len(importable) == 0 {
+		return fmt.Errorf("sonarr matched none of the %d files in %s", len(items), contentPath)
+	}
+
+	// Copy, so the pack keeps seeding
+	if err := h.sonarrClient.ManualImport(ctx, record.MediaID, strings.ToUpper(torrent.Hash), "copy", importable); err != nil {
+		return fmt.Errorf("failed to start import: %w", err)
+	}
+	log.Printf("Importing %d of %d files of %s into Sonarr series %d", len(importable), len(items), torrent.Name, record.MediaID)
+
+	now := time.Now()
+	return h.history.Update(record.ID, func(r *HistoryRecord) {
+		r.Status, r.ImportedAt = HistoryStatusImported, &now
+	})
+}
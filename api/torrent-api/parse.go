@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// ParseSchemaVersion is bumped whenever a ParseResponse field changes meaning or is removed
+const ParseSchemaVersion = 1
+
+var (
+	seasonEpisodePattern = regexp.MustCompile(`(?i)\bS(\d{1,2})[ .]?E(\d{1,3})(?:-?E?(\d{1,3}))?`)
+	crossEpisodePattern  = regexp.MustCompile(`(?i)\b(\d{1,2})x(\d{2,3})\b`)
+	seasonOnlyPattern    = regexp.MustCompile(`(?i)\b(?:S|Season[ .]?)(\d{1,2})\b`)
+)
+
+type ParseRequest struct {
+	Name       string `json:"name,omitempty"`        // Torrent name
+	MagnetLink string `json:"magnet_link,omitempty"` // Or a magnet link to take the name from
+	SourceURL  string `json:"source_url,omitempty"`  // Page the magnet was found on, for anime detection
+	Health     bool   `json:"health,omitempty"`      // Scrape the magnet's trackers
+	Type       string `json:"type,omitempty"`        // "movie" or "tv" as an add would give it; dry runs only
+	// Also run the extractor, detection and Radarr/Sonarr lookup the add would,
+	// without adding anything to qBittorrent or the library
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// ParseDryRun is what an add of the torrent would do up to the library add
+type ParseDryRun struct {
+	Extracted    *ExtractedMedia `json:"extracted,omitempty"` // extractor output, or local extraction
+	ExtractError string          `json:"extract_error,omitempty"`
+	Category     string          `json:"category"`   // qBittorrent category, with the extractor's media type applied
+	MediaType    string          `json:"media_type"` // "movie", "tv", or "game", "software" or "book"
+	// Why the add would be refused at detection, e.g. NON_MEDIA_REJECTED
+	Error      string            `json:"error,omitempty"`
+	Code       string            `json:"code,omitempty"`
+	Match      *SearchResult     `json:"match,omitempty"` // the Radarr/Sonarr lookup result it would add
+	MatchError string            `json:"match_error,omitempty"`
+	Correction *LookupCorrection `json:"lookup_correction,omitempty"`
+}
+
+// EpisodeInfo is the season/episode breakdown of a TV release
+type EpisodeInfo struct {
+	Season          *int  `json:"season,omitempty"`
+	Episodes        []int `json:"episodes,omitempty"`
+	AbsoluteEpisode int   `json:"absolute_episode,omitempty"` // anime " - 05" numbering
+	SeasonPack      bool  `json:"season_pack"`
+}
+
+// ParseDetection is the full categorization result with the rules that fired
+type ParseDetection struct {
+	CategoryDecision
+	Anime        bool   `json:"anime"`
+	NonMedia     string `json:"non_media,omitempty"`
+	NonMediaRule string `json:"non_media_rule,omitempty"`
+}
+
+type ParseResponse struct {
+	SchemaVersion int            `json:"schema_version"`
+	Success       bool           `json:"success"`
+	Message       string         `json:"message"`
+	Name          string         `json:"name,omitempty"`
+	CleanedTitle  string         `json:"cleaned_title,omitempty"` // Title as it would be looked up in Radarr/Sonarr
+	MovieInfo     *MovieInfo     `json:"movie_info,omitempty"`
+	Episode       *EpisodeInfo   `json:"episode,omitempty"`
+	Detection     ParseDetection `json:"detection"`
+	Health        *TorrentHealth `json:"health,omitempty"` // Tracker scrape, as the add would see it
+	// PREFERRED_LANGUAGE when the add would pick the Radarr/Sonarr profile for it
+	AudioLanguage string       `json:"audio_language,omitempty"`
+	DryRun        *ParseDryRun `json:"dry_run,omitempty"`
+}
+
+// parseEpisodeInfo extracts season and episode numbers, or nil if there are none
+func parseEpisodeInfo(name string, anime bool) *EpisodeInfo {
+	if m := seasonEpisodePattern.FindStringSubmatch(name); m != nil {
+		season, _ := strconv.Atoi(m[1])
+		first, _ := strconv.Atoi(m[2])
+		info := &EpisodeInfo{Season: &season, Episodes: []int{first}}
+		// S01E01-E03 is a multi-episode release
+		if last, err := strconv.Atoi(m[3]); err == nil && last > first && last-first < 50 {
+			for ep := first + 1; ep <= last; ep++ {
+				info.Episodes = append(info.Episodes, ep)
+			}
+		}
+		return info
+	}
+
+	if m := crossEpisodePattern.FindStringSubmatch(name); m != nil {
+		season, _ := strconv.Atoi(m[1])
+		episode, _ := strconv.Atoi(m[2])
+		return &EpisodeInfo{Season: &season, Episodes: []int{episode}}
+	}
+
+	if m := seasonOnlyPattern.FindStringSubmatch(name); m != nil {
+		season, _ := strconv.Atoi(m[1])
+		return &EpisodeInfo{Season: &season, SeasonPack: true}
+	}
+
+	if anime {
+		if m := animeEpisodePattern.FindStringSubmatch(name); m != nil {
+			episode, _ := strconv.Atoi(m[1])
+			return &EpisodeInfo{AbsoluteEpisode: episode}
+		}
+	}
+	return nil
+}
+
+// Parse runs the same name parsing and detection as /api/torrent without adding
+// anything, for previews and for tuning detection rules
+func (h *TorrentHandler) Parse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(ParseResponse{
+			SchemaVersion: ParseSchemaVersion,
+			Success:       false,
+			Message:       "Method not allowed. Use POST.",
+		})
+		return
+	}
+
+	var req ParseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ParseResponse{
+			SchemaVersion: ParseSchemaVersion,
+			Success:       false,
+			Message:       "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	name := req.Name
+	if name == "" && req.MagnetLink != "" {
+		if !isValidMagnetLink(req.MagnetLink) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ParseResponse{
+				SchemaVersion: ParseSchemaVersion,
+				Success:       false,
+				Message:       "Invalid magnet link format",
+			})
+			return
+		}
+		name = extractNameFromMagnet(req.MagnetLink)
+	}
+	if name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ParseResponse{
+			SchemaVersion: ParseSchemaVersion,
+			Success:       false,
+			Message:       "Name or magnet link is required",
+		})
+		return
+	}
+	if req.Type != "" && req.Type != "movie" && req.Type != "tv" && req.Type != "series" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ParseResponse{
+			SchemaVersion: ParseSchemaVersion,
+			Success:       false,
+			Message:       "Invalid type. Use 'movie' or 'tv'",
+		})
+		return
+	}
+
+	anime := isAnimeSource(req.MagnetLink, req.SourceURL)
+	detection := ParseDetection{
+		CategoryDecision: h.cfg().Detector.explain(name, anime),
+		Anime:            anime,
+	}
+	detection.NonMedia, detection.NonMediaRule = explainNonMedia(name)
+
+	movieInfo := ExtractMovieInfo(name)
+	cleaned := movieInfo.Title
+	if detection.Category == "sonarr" {
+		if anime {
+			cleaned = cleanAnimeName(name)
+		} else {
+			cleaned = cleanSeriesName(name)
+		}
+	}
+
+	// Dry runs also call the extractor and the Radarr/Sonarr lookup
+	var dryRun *ParseDryRun
+	if req.DryRun {
+		dryRun = h.dryRunAdd(r.Context(), name, anime, AddTorrentRequest{
+			MagnetLink: req.MagnetLink,
+			Type:       req.Type,
+			SourceURL:  req.SourceURL,
+		})
+	}
+
+	// The scrape is the only other network call, made only when asked for
+	var health *TorrentHealth
+	if req.MagnetLink != "" && req.Health {
+		var err error
+		if health, err = scrapeTorrentHealth(r.Context(), req.MagnetLink); err != nil {
+			log.Printf("Warning: could not check torrent health: %v", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ParseResponse{
+		SchemaVersion: ParseSchemaVersion,
+		Success:       true,
+		Message:       "OK",
+		Name:          name,
+		CleanedTitle:  cleaned,
+		MovieInfo:     &movieInfo,
+		Episode:       parseEpisodeInfo(name, anime),
+		Detection:     detection,
+		Health:        health,
+		AudioLanguage: h.audioLanguage(movieInfo),
+		DryRun:        dryRun,
+	})
+}
+
+// dryRunAdd runs the extract, detect and match steps of an add of name on their
+// own, so nothing is journaled, recorded or added
+func (h *TorrentHandler) dryRunAdd(ctx context.Context, name string, anime bool, req AddTorrentRequest) *ParseDryRun {
+	p := &AddPipeline{Request: req, TorrentName: name, Anime: anime}
+	dryRun := &ParseDryRun{}
+
+	if err := h.stepExtract(p)(ctx); err != nil {
+		dryRun.ExtractError = err.Error()
+	}
+	dryRun.Extracted = p.Extracted
+
+	if err := h.stepDetect(p)(ctx); err != nil {
+		dryRun.Error, dryRun.Code = err.Error(), errorCode(err)
+	}
+	dryRun.Category, dryRun.MediaType = p.Category, p.mediaKind()
+	if dryRun.Error != "" || p.NonMedia != "" || p.Extracted == nil {
+		return dryRun
+	}
+
+	if err := h.stepMatch(p)(ctx); err != nil {
+		dryRun.MatchError = err.Error()
+		return dryRun
+	}
+	match := newSearchResult(pipelineCard(p), p.MovieMatch, p.SeriesMatch)
+	dryRun.Match, dryRun.Correction = &match, p.Correction
+	return dryRun
+}
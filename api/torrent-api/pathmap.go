@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// A drive letter path such as D:\Downloads or D:/Downloads
+var windowsDrivePattern = regexp.MustCompile(`^[A-Za-z]:([\\/]|$)`)
+
+// PathMapping maps a folder as qBittorrent reports it to the same folder as
+// Radarr/Sonarr see it, e.g. D:\Downloads to /downloads
+type PathMapping struct {
+	From string
+	To   string
+}
+
+// PathMappings are the PATH_MAPPINGS entries; the longest matching From wins
+type PathMappings []PathMapping
+
+// parsePathMappings parses "from=to" entries separated by commas, e.g.
+// "D:\Downloads=/downloads,\\nas\media=/media"
+func parsePathMappings(spec string) (PathMappings, error) {
+	var mappings PathMappings
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(entry, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || !isAbsolutePath(from) || !isAbsolutePath(to) {
+			return nil, fmt.Errorf("invalid PATH_MAPPINGS entry %q: use from=to with absolute paths", entry)
+		}
+		mappings = append(mappings, PathMapping{From: normalizePath(from), To: normalizePath(to)})
+	}
+	return mappings, nil
+}
+
+// isWindowsPath reports whether p is a drive letter or UNC (\\server\share) path
+func isWindowsPath(p string) bool {
+	return windowsDrivePattern.MatchString(p) || strings.HasPrefix(p, `\\`) || strings.HasPrefix(p, "//")
+}
+
+func isAbsolutePath(p string) bool {
+	return isWindowsPath(p) || strings.HasPrefix(p, "/")
+}
+
+// normalizePath cleans p and writes Windows paths with forward slashes, an upper
+// case drive letter and a leading "//" for UNC shares, so D:\Downloads\ and
+// d:/Downloads compare equal
+func normalizePath(p string) string {
+	if !isWindowsPath(p) {
+		return path.Clean(p)
+	}
+	p = strings.ReplaceAll(p, `\`, "/")
+	if strings.HasPrefix(p, "//") {
+		return "/" + path.Clean("/"+strings.TrimLeft(p, "/"))
+	}
+	return strings.ToUpper(p[:1]) + ":" + path.Clean("/"+p[2:])
+}
+
+// pathKey is p normalized for comparison; Windows paths ignore case
+func pathKey(p string) string {
+	p = normalizePath(p)
+	if isWindowsPath(p) {
+		return strings.ToLower(p)
+	}
+	return p
+}
+
+// hasPathPrefix reports whether p is dir or inside it
+func hasPathPrefix(p, dir string) bool {
+	p, dir = pathKey(p), pathKey(dir)
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// Map translates a qBittorrent path to the Radarr/Sonarr path. Paths outside
+// every mapping are returned unchanged.
+func (m PathMappings) Map(p string) string {
+	var best *PathMapping
+	for i := range m {
+		if hasPathPrefix(p, m[i].From) && (best == nil || len(m[i].From) > len(best.From)) {
+			best = &m[i]
+		}
+	}
+	if best == nil {
+		return p
+	}
+
+	rest := strings.TrimPrefix(normalizePath(p)[len(best.From):], "/")
+	mapped := best.To
+	if rest != "" {
+		mapped = strings.TrimSuffix(mapped, "/") + "/" + rest
+	}
+	if isWindowsPath(mapped) {
+		return strings.ReplaceAll(mapped, "/", `\`)
+	}
+	return mapped
+}
+
+// pathVolume is the part of p that decides whether two paths can be
+// hardlinked: the drive, the UNC share, or the first folder of a POSIX path
+// (the usual container mount, as in /data/torrents and /data/media)
+func pathVolume(p string) string {
+	key := pathKey(p)
+	switch {
+	case strings.HasPrefix(key, "//"):
+		parts := strings.SplitN(strings.TrimPrefix(key, "//"), "/", 3)
+		return "//" + strings.Join(parts[:min(len(parts), 2)], "/")
+	case isWindowsPath(key):
+		return key[:2]
+	}
+	first, _, _ := strings.Cut(strings.TrimPrefix(key, "/"), "/")
+	return "/" + first
+}
+
+// checkPathMapping is the path_mapping self-test: the qBittorrent save path,
+// mapped through PATH_MAPPINGS, must be a path Radarr/Sonarr can reach, and
+// should share a volume with their root folders so imports are hardlinks
+func (h *TorrentHandler) checkPathMapping(ctx context.Context) SelfTestCheck {
+	check := SelfTestCheck{Name: "path_mapping"}
+	savePath, err := h.qbClient.GetDefaultSavePath(ctx)
+	if err != nil {
+		check.Message = err.Error()
+		return check
+	}
+
+	var roots []string
+	if folders, err := h.radarrClient.GetRootFolders(ctx); err == nil {
+		for _, folder := range folders {
+			roots = append(roots, folder.Path)
+		}
+	}
+	if folders, err := h.sonarrClient.GetRootFolders(ctx); err == nil {
+		for _, folder := range folders {
+			roots = append(roots, folder.Path)
+		}
+	}
+
+	mapped := h.cfg().PathMappings.Map(savePath)
+	seen := mapped
+	if mapped != savePath {
+		seen = fmt.Sprintf("%s (%s in qBittorrent)", mapped, savePath)
+	}
+
+	var copies []string
+	for _, root := range roots {
+		if isWindowsPath(mapped) != isWindowsPath(root) {
+			check.Message = fmt.Sprintf("downloads are saved to %s, which Radarr/Sonarr with root folder %s cannot reach; add a PATH_MAPPINGS entry", seen, root)
+			return check
+		}
+		if pathVolume(mapped) != pathVolume(root) {
+			copies = append(copies, root)
+		}
+	}
+
+	check.OK = true
+	check.Message = "downloads are saved to " + seen
+	if len(copies) > 0 {
+		check.Message += fmt.Sprintf("; imports into %s are copied, not hardlinked, since they are on another volume", strings.Join(copies, ", "))
+	}
+	return check
+}
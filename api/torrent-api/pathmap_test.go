@@ -0,0 +1,120 @@
+package main
+
+import "testing"
+
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`D:\Downloads`, "D:/Downloads"},
+		{`d:\Downloads\`, "D:/Downloads"},
+		{"d:/Downloads/", "D:/Downloads"},
+		{`D:\`, "D:/"},
+		{"D:", "D:/"},
+		{`D:\Downloads\..\Media`, "D:/Media"},
+		{`\\nas\share`, "//nas/share"},
+		{`\\nas\share\`, "//nas/share"},
+		{"//nas/share/", "//nas/share"},
+		{`\\nas\share\Movies`, "//nas/share/Movies"},
+		{"/downloads/", "/downloads"},
+		{"/downloads//tv", "/downloads/tv"},
+		{"/", "/"},
+	}
+	for _, tt := range tests {
+		if got := normalizePath(tt.in); got != tt.want {
+			t.Errorf("normalizePath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHasPathPrefix(t *testing.T) {
+	tests := []struct {
+		p, dir string
+		want   bool
+	}{
+		{`D:\Downloads\Movie`, `D:\Downloads`, true},
+		{`d:\downloads\Movie`, `D:\Downloads`, true},
+		{"D:/Downloads/Movie", `D:\Downloads\`, true},
+		{`D:\Downloads`, `D:\Downloads`, true},
+		{`D:\DownloadsOld\Movie`, `D:\Downloads`, false},
+		{`E:\Downloads\Movie`, `D:\Downloads`, false},
+		{`\\nas\share\Movies`, "//nas/share", true},
+		{`\\NAS\Share\Movies`, `\\nas\share`, true},
+		{`\\nas\share2\Movies`, `\\nas\share`, false},
+		{"/downloads/tv", "/downloads/", true},
+		{"/Downloads/tv", "/downloads", false}, // POSIX paths are case sensitive
+		{"/downloads-old/tv", "/downloads", false},
+		{"/anything", "/", true},
+	}
+	for _, tt := range tests {
+		if got := hasPathPrefix(tt.p, tt.dir); got != tt.want {
+			t.Errorf("hasPathPrefix(%q, %q) = %v, want %v", tt.p, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestPathMappingsMap(t *testing.T) {
+	mappings, err := parsePathMappings(`D:\Downloads=/downloads, D:\Downloads\TV=/tv, \\nas\media=/media, /data=E:\Data`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		in, want string
+	}{
+		{`D:\Downloads\Movie (2020)`, "/downloads/Movie (2020)"},
+		{"d:/downloads/Movie (2020)/", "/downloads/Movie (2020)"},
+		{`D:\Downloads`, "/downloads"},
+		{`D:\Downloads\TV\Show S01`, "/tv/Show S01"}, // longest prefix wins
+		{`D:\Downloads\TVShows`, "/downloads/TVShows"},
+		{`\\nas\media\Movies`, "/media/Movies"},
+		{"//nas/media/Movies", "/media/Movies"},
+		{"/data/torrents/x", `E:\Data\torrents\x`},
+		{`F:\Other\Movie`, `F:\Other\Movie`}, // unmapped paths pass through
+		{"/srv/downloads", "/srv/downloads"},
+	}
+	for _, tt := range tests {
+		if got := mappings.Map(tt.in); got != tt.want {
+			t.Errorf("Map(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if got := PathMappings(nil).Map(`D:\Downloads`); got != `D:\Downloads` {
+		t.Errorf("empty mappings changed the path to %q", got)
+	}
+}
+
+func TestParsePathMappingsRejectsRelativePaths(t *testing.T) {
+	for _, spec := range []string{"downloads=/downloads", `D:\Downloads=media`, `D:\Downloads`} {
+		if _, err := parsePathMappings(spec); err == nil {
+			t.Errorf("parsePathMappings(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestPathVolume(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`D:\Downloads\Movie`, "d:"},
+		{"d:/Media", "d:"},
+		{`\\nas\share\Movies`, "//nas/share"},
+		{"//NAS/Share/TV", "//nas/share"},
+		{`\\nas`, "//nas"},
+		{"/data/torrents", "/data"},
+		{"/data/media/", "/data"},
+		{"/downloads", "/downloads"},
+	}
+	for _, tt := range tests {
+		if got := pathVolume(tt.in); got != tt.want {
+			t.Errorf("pathVolume(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if pathVolume("/data/torrents") != pathVolume("/data/media") {
+		t.Error("folders under one mount should share a volume")
+	}
+	if pathVolume(`D:\Downloads`) == pathVolume(`E:\Media`) {
+		t.Error("different drives should be different volumes")
+	}
+}
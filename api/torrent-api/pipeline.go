@@ -0,0 +1,980 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pipeline step names, in execution order. Rating-limited keys run match and
+// rating_check before qbittorrent_add; ADD_ORDER=library_first runs match through
+// library_add before it.
+const (
+	StepExtract      = "extract"
+	StepDetect       = "detect"
+	StepHealthCheck  = "health_check"
+	StepNZBFallback  = "nzb_fallback"
+	StepFailureCheck = "failure_history"
+	StepCrossLibrary = "cross_library"
+	StepDuplicate    = "duplicate_episodes"
+	StepQBAdd        = "qbittorrent_add"
+	StepFileCheck    = "file_check"
+	StepFileExtract  = "file_extract"
+	StepMatch        = "match"
+	StepRatingCheck  = "rating_check"
+	StepWatchCheck   = "watch_history"
+	StepLibraryAdd   = "library_add"
+	StepRequesterTag = "requester_tag"
+	StepRollback     = "rollback"
+)
+
+// Adds of a series this soon after the first get its result
+const seriesCoalesceTTL = 10 * time.Minute
+
+// Step outcomes
+const (
+	StepStatusOK      = "ok"
+	StepStatusFailed  = "failed"
+	StepStatusSkipped = "skipped"
+)
+
+// StepPolicy controls how long a step may run and how it is retried
+type StepPolicy struct {
+	Timeout  time.Duration
+	Retries  int           // extra attempts after the first
+	Backoff  time.Duration // wait between attempts
+	Required bool          // a failed required step aborts the pipeline
+}
+
+// Library adds are not idempotent, so they are never retried
+var defaultStepPolicies = map[string]StepPolicy{
+	StepExtract:      {Timeout: 10 * time.Second, Retries: 1, Backoff: 500 * time.Millisecond},
+	StepDetect:       {Timeout: 2 * time.Second},
+	StepHealthCheck:  {Timeout: 10 * time.Second},
+	StepNZBFallback:  {Timeout: 45 * time.Second},
+	StepFailureCheck: {Required: true},
+	StepCrossLibrary: {Timeout: 10 * time.Second, Required: true},
+	StepQBAdd:        {Timeout: 15 * time.Second, Retries: 2, Backoff: time.Second, Required: true},
+	StepFileCheck:    {}, // waits up to FILE_CHECK_WAIT itself
+	StepFileExtract:  {Timeout: 10 * time.Second, Retries: 1, Backoff: 500 * time.Millisecond},
+	StepMatch:        {Timeout: 15 * time.Second, Retries: 1, Backoff: time.Second},
+	StepRatingCheck:  {Required: true},
+	StepWatchCheck:   {Timeout: 5 * time.Second},
+	StepLibraryAdd:   {Timeout: 30 * time.Second},
+	StepRequesterTag: {Timeout: 10 * time.Second, Retries: 1, Backoff: time.Second},
+	StepRollback:     {Timeout: 15 * time.Second, Retries: 2, Backoff: time.Second},
+}
+
+// StepResult records the outcome of one pipeline step
+type StepResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Attempts   int    `json:"attempts,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+	Code       string `json:"code,omitempty"`
+}
+
+// AddPipeline carries the state of a single torrent add through its steps
+type AddPipeline struct {
+	Request        AddTorrentRequest
+	Anime          bool
+	TorrentName    string
+	Extracted      *ExtractedMedia
+	Category       string
+	IsMovie        bool
+	NonMedia       string // "game", "software" or "book"; empty for movies/TV
+	MaxRating      string // certification limit of the requesting API key
+	MovieMatch     *RadarrSearchResult
+	SeriesMatch    *SonarrSearchResult
+	Correction     *LookupCorrection
+	Release        *ReleaseStatus         // the matched title's release status
+	Duplicate      *DuplicateEpisodes     // episodes Sonarr already has, when DUPLICATE_EPISODES found any
+	Disagreement   *DetectionDisagreement // detector and extractor picked different categories
+	MediaTitle     string
+	MediaID        int    // Radarr movie / Sonarr series ID when we added it
+	AudioLanguage  string // PREFERRED_LANGUAGE when the release carries it
+	SeedingPolicy  string // tracker domain, "private" or "public" when share limits were set
+	Health         *TorrentHealth
+	Files          *FileCheck
+	Usenet         *UsenetGrab // dead torrent replaced by an NZB, qBittorrent is skipped
+	TorrentExisted bool        // qBittorrent already had the torrent, so rollback and undo leave it alone
+	CompleteSeries bool        // box set of every season, kept away from Sonarr's download handling
+	AddedToLibrary bool
+	LibraryRetry   bool // Radarr/Sonarr was down; the libraryretry worker adds it later
+	RolledBack     bool
+	Warnings       []string
+	Steps          []StepResult
+	StartedAt      time.Time
+	Duration       time.Duration
+	APIKey         string // name of the requesting API key
+	HistoryID      int64  // processing record while the add runs, when history is enabled
+	Attempt        int    // 1, or more when the reaper retries an interrupted add
+
+	progress StepHook // per-add progress callback, e.g. a chat bot updating its reply
+}
+
+// mediaKind is "movie", "tv", or the non-media kind once detection has run
+func (p *AddPipeline) mediaKind() string {
+	switch {
+	case p.NonMedia != "":
+		return p.NonMedia
+	case p.Category == "":
+		return "" // undetermined with DETECT_DEFAULT=refuse
+	case p.IsMovie:
+		return "movie"
+	}
+	return "tv"
+}
+
+// PipelineError is returned when a required step fails
+type PipelineError struct {
+	Step string
+	Err  error
+}
+
+func (e *PipelineError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PipelineError) Unwrap() error {
+	return e.Err
+}
+
+// StepHook is called after every step finishes or is skipped
+type StepHook func(p *AddPipeline, step StepResult)
+
+// CompleteHook is called once when the pipeline finishes; err is nil on success
+type CompleteHook func(p *AddPipeline, err error)
+
+// PipelineRunner executes steps with their policies and notifies hooks,
+// so metrics and history can observe every add uniformly
+type PipelineRunner struct {
+	policies      map[string]StepPolicy
+	stepHooks     []StepHook
+	completeHooks []CompleteHook
+	inflight      *InflightAdds
+}
+
+func NewPipelineRunner() *PipelineRunner {
+	policies := make(map[string]StepPolicy, len(defaultStepPolicies))
+	for name, policy := range defaultStepPolicies {
+		policies[name] = policy
+	}
+	return &PipelineRunner{policies: policies, inflight: NewInflightAdds()}
+}
+
+// SetPolicy overrides the policy for a step
+func (r *PipelineRunner) SetPolicy(name string, policy StepPolicy) {
+	r.policies[name] = policy
+}
+
+// OnStep registers a hook called after every step
+func (r *PipelineRunner) OnStep(hook StepHook) {
+	r.stepHooks = append(r.stepHooks, hook)
+}
+
+// OnComplete registers a hook called when a pipeline finishes
+func (r *PipelineRunner) OnComplete(hook CompleteHook) {
+	r.completeHooks = append(r.completeHooks, hook)
+}
+
+// Run executes fn under the step's timeout and retry policy and records the result.
+// The returned error is a *PipelineError for required steps.
+func (r *PipelineRunner) Run(ctx context.Context, p *AddPipeline, name string, fn func(ctx context.Context) error) error {
+	policy := r.policies[name]
+	start := time.Now()
+
+	ctx, span := StartSpan(ctx, "step "+name, SpanKindInternal)
+	defer span.End()
+
+	var err error
+	attempts := 0
+	for attempts <= policy.Retries {
+		attempts++
+		r.inflight.step(p, name, attempts)
+		attemptCtx, attemptSpan := StartSpan(ctx, fmt.Sprintf("%s attempt %d", name, attempts), SpanKindInternal)
+		err = runWithTimeout(attemptCtx, name, policy.Timeout, fn)
+		attemptSpan.RecordError(err)
+		attemptSpan.End()
+		if err == nil || ctx.Err() != nil || attempts > policy.Retries {
+			break
+		}
+
+		log.Printf("Step %s failed (attempt %d), retrying: %v", name, attempts, err)
+		select {
+		case <-time.After(policy.Backoff):
+		case <-ctx.Done():
+		}
+	}
+
+	result := StepResult{
+		Name:       name,
+		Status:     StepStatusOK,
+		Attempts:   attempts,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = StepStatusFailed
+		result.Error = err.Error()
+		result.Code = errorCode(err)
+	}
+	span.SetAttribute("attempts", attempts)
+	span.RecordError(err)
+	r.record(p, result)
+
+	if err != nil && policy.Required {
+		return &PipelineError{Step: name, Err: err}
+	}
+	return err
+}
+
+// Skip records a step that was not run
+func (r *PipelineRunner) Skip(p *AddPipeline, name, reason string) {
+	r.record(p, StepResult{Name: name, Status: StepStatusSkipped, Error: reason})
+}
+
+// Finish stamps the total duration and notifies completion hooks
+func (r *PipelineRunner) Finish(p *AddPipeline, err error) {
+	p.Duration = time.Since(p.StartedAt)
+	for _, hook := range r.completeHooks {
+		hook(p, err)
+	}
+}
+
+func (r *PipelineRunner) record(p *AddPipeline, result StepResult) {
+	p.Steps = append(p.Steps, result)
+	r.inflight.finished(p, result)
+	log.Printf("Step %s: %s in %dms", result.Name, result.Status, result.DurationMs)
+	for _, hook := range r.stepHooks {
+		hook(p, result)
+	}
+	if p.progress != nil {
+		p.progress(p, result)
+	}
+}
+
+func runWithTimeout(ctx context.Context, name string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+
+	stepCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := fn(stepCtx)
+	if err != nil && errors.Is(stepCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return &StepTimeoutError{Step: name, Timeout: timeout}
+	}
+	return err
+}
+
+// StepTimeoutError is returned when a step runs past its policy timeout
+type StepTimeoutError struct {
+	Step    string
+	Timeout time.Duration
+}
+
+func (e *StepTimeoutError) Error() string {
+	return fmt.Sprintf("step %s timed out after %s", e.Step, e.Timeout)
+}
+
+// runAddPipeline runs extract → detect → qb add → match → library add for one magnet.
+// progress, if not nil, is called after every step of this add.
+func (h *TorrentHandler) runAddPipeline(ctx context.Context, req AddTorrentRequest, progress StepHook) (*AddPipeline, error) {
+	p := &AddPipeline{
+		Request:   req,
+		StartedAt: time.Now(),
+		// Anime trackers bias detection toward Sonarr
+		Anime:       isAnimeSource(req.MagnetLink, req.SourceURL),
+		TorrentName: extractNameFromMagnet(req.MagnetLink),
+		progress:    progress,
+	}
+	if key := apiKeyFromContext(ctx); key != nil {
+		p.MaxRating, p.APIKey = key.MaxRating, key.Name
+	}
+	p.Attempt = addAttempt(ctx)
+
+	// Refused before any step runs, so nothing is recorded
+	if err := h.checkAddsAllowed(ctx); err != nil {
+		return p, err
+	}
+
+	// Listed by /api/inflight until it finishes
+	untrack := h.pipeline.inflight.Track(p)
+	defer untrack()
+
+	// Adds beyond ADD_CONCURRENCY wait, interactive ones first
+	release, err := h.adds.Acquire(ctx, jobPriority(ctx), h.cfg().AddConcurrency, p.TorrentName)
+	if err != nil {
+		return p, err
+	}
+	defer release()
+
+	// Journaled with a heartbeat, so the reaper retries it if the process dies
+	stopHeartbeat := h.journalAdd(p)
+	defer stopHeartbeat()
+
+	ctx, span := StartSpan(ctx, "add pipeline", SpanKindInternal)
+	defer span.End()
+	span.SetAttribute("torrent.name", p.TorrentName)
+
+	err = h.executeAddPipeline(ctx, p)
+	h.pipeline.Finish(p, err)
+	if err == nil {
+		h.publishAdded(p)
+	}
+
+	span.SetAttribute("category", p.Category)
+	span.SetAttribute("media.title", p.MediaTitle)
+	span.RecordError(err)
+	return p, err
+}
+
+func (h *TorrentHandler) executeAddPipeline(ctx context.Context, p *AddPipeline) error {
+	// Extraction failures are soft, we can still add to qBittorrent, unless
+	// DEGRADATION says extractor=fail
+	if err := h.pipeline.Run(ctx, p, StepExtract, h.stepExtract(p)); err != nil {
+		if h.cfg().Degradation.For(DependencyExtractor) == DegradeFail {
+			return &PipelineError{Step: StepExtract, Err: newAPIError(ErrCodeDependencyUnavailable, "extractor is unavailable: %v", err)}
+		}
+		log.Printf("Warning: could not extract media name: %v", err)
+		h.recordSoftFailure(DependencyExtractor, softFailureKind(err))
+	}
+
+	if err := h.pipeline.Run(ctx, p, StepDetect, h.stepDetect(p)); err != nil {
+		return err
+	}
+
+	// Sonarr can't parse most box set names, so packs get their own category and
+	// the packimport worker imports them into the matched series
+	if !p.IsMovie && p.NonMedia == "" && isCompleteSeries(p.TorrentName) {
+		p.CompleteSeries = true
+		p.Category = h.cfg().CompleteSeriesCategory
+		log.Printf("Complete series pack, category: %s", p.Category)
+	}
+
+	// Only a confirmed dead swarm blocks the add, unreachable trackers don't
+	if h.cfg().HealthCheck != "" {
+		err := h.pipeline.Run(ctx, p, StepHealthCheck, func(ctx context.Context) error {
+			return h.checkHealth(ctx, p)
+		})
+		// NZB_FALLBACK swaps a dead torrent for the same title from Usenet
+		if (err == nil || errorCode(err) == ErrCodeNoSeeders) && h.wantsUsenetFallback(p) {
+			if fallbackErr := h.pipeline.Run(ctx, p, StepNZBFallback, h.stepNZBFallback(p)); fallbackErr != nil {
+				log.Printf("Warning: no Usenet fallback: %v", fallbackErr)
+			} else {
+				err = nil
+			}
+		}
+		if errorCode(err) == ErrCodeNoSeeders {
+			return &PipelineError{Step: StepHealthCheck, Err: err}
+		}
+		if err != nil {
+			log.Printf("Warning: could not check torrent health: %v", err)
+		}
+	}
+
+	if h.history != nil {
+		if err := h.pipeline.Run(ctx, p, StepFailureCheck, h.stepFailureCheck(p)); err != nil {
+			return err
+		}
+	}
+
+	// A title already in the other library is only downloaded once confirmed
+	if p.NonMedia == "" && p.Extracted != nil {
+		if err := h.pipeline.Run(ctx, p, StepCrossLibrary, h.stepCrossLibrary(p)); err != nil {
+			return err
+		}
+	}
+
+	// Rating-limited keys must pass the gate before anything is downloaded, and
+	// episodes Sonarr already has are found before they are downloaded again
+	libraryFirst := h.cfg().AddOrder == AddOrderLibraryFirst
+	checkDuplicates := h.checksDuplicates(p)
+	earlyMatch := p.MaxRating != "" || libraryFirst || checkDuplicates
+	matched := false
+	if earlyMatch {
+		var err error
+		if matched, err = h.matchMedia(ctx, p); err != nil {
+			return err
+		}
+	}
+	if p.MaxRating != "" {
+		if err := h.pipeline.Run(ctx, p, StepRatingCheck, h.stepRatingCheck(p)); err != nil {
+			return err
+		}
+	}
+	if checkDuplicates && matched {
+		err := h.pipeline.Run(ctx, p, StepDuplicate, h.stepDuplicateCheck(p))
+		if errorCode(err) == ErrCodeDuplicateEpisode {
+			return err
+		}
+		if err != nil {
+			log.Printf("Warning: could not check for duplicate episodes: %v", err)
+		}
+	}
+	// Downloads Sonarr must not import stay out of the library
+	downloadOnly := p.Duplicate != nil && p.Duplicate.Decision == "download_only"
+	if downloadOnly {
+		matched = false
+		h.pipeline.Skip(p, StepLibraryAdd, "duplicate episodes, download only")
+	}
+
+	// In library_first order only a title Radarr/Sonarr took is downloaded, or
+	// one whose library add waits for it to come back
+	if libraryFirst && p.NonMedia == "" && !p.LibraryRetry && !downloadOnly {
+		if !matched {
+			return &PipelineError{Step: StepMatch, Err: newAPIError(ErrCodeNoLibraryMatch, "no Radarr/Sonarr match for %s, torrent not added", p.TorrentName)}
+		}
+		if err := h.addToLibrary(ctx, p); err != nil {
+			if errorCode(err) == "" {
+				err = newAPIError(ErrCodeLibraryAddFailed, "%v", err)
+			}
+			return &PipelineError{Step: StepLibraryAdd, Err: fmt.Errorf("library add failed, torrent not added: %w", err)}
+		}
+	}
+
+	if p.Usenet != nil {
+		h.pipeline.Skip(p, StepQBAdd, "NZB grabbed from Usenet instead")
+	} else if err := h.pipeline.Run(ctx, p, StepQBAdd, h.stepQBAdd(p)); err != nil {
+		log.Printf("Error adding torrent: %v", err)
+		if isUnavailable(err) {
+			err = &PipelineError{Step: StepQBAdd, Err: newAPIError(ErrCodeDependencyUnavailable, "qbittorrent is unavailable: %v", err)}
+		}
+		if libraryFirst && p.AddedToLibrary {
+			return h.rollbackLibrary(ctx, p, err)
+		}
+		return err
+	}
+	// Samples are skipped and split movies flagged once the metadata is in
+	if hash := infoHashHex(p.Request.MagnetLink); hash != "" && h.cfg().FileCheckWait > 0 && p.Usenet == nil {
+		if err := h.pipeline.Run(ctx, p, StepFileCheck, h.stepFileCheck(p, hash)); err != nil {
+			log.Printf("Warning: could not check torrent files: %v", err)
+		}
+	}
+
+	if !libraryFirst {
+		// Pack names are often generic; the main video file usually names the title
+		if p.Files != nil && p.Files.MainFile != "" && p.NonMedia == "" && !earlyMatch {
+			if err := h.pipeline.Run(ctx, p, StepFileExtract, h.stepFileExtract(p)); err != nil {
+				log.Printf("Warning: could not extract media name from %s: %v", p.Files.MainFile, err)
+			}
+		}
+		if !earlyMatch {
+			var err error
+			if matched, err = h.matchMedia(ctx, p); err != nil {
+				return h.rollback(ctx, p, err)
+			}
+		}
+		if !matched {
+			return nil
+		}
+		if err := h.addToLibrary(ctx, p); err != nil {
+			if h.strict(p) || errorCode(err) == ErrCodeDependencyUnavailable {
+				return h.rollback(ctx, p, err)
+			}
+			// The torrent stays without its library entry
+			h.recordSoftFailure(arrDependency(p), softFailureKind(err))
+		}
+	}
+
+	// Attribute the title to the API key that asked for it
+	if p.AddedToLibrary && h.cfg().RequesterTags && apiKeyFromContext(ctx) != nil {
+		if err := h.pipeline.Run(ctx, p, StepRequesterTag, func(ctx context.Context) error {
+			return h.tagRequester(ctx, p.IsMovie, p.MediaID)
+		}); err != nil {
+			log.Printf("Warning: could not tag requester: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// addToLibrary checks watch history and adds the matched title to Radarr/Sonarr.
+// A title that is already in the library, or whose add DEGRADATION deferred, is
+// not an error; an unavailable dependency set to fail returns DEPENDENCY_UNAVAILABLE.
+func (h *TorrentHandler) addToLibrary(ctx context.Context, p *AddPipeline) error {
+	// Watch history is informational only
+	if h.tautulliClient != nil {
+		if err := h.pipeline.Run(ctx, p, StepWatchCheck, h.stepWatchCheck(p)); err != nil {
+			log.Printf("Warning: could not check watch history: %v", err)
+			if err := h.degrade(p, DependencyTautulli, StepWatchCheck, err); err != nil {
+				return err
+			}
+			h.recordSoftFailure(DependencyTautulli, softFailureKind(err))
+		}
+	}
+
+	err := h.pipeline.Run(ctx, p, StepLibraryAdd, h.stepLibraryAdd(p))
+	if err == nil {
+		return nil
+	}
+	// Check if media already exists (common case)
+	if strings.Contains(err.Error(), "already") || strings.Contains(err.Error(), "exists") {
+		log.Printf("Media already exists in library: %v", err)
+		return nil
+	}
+	log.Printf("Warning: could not add media to library: %v", err)
+	if err := h.degrade(p, arrDependency(p), StepLibraryAdd, err); err != nil {
+		return err
+	}
+	if p.LibraryRetry {
+		h.recordSoftFailure(arrDependency(p), softFailureKind(err))
+		return nil
+	}
+	// The torrent stays when the add isn't strict, so say why the series is missing
+	if errorCode(err) == ErrCodePathConflict {
+		p.Warnings = append(p.Warnings, "Not added to Sonarr: "+err.Error())
+	}
+	return err
+}
+
+// strict reports whether a failed library add should undo the qBittorrent add
+func (h *TorrentHandler) strict(p *AddPipeline) bool {
+	if p.Request.Strict != nil {
+		return *p.Request.Strict
+	}
+	return h.cfg().StrictLibraryAdd
+}
+
+// rollback removes the just-added torrent so a failed library add doesn't leave
+// an unmanaged download, and returns the error for the request
+func (h *TorrentHandler) rollback(ctx context.Context, p *AddPipeline, cause error) error {
+	if errorCode(cause) == "" {
+		cause = newAPIError(ErrCodeLibraryAddFailed, "%v", cause)
+	}
+	// An NZB grabbed through Prowlarr can't be taken back from here
+	if p.Usenet != nil {
+		return &PipelineError{Step: StepLibraryAdd, Err: fmt.Errorf("library add failed, NZB %s was already grabbed: %w", p.Usenet.Title, cause)}
+	}
+	// A torrent qBittorrent had before this add isn't ours to remove
+	if p.TorrentExisted {
+		h.pipeline.Skip(p, StepRollback, "torrent was already in qBittorrent")
+		return &PipelineError{Step: StepLibraryAdd, Err: fmt.Errorf("library add failed, the torrent qBittorrent already had was left in place: %w", cause)}
+	}
+
+	// qBittorrent only matches hex hashes, so base32 magnets are converted
+	hash := infoHashHex(p.Request.MagnetLink)
+	err := h.pipeline.Run(ctx, p, StepRollback, func(ctx context.Context) error {
+		if hash == "" {
+			return fmt.Errorf("magnet link has no valid info hash")
+		}
+		return h.qbClient.DeleteTorrent(ctx, hash, true)
+	})
+	if err != nil {
+		log.Printf("Error rolling back torrent %s: %v", hash, err)
+		return &PipelineError{Step: StepLibraryAdd, Err: fmt.Errorf("library add failed and the torrent could not be removed (%v): %w", err, cause)}
+	}
+
+	p.RolledBack = true
+	log.Printf("Rolled back torrent %s after failed library add", hash)
+	return &PipelineError{Step: StepLibraryAdd, Err: fmt.Errorf("library add failed, torrent removed from qBittorrent: %w", cause)}
+}
+
+// rollbackLibrary removes the movie/series a library_first add created when the
+// torrent could not be added, and returns the error for the request
+func (h *TorrentHandler) rollbackLibrary(ctx context.Context, p *AddPipeline, cause error) error {
+	err := h.pipeline.Run(ctx, p, StepRollback, func(ctx context.Context) error {
+		if p.IsMovie {
+			return h.radarrClient.DeleteMovie(ctx, p.MediaID)
+		}
+		return h.sonarrClient.DeleteSeries(ctx, p.MediaID)
+	})
+	if err != nil {
+		log.Printf("Error rolling back library add of %s: %v", p.MediaTitle, err)
+		return &PipelineError{Step: StepQBAdd, Err: fmt.Errorf("torrent add failed and %s could not be removed from the library (%v): %w", p.MediaTitle, err, cause)}
+	}
+
+	p.RolledBack = true
+	p.AddedToLibrary = false
+	log.Printf("Rolled back library add of %s after failed torrent add", p.MediaTitle)
+	return &PipelineError{Step: StepQBAdd, Err: fmt.Errorf("torrent add failed, %s removed from the library again: %w", p.MediaTitle, cause)}
+}
+
+// matchMedia runs the match step, recording skips when there is nothing to match.
+// It reports whether a library match was found, and fails only when Radarr/Sonarr
+// is down and DEGRADATION says to.
+func (h *TorrentHandler) matchMedia(ctx context.Context, p *AddPipeline) (bool, error) {
+	// Non-media torrents never go to Radarr/Sonarr
+	if p.NonMedia != "" {
+		h.pipeline.Skip(p, StepMatch, "non-media torrent")
+		h.pipeline.Skip(p, StepLibraryAdd, "non-media torrent")
+		return false, nil
+	}
+
+	// Only try to add to library if we successfully extracted the media name
+	if p.Extracted == nil {
+		log.Printf("Skipping library add - could not extract media name")
+		h.pipeline.Skip(p, StepMatch, "media name not extracted")
+		h.pipeline.Skip(p, StepLibraryAdd, "media name not extracted")
+		return false, nil
+	}
+	p.MediaTitle = p.Extracted.ExtractedName
+
+	if err := h.pipeline.Run(ctx, p, StepMatch, h.stepMatch(p)); err != nil {
+		log.Printf("Warning: could not match media: %v", err)
+		if err := h.degrade(p, arrDependency(p), StepMatch, err); err != nil {
+			return false, err
+		}
+		// Radarr/Sonarr answering without a match is a soft failure too
+		kind := softFailureKind(err)
+		if kind == SoftFailureError {
+			kind = SoftFailureNotFound
+		}
+		h.recordSoftFailure(arrDependency(p), kind)
+		h.recordSoftFailure(arrDependency(p), SoftFailureSkipped)
+		if p.LibraryRetry {
+			h.pipeline.Skip(p, StepLibraryAdd, "deferred until "+arrDependency(p)+" is back")
+		} else {
+			h.pipeline.Skip(p, StepLibraryAdd, "no library match")
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+func (h *TorrentHandler) stepExtract(p *AddPipeline) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		torrentName := p.TorrentName
+		if p.Anime {
+			// Fansub names confuse the extractor; give it the bare series name
+			torrentName = cleanAnimeName(torrentName)
+			log.Printf("Anime source detected, cleaned name: %s", torrentName)
+		}
+
+		extractedMedia, err := h.extractName(ctx, torrentName, p.Anime)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Extracted media: %s (%s) - Type: %s [%s]", extractedMedia.ExtractedName, extractedMedia.Year, extractedMedia.MediaType, extractedMedia.Source)
+		debugf(ctx, "Extracted %q (%s), type %q, from %s; cleaned name: %q", extractedMedia.ExtractedName, extractedMedia.Year, extractedMedia.MediaType, extractedMedia.Source, cleanTorrentName(torrentName))
+		p.Extracted = extractedMedia
+		return nil
+	}
+}
+
+// extractName asks the extractor for the media name, falling back to local
+// extraction when DEGRADATION says so
+func (h *TorrentHandler) extractName(ctx context.Context, name string, anime bool) (*ExtractedMedia, error) {
+	if h.cfg().Degradation.For(DependencyExtractor) == DegradeFallback {
+		extractedMedia, err := h.extractorClient.ExtractNameHedged(ctx, name, anime)
+		if err != nil {
+			log.Printf("Extractor failed, using local extraction: %v", err)
+			h.recordSoftFailure(DependencyExtractor, softFailureKind(err))
+			return localExtractName(name, anime), nil
+		}
+		if extractedMedia.fallback != "" {
+			h.recordSoftFailure(DependencyExtractor, extractedMedia.fallback)
+		}
+		return extractedMedia, nil
+	}
+	// Hedging answers with local extraction, which skip and fail rule out
+	return h.extractorClient.ExtractName(ctx, name)
+}
+
+func (h *TorrentHandler) stepDetect(p *AddPipeline) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if err := h.detect(ctx, p); err != nil {
+			return err
+		}
+		// Keys limited to movies or TV can't add the other kind, or non-media
+		return checkKeyMediaType(ctx, p.mediaKind())
+	}
+}
+
+// detect sets the category from the request's type, non-media detection, the
+// detector and the extractor
+func (h *TorrentHandler) detect(ctx context.Context, p *AddPipeline) error {
+	// User specified type
+	switch p.Request.Type {
+	case "movie":
+		p.Category, p.IsMovie = "radarr", true
+		debugf(ctx, "Type movie given by the request")
+		return nil
+	case "tv", "series":
+		p.Category, p.IsMovie = "sonarr", false
+		debugf(ctx, "Type tv given by the request")
+		return nil
+	}
+
+	// Games, software and books follow the configured policy instead of the movie path
+	if kind := classifyNonMedia(p.TorrentName); kind != "" {
+		p.NonMedia = kind
+		switch h.cfg().NonMediaPolicy {
+		case NonMediaPolicyReject:
+			return newAPIError(ErrCodeNonMediaRejected, "%s torrents are not accepted", kind)
+		case NonMediaPolicyDownloadOnly:
+			p.Category = ""
+		default:
+			p.Category = h.cfg().NonMediaCategory
+			if p.Category == "" {
+				p.Category = kind
+			}
+		}
+		log.Printf("Non-media torrent (%s), category: %q", kind, p.Category)
+		debugf(ctx, "Non-media torrent (%s) with policy %q, category %q", kind, h.cfg().NonMediaPolicy, p.Category)
+		return nil
+	}
+
+	// Auto-detect type from magnet link
+	decision := h.cfg().Detector.explain(p.TorrentName, p.Anime)
+	p.Category = decision.Category
+	p.IsMovie = p.Category == "radarr"
+	debugf(ctx, "Detected %s: tv score %d %v, movie score %d %v (%s)", decision.Category, decision.TVScore, decision.TVRules, decision.MovieScore, decision.MovieRules, decision.Reason)
+
+	// Use extractor's media type if available
+	if extractorCategoryApplies(p.TorrentName, p.Anime, p.Extracted) {
+		if category := extractorCategory(p.Extracted); category != "" {
+			p.Category, p.IsMovie = category, category == "radarr"
+		}
+		if p.Category != decision.Category {
+			p.Disagreement = &DetectionDisagreement{Anime: p.Anime, Detector: decision, Extractor: p.Extracted.MediaType}
+		}
+		log.Printf("Updated category based on extractor: %s", p.Category)
+		debugf(ctx, "Extractor type %q overrides detection: %s", p.Extracted.MediaType, p.Category)
+	}
+	if p.Category == "" {
+		return newAPIError(ErrCodeMediaTypeUndetermined, "could not tell whether %s is a movie or TV; resend with type movie or tv", p.TorrentName)
+	}
+
+	log.Printf("Adding torrent with category: %s", p.Category)
+	return nil
+}
+
+// extractorCategoryApplies reports whether the extractor's media type overrides
+// detection: it must have one, and an anime release must not carry an episode
+// number the extractor can't see
+func extractorCategoryApplies(name string, anime bool, extracted *ExtractedMedia) bool {
+	episodic := anime && animeEpisodePattern.MatchString(name)
+	return extracted != nil && extracted.MediaType != "" && !episodic
+}
+
+// extractorCategory maps the extractor's media type to a category, or ""
+func extractorCategory(extracted *ExtractedMedia) string {
+	switch extracted.MediaType {
+	case "movie":
+		return "radarr"
+	case "tv", "series":
+		return "sonarr"
+	}
+	return ""
+}
+
+func (h *TorrentHandler) stepQBAdd(p *AddPipeline) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		// Ensure category exists in qBittorrent
+		if p.Category != "" {
+			if err := h.qbClient.EnsureCategory(ctx, p.Category); err != nil {
+				log.Printf("Warning: could not ensure category exists: %v", err)
+			}
+		}
+
+		// A .torrent file's magnet link is recorded without its trackers, but
+		// they still pick the seeding policy
+		policyLink := p.Request.MagnetLink
+		if p.Request.TorrentFile != nil {
+			if t, err := parseTorrentFile(p.Request.TorrentFile); err == nil {
+				policyLink = t.magnet(true)
+			}
+		}
+		seeding, rule := h.cfg().Seeding.PolicyFor(policyLink, p.Category)
+		if seeding != nil {
+			p.SeedingPolicy = rule
+			log.Printf("Applying %s seeding policy", rule)
+			debugf(ctx, "Seeding policy %s: %+v", rule, *seeding)
+		}
+		added, err := h.qbClient.AddTorrent(ctx, p.Request.MagnetLink, p.Request.TorrentFile, p.Category, seeding)
+		if err == nil && !added {
+			p.TorrentExisted = true
+			p.Warnings = append(p.Warnings, "qBittorrent already has this torrent; it was left as it is")
+		}
+		return err
+	}
+}
+
+func (h *TorrentHandler) stepMatch(p *AddPipeline) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if p.IsMovie {
+			movie, err := h.radarrClient.MatchMovie(ctx, p.Extracted)
+			if err != nil {
+				return err
+			}
+			p.MovieMatch = movie
+			p.Correction = movie.Correction
+			debugf(ctx, "Matched movie %s (%d), TMDB %d", movie.Title, movie.Year, movie.TMDBID)
+			p.setRelease(movieRelease(movie))
+			return nil
+		}
+
+		series := h.rememberedSeries(ctx, p.Extracted.ExtractedName, p.Extracted.Year)
+		if series == nil {
+			var err error
+			if series, err = h.sonarrClient.MatchSeries(ctx, p.Extracted); err != nil {
+				return err
+			}
+			p.Correction = series.Correction
+			debugf(ctx, "Matched series %s (%d), TVDB %d", series.Title, series.Year, series.TVDBID)
+		}
+		p.SeriesMatch = series
+		p.setRelease(seriesRelease(series))
+		return nil
+	}
+}
+
+// setRelease records the matched title's release status and warns when the
+// download is likely a CAM or fake
+func (p *AddPipeline) setRelease(release *ReleaseStatus) {
+	p.Release = release
+	if release != nil && release.Message != "" {
+		p.Warnings = append(p.Warnings, release.Message)
+	}
+}
+
+func (h *TorrentHandler) stepRatingCheck(p *AddPipeline) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		title, certification := p.TorrentName, ""
+		if p.MovieMatch != nil {
+			title, certification = p.MovieMatch.Title, p.MovieMatch.Certification
+		} else if p.SeriesMatch != nil {
+			title, certification = p.SeriesMatch.Title, p.SeriesMatch.Certification
+		}
+		return checkRating(title, certification, p.MaxRating)
+	}
+}
+
+// stepFailureCheck warns when the same torrent or title failed before, and
+// refuses the add without force when PREVIOUS_FAILURE_REQUIRE_FORCE is set
+func (h *TorrentHandler) stepFailureCheck(p *AddPipeline) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		title := ""
+		if p.Extracted != nil {
+			title = p.Extracted.ExtractedName
+		}
+		record := h.history.PreviousFailure(extractInfoHash(p.Request.MagnetLink), title)
+		if record == nil {
+			return nil
+		}
+
+		reason := record.Error
+		if reason == "" {
+			reason = record.Code
+		}
+		name := record.MediaTitle
+		if name == "" {
+			name = record.Name
+		}
+		warning := fmt.Sprintf("%s failed before on %s: %s", name, record.AddedAt.Format("2006-01-02"), reason)
+		p.Warnings = append(p.Warnings, warning)
+		if h.cfg().PreviousFailureRequireForce && !p.Request.Force {
+			return newAPIError(ErrCodePreviouslyFailed, "%s; resend with force to add anyway", warning)
+		}
+		return nil
+	}
+}
+
+func (h *TorrentHandler) stepCrossLibrary(p *AddPipeline) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		year, _ := strconv.Atoi(p.Extracted.Year)
+		warnings, err := h.checkCrossLibrary(ctx, p.Extracted.ExtractedName, year, p.IsMovie, p.Request.Confirm)
+		p.Warnings = append(p.Warnings, warnings...)
+		return err
+	}
+}
+
+func (h *TorrentHandler) stepWatchCheck(p *AddPipeline) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var title string
+		var year int
+		if p.IsMovie {
+			title, year = p.MovieMatch.Title, p.MovieMatch.Year
+		} else {
+			title, year = p.SeriesMatch.Title, p.SeriesMatch.Year
+		}
+
+		record, err := h.tautulliClient.FindWatched(ctx, title, year, p.IsMovie)
+		if err != nil {
+			return err
+		}
+		if record != nil {
+			p.Warnings = append(p.Warnings, watchedWarning(record))
+		}
+		return nil
+	}
+}
+
+// audioLanguage is PREFERRED_LANGUAGE if the release is tagged with it, or is
+// multi-audio and so most likely has the household's dub too
+func (h *TorrentHandler) audioLanguage(info MovieInfo) string {
+	language := h.cfg().PreferredLanguage
+	if language == "" || !(info.MultiAudio || info.hasLanguage(language)) {
+		return ""
+	}
+	return language
+}
+
+func (h *TorrentHandler) stepLibraryAdd(p *AddPipeline) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if p.AudioLanguage = h.audioLanguage(ExtractMovieInfo(p.TorrentName)); p.AudioLanguage != "" {
+			debugf(ctx, "Release has %s audio, using the profile for it if there is one", p.AudioLanguage)
+		}
+
+		if p.IsMovie {
+			log.Printf("Adding movie to Radarr: %s", p.MovieMatch.Title)
+			// Don't search, we're adding via torrent
+			movie, err := h.radarrClient.AddMatchedMovie(ctx, p.MovieMatch, p.Request.arrChoice(), false, 0, p.AudioLanguage)
+			if err != nil {
+				return err
+			}
+			log.Printf("Movie added to Radarr: %s", movie.Title)
+			p.MediaTitle = movie.Title
+			p.MediaID = movie.ID
+			p.AddedToLibrary = true
+			return nil
+		}
+
+		seriesType := "standard"
+		if p.Anime {
+			seriesType = "anime"
+		}
+		log.Printf("Adding series to Sonarr: %s", p.SeriesMatch.Title)
+		monitor := h.seriesMonitor(p.SeriesMatch)
+		if p.CompleteSeries {
+			monitor = MonitorCompleteSeries
+		}
+		debugf(ctx, "Adding series as %s, monitoring %s", seriesType, monitor)
+		add := func(ctx context.Context) (*SonarrSeries, error) {
+			return h.sonarrClient.AddMatchedSeries(ctx, p.SeriesMatch, p.Request.arrChoice(), seriesType, monitor, false, p.AudioLanguage)
+		}
+		var series *SonarrSeries
+		var shared bool
+		var err error
+		// A zero TVDB ID says nothing about the series, so it is never coalesced
+		if p.SeriesMatch.TVDBID != 0 {
+			// Other adds wait on this one, so it isn't cut short when this
+			// request's client goes away
+			series, shared, err = h.seriesCoalescer.Do(p.SeriesMatch.TVDBID, seriesCoalesceTTL, func() (*SonarrSeries, error) {
+				return add(context.WithoutCancel(ctx))
+			})
+		} else {
+			series, err = add(ctx)
+		}
+		if err != nil {
+			return err
+		}
+		if shared {
+			log.Printf("Series add coalesced with a concurrent request: %s", series.Title)
+		} else {
+			log.Printf("Series added to Sonarr: %s", series.Title)
+		}
+		p.MediaTitle = series.Title
+		p.MediaID = series.ID
+		p.AddedToLibrary = true
+		return nil
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// An unconfirmed preview's title is removed from the library after this long
+const mediaPreviewTTL = 15 * time.Minute
+
+// A preview lists this many releases, in the app's own ranking
+const maxPreviewReleases = 10
+
+// PreviewRelease is an indexer release a preview offers, with the reasons the
+// app would not grab it
+type PreviewRelease struct {
+	GUID       string   `json:"guid"`
+	Title      string   `json:"title"`
+	Indexer    string   `json:"indexer,omitempty"`
+	Protocol   string   `json:"protocol"`
+	Quality    string   `json:"quality"`
+	Size       int64    `json:"size"`
+	Seeders    *int     `json:"seeders,omitempty"`
+	Approved   bool     `json:"approved"`
+	Rejections []string `json:"rejections,omitempty"`
+}
+
+// MediaPreview is a title added by a "preview": true request, held in the
+// library without a search until it is confirmed, cancelled or expires
+type MediaPreview struct {
+	ID        string           `json:"id"`
+	ExpiresAt time.Time        `json:"expires_at"`
+	Total     int              `json:"total"`    // releases found
+	Approved  int              `json:"approved"` // releases the app would grab
+	Releases  []PreviewRelease `json:"releases"`
+	Error     string           `json:"error,omitempty"` // the release search failed
+
+	mediaType  string
+	mediaID    int
+	mediaTitle string
+	apiKey     string
+	releases   []ArrRelease // all found, for the grab
+	timer      *time.Timer
+}
+
+// MediaPreviews keeps the previews waiting for an answer; they are lost on
+// restart, leaving their titles in the library without a search
+type MediaPreviews struct {
+	mu       sync.Mutex
+	nextID   int64
+	previews map[string]*MediaPreview
+}
+
+func NewMediaPreviews() *MediaPreviews {
+	return &MediaPreviews{previews: make(map[string]*MediaPreview)}
+}
+
+// add registers a preview, calling expire with it once mediaPreviewTTL passed
+func (m *MediaPreviews) add(preview *MediaPreview, expire func(*MediaPreview)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	preview.ID = fmt.Sprintf("preview-%d", m.nextID)
+	preview.ExpiresAt = time.Now().Add(mediaPreviewTTL)
+	m.previews[preview.ID] = preview
+	preview.timer = time.AfterFunc(mediaPreviewTTL, func() {
+		if m.take(preview.ID) != nil {
+			expire(preview)
+		}
+	})
+}
+
+// get returns a waiting preview, or nil
+func (m *MediaPreviews) get(id string) *MediaPreview {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.previews[id]
+}
+
+// take removes a waiting preview and returns it, or nil if it was answered or
+// expired in the meantime
+func (m *MediaPreviews) take(id string) *MediaPreview {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	preview, ok := m.previews[id]
+	if !ok {
+		return nil
+	}
+	delete(m.previews, id)
+	preview.timer.Stop()
+	return preview
+}
+
+// previewReleases runs the app's release search for a title added without one
+// and holds it as a preview. Series are searched for the given season.
+func (h *TorrentHandler) previewReleases(ctx context.Context, resp *AddMediaResponse, season int) *MediaPreview {
+	preview := &MediaPreview{
+		mediaType:  resp.MediaType,
+		mediaID:    resp.MediaID,
+		mediaTitle: resp.MediaTitle,
+		apiKey:     keyName(ctx),
+	}
+
+	var releases []ArrRelease
+	var err error
+	if resp.MediaType == "movie" {
+		releases, err = h.radarrClient.SearchReleases(ctx, resp.MediaID)
+	} else {
+		releases, err = h.sonarrClient.SearchSeasonReleases(ctx, resp.MediaID, season)
+	}
+	if err != nil {
+		log.Printf("Warning: could not list releases of %s: %v", resp.MediaTitle, err)
+		preview.Error = err.Error()
+	}
+
+	preview.releases = releases
+	preview.Total = len(releases)
+	preview.Releases = make([]PreviewRelease, 0, maxPreviewReleases)
+	for _, r := range releases {
+		if r.Approved {
+			preview.Approved++
+		}
+		if len(preview.Releases) < maxPreviewReleases {
+			preview.Releases = append(preview.Releases, PreviewRelease{
+				GUID:       r.GUID,
+				Title:      r.Title,
+				Indexer:    r.Indexer,
+				Protocol:   r.Protocol,
+				Quality:    r.Quality.Quality.Name,
+				Size:       r.Size,
+				Seeders:    r.Seeders,
+				Approved:   r.Approved,
+				Rejections: r.Rejections,
+			})
+		}
+	}
+
+	h.previews.add(preview, h.expirePreview)
+	log.Printf("Previewing %d releases (%d approved) of %s as %s", preview.Total, preview.Approved, preview.mediaTitle, preview.ID)
+	return preview
+}
+
+// previewSeason picks the season a series preview searches: the latest of an
+// airing series, as that is what gets monitored, otherwise the first
+func previewSeason(series *SonarrSearchResult) int {
+	season := 1
+	if series.Airing() {
+		for _, s := range series.Seasons {
+			if s.SeasonNumber > season {
+				season = s.SeasonNumber
+			}
+		}
+	}
+	return season
+}
+
+// resolvePreview answers a preview: cancel removes its title from the library,
+// otherwise the chosen release is grabbed, or without one the app's search is
+// scheduled like any search on add
+func (h *TorrentHandler) resolvePreview(ctx context.Context, req AddMediaRequest) (*AddMediaResponse, error) {
+	preview := h.previews.get(req.PreviewID)
+	// Other keys' previews don't exist as far as limited keys are concerned
+	if key := apiKeyFromContext(ctx); preview != nil && key != nil && !key.Admin && preview.apiKey != key.Name {
+		preview = nil
+	}
+	if preview == nil {
+		err := newAPIError(ErrCodePreviewNotFound, "preview %s not found or expired", req.PreviewID)
+		return &AddMediaResponse{Success: false, Message: err.Error(), Code: errorCode(err)}, err
+	}
+
+	var release *ArrRelease
+	if req.Release != "" && !req.Cancel {
+		for i := range preview.releases {
+			if preview.releases[i].GUID == req.Release {
+				release = &preview.releases[i]
+				break
+			}
+		}
+		if release == nil {
+			err := newAPIError(ErrCodePreviewNotFound, "release %s is not in preview %s", req.Release, req.PreviewID)
+			return &AddMediaResponse{Success: false, Message: err.Error(), Code: errorCode(err)}, err
+		}
+	}
+	if h.previews.take(preview.ID) == nil {
+		err := newAPIError(ErrCodePreviewNotFound, "preview %s not found or expired", req.PreviewID)
+		return &AddMediaResponse{Success: false, Message: err.Error(), Code: errorCode(err)}, err
+	}
+
+	resp := &AddMediaResponse{
+		Success:    true,
+		MediaTitle: preview.mediaTitle,
+		MediaType:  preview.mediaType,
+		MediaID:    preview.mediaID,
+	}
+	isMovie := preview.mediaType == "movie"
+	switch {
+	case req.Cancel:
+		if err := h.removePreviewed(ctx, preview); err != nil {
+			log.Printf("Error removing previewed %s: %v", preview.mediaTitle, err)
+			return &AddMediaResponse{Success: false, Message: "Failed to cancel preview: " + err.Error(), Code: errorCode(err)}, err
+		}
+		resp.Message = "Preview cancelled; " + preview.mediaTitle + " was removed again"
+	case release != nil:
+		var err error
+		if isMovie {
+			err = h.radarrClient.GrabRelease(ctx, release)
+		} else {
+			err = h.sonarrClient.GrabRelease(ctx, release)
+		}
+		if err != nil {
+			// The title stays in the library; a later search can still find something
+			log.Printf("Error grabbing %s for %s: %v", release.Title, preview.mediaTitle, err)
+			return &AddMediaResponse{Success: false, Message: "Failed to grab release: " + err.Error(), Code: errorCode(err)}, err
+		}
+		log.Printf("Grabbing %s for %s", release.Title, preview.mediaTitle)
+		resp.Message = "Grabbing " + release.Title
+	default:
+		at := h.searchAfterAdd(isMovie, preview.mediaID, preview.mediaTitle)
+		resp.SearchAt = &at
+		resp.Message = "Search scheduled for " + preview.mediaTitle
+	}
+	return resp, nil
+}
+
+// expirePreview removes the title of a preview nobody answered
+func (h *TorrentHandler) expirePreview(preview *MediaPreview) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := h.removePreviewed(ctx, preview); err != nil {
+		log.Printf("Warning: could not remove expired preview of %s: %v", preview.mediaTitle, err)
+		return
+	}
+	log.Printf("Preview %s expired; removed %s", preview.ID, preview.mediaTitle)
+}
+
+// removePreviewed deletes a previewed title from its library, and marks its add
+// in the history as deleted
+func (h *TorrentHandler) removePreviewed(ctx context.Context, preview *MediaPreview) error {
+	var err error
+	if preview.mediaType == "movie" {
+		err = h.radarrClient.DeleteMovie(ctx, preview.mediaID)
+	} else {
+		err = h.sonarrClient.DeleteSeries(ctx, preview.mediaID)
+	}
+	if err != nil && !isArrNotFound(err) {
+		return err
+	}
+
+	if h.history == nil {
+		return nil
+	}
+	records := h.history.List()
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.Source == "media" && record.MediaType == preview.mediaType && record.MediaID == preview.mediaID {
+			if err := h.history.Update(record.ID, func(record *HistoryRecord) {
+				record.Status = HistoryStatusDeleted
+			}); err != nil {
+				log.Printf("Warning: could not record history: %v", err)
+			}
+			break
+		}
+	}
+	return nil
+}
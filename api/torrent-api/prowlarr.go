@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProwlarrClient searches Prowlarr's indexers and sends grabs to the download
+// clients configured in Prowlarr
+type ProwlarrClient struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// ProwlarrRelease is one search result
+type ProwlarrRelease struct {
+	GUID        string    `json:"guid"`
+	IndexerID   int       `json:"indexerId"`
+	Indexer     string    `json:"indexer"`
+	Title       string    `json:"title"`
+	Size        int64     `json:"size"`
+	Protocol    string    `json:"protocol"` // "usenet" or "torrent"
+	PublishDate time.Time `json:"publishDate"`
+	Grabs       int       `json:"grabs"`
+}
+
+// UsenetGrab is the NZB an add fell back to
+type UsenetGrab struct {
+	Title   string `json:"title"`
+	Indexer string `json:"indexer"`
+	Size    int64  `json:"size"`
+}
+
+// Newznab categories
+const (
+	prowlarrCategoryMovies = "2000"
+	prowlarrCategoryTV     = "5000"
+)
+
+func NewProwlarrClient(baseURL, apiKey string) *ProwlarrClient {
+	return &ProwlarrClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second, // Prowlarr waits for every indexer
+			Transport: newTracingTransport(),
+		},
+	}
+}
+
+// Search runs a search across Prowlarr's indexers in a Newznab category
+func (c *ProwlarrClient) Search(ctx context.Context, query, category string) ([]ProwlarrRelease, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("type", "search")
+	params.Set("categories", category)
+
+	var releases []ProwlarrRelease
+	if err := c.do(ctx, http.MethodGet, "/api/v1/search?"+params.Encode(), nil, &releases); err != nil {
+		return nil, fmt.Errorf("prowlarr search failed: %w", err)
+	}
+	return releases, nil
+}
+
+// Grab has Prowlarr send a release to its download client
+func (c *ProwlarrClient) Grab(ctx context.Context, release ProwlarrRelease) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"guid":      release.GUID,
+		"indexerId": release.IndexerID,
+	})
+	if err != nil {
+		return err
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/search", body, nil); err != nil {
+		return fmt.Errorf("prowlarr grab failed: %w", err)
+	}
+	return nil
+}
+
+func (c *ProwlarrClient) do(ctx context.Context, method, endpoint string, body []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Api-Key", c.apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// usenetQuery is the search term for the torrent's title: name and year for
+// movies, name and episode or season for TV
+func usenetQuery(p *AddPipeline) string {
+	query := p.Extracted.ExtractedName
+	if p.IsMovie {
+		if p.Extracted.Year != "" {
+			query += " " + p.Extracted.Year
+		}
+		return query
+	}
+	info := parseEpisodeInfo(p.TorrentName, p.Anime)
+	switch {
+	case info == nil:
+	case info.Season != nil && len(info.Episodes) > 0:
+		query += fmt.Sprintf(" S%02dE%02d", *info.Season, info.Episodes[0])
+	case info.Season != nil:
+		query += fmt.Sprintf(" S%02d", *info.Season)
+	case info.AbsoluteEpisode > 0:
+		query += fmt.Sprintf(" %02d", info.AbsoluteEpisode)
+	}
+	return query
+}
+
+// pickUsenetRelease returns the NZB whose title starts with the query, preferring
+// the torrent's resolution, then the most grabbed
+func pickUsenetRelease(releases []ProwlarrRelease, query, torrentName string) *ProwlarrRelease {
+	wanted := normalizeTitle(query)
+	resolution := qualityResolutions[ExtractMovieInfo(torrentName).Quality]
+
+	var candidates []ProwlarrRelease
+	for _, release := range releases {
+		if release.Protocol != "usenet" {
+			continue
+		}
+		if !strings.HasPrefix(normalizeTitle(release.Title)+" ", wanted+" ") {
+			continue
+		}
+		candidates = append(candidates, release)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		iSame := qualityResolutions[ExtractMovieInfo(candidates[i].Title).Quality] == resolution
+		jSame := qualityResolutions[ExtractMovieInfo(candidates[j].Title).Quality] == resolution
+		if iSame != jSame {
+			return iSame
+		}
+		return candidates[i].Grabs > candidates[j].Grabs
+	})
+	return &candidates[0]
+}
+
+// wantsUsenetFallback reports whether the health check found a dead torrent
+// that NZB_FALLBACK should replace
+func (h *TorrentHandler) wantsUsenetFallback(p *AddPipeline) bool {
+	config := h.cfg()
+	return config.NZBFallback && h.prowlarrClient != nil && p.NonMedia == "" &&
+		p.Health != nil && p.Health.Seeders < config.HealthMinSeeders
+}
+
+// stepNZBFallback searches Prowlarr for the torrent's title and grabs the best
+// NZB; the add then skips qBittorrent and goes on to the library
+func (h *TorrentHandler) stepNZBFallback(p *AddPipeline) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if p.Extracted == nil || p.Extracted.ExtractedName == "" {
+			return errors.New("no media name to search for")
+		}
+
+		query := usenetQuery(p)
+		category := prowlarrCategoryTV
+		if p.IsMovie {
+			category = prowlarrCategoryMovies
+		}
+		releases, err := h.prowlarrClient.Search(ctx, query, category)
+		if err != nil {
+			return err
+		}
+		release := pickUsenetRelease(releases, query, p.TorrentName)
+		if release == nil {
+			return fmt.Errorf("no NZB found for %q", query)
+		}
+		if err := h.prowlarrClient.Grab(ctx, *release); err != nil {
+			return err
+		}
+
+		p.Usenet = &UsenetGrab{Title: release.Title, Indexer: release.Indexer, Size: release.Size}
+		log.Printf("Torrent %s is dead, grabbed NZB %s from %s", p.TorrentName, release.Title, release.Indexer)
+		return nil
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// proxyRule allows one method on a path and everything below it
+type proxyRule struct {
+	Method string
+	Prefix string
+}
+
+// Sub-paths the extension may reach through /api/proxy/<service>/. Anything that
+// changes configuration, deletes media or exposes credentials stays blocked.
+var proxyRules = map[string][]proxyRule{
+	"radarr": {
+		{http.MethodGet, "/api/v3/movie"},
+		{http.MethodGet, "/api/v3/calendar"},
+		{http.MethodGet, "/api/v3/queue"},
+		{http.MethodGet, "/api/v3/history"},
+		{http.MethodGet, "/api/v3/wanted"},
+		{http.MethodGet, "/api/v3/release"},
+		{http.MethodGet, "/api/v3/qualityprofile"},
+		{http.MethodGet, "/api/v3/tag"},
+		{http.MethodGet, "/api/v3/system/status"},
+		{http.MethodPost, "/api/v3/command"},
+		{http.MethodPost, "/api/v3/release"},
+	},
+	"sonarr": {
+		{http.MethodGet, "/api/v3/series"},
+		{http.MethodGet, "/api/v3/episode"},
+		{http.MethodGet, "/api/v3/calendar"},
+		{http.MethodGet, "/api/v3/queue"},
+		{http.MethodGet, "/api/v3/history"},
+		{http.MethodGet, "/api/v3/wanted"},
+		{http.MethodGet, "/api/v3/release"},
+		{http.MethodGet, "/api/v3/qualityprofile"},
+		{http.MethodGet, "/api/v3/tag"},
+		{http.MethodGet, "/api/v3/system/status"},
+		{http.MethodPost, "/api/v3/command"},
+		{http.MethodPost, "/api/v3/release"},
+	},
+	"qbittorrent": {
+		{http.MethodGet, "/api/v2/app/version"},
+		{http.MethodGet, "/api/v2/transfer/info"},
+		{http.MethodGet, "/api/v2/sync/maindata"},
+		{http.MethodGet, "/api/v2/torrents/info"},
+		{http.MethodGet, "/api/v2/torrents/properties"},
+		{http.MethodGet, "/api/v2/torrents/files"},
+		{http.MethodGet, "/api/v2/torrents/trackers"},
+		{http.MethodGet, "/api/v2/torrents/categories"},
+		{http.MethodPost, "/api/v2/torrents/pause"},
+		{http.MethodPost, "/api/v2/torrents/resume"},
+		{http.MethodPost, "/api/v2/torrents/stop"},
+		{http.MethodPost, "/api/v2/torrents/start"},
+		{http.MethodPost, "/api/v2/torrents/recheck"},
+		{http.MethodPost, "/api/v2/torrents/reannounce"},
+		{http.MethodPost, "/api/v2/torrents/setCategory"},
+		{http.MethodPost, "/api/v2/torrents/topPrio"},
+		{http.MethodPost, "/api/v2/torrents/bottomPrio"},
+	},
+}
+
+// The Radarr/Sonarr commands POST /api/v3/command may run: searches and
+// refreshes, not updates, backups, renames or blocklist clearing
+var proxyCommands = map[string]map[string]bool{
+	"radarr": {"MoviesSearch": true, "RefreshMovie": true},
+	"sonarr": {"SeriesSearch": true, "SeasonSearch": true, "EpisodeSearch": true, "RefreshSeries": true},
+}
+
+// proxyCommandAllowed reports whether body names a command in proxyCommands
+func proxyCommandAllowed(service string, body []byte) (string, bool) {
+	var command struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(body, &command); err != nil {
+		return "", false
+	}
+	return command.Name, proxyCommands[service][command.Name]
+}
+
+// maxProxyBody bounds request bodies forwarded through the proxy
+const maxProxyBody = 1 << 20
+
+// proxyAllowed reports whether method and sub-path match a rule for service
+func proxyAllowed(service, method, subPath string) bool {
+	for _, rule := range proxyRules[service] {
+		if rule.Method == method && (subPath == rule.Prefix || strings.HasPrefix(subPath, rule.Prefix+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Proxy forwards whitelisted requests under /api/proxy/{radarr,sonarr,qbittorrent}/
+// with the service's credentials injected, so the extension never holds them
+func (h *TorrentHandler) Proxy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Full credentials behind the proxy need an authenticated, unrestricted key
+	key := apiKeyFromContext(r.Context())
+	if key == nil || key.MaxRating != "" || key.MediaType != "" {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Success: false,
+			Message: "Proxy endpoints require an unrestricted API key (see API_KEYS)",
+		})
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, "/api/proxy/")
+	service, subPath, _ := strings.Cut(rest, "/")
+	subPath = path.Clean("/" + subPath)
+
+	if _, ok := proxyRules[service]; !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Success: false,
+			Message: "Unknown service. Use radarr, sonarr or qbittorrent",
+		})
+		return
+	}
+
+	if !proxyAllowed(service, r.Method, subPath) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Success: false,
+			Message: r.Method + " " + subPath + " is not allowed through the proxy",
+		})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxProxyBody))
+	if err != nil {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Success: false,
+			Message: "Request body too large",
+		})
+		return
+	}
+
+	if r.Method == http.MethodPost && strings.HasPrefix(subPath, "/api/v3/command") {
+		if name, ok := proxyCommandAllowed(service, body); !ok {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(ErrorResponse{
+				Success: false,
+				Message: "Command " + strconv.Quote(name) + " is not allowed through the proxy",
+			})
+			return
+		}
+	}
+
+	// Our own API key must not reach the upstream service
+	query := r.URL.Query()
+	query.Del("apikey")
+
+	out := proxyRequest{
+		Method:      r.Method,
+		Path:        subPath,
+		Query:       query.Encode(),
+		Body:        body,
+		ContentType: r.Header.Get("Content-Type"),
+	}
+
+	var resp *http.Response
+	switch service {
+	case "radarr":
+		resp, err = out.send(r.Context(), h.radarrClient.httpClient, h.radarrClient.baseURL.Get(), h.radarrClient.apiKey.Get())
+	case "sonarr":
+		resp, err = out.send(r.Context(), h.sonarrClient.httpClient, h.sonarrClient.baseURL.Get(), h.sonarrClient.apiKey.Get())
+	case "qbittorrent":
+		resp, err = h.qbClient.Forward(r.Context(), out)
+	}
+	if err != nil {
+		log.Printf("Proxy %s %s %s failed: %v", service, r.Method, subPath, err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Success: false,
+			Message: "Upstream request failed: " + err.Error(),
+		})
+		return
+	}
+	defer resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	} else {
+		w.Header().Del("Content-Type")
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// proxyRequest is a request to forward to an upstream service
+type proxyRequest struct {
+	Method      string
+	Path        string
+	Query       string
+	Body        []byte
+	ContentType string
+}
+
+// send forwards the request to baseURL, adding apiKey as X-Api-Key when set
+func (p proxyRequest) send(ctx context.Context, client *http.Client, baseURL, apiKey string) (*http.Response, error) {
+	target := baseURL + p.Path
+	if p.Query != "" {
+		target += "?" + p.Query
+	}
+
+	req, err := http.NewRequestWithContext(ctx, p.Method, target, bytes.NewReader(p.Body))
+	if err != nil {
+		return nil, err
+	}
+	if p.ContentType != "" {
+		req.Header.Set("Content-Type", p.ContentType)
+	}
+	if apiKey != "" {
+		req.Header.Set("X-Api-Key", apiKey)
+	}
+	return client.Do(req)
+}
+
+// Forward sends a proxied request with the qBittorrent session cookie,
+// logging in again once if the session has expired
+func (c *QBittorrentClient) Forward(ctx context.Context, p proxyRequest) (*http.Response, error) {
+	if !c.loggedIn.Load() {
+		if err := c.Login(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := p.send(ctx, c.httpClient, c.baseURL, "")
+	if err != nil || resp.StatusCode != http.StatusForbidden {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	if err := c.Login(ctx); err != nil {
+		return nil, err
+	}
+	return p.send(ctx, c.httpClient, c.baseURL, "")
+}
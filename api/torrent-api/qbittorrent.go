@@ -1,12 +1,21 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,7 +24,14 @@ type QBittorrentClient struct {
 	username   string
 	password   string
 	httpClient *http.Client
-	loggedIn   bool
+	loggedIn   atomic.Bool // read by every request, set by logins and the keep-alive
+	// Pre-authenticated SID cookie for instances behind reverse-proxy auth;
+	// when set, it replaces the username/password login
+	sid     string
+	sidFile string // re-read on every login so an external process can refresh it
+
+	sessionMu  sync.Mutex
+	loggedInAt time.Time // when the current session was created
 }
 
 func NewQBittorrentClient(baseURL, username, password string) *QBittorrentClient {
@@ -25,22 +41,71 @@ func NewQBittorrentClient(baseURL, username, password string) *QBittorrentClient
 		username: username,
 		password: password,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-			Jar:     jar,
+			Timeout:   30 * time.Second,
+			Transport: newTracingTransport(),
+			Jar:       jar,
 		},
-		loggedIn: false,
 	}
 }
 
+// UseSession makes the client authenticate with a SID cookie, given directly or
+// read from sidFile, instead of logging in with username and password
+func (c *QBittorrentClient) UseSession(sid, sidFile string) {
+	c.sid = sid
+	c.sidFile = sidFile
+	c.loggedIn.Store(false)
+}
+
+// postForm sends a form-encoded POST bound to ctx
+func (c *QBittorrentClient) postForm(ctx context.Context, endpoint string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.httpClient.Do(req)
+}
+
+// postTorrentFile sends the fields and a .torrent file as the multipart POST
+// torrents/add takes file uploads in, bound to ctx
+func (c *QBittorrentClient) postTorrentFile(ctx context.Context, endpoint string, data url.Values, torrentFile []byte) (*http.Response, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for key, values := range data {
+		for _, value := range values {
+			mw.WriteField(key, value)
+		}
+	}
+	part, err := mw.CreateFormFile("torrents", "upload.torrent")
+	if err != nil {
+		return nil, err
+	}
+	part.Write(torrentFile)
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return c.httpClient.Do(req)
+}
+
 // Login authenticates with qBittorrent
-func (c *QBittorrentClient) Login() error {
+func (c *QBittorrentClient) Login(ctx context.Context) error {
+	if c.sid != "" || c.sidFile != "" {
+		return c.loginWithSession(ctx)
+	}
+
 	loginURL := fmt.Sprintf("%s/api/v2/auth/login", c.baseURL)
 
 	data := url.Values{}
 	data.Set("username", c.username)
 	data.Set("password", c.password)
 
-	resp, err := c.httpClient.PostForm(loginURL, data)
+	resp, err := c.postForm(ctx, loginURL, data)
 	if err != nil {
 		return fmt.Errorf("failed to login: %w", err)
 	}
@@ -51,42 +116,178 @@ func (c *QBittorrentClient) Login() error {
 		return fmt.Errorf("login failed: %s", string(body))
 	}
 
-	c.loggedIn = true
+	c.setLoggedIn()
 	return nil
 }
 
-// AddTorrent adds a torrent to qBittorrent with the specified category
-func (c *QBittorrentClient) AddTorrent(magnetLink, category string) error {
-	if !c.loggedIn {
-		if err := c.Login(); err != nil {
-			return err
+// loginWithSession installs the configured SID cookie and checks qBittorrent accepts it
+func (c *QBittorrentClient) loginWithSession(ctx context.Context) error {
+	sid := c.sid
+	if c.sidFile != "" {
+		data, err := os.ReadFile(c.sidFile)
+		if err != nil {
+			return fmt.Errorf("failed to read qBittorrent session file: %w", err)
+		}
+		sid = strings.TrimSpace(string(data))
+		if sid == "" {
+			return fmt.Errorf("qBittorrent session file %s is empty", c.sidFile)
+		}
+		secretRedactor.Add(sid)
+	}
+
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return fmt.Errorf("invalid qBittorrent URL: %w", err)
+	}
+	c.httpClient.Jar.SetCookies(u, []*http.Cookie{{Name: "SID", Value: sid, Path: "/"}})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/v2/app/version", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to login: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("login failed: session cookie rejected (status %d)", resp.StatusCode)
+	}
+
+	c.setLoggedIn()
+	return nil
+}
+
+func (c *QBittorrentClient) setLoggedIn() {
+	c.sessionMu.Lock()
+	c.loggedInAt = time.Now()
+	c.sessionMu.Unlock()
+	c.loggedIn.Store(true)
+}
+
+// Session returns when the current session was created, nil before the first login
+func (c *QBittorrentClient) Session() *SessionStatus {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
+	if c.loggedInAt.IsZero() {
+		return nil
+	}
+	loggedInAt := c.loggedInAt
+	return &SessionStatus{LoggedInAt: &loggedInAt, AgeSeconds: int(time.Since(loggedInAt).Seconds())}
+}
+
+// KeepAlive logs in when there is no session yet and otherwise pings qBittorrent,
+// which resets the session's idle timeout. An expired session is replaced, so
+// adds don't pay for the login round trip.
+func (c *QBittorrentClient) KeepAlive(ctx context.Context) error {
+	if !c.loggedIn.Load() {
+		return c.Login(ctx)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/v2/app/version", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach qBittorrent: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusForbidden:
+		log.Printf("qBittorrent session expired, logging in again")
+		c.loggedIn.Store(false)
+		return c.Login(ctx)
+	}
+	return fmt.Errorf("keep-alive failed with status %d", resp.StatusCode)
+}
+
+// AddTorrent adds a torrent to qBittorrent with the specified category and, if not
+// nil, share limits. A non-nil torrentFile is uploaded instead of the magnet link.
+// It reports false when qBittorrent refused the torrent as one it already has
+// ("Fails.", or 409 from newer versions).
+func (c *QBittorrentClient) AddTorrent(ctx context.Context, magnetLink string, torrentFile []byte, category string, seeding *SeedingPolicy) (bool, error) {
+	if !c.loggedIn.Load() {
+		if err := c.Login(ctx); err != nil {
+			return false, err
 		}
 	}
 
 	addURL := fmt.Sprintf("%s/api/v2/torrents/add", c.baseURL)
 
 	data := url.Values{}
-	data.Set("urls", magnetLink)
 	data.Set("category", category)
+	if seeding != nil {
+		if seeding.RatioLimit != nil {
+			data.Set("ratioLimit", strconv.FormatFloat(*seeding.RatioLimit, 'f', -1, 64))
+		}
+		if seeding.SeedingTimeLimit != nil {
+			data.Set("seedingTimeLimit", strconv.Itoa(*seeding.SeedingTimeLimit))
+		}
+	}
 
-	resp, err := c.httpClient.PostForm(addURL, data)
+	var resp *http.Response
+	var err error
+	if torrentFile != nil {
+		resp, err = c.postTorrentFile(ctx, addURL, data, torrentFile)
+	} else {
+		data.Set("urls", magnetLink)
+		resp, err = c.postForm(ctx, addURL, data)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to add torrent: %w", err)
+		return false, fmt.Errorf("failed to add torrent: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		return false, nil
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("failed to add torrent: status %d, body: %s", resp.StatusCode, string(body))
+	case strings.TrimSpace(string(body)) == "Fails.":
+		return false, nil
+	}
+	return true, nil
+}
+
+// DeleteTorrent removes a torrent by info hash, optionally with its downloaded files
+func (c *QBittorrentClient) DeleteTorrent(ctx context.Context, hash string, deleteFiles bool) error {
+	if !c.loggedIn.Load() {
+		if err := c.Login(ctx); err != nil {
+			return err
+		}
+	}
+
+	deleteURL := fmt.Sprintf("%s/api/v2/torrents/delete", c.baseURL)
+
+	data := url.Values{}
+	data.Set("hashes", hash)
+	data.Set("deleteFiles", fmt.Sprint(deleteFiles))
+
+	resp, err := c.postForm(ctx, deleteURL, data)
+	if err != nil {
+		return fmt.Errorf("failed to delete torrent: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to add torrent: status %d, body: %s", resp.StatusCode, string(body))
+		return fmt.Errorf("failed to delete torrent: status %d, body: %s", resp.StatusCode, string(body))
 	}
 
 	return nil
 }
 
 // EnsureCategory creates a category if it doesn't exist
-func (c *QBittorrentClient) EnsureCategory(category string) error {
-	if !c.loggedIn {
-		if err := c.Login(); err != nil {
+func (c *QBittorrentClient) EnsureCategory(ctx context.Context, category string) error {
+	if !c.loggedIn.Load() {
+		if err := c.Login(ctx); err != nil {
 			return err
 		}
 	}
@@ -97,7 +298,335 @@ func (c *QBittorrentClient) EnsureCategory(category string) error {
 	data.Set("category", category)
 
 	// We don't care if this fails (category might already exist)
-	c.httpClient.PostForm(createURL, data)
+	if resp, err := c.postForm(ctx, createURL, data); err == nil {
+		resp.Body.Close()
+	}
+
+	return nil
+}
 
+// SetShareLimits sets the share limits of torrents; a nil policy or unset field
+// goes back to qBittorrent's global limit
+func (c *QBittorrentClient) SetShareLimits(ctx context.Context, hashes []string, seeding *SeedingPolicy) error {
+	if !c.loggedIn.Load() {
+		if err := c.Login(ctx); err != nil {
+			return err
+		}
+	}
+
+	// -2 is "use the global limit"
+	ratioLimit, seedingTimeLimit := "-2", "-2"
+	if seeding != nil && seeding.RatioLimit != nil {
+		ratioLimit = strconv.FormatFloat(*seeding.RatioLimit, 'f', -1, 64)
+	}
+	if seeding != nil && seeding.SeedingTimeLimit != nil {
+		seedingTimeLimit = strconv.Itoa(*seeding.SeedingTimeLimit)
+	}
+
+	data := url.Values{}
+	data.Set("hashes", strings.Join(hashes, "|"))
+	data.Set("ratioLimit", ratioLimit)
+	data.Set("seedingTimeLimit", seedingTimeLimit)
+	data.Set("inactiveSeedingTimeLimit", "-2")
+
+	resp, err := c.postForm(ctx, fmt.Sprintf("%s/api/v2/torrents/setShareLimits", c.baseURL), data)
+	if err != nil {
+		return fmt.Errorf("failed to set share limits: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to set share limits: status %d, body: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// QBTransferInfo is qBittorrent's global transfer state (/api/v2/transfer/info)
+type QBTransferInfo struct {
+	DownloadSpeed     int64  `json:"dl_info_speed"`
+	UploadSpeed       int64  `json:"up_info_speed"`
+	SessionDownloaded int64  `json:"dl_info_data"`
+	SessionUploaded   int64  `json:"up_info_data"`
+	DHTNodes          int    `json:"dht_nodes"`
+	ConnectionStatus  string `json:"connection_status"`
+}
+
+// QBTorrentState is the per-torrent subset of the sync API we use
+type QBTorrentState struct {
+	State         string  `json:"state"`
+	DownloadSpeed int64   `json:"dlspeed"`
+	UploadSpeed   int64   `json:"upspeed"`
+	Progress      float64 `json:"progress"`
+}
+
+// QBMainData is a full (rid=0) snapshot from /api/v2/sync/maindata
+type QBMainData struct {
+	ServerState struct {
+		AlltimeDownloaded int64  `json:"alltime_dl"`
+		AlltimeUploaded   int64  `json:"alltime_ul"`
+		GlobalRatio       string `json:"global_ratio"`
+		FreeSpaceOnDisk   int64  `json:"free_space_on_disk"`
+	} `json:"server_state"`
+	Torrents map[string]QBTorrentState `json:"torrents"`
+}
+
+// get sends an authenticated GET; the caller closes the body of the 200 response
+func (c *QBittorrentClient) get(ctx context.Context, path string) (*http.Response, error) {
+	if !c.loggedIn.Load() {
+		if err := c.Login(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query qBittorrent: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("qBittorrent returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return resp, nil
+}
+
+// getJSON sends an authenticated GET and decodes the JSON response
+func (c *QBittorrentClient) getJSON(ctx context.Context, path string, out interface{}) error {
+	resp, err := c.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode qBittorrent response: %w", err)
+	}
+	return nil
+}
+
+// getText sends an authenticated GET and returns the plain text response
+func (c *QBittorrentClient) getText(ctx context.Context, path string) (string, error) {
+	resp, err := c.get(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read qBittorrent response: %w", err)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// GetVersion returns the qBittorrent version, e.g. "v4.6.0", and its WebUI API
+// version, e.g. "2.9.3"
+func (c *QBittorrentClient) GetVersion(ctx context.Context) (AppVersion, error) {
+	app, err := c.getText(ctx, "/api/v2/app/version")
+	if err != nil {
+		return AppVersion{}, err
+	}
+	api, err := c.getText(ctx, "/api/v2/app/webapiVersion")
+	if err != nil {
+		return AppVersion{}, err
+	}
+	return AppVersion{Version: app, APIVersion: api}, nil
+}
+
+// GetTransferInfo returns global speeds, session totals and DHT nodes
+func (c *QBittorrentClient) GetTransferInfo(ctx context.Context) (*QBTransferInfo, error) {
+	var info QBTransferInfo
+	if err := c.getJSON(ctx, "/api/v2/transfer/info", &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetMainData returns a full sync snapshot of the server state and torrents
+func (c *QBittorrentClient) GetMainData(ctx context.Context) (*QBMainData, error) {
+	var data QBMainData
+	if err := c.getJSON(ctx, "/api/v2/sync/maindata?rid=0", &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// GetDefaultSavePath returns the folder new torrents are saved to, as qBittorrent sees it
+func (c *QBittorrentClient) GetDefaultSavePath(ctx context.Context) (string, error) {
+	var prefs struct {
+		SavePath string `json:"save_path"`
+	}
+	if err := c.getJSON(ctx, "/api/v2/app/preferences", &prefs); err != nil {
+		return "", err
+	}
+	return prefs.SavePath, nil
+}
+
+// QBFile is one file of a torrent (/api/v2/torrents/files)
+type QBFile struct {
+	Index    int    `json:"index"`
+	Name     string `json:"name"` // path inside the torrent
+	Size     int64  `json:"size"`
+	Priority int    `json:"priority"` // 0 is "do not download"
+}
+
+// GetFiles lists a torrent's files; it is empty until a magnet's metadata has arrived
+func (c *QBittorrentClient) GetFiles(ctx context.Context, hash string) ([]QBFile, error) {
+	var files []QBFile
+	if err := c.getJSON(ctx, "/api/v2/torrents/files?hash="+url.QueryEscape(hash), &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// SetFilePriority sets the download priority of files by index
+func (c *QBittorrentClient) SetFilePriority(ctx context.Context, hash string, indexes []int, priority int) error {
+	if !c.loggedIn.Load() {
+		if err := c.Login(ctx); err != nil {
+			return err
+		}
+	}
+
+	ids := make([]string, len(indexes))
+	for i, index := range indexes {
+		ids[i] = strconv.Itoa(index)
+	}
+	data := url.Values{}
+	data.Set("hash", hash)
+	data.Set("id", strings.Join(ids, "|"))
+	data.Set("priority", strconv.Itoa(priority))
+
+	resp, err := c.postForm(ctx, c.baseURL+"/api/v2/torrents/filePrio", data)
+	if err != nil {
+		return fmt.Errorf("failed to set file priority: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to set file priority: status %d, body: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// QBTorrentInfo is the per-torrent subset of /api/v2/torrents/info we use
+type QBTorrentInfo struct {
+	Hash        string  `json:"hash"`
+	Name        string  `json:"name"`
+	Category    string  `json:"category"`
+	State       string  `json:"state"`
+	Progress    float64 `json:"progress"`     // 0 to 1
+	ContentPath string  `json:"content_path"` // the torrent's root folder, or its file
+	ETA         int64   `json:"eta"`          // seconds; qbETAInfinity when unknown
+	Size        int64   `json:"size"`
+	DlSpeed     int64   `json:"dlspeed"` // bytes/s
+	AddedOn     int64   `json:"added_on"`
+}
+
+// qbETAInfinity is the ETA qBittorrent reports for torrents that won't finish
+const qbETAInfinity = 8640000
+
+// ListTorrents lists torrents matching a qBittorrent state filter (e.g.
+// "downloading") and, if set, a category, newest first
+func (c *QBittorrentClient) ListTorrents(ctx context.Context, filter, category string) ([]QBTorrentInfo, error) {
+	query := url.Values{"filter": {filter}, "sort": {"added_on"}, "reverse": {"true"}}
+	if category != "" {
+		query.Set("category", category)
+	}
+	var torrents []QBTorrentInfo
+	if err := c.getJSON(ctx, "/api/v2/torrents/info?"+query.Encode(), &torrents); err != nil {
+		return nil, err
+	}
+	return torrents, nil
+}
+
+// GetCategoryTorrents lists the torrents in a category
+func (c *QBittorrentClient) GetCategoryTorrents(ctx context.Context, category string) ([]QBTorrentInfo, error) {
+	var torrents []QBTorrentInfo
+	if err := c.getJSON(ctx, "/api/v2/torrents/info?category="+url.QueryEscape(category), &torrents); err != nil {
+		return nil, err
+	}
+	return torrents, nil
+}
+
+// GetTorrent returns a torrent by info hash, or nil if qBittorrent doesn't have it
+func (c *QBittorrentClient) GetTorrent(ctx context.Context, hash string) (*QBTorrentInfo, error) {
+	var torrents []QBTorrentInfo
+	if err := c.getJSON(ctx, "/api/v2/torrents/info?hashes="+url.QueryEscape(hash), &torrents); err != nil {
+		return nil, err
+	}
+	if len(torrents) == 0 {
+		return nil, nil
+	}
+	return &torrents[0], nil
+}
+
+// QBTracker is one tracker of a torrent (/api/v2/torrents/trackers). qBittorrent
+// also lists DHT, PeX and LSD as pseudo-trackers named like "** [DHT] **".
+type QBTracker struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status"` // 0 disabled, 1 not contacted, 2 working, 3 updating, 4 not working
+	Tier     int    `json:"tier"`
+	Seeds    int    `json:"num_seeds"`
+	Leechers int    `json:"num_leeches"`
+	Message  string `json:"msg"`
+}
+
+// GetTrackers lists a torrent's trackers
+func (c *QBittorrentClient) GetTrackers(ctx context.Context, hash string) ([]QBTracker, error) {
+	var trackers []QBTracker
+	if err := c.getJSON(ctx, "/api/v2/torrents/trackers?hash="+url.QueryEscape(hash), &trackers); err != nil {
+		return nil, err
+	}
+	return trackers, nil
+}
+
+// Reannounce makes qBittorrent announce a torrent to all its trackers now
+func (c *QBittorrentClient) Reannounce(ctx context.Context, hash string) error {
+	data := url.Values{}
+	data.Set("hashes", hash)
+	return c.postAction(ctx, "/api/v2/torrents/reannounce", data, "reannounce")
+}
+
+// AddTrackers adds tracker URLs to a torrent
+func (c *QBittorrentClient) AddTrackers(ctx context.Context, hash string, urls []string) error {
+	data := url.Values{}
+	data.Set("hash", hash)
+	data.Set("urls", strings.Join(urls, "\n"))
+	return c.postAction(ctx, "/api/v2/torrents/addTrackers", data, "add trackers")
+}
+
+// RemoveTrackers removes tracker URLs from a torrent
+func (c *QBittorrentClient) RemoveTrackers(ctx context.Context, hash string, urls []string) error {
+	data := url.Values{}
+	data.Set("hash", hash)
+	data.Set("urls", strings.Join(urls, "|"))
+	return c.postAction(ctx, "/api/v2/torrents/removeTrackers", data, "remove trackers")
+}
+
+// postAction posts a form to a torrent action endpoint that answers 200 with no body
+func (c *QBittorrentClient) postAction(ctx context.Context, path string, data url.Values, action string) error {
+	if !c.loggedIn.Load() {
+		if err := c.Login(ctx); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.postForm(ctx, c.baseURL+path, data)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to %s: status %d, body: %s", action, resp.StatusCode, string(body))
+	}
 	return nil
 }
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// QueueItem is a download with its qBittorrent progress and, once Radarr or
+// Sonarr track it, their import status
+type QueueItem struct {
+	Hash          string   `json:"hash,omitempty"` // missing for Usenet downloads
+	Name          string   `json:"name"`
+	Type          string   `json:"type,omitempty"` // "movie" or "tv"
+	Title         string   `json:"title,omitempty"`
+	Year          int      `json:"year,omitempty"`
+	MediaID       int      `json:"media_id,omitempty"` // Radarr movie / Sonarr series ID
+	Episodes      []string `json:"episodes,omitempty"` // e.g. "S02E05"
+	State         string   `json:"state,omitempty"`    // qBittorrent's state, e.g. "downloading" or "stalledDL"
+	Progress      float64  `json:"progress"`           // 0 to 1
+	ETA           *int64   `json:"eta,omitempty"`      // seconds; missing when qBittorrent can't tell
+	Size          int64    `json:"size"`
+	DownloadSpeed int64    `json:"download_speed"`          // bytes/s
+	ImportStatus  string   `json:"import_status,omitempty"` // Radarr/Sonarr's state, e.g. "downloading", "importPending" or "failedPending"; missing when neither tracks it
+	Messages      []string `json:"messages,omitempty"`      // Radarr/Sonarr warnings, e.g. why an import is stuck
+}
+
+type QueueResponse struct {
+	Success  bool        `json:"success"`
+	Message  string      `json:"message"`
+	Items    []QueueItem `json:"items,omitempty"`
+	Warnings []string    `json:"warnings,omitempty"`
+}
+
+// Queue merges qBittorrent's transfer list with the Radarr and Sonarr queues,
+// so the extension shows one list of downloads with where each one stands
+func (h *TorrentHandler) Queue(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(QueueResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+
+	items, warnings, err := h.queue(r.Context())
+	if err != nil {
+		log.Printf("Error fetching queue: %v", err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(QueueResponse{
+			Success: false,
+			Message: "Failed to fetch queue: " + err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(QueueResponse{
+		Success:  true,
+		Message:  "OK",
+		Items:    items,
+		Warnings: warnings,
+	})
+}
+
+// queue returns the unfinished torrents and everything Radarr or Sonarr still
+// has queued, matched by info hash. Finished torrents the apps no longer track
+// are left out. One source failing only adds a warning; all failing is an error.
+func (h *TorrentHandler) queue(ctx context.Context) ([]QueueItem, []string, error) {
+	var warnings []string
+	torrents, qbErr := h.qbClient.ListTorrents(ctx, "all", "")
+	if qbErr != nil {
+		warnings = append(warnings, "qBittorrent unavailable: "+qbErr.Error())
+	}
+	movies, movieErr := h.radarrClient.GetQueue(ctx)
+	if movieErr != nil {
+		warnings = append(warnings, "Radarr queue unavailable: "+movieErr.Error())
+	}
+	episodes, episodeErr := h.sonarrClient.GetQueue(ctx)
+	if episodeErr != nil {
+		warnings = append(warnings, "Sonarr queue unavailable: "+episodeErr.Error())
+	}
+	if qbErr != nil && movieErr != nil && episodeErr != nil {
+		return nil, nil, fmt.Errorf("qbittorrent: %v; radarr: %v; sonarr: %v", qbErr, movieErr, episodeErr)
+	}
+
+	// Sonarr's per-episode records of a download become one item
+	tracked := make(map[string]*QueueItem)
+	var order []string
+	for _, records := range []struct {
+		kind    string
+		records []ArrQueueRecord
+	}{{"movie", movies}, {"tv", episodes}} {
+		for _, record := range records.records {
+			key := strings.ToLower(record.DownloadID)
+			if key == "" {
+				key = fmt.Sprintf("%s-%d", records.kind, record.ID)
+			}
+			item := tracked[key]
+			if item == nil {
+				item = newQueueItem(records.kind, record)
+				tracked[key] = item
+				order = append(order, key)
+			}
+			if record.Episode != nil {
+				item.Episodes = append(item.Episodes, fmt.Sprintf("S%02dE%02d", record.Episode.SeasonNumber, record.Episode.EpisodeNumber))
+			}
+			item.Messages = appendQueueMessages(item.Messages, record)
+		}
+	}
+
+	added := h.addedTorrents()
+	items := []QueueItem{}
+	for _, t := range torrents {
+		item, ok := tracked[t.Hash]
+		if !ok && t.Progress >= 1 {
+			continue
+		}
+		if !ok {
+			item = &QueueItem{}
+			if record := added[t.Hash]; record != nil {
+				item.Type, item.Title, item.MediaID = record.MediaType, record.MediaTitle, record.MediaID
+			}
+		}
+		delete(tracked, t.Hash)
+		item.Hash, item.Name, item.State = t.Hash, t.Name, t.State
+		item.Progress, item.Size, item.DownloadSpeed = t.Progress, t.Size, t.DlSpeed
+		item.ETA = nil
+		if t.ETA >= 0 && t.ETA < qbETAInfinity {
+			eta := t.ETA
+			item.ETA = &eta
+		}
+		items = append(items, *item)
+	}
+	// Usenet downloads and torrents in another client
+	for _, key := range order {
+		if item, ok := tracked[key]; ok {
+			items = append(items, *item)
+		}
+	}
+	for i := range items {
+		sort.Strings(items[i].Episodes)
+	}
+	return items, warnings, nil
+}
+
+// newQueueItem describes a download from the first queue record naming it
+func newQueueItem(kind string, record ArrQueueRecord) *QueueItem {
+	item := &QueueItem{
+		Name:         record.Title,
+		Type:         kind,
+		Size:         int64(record.Size),
+		ImportStatus: record.TrackedDownloadState,
+	}
+	if record.Protocol == "torrent" {
+		item.Hash = strings.ToLower(record.DownloadID)
+	}
+	if record.Size > 0 {
+		item.Progress = 1 - record.SizeLeft/record.Size
+	}
+	switch {
+	case record.Movie != nil:
+		item.Title, item.Year, item.MediaID = record.Movie.Title, record.Movie.Year, record.MovieID
+	case record.Series != nil:
+		item.Title, item.Year, item.MediaID = record.Series.Title, record.Series.Year, record.SeriesID
+	}
+	return item
+}
+
+// appendQueueMessages adds a record's error and status messages not yet in messages
+func appendQueueMessages(messages []string, record ArrQueueRecord) []string {
+	candidates := []string{record.ErrorMessage}
+	for _, status := range record.StatusMessages {
+		candidates = append(candidates, status.Messages...)
+	}
+next:
+	for _, message := range candidates {
+		if message == "" {
+			continue
+		}
+		for _, m := range messages {
+			if m == message {
+				continue next
+			}
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// addedTorrents maps hex info hashes to their latest successful add in history
+func (h *TorrentHandler) addedTorrents() map[string]*HistoryRecord {
+	added := make(map[string]*HistoryRecord)
+	if h.history == nil {
+		return added
+	}
+	records := h.history.List()
+	for i := range records {
+		if records[i].Status == HistoryStatusFailed || records[i].InfoHash == "" {
+			continue
+		}
+		// Base32 magnets are recorded as given
+		if b, err := decodeInfoHash(records[i].InfoHash); err == nil {
+			added[hex.EncodeToString(b)] = &records[i]
+		}
+	}
+	return added
+}
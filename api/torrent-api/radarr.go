@@ -1,21 +1,24 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type RadarrClient struct {
-	baseURL    string
-	apiKey     string
+	baseURL    *arrBaseURL
+	apiKey     *arrAPIKey
 	httpClient *http.Client
+	defaults   ArrDefaults
 }
 
 type RadarrMovie struct {
@@ -36,72 +39,127 @@ type RadarrAddOptions struct {
 }
 
 type RadarrSearchResult struct {
-	Title     string `json:"title"`
-	TitleSlug string `json:"titleSlug"`
-	Year      int    `json:"year"`
-	TMDBID    int    `json:"tmdbId"`
+	Title         string `json:"title"`
+	TitleSlug     string `json:"titleSlug"`
+	Year          int    `json:"year"`
+	TMDBID        int    `json:"tmdbId"`
+	Certification string `json:"certification"`
+	Overview      string `json:"overview"`
+	RemotePoster  string `json:"remotePoster"`
+
+	Status          string     `json:"status"` // "tba", "announced", "inCinemas" or "released"
+	InCinemas       *time.Time `json:"inCinemas,omitempty"`
+	DigitalRelease  *time.Time `json:"digitalRelease,omitempty"`
+	PhysicalRelease *time.Time `json:"physicalRelease,omitempty"`
+
+	Correction *LookupCorrection `json:"-"` // set when a transformed term matched
 }
 
 type RadarrRootFolder struct {
-	ID   int    `json:"id"`
-	Path string `json:"path"`
+	ID         int    `json:"id"`
+	Path       string `json:"path"`
+	Accessible bool   `json:"accessible"`
+	FreeSpace  *int64 `json:"freeSpace,omitempty"`
+}
+
+// Validate checks the folder is mounted and has room, since Radarr otherwise
+// rejects the add with an opaque error when e.g. an NFS mount is down
+func (f RadarrRootFolder) Validate() error {
+	if !f.Accessible {
+		return newAPIError(ErrCodeRootFolderInaccessible, "Radarr root folder %s is not accessible", f.Path)
+	}
+	if f.FreeSpace != nil && *f.FreeSpace <= 0 {
+		return newAPIError(ErrCodeRootFolderInaccessible, "Radarr root folder %s has no free space", f.Path)
+	}
+	return nil
 }
 
 type RadarrQualityProfile struct {
+	ID          int                     `json:"id"`
+	Name        string                  `json:"name"`
+	Cutoff      int                     `json:"cutoff"`
+	Items       []ArrQualityProfileItem `json:"items"`
+	FormatItems []RadarrFormatItem      `json:"formatItems,omitempty"`
+}
+
+// RadarrFormatItem is a custom format's score in a quality profile
+type RadarrFormatItem struct {
+	Format int    `json:"format"`
+	Name   string `json:"name"`
+	Score  int    `json:"score"`
+}
+
+// RadarrCustomFormat is a Radarr custom format, e.g. "French" or "MULTi"
+type RadarrCustomFormat struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
 }
 
-func NewRadarrClient(baseURL, apiKey string) *RadarrClient {
-	return &RadarrClient{
-		baseURL: strings.TrimSuffix(baseURL, "/"),
-		apiKey:  apiKey,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-	}
+type RadarrMovieFile struct {
+	ID      int        `json:"id"`
+	Size    int64      `json:"size"`
+	Quality ArrQuality `json:"quality"`
 }
 
-func (c *RadarrClient) doRequest(method, endpoint string, body interface{}) ([]byte, error) {
-	var reqBody io.Reader
-	if body != nil {
-		jsonData, err := json.Marshal(body)
-		if err != nil {
-			return nil, err
-		}
-		reqBody = bytes.NewBuffer(jsonData)
-	}
+// RadarrLibraryMovie is a movie already in the Radarr library
+type RadarrLibraryMovie struct {
+	ID        int              `json:"id"`
+	Title     string           `json:"title"`
+	Year      int              `json:"year"`
+	TMDBID    int              `json:"tmdbId"`
+	Monitored bool             `json:"monitored"`
+	HasFile   bool             `json:"hasFile"`
+	MovieFile *RadarrMovieFile `json:"movieFile,omitempty"`
+
+	IsAvailable bool   `json:"isAvailable"` // released per the minimum availability
+	Path        string `json:"path,omitempty"`
+	SizeOnDisk  int64  `json:"sizeOnDisk"`
+}
 
-	req, err := http.NewRequest(method, fmt.Sprintf("%s%s", c.baseURL, endpoint), reqBody)
-	if err != nil {
-		return nil, err
-	}
+// RadarrCalendarMovie is a movie with a release date in the calendar range
+type RadarrCalendarMovie struct {
+	RadarrLibraryMovie
+	InCinemas       *time.Time `json:"inCinemas,omitempty"`
+	PhysicalRelease *time.Time `json:"physicalRelease,omitempty"`
+	DigitalRelease  *time.Time `json:"digitalRelease,omitempty"`
+}
 
-	req.Header.Set("X-Api-Key", c.apiKey)
-	req.Header.Set("Content-Type", "application/json")
+type radarrMoviePage struct {
+	ArrPage
+	Records []RadarrLibraryMovie `json:"records"`
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
+func NewRadarrClient(baseURL, apiKey string) *RadarrClient {
+	return &RadarrClient{
+		baseURL: newArrBaseURL(baseURL),
+		apiKey:  newArrAPIKey(apiKey),
+		httpClient: &http.Client{
+			Timeout:       30 * time.Second,
+			Transport:     newTracingTransport(),
+			CheckRedirect: arrCheckRedirect,
+		},
 	}
-	defer resp.Body.Close()
+}
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+func (c *RadarrClient) doRequest(ctx context.Context, method, endpoint string, body interface{}) ([]byte, error) {
+	return doArrRequest(ctx, "Radarr", c.httpClient, c.baseURL, c.apiKey.Get(), method, endpoint, body)
+}
 
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("API error: status %d, body: %s", resp.StatusCode, string(respBody))
-	}
+// TestAPIKey checks that Radarr accepts key, without using it for anything else
+func (c *RadarrClient) TestAPIKey(ctx context.Context, key string) error {
+	_, err := doArrRequest(ctx, "Radarr", c.httpClient, c.baseURL, key, "GET", "/api/v3/system/status", nil)
+	return err
+}
 
-	return respBody, nil
+// SetAPIKey replaces the API key after it was rotated in Radarr
+func (c *RadarrClient) SetAPIKey(key string) {
+	c.apiKey.set(key)
 }
 
 // SearchMovie searches for a movie by term
-func (c *RadarrClient) SearchMovie(term string) ([]RadarrSearchResult, error) {
+func (c *RadarrClient) SearchMovie(ctx context.Context, term string) ([]RadarrSearchResult, error) {
 	endpoint := fmt.Sprintf("/api/v3/movie/lookup?term=%s", url.QueryEscape(term))
-	respBody, err := c.doRequest("GET", endpoint, nil)
+	respBody, err := c.doRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -114,9 +172,16 @@ func (c *RadarrClient) SearchMovie(term string) ([]RadarrSearchResult, error) {
 	return results, nil
 }
 
+// SetDefaults sets the root folder and quality profile new movies are added with
+func (c *RadarrClient) SetDefaults(defaults ArrDefaults) {
+	c.defaults = defaults
+}
+
 // GetRootFolders gets available root folders
-func (c *RadarrClient) GetRootFolders() ([]RadarrRootFolder, error) {
-	respBody, err := c.doRequest("GET", "/api/v3/rootfolder", nil)
+func (c *RadarrClient) GetRootFolders(ctx context.Context) ([]RadarrRootFolder, error) {
+	respBody, err := arrCache.Fetch(c.baseURL.Get(), arrRootFolderEndpoint, func() ([]byte, error) {
+		return c.doRequest(ctx, "GET", arrRootFolderEndpoint, nil)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -130,8 +195,10 @@ func (c *RadarrClient) GetRootFolders() ([]RadarrRootFolder, error) {
 }
 
 // GetQualityProfiles gets available quality profiles
-func (c *RadarrClient) GetQualityProfiles() ([]RadarrQualityProfile, error) {
-	respBody, err := c.doRequest("GET", "/api/v3/qualityprofile", nil)
+func (c *RadarrClient) GetQualityProfiles(ctx context.Context) ([]RadarrQualityProfile, error) {
+	respBody, err := arrCache.Fetch(c.baseURL.Get(), arrQualityProfileEndpoint, func() ([]byte, error) {
+		return c.doRequest(ctx, "GET", arrQualityProfileEndpoint, nil)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -145,8 +212,8 @@ func (c *RadarrClient) GetQualityProfiles() ([]RadarrQualityProfile, error) {
 }
 
 // AddMovie adds a movie to Radarr
-func (c *RadarrClient) AddMovie(movie RadarrMovie) (*RadarrMovie, error) {
-	respBody, err := c.doRequest("POST", "/api/v3/movie", movie)
+func (c *RadarrClient) AddMovie(ctx context.Context, movie RadarrMovie) (*RadarrMovie, error) {
+	respBody, err := c.doRequest(ctx, "POST", "/api/v3/movie", movie)
 	if err != nil {
 		return nil, err
 	}
@@ -159,67 +226,270 @@ func (c *RadarrClient) AddMovie(movie RadarrMovie) (*RadarrMovie, error) {
 	return &result, nil
 }
 
-// AddMovieFromMagnet extracts movie info from magnet and adds to Radarr
-func (c *RadarrClient) AddMovieFromMagnet(magnetLink string, extractedMedia *ExtractedMedia) (*RadarrMovie, error) {
-	// Use extracted name from the extractor API
-	searchTerm := extractedMedia.ExtractedName
-	if extractedMedia.Year != "" {
-		searchTerm = searchTerm + " " + extractedMedia.Year
+// GetCutoffUnmet returns monitored movies whose file is below the quality profile cutoff
+func (c *RadarrClient) GetCutoffUnmet(ctx context.Context, pageSize int) ([]RadarrLibraryMovie, int, error) {
+	endpoint := fmt.Sprintf("/api/v3/wanted/cutoff?page=1&pageSize=%d&monitored=true&sortKey=title", pageSize)
+	respBody, err := c.doRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	// Search for the movie
-	results, err := c.SearchMovie(searchTerm)
+	var page radarrMoviePage
+	if err := json.Unmarshal(respBody, &page); err != nil {
+		return nil, 0, err
+	}
+
+	return page.Records, page.TotalRecords, nil
+}
+
+// GetMissing returns monitored movies without a file
+func (c *RadarrClient) GetMissing(ctx context.Context, pageSize int) ([]RadarrLibraryMovie, error) {
+	endpoint := fmt.Sprintf("/api/v3/wanted/missing?page=1&pageSize=%d&monitored=true&sortKey=title", pageSize)
+	respBody, err := c.doRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search movie: %w", err)
+		return nil, err
 	}
 
-	if len(results) == 0 {
-		return nil, fmt.Errorf("movie not found: %s", searchTerm)
+	var page radarrMoviePage
+	if err := json.Unmarshal(respBody, &page); err != nil {
+		return nil, err
 	}
 
-	// Get first result
-	searchResult := results[0]
+	return page.Records, nil
+}
 
-	// Get root folder
-	folders, err := c.GetRootFolders()
+// GetMovies returns every movie in the library
+func (c *RadarrClient) GetMovies(ctx context.Context) ([]RadarrLibraryMovie, error) {
+	respBody, err := c.doRequest(ctx, "GET", "/api/v3/movie", nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get root folders: %w", err)
+		return nil, err
 	}
-	if len(folders) == 0 {
-		return nil, fmt.Errorf("no root folders configured in Radarr")
+
+	var movies []RadarrLibraryMovie
+	if err := json.Unmarshal(respBody, &movies); err != nil {
+		return nil, err
 	}
 
-	// Get quality profile
-	profiles, err := c.GetQualityProfiles()
+	return movies, nil
+}
+
+// FindMoviesByTMDBID returns the library movies with a TMDB ID, usually none or one
+func (c *RadarrClient) FindMoviesByTMDBID(ctx context.Context, tmdbID int) ([]RadarrLibraryMovie, error) {
+	respBody, err := c.doRequest(ctx, "GET", fmt.Sprintf("/api/v3/movie?tmdbId=%d", tmdbID), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get quality profiles: %w", err)
+		return nil, err
 	}
-	if len(profiles) == 0 {
-		return nil, fmt.Errorf("no quality profiles configured in Radarr")
+
+	var movies []RadarrLibraryMovie
+	if err := json.Unmarshal(respBody, &movies); err != nil {
+		return nil, err
 	}
 
-	// Create movie
-	movie := RadarrMovie{
-		Title:               searchResult.Title,
-		TitleSlug:           searchResult.TitleSlug,
-		Year:                searchResult.Year,
-		TMDBID:              searchResult.TMDBID,
-		QualityProfileID:    profiles[0].ID,
-		RootFolderPath:      folders[0].Path,
-		Monitored:           true,
-		MinimumAvailability: "released",
-		AddOptions: &RadarrAddOptions{
-			SearchForMovie: false, // Don't search, we're adding via torrent
-		},
+	return movies, nil
+}
+
+// GetCalendar returns the movies released in cinemas, physically or digitally
+// between start and end
+func (c *RadarrClient) GetCalendar(ctx context.Context, start, end time.Time) ([]RadarrCalendarMovie, error) {
+	endpoint := fmt.Sprintf("/api/v3/calendar?start=%s&end=%s", start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+	respBody, err := c.doRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var movies []RadarrCalendarMovie
+	if err := json.Unmarshal(respBody, &movies); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// EnsureTag returns the ID of the tag with label, creating it if needed
+func (c *RadarrClient) EnsureTag(ctx context.Context, label string) (int, error) {
+	return ensureArrTag(ctx, c.doRequest, label)
+}
+
+// AddMovieTag adds a tag to a movie, keeping its other tags
+func (c *RadarrClient) AddMovieTag(ctx context.Context, movieID, tagID int) error {
+	return c.ApplyMovieTag(ctx, []int{movieID}, tagID, "add")
+}
+
+// ApplyMovieTag adds ("add") or removes ("remove") a tag on movies, keeping their other tags
+func (c *RadarrClient) ApplyMovieTag(ctx context.Context, movieIDs []int, tagID int, apply string) error {
+	_, err := c.doRequest(ctx, "PUT", "/api/v3/movie/editor", map[string]interface{}{
+		"movieIds":  movieIDs,
+		"tags":      []int{tagID},
+		"applyTags": apply,
+	})
+	return err
+}
+
+// UnmonitorMovie stops Radarr from searching for a movie
+func (c *RadarrClient) UnmonitorMovie(ctx context.Context, movieID int) error {
+	_, err := c.doRequest(ctx, "PUT", "/api/v3/movie/editor", map[string]interface{}{
+		"movieIds":  []int{movieID},
+		"monitored": false,
+	})
+	return err
+}
+
+// DeleteMovie removes a movie from Radarr, leaving any files on disk
+func (c *RadarrClient) DeleteMovie(ctx context.Context, movieID int) error {
+	_, err := c.doRequest(ctx, "DELETE", fmt.Sprintf("/api/v3/movie/%d?deleteFiles=false", movieID), nil)
+	return err
+}
+
+// GetQueuedMovieIDs returns the IDs of movies with a download in Radarr's queue
+func (c *RadarrClient) GetQueuedMovieIDs(ctx context.Context) (map[int]bool, error) {
+	respBody, err := c.doRequest(ctx, "GET", "/api/v3/queue?page=1&pageSize=1000", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var page struct {
+		Records []struct {
+			MovieID int `json:"movieId"`
+		} `json:"records"`
+	}
+	if err := json.Unmarshal(respBody, &page); err != nil {
+		return nil, err
+	}
+
+	ids := make(map[int]bool, len(page.Records))
+	for _, record := range page.Records {
+		ids[record.MovieID] = true
+	}
+	return ids, nil
+}
+
+// GetQueue returns Radarr's activity queue with each record's movie
+func (c *RadarrClient) GetQueue(ctx context.Context) ([]ArrQueueRecord, error) {
+	return getArrQueue(ctx, c.doRequest, "Movie")
+}
+
+// SearchReleases asks Radarr's indexers for releases of a library movie
+func (c *RadarrClient) SearchReleases(ctx context.Context, movieID int) ([]ArrRelease, error) {
+	respBody, err := c.doRequest(ctx, "GET", fmt.Sprintf("/api/v3/release?movieId=%d", movieID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []ArrRelease
+	if err := json.Unmarshal(respBody, &releases); err != nil {
+		return nil, err
+	}
+
+	return releases, nil
+}
+
+// GrabRelease sends a release found by SearchReleases to the download client
+func (c *RadarrClient) GrabRelease(ctx context.Context, release *ArrRelease) error {
+	_, err := c.doRequest(ctx, "POST", "/api/v3/release", map[string]interface{}{
+		"guid":      release.GUID,
+		"indexerId": release.IndexerID,
+	})
+	return err
+}
+
+// TriggerSearch starts Radarr's search for a library movie
+func (c *RadarrClient) TriggerSearch(ctx context.Context, movieID int) error {
+	_, err := c.doRequest(ctx, "POST", "/api/v3/command", map[string]interface{}{
+		"name":     "MoviesSearch",
+		"movieIds": []int{movieID},
+	})
+	return err
+}
+
+// MovieExists reports whether a movie with the given ID is still in the library
+func (c *RadarrClient) MovieExists(ctx context.Context, movieID int) (bool, error) {
+	_, err := c.doRequest(ctx, "GET", fmt.Sprintf("/api/v3/movie/%d", movieID), nil)
+	if isArrNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetSystemStatus returns the app name and version, and doubles as a connectivity check
+func (c *RadarrClient) GetSystemStatus(ctx context.Context) (*ArrSystemStatus, error) {
+	respBody, err := c.doRequest(ctx, "GET", "/api/v3/system/status", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var status ArrSystemStatus
+	if err := json.Unmarshal(respBody, &status); err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// GetDownloadClients returns the configured download clients
+func (c *RadarrClient) GetDownloadClients(ctx context.Context) ([]ArrDownloadClient, error) {
+	respBody, err := c.doRequest(ctx, "GET", "/api/v3/downloadclient", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var clients []ArrDownloadClient
+	if err := json.Unmarshal(respBody, &clients); err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// GetDownloadClientSchema returns the blank definition for a download client implementation
+func (c *RadarrClient) GetDownloadClientSchema(ctx context.Context, implementation string) (*ArrDownloadClient, error) {
+	respBody, err := c.doRequest(ctx, "GET", "/api/v3/downloadclient/schema", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var schemas []ArrDownloadClient
+	if err := json.Unmarshal(respBody, &schemas); err != nil {
+		return nil, err
+	}
+
+	for i := range schemas {
+		if schemas[i].Implementation == implementation {
+			return &schemas[i], nil
+		}
+	}
+	return nil, fmt.Errorf("Radarr has no %s download client implementation", implementation)
+}
+
+// SaveDownloadClient creates the download client, or updates it when it has an ID
+func (c *RadarrClient) SaveDownloadClient(ctx context.Context, client ArrDownloadClient) error {
+	if client.ID == 0 {
+		_, err := c.doRequest(ctx, "POST", "/api/v3/downloadclient", client)
+		return err
+	}
+	_, err := c.doRequest(ctx, "PUT", fmt.Sprintf("/api/v3/downloadclient/%d", client.ID), client)
+	return err
+}
+
+// MatchMovie looks up the extracted media in Radarr and returns the best match
+func (c *RadarrClient) MatchMovie(ctx context.Context, extractedMedia *ExtractedMedia) (*RadarrSearchResult, error) {
+	// Use extracted name from the extractor API
+	searchTerm := extractedMedia.ExtractedName
+	if extractedMedia.Year != "" {
+		searchTerm = searchTerm + " " + extractedMedia.Year
 	}
 
-	return c.AddMovie(movie)
+	return c.MatchMovieByName(ctx, searchTerm)
 }
 
-// AddMovieByName searches for a movie by name and adds it to Radarr
-func (c *RadarrClient) AddMovieByName(searchTerm string) (*RadarrMovie, error) {
-	// Search for the movie
-	results, err := c.SearchMovie(searchTerm)
+// MatchMovieByName looks up a search term in Radarr and returns the best match
+func (c *RadarrClient) MatchMovieByName(ctx context.Context, searchTerm string) (*RadarrSearchResult, error) {
+	// Search for the movie, loosening the term while nothing is found
+	var results []RadarrSearchResult
+	correction, err := searchWithCorrections(searchTerm, func(term string) (bool, error) {
+		var err error
+		results, err = c.SearchMovie(ctx, term)
+		return len(results) > 0, err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to search movie: %w", err)
 	}
@@ -229,25 +499,71 @@ func (c *RadarrClient) AddMovieByName(searchTerm string) (*RadarrMovie, error) {
 	}
 
 	// Get first result
-	searchResult := results[0]
+	match := results[0]
+	match.Correction = correction
+	return &match, nil
+}
 
+// AddMatchedMovie adds a lookup result to Radarr using the default root folder and
+// quality profile, or those set in choice. Unless a profile was picked,
+// an audio language picks the profile scoring its custom format highest instead,
+// and a preferred resolution the profile nearest to it.
+func (c *RadarrClient) AddMatchedMovie(ctx context.Context, searchResult *RadarrSearchResult, choice ArrChoice, searchForMovie bool, preferredResolution int, language string) (*RadarrMovie, error) {
 	// Get root folder
-	folders, err := c.GetRootFolders()
+	folders, err := c.GetRootFolders(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get root folders: %w", err)
 	}
 	if len(folders) == 0 {
 		return nil, fmt.Errorf("no root folders configured in Radarr")
 	}
+	paths := make([]string, len(folders))
+	for i, folder := range folders {
+		paths[i] = folder.Path
+	}
+	defaults := c.defaults.with(choice)
+	folderIndex, err := defaults.rootFolderIndex("Radarr", paths)
+	if err != nil {
+		return nil, err
+	}
+	folder := folders[folderIndex]
+	if err := folder.Validate(); err != nil {
+		return nil, err
+	}
 
-	// Get quality profile
-	profiles, err := c.GetQualityProfiles()
+	// Get quality profile; one the request picked beats the language and quality hints
+	profiles, err := c.GetQualityProfiles(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get quality profiles: %w", err)
 	}
 	if len(profiles) == 0 {
 		return nil, fmt.Errorf("no quality profiles configured in Radarr")
 	}
+	ids, names := make([]int, len(profiles)), make([]string, len(profiles))
+	for i, profile := range profiles {
+		ids[i], names[i] = profile.ID, profile.Name
+	}
+	profileID, err := defaults.qualityProfileID("Radarr", ids, names)
+	if err != nil {
+		return nil, err
+	}
+	if choice.QualityProfileID != 0 {
+		language, preferredResolution = "", 0
+	}
+	if language != "" {
+		if i := c.languageQualityProfile(ctx, language, profiles); i >= 0 {
+			profileID = profiles[i].ID
+			log.Printf("Using quality profile %s for %s audio", profiles[i].Name, language)
+		}
+	}
+	if preferredResolution > 0 {
+		if i := nearestQualityProfile(preferredResolution, profiles); i >= 0 {
+			profileID = profiles[i].ID
+			log.Printf("Using quality profile %s for preferred quality %dp", profiles[i].Name, preferredResolution)
+		} else {
+			log.Printf("Warning: no Radarr quality profile allows %dp; using the default", preferredResolution)
+		}
+	}
 
 	// Create movie
 	movie := RadarrMovie{
@@ -255,16 +571,65 @@ func (c *RadarrClient) AddMovieByName(searchTerm string) (*RadarrMovie, error) {
 		TitleSlug:           searchResult.TitleSlug,
 		Year:                searchResult.Year,
 		TMDBID:              searchResult.TMDBID,
-		QualityProfileID:    profiles[0].ID,
-		RootFolderPath:      folders[0].Path,
+		QualityProfileID:    profileID,
+		RootFolderPath:      folder.Path,
 		Monitored:           true,
 		MinimumAvailability: "released",
 		AddOptions: &RadarrAddOptions{
-			SearchForMovie: true, // Search for the movie after adding
+			SearchForMovie: searchForMovie,
 		},
 	}
 
-	return c.AddMovie(movie)
+	return c.AddMovie(ctx, movie)
+}
+
+// GetTags lists the tags
+func (c *RadarrClient) GetTags(ctx context.Context) ([]ArrTag, error) {
+	return listArrTags(ctx, c.doRequest)
+}
+
+// GetCustomFormats lists the custom formats
+func (c *RadarrClient) GetCustomFormats(ctx context.Context) ([]RadarrCustomFormat, error) {
+	respBody, err := c.doRequest(ctx, "GET", "/api/v3/customformat", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var formats []RadarrCustomFormat
+	if err := json.Unmarshal(respBody, &formats); err != nil {
+		return nil, err
+	}
+	return formats, nil
+}
+
+// languageQualityProfile returns the index of the quality profile scoring the
+// custom formats named after language highest, or -1 if none scores them
+func (c *RadarrClient) languageQualityProfile(ctx context.Context, language string, profiles []RadarrQualityProfile) int {
+	formats, err := c.GetCustomFormats(ctx)
+	if err != nil {
+		log.Printf("Warning: could not list Radarr custom formats: %v", err)
+		return -1
+	}
+	matching := make(map[int]bool)
+	for _, format := range formats {
+		if strings.Contains(strings.ToLower(format.Name), strings.ToLower(language)) {
+			matching[format.ID] = true
+		}
+	}
+
+	best, bestScore := -1, 0
+	for i, profile := range profiles {
+		score := 0
+		for _, item := range profile.FormatItems {
+			if matching[item.Format] {
+				score += item.Score
+			}
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
 }
 
 // cleanTorrentName removes quality tags and other noise from torrent names to extract movie title
@@ -289,17 +654,15 @@ func cleanTorrentName(name string) string {
 		// Quality indicators
 		`(?i)\b(720p|1080p|2160p|4K|UHD|HD|SD)\b.*`,
 		// Source indicators
-		`(?i)\b(BluRay|Blu-Ray|BDRip|BRRip|DVDRip|DVDR|DVD-R|HDRip|WEBRip|WEB-DL|WEBDL|WEB|HDTV|HDR|SDR|CAM|HDCAM|TS|TELESYNC|TC|TELECINE|SCR|SCREENER|R5|DVDScr)\b.*`,
+		`(?i)\b(BluRay|Blu-Ray|BDRip|BRRip|DVDRip|DVDR|DVD-R|HDRip|WEBRip|WEB-DL|WEBDL|WEB|HDTV|HDR|HDR10|DV|DoVi|SDR|CAM|HDCAM|TS|TELESYNC|TC|TELECINE|SCR|SCREENER|R5|DVDScr)\b.*`,
 		// Codec indicators
-		`(?i)\b(x264|x265|HEVC|H\.?264|H\.?265|XviD|DivX|AVC|MPEG|VP9|AV1)\b.*`,
+		`(?i)\b(x264|x265|HEVC|H\.?264|H\.?265|XviD|DivX|AVC|MPEG|VP9|AV1|10bit|10-bit)\b.*`,
 		// Audio indicators
-		`(?i)\b(AAC|AC3|DTS|DTS-HD|TrueHD|Atmos|FLAC|MP3|DD5\.?1|DD7\.?1|5\.1|7\.1)\b.*`,
-		// Release groups and tags
-		`(?i)\b(YIFY|YTS|RARBG|SPARKS|AXXO|FGT|EVO|GECKOS|DRONES|STUTTERSHIT|PSA|MkvCage|ETRG|EtHD|VPPV|ION10|BONE|NTG|CMRG|FLUX|NOGRP)\b.*`,
+		`(?i)\b(AAC|AC3|E-?AC-?3|DDP\d?|DTS|DTS-HD|TrueHD|Atmos|FLAC|MP3|DD5\.?1|DD7\.?1|5\.1|7\.1)\b.*`,
 		// Other common tags
 		`(?i)\b(EXTENDED|UNRATED|DIRECTORS\.?CUT|DC|THEATRICAL|REMASTERED|IMAX|3D|PROPER|REPACK|INTERNAL|LIMITED|COMPLETE|FINAL)\b.*`,
 		// Language tags
-		`(?i)\b(MULTI|MULTi|DUAL|FRENCH|GERMAN|SPANISH|ITALIAN|RUSSIAN|HINDI|KOREAN|JAPANESE|CHINESE)\b.*`,
+		`(?i)\b(MULTI|MULTi|DUAL|TRUEFRENCH|FRENCH|VFF|VFQ|GERMAN|SPANISH|ITALIAN|RUSSIAN|HINDI|KOREAN|JAPANESE|CHINESE)\b.*`,
 		// Subtitles
 		`(?i)\b(SUBBED|DUBBED|SUBS|HARDSUB|HARDCODED|HC)\b.*`,
 	}
@@ -309,6 +672,10 @@ func cleanTorrentName(name string) string {
 		name = re.ReplaceAllString(name, "")
 	}
 
+	// Release groups, built in or from SITE_LIST_URL
+	sites := activeSitePatterns()
+	name = sites.groups.ReplaceAllString(name, "")
+
 	// Remove bracketed content (usually contains release info)
 	name = regexp.MustCompile(`\[.*?\]`).ReplaceAllString(name, "")
 	name = regexp.MustCompile(`\{.*?\}`).ReplaceAllString(name, "")
@@ -324,7 +691,7 @@ func cleanTorrentName(name string) string {
 	name = regexp.MustCompile(`(?i)(-?\s*www\.[^\s]+)$`).ReplaceAllString(name, "")
 
 	// Remove torrent site names
-	name = regexp.MustCompile(`(?i)\b(tamilrockers|tamilmv|tamilblasters|tamilyogi|isaimini|movierulz|filmyzilla|bolly4u|khatrimaza|123movies|putlocker|fmovies|gomovies|primewire|solarmovie|yesmovies|cmovies|bmovies|azmovies|lookmovie|flixtor|hdeuropix|soap2day|bflix|m4uhd|hdtoday|myflixer|dopebox|sockshare|vumoo|1337x|kickass|piratebay|rartv|ettv|eztv)\b\s*-?\s*`).ReplaceAllString(name, "")
+	name = sites.sites.ReplaceAllString(name, "")
 
 	// Remove site URLs and patterns like [TamilMV] or - TamilRockers
 	name = regexp.MustCompile(`(?i)\[\s*(tamilrockers|tamilmv|tamilblasters|tamilyogi)\s*\]`).ReplaceAllString(name, "")
@@ -346,13 +713,124 @@ func cleanTorrentName(name string) string {
 
 // ExtractMovieInfo extracts structured movie information from a torrent name
 type MovieInfo struct {
-	Title   string
-	Year    string
-	Quality string
-	Source  string
-	Codec   string
-	Audio   string
-	Group   string
+	Title   string `json:"title"`
+	Year    string `json:"year,omitempty"`
+	Quality string `json:"quality,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Codec   string `json:"codec,omitempty"`
+	Audio   string `json:"audio,omitempty"`
+	Group   string `json:"group,omitempty"`
+
+	HDR      string `json:"hdr,omitempty"`       // "DV", "HDR10+", "HDR10", "HDR" or "HLG"; "DV HDR10" for a fallback layer
+	BitDepth int    `json:"bit_depth,omitempty"` // 10 or 12 when tagged
+
+	MultiAudio bool     `json:"multi_audio,omitempty"` // tagged MULTI or DUAL audio
+	Languages  []string `json:"languages,omitempty"`   // audio languages tagged, e.g. "French"
+}
+
+var (
+	hdrPattern      = regexp.MustCompile(`(?i)\b(DV|DoVi|Dolby ?Vision|HDR10\+|HDR10Plus|HDR10|HDR|HLG)(?:[^A-Za-z0-9+]|$)`)
+	bitDepthPattern = regexp.MustCompile(`(?i)\b(?:(10|12)[ -]?bits?|Hi(10)P)\b`)
+	// Scene language tags are upper case (or "MULTi"-style), unlike title words
+	// such as "The French Connection"
+	languagePattern = regexp.MustCompile(`\b(MULTI|MULTi|DUAL[ -]AUDIO|Dual[ -]Audio|DUAL|TRUEFRENCH|FRENCH|VFF|VFQ|VF2|GERMAN|iTALiAN|ITALIAN|ITA|SPANISH|CASTELLANO|LATINO|PORTUGUESE|RUSSIAN|RUS|JAPANESE|JPN|KOREAN|CHINESE|MANDARIN|HINDI|ENGLISH|ENG)\b`)
+	audioPattern    = regexp.MustCompile(`(?i)\b(E-?AC-?3|DDP|DD\+|Opus|AAC|AC3|DTS-HD|DTS|TrueHD|Atmos|FLAC|DD5 ?1|DD7 ?1)(?:[^A-Za-z+]|$)`)
+)
+
+// Canonical names of tags with several spellings
+var hdrNames = map[string]string{
+	"dv": "DV", "dovi": "DV", "dolby vision": "DV", "dolbyvision": "DV",
+	"hdr10+": "HDR10+", "hdr10plus": "HDR10+", "hdr10": "HDR10", "hdr": "HDR", "hlg": "HLG",
+}
+
+var audioNames = map[string]string{
+	"eac3": "E-AC3", "e-ac3": "E-AC3", "eac-3": "E-AC3", "e-ac-3": "E-AC3", "ddp": "DDP", "dd+": "DDP", "opus": "Opus",
+}
+
+// languageNames maps language tags to the names Radarr and Sonarr use; "" marks
+// a multi-audio tag
+var languageNames = map[string]string{
+	"multi": "", "dual audio": "", "dual-audio": "", "dual": "",
+	"truefrench": "French", "french": "French", "vff": "French", "vfq": "French", "vf2": "French",
+	"german": "German", "italian": "Italian", "ita": "Italian",
+	"spanish": "Spanish", "castellano": "Spanish", "latino": "Spanish", "portuguese": "Portuguese",
+	"russian": "Russian", "rus": "Russian", "japanese": "Japanese", "jpn": "Japanese",
+	"korean": "Korean", "chinese": "Chinese", "mandarin": "Chinese", "hindi": "Hindi",
+	"english": "English", "eng": "English",
+}
+
+// extractLanguages returns whether a name is tagged multi-audio and the audio
+// languages it names. Only tags after the year count when there is one, since
+// titles come before it.
+func extractLanguages(name, year string) (multi bool, languages []string) {
+	if year != "" {
+		if i := strings.Index(name, year); i >= 0 {
+			name = name[i+len(year):]
+		}
+	}
+	seen := make(map[string]bool)
+	for _, m := range languagePattern.FindAllStringSubmatch(name, -1) {
+		language := languageNames[strings.ToLower(m[1])]
+		if language == "" {
+			multi = true
+			continue
+		}
+		if !seen[language] {
+			seen[language] = true
+			languages = append(languages, language)
+		}
+	}
+	return multi, languages
+}
+
+// hasLanguage reports whether language is one of the tagged languages
+func (info MovieInfo) hasLanguage(language string) bool {
+	for _, l := range info.Languages {
+		if strings.EqualFold(l, language) {
+			return true
+		}
+	}
+	return false
+}
+
+// Format scores rank releases of the same resolution, like a custom format score
+var (
+	hdrRanks   = map[string]int{"DV": 4, "HDR10+": 3, "HDR10": 2, "HDR": 1, "HLG": 1}
+	audioRanks = map[string]int{"TrueHD": 3, "Atmos": 3, "DTS-HD": 3, "E-AC3": 2, "DDP": 2, "DTS": 2, "FLAC": 2, "AC3": 1, "Opus": 1, "AAC": 1}
+)
+
+// formatScore scores a release's HDR format, bit depth and audio codec
+func formatScore(info MovieInfo) int {
+	score := 0
+	if hdr, _, _ := strings.Cut(info.HDR, " "); hdr != "" {
+		score += hdrRanks[hdr] * 10
+	}
+	if info.BitDepth >= 10 {
+		score += 5
+	}
+	for name, rank := range audioRanks {
+		if strings.EqualFold(info.Audio, name) {
+			score += rank
+		}
+	}
+	return score
+}
+
+// extractHDR returns the HDR formats tagged in a name, Dolby Vision first
+func extractHDR(name string) string {
+	var formats []string
+	seen := make(map[string]bool)
+	for _, m := range hdrPattern.FindAllStringSubmatch(name, -1) {
+		format := hdrNames[strings.ToLower(m[1])]
+		if !seen[format] {
+			seen[format] = true
+			formats = append(formats, format)
+		}
+	}
+	sort.SliceStable(formats, func(i, j int) bool {
+		return hdrRanks[formats[i]] > hdrRanks[formats[j]]
+	})
+	return strings.Join(formats, " ")
 }
 
 func ExtractMovieInfo(torrentName string) MovieInfo {
@@ -390,12 +868,23 @@ func ExtractMovieInfo(torrentName string) MovieInfo {
 		info.Codec = matches[1]
 	}
 
-	// Extract audio
-	audioPattern := regexp.MustCompile(`(?i)\b(AAC|AC3|DTS|DTS-HD|TrueHD|Atmos|FLAC|DD5\.?1|DD7\.?1)\b`)
+	// Extract audio, with the E-AC3 spellings unified
 	if matches := audioPattern.FindStringSubmatch(workingName); len(matches) > 1 {
 		info.Audio = matches[1]
+		if canonical, ok := audioNames[strings.ToLower(matches[1])]; ok {
+			info.Audio = canonical
+		}
 	}
 
+	// Extract HDR format and bit depth
+	info.HDR = extractHDR(workingName)
+	if matches := bitDepthPattern.FindStringSubmatch(workingName); matches != nil {
+		info.BitDepth, _ = strconv.Atoi(matches[1] + matches[2])
+	}
+
+	// Extract multi-audio markers and audio languages
+	info.MultiAudio, info.Languages = extractLanguages(workingName, info.Year)
+
 	// Extract release group (usually at the end after a dash)
 	groupPattern := regexp.MustCompile(`-([A-Za-z0-9]+)(?:\s*\[.*\])?$`)
 	if matches := groupPattern.FindStringSubmatch(name); len(matches) > 1 {
@@ -0,0 +1,44 @@
+package main
+
+import "strings"
+
+// ratingLevels maps US film and TV certifications onto one comparable scale
+var ratingLevels = map[string]int{
+	"G":        0,
+	"TV-Y":     0,
+	"TV-G":     0,
+	"PG":       1,
+	"TV-Y7":    1,
+	"TV-Y7-FV": 1,
+	"TV-PG":    1,
+	"PG-13":    2,
+	"TV-14":    2,
+	"R":        3,
+	"TV-MA":    3,
+	"NC-17":    4,
+	"X":        4,
+}
+
+// ratingLevel returns the level of a certification, false if it is unknown or unrated
+func ratingLevel(certification string) (int, bool) {
+	level, ok := ratingLevels[strings.ToUpper(strings.TrimSpace(certification))]
+	return level, ok
+}
+
+// checkRating blocks titles rated above maxRating. Unrated titles are blocked
+// too, since a restricted key can't be trusted with unknown content.
+func checkRating(title, certification, maxRating string) error {
+	if maxRating == "" {
+		return nil
+	}
+	max, _ := ratingLevel(maxRating)
+
+	level, ok := ratingLevel(certification)
+	if !ok {
+		return newAPIError(ErrCodeContentRatingBlocked, "%s has no known certification; this key is limited to %s", title, maxRating)
+	}
+	if level > max {
+		return newAPIError(ErrCodeContentRatingBlocked, "%s is rated %s; this key is limited to %s", title, certification, maxRating)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// isProbePath reports whether path is /health or one of the /health/ probes
+func isProbePath(path string) bool {
+	return path == "/health" || strings.HasPrefix(path, "/health/")
+}
+
+// AppVersion is the version a dependency reported when it warmed up
+type AppVersion struct {
+	Version    string `json:"version,omitempty"`
+	APIVersion string `json:"api_version,omitempty"`
+}
+
+// DependencyStatus is the warm-up state of one dependency
+type DependencyStatus struct {
+	Ready   bool       `json:"ready"`
+	ReadyAt *time.Time `json:"ready_at,omitempty"`
+	AppVersion
+	LastError string `json:"last_error,omitempty"`
+}
+
+// SessionStatus is the age of the qBittorrent login session
+type SessionStatus struct {
+	LoggedInAt *time.Time `json:"logged_in_at"`
+	AgeSeconds int        `json:"age_seconds"`
+}
+
+type ReadinessResponse struct {
+	Ready              bool                        `json:"ready"`
+	Dependencies       map[string]DependencyStatus `json:"dependencies"`
+	QBittorrentSession *SessionStatus              `json:"qbittorrent_session,omitempty"`
+}
+
+// Readiness tracks whether each dependency has answered successfully at least once.
+// Once warmed up a dependency stays ready; later outages are reported per request.
+type Readiness struct {
+	mu   sync.Mutex
+	deps map[string]*DependencyStatus
+}
+
+func NewReadiness(names ...string) *Readiness {
+	r := &Readiness{deps: make(map[string]*DependencyStatus)}
+	for _, name := range names {
+		r.deps[name] = &DependencyStatus{}
+	}
+	return r
+}
+
+// Record stores the outcome of a warm-up check and the version it found
+func (r *Readiness) Record(name string, version AppVersion, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dep, ok := r.deps[name]
+	if !ok || dep.Ready {
+		return
+	}
+	if err != nil {
+		dep.LastError = err.Error()
+		return
+	}
+	now := time.Now()
+	dep.Ready, dep.ReadyAt, dep.AppVersion, dep.LastError = true, &now, version, ""
+	log.Printf("Dependency %s is ready", name)
+}
+
+// Pending returns the dependencies that have not warmed up yet, sorted
+func (r *Readiness) Pending() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var pending []string
+	for name, dep := range r.deps {
+		if !dep.Ready {
+			pending = append(pending, name)
+		}
+	}
+	sort.Strings(pending)
+	return pending
+}
+
+// Status returns a snapshot of all dependencies
+func (r *Readiness) Status() ReadinessResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	resp := ReadinessResponse{Ready: true, Dependencies: make(map[string]DependencyStatus, len(r.deps))}
+	for name, dep := range r.deps {
+		resp.Dependencies[name] = *dep
+		resp.Ready = resp.Ready && dep.Ready
+	}
+	return resp
+}
+
+// WarmUp checks qBittorrent login and Radarr/Sonarr status until each has
+// succeeded once, retrying every interval, and records their versions
+func (h *TorrentHandler) WarmUp(ctx context.Context, interval time.Duration) {
+	arrVersion := func(status *ArrSystemStatus, err error) (AppVersion, error) {
+		if err != nil {
+			return AppVersion{}, err
+		}
+		return AppVersion{Version: status.Version, APIVersion: "v3"}, nil
+	}
+	checks := map[string]func(ctx context.Context) (AppVersion, error){
+		"qbittorrent": func(ctx context.Context) (AppVersion, error) {
+			if err := h.qbClient.Login(ctx); err != nil {
+				return AppVersion{}, err
+			}
+			return h.qbClient.GetVersion(ctx)
+		},
+		"radarr": func(ctx context.Context) (AppVersion, error) {
+			return arrVersion(h.radarrClient.GetSystemStatus(ctx))
+		},
+		"sonarr": func(ctx context.Context) (AppVersion, error) {
+			return arrVersion(h.sonarrClient.GetSystemStatus(ctx))
+		},
+	}
+
+	for {
+		for _, name := range h.readiness.Pending() {
+			checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			version, err := checks[name](checkCtx)
+			cancel()
+			h.readiness.Record(name, version, err)
+		}
+		if len(h.readiness.Pending()) == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Ready is the readiness probe: 200 once every dependency has warmed up, 503 until then
+func (h *TorrentHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	status := h.readiness.Status()
+	status.QBittorrentSession = h.qbClient.Session()
+	if status.Ready {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// A running add refreshes its heartbeat this often; one that missed three is dead
+const (
+	addHeartbeatInterval = 30 * time.Second
+	deadAddAfter         = 3 * addHeartbeatInterval
+)
+
+// An interrupted add is retried until it has run this many times
+const maxAddAttempts = 3
+
+// processStart tells the adds of a previous run, which are all dead, from ours
+var processStart = time.Now()
+
+type addAttemptKey struct{}
+
+// withAddAttempt marks the add made with ctx as its attempt'th run
+func withAddAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, addAttemptKey{}, attempt)
+}
+
+// addAttempt is the run of the add made with ctx, 1 unless the reaper retries it
+func addAttempt(ctx context.Context) int {
+	if attempt, ok := ctx.Value(addAttemptKey{}).(int); ok {
+		return attempt
+	}
+	return 1
+}
+
+// journalAdd records the add as processing, with its request, and refreshes the
+// record's heartbeat until the returned function is called. The completion hook
+// replaces the record with the outcome.
+func (h *TorrentHandler) journalAdd(p *AddPipeline) func() {
+	if h.history == nil {
+		return func() {}
+	}
+
+	now := time.Now()
+	req := p.Request
+	req.Debug = false
+	id, err := h.history.Add(HistoryRecord{
+		AddedAt:     p.StartedAt,
+		Source:      "torrent",
+		Name:        p.TorrentName,
+		InfoHash:    extractInfoHash(p.Request.MagnetLink),
+		Feed:        p.Request.Feed,
+		Status:      HistoryStatusProcessing,
+		HeartbeatAt: &now,
+		Request:     &req,
+		APIKey:      p.APIKey,
+		Attempts:    p.Attempt,
+	})
+	if err != nil {
+		log.Printf("Warning: could not journal add of %s: %v", p.TorrentName, err)
+		return func() {}
+	}
+	p.HistoryID = id
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(addHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				h.history.Heartbeat(id)
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// ReapDeadAdds is the reaper worker, also run at startup: adds left processing
+// by a crash or restart are marked ADD_INTERRUPTED and run again, up to
+// maxAddAttempts runs in all. Adds are idempotent in qBittorrent and the
+// library, so a retry after a partial run is safe: adds happen at least once.
+func (h *TorrentHandler) ReapDeadAdds(ctx context.Context) error {
+	if h.history == nil {
+		return nil
+	}
+
+	for _, record := range h.history.Unfinished() {
+		if record.HeartbeatAt != nil && record.HeartbeatAt.After(processStart) && time.Since(*record.HeartbeatAt) < deadAddAfter {
+			continue
+		}
+
+		attempts := max(record.Attempts, 1)
+		var key *APIKey
+		var message string
+		retry := false
+		switch {
+		case record.Request == nil || attempts >= maxAddAttempts:
+			message = fmt.Sprintf("interrupted by a crash or restart after %d attempts", attempts)
+		case record.APIKey != "" && h.apiKey(record.APIKey) == nil:
+			message = fmt.Sprintf("interrupted by a crash or restart; API key %s no longer exists", record.APIKey)
+		default:
+			key = h.apiKey(record.APIKey)
+			// Paused adds stay processing until a later run
+			if h.maintenance.Check(key) != nil {
+				continue
+			}
+			message = fmt.Sprintf("interrupted by a crash or restart (attempt %d of %d), retried", attempts, maxAddAttempts)
+			retry = true
+		}
+		// Another run of the reaper may have got here first
+		claimed := false
+		if err := h.history.Update(record.ID, func(r *HistoryRecord) {
+			if r.Status != HistoryStatusProcessing {
+				return
+			}
+			r.Status, r.Code, r.Error = HistoryStatusFailed, ErrCodeAddInterrupted, message
+			r.HeartbeatAt, r.Request = nil, nil
+			claimed = true
+		}); err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+		log.Printf("Warning: add of %s was %s", record.Name, message)
+		if !retry {
+			continue
+		}
+
+		req := *record.Request
+		req.Feed = record.Feed
+		addCtx := withAddAttempt(withJobPriority(context.Background(), PriorityBackground), attempts+1)
+		if key != nil {
+			addCtx = context.WithValue(addCtx, apiKeyContextKey{}, key)
+		}
+		go func(name string) {
+			if _, err := h.runAddPipeline(addCtx, req, nil); err != nil {
+				log.Printf("Warning: retry of %s failed: %v", name, err)
+			}
+		}(record.Name)
+	}
+	return nil
+}
+
+// apiKey returns the configured API key with the given name, or nil
+func (h *TorrentHandler) apiKey(name string) *APIKey {
+	for _, key := range h.apiKeys {
+		if key.Name == name {
+			return key
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Reclassify limits on history entries per request
+const (
+	defaultReclassifyLimit = 100
+	maxReclassifyLimit     = 1000
+)
+
+// Classifications compared by reclassify
+const (
+	ClassMovie    = "movie"
+	ClassTV       = "tv"
+	ClassNonMedia = "non_media"
+)
+
+// ReclassifyRequest selects the torrent adds to re-run extraction and detection on
+type ReclassifyRequest struct {
+	IDs   []int64    `json:"ids,omitempty"`   // history IDs; default the latest torrent adds
+	Since *time.Time `json:"since,omitempty"` // only adds since then
+	Limit int        `json:"limit,omitempty"` // default 100, at most 1000
+	Local bool       `json:"local,omitempty"` // use local extraction instead of the extractor
+	All   bool       `json:"all,omitempty"`   // report unchanged entries too
+}
+
+// ReclassifyResult compares what an add was classified as with what the
+// current rules say
+type ReclassifyResult struct {
+	ID          int64             `json:"id"`
+	Name        string            `json:"name"`
+	AddedAt     time.Time         `json:"added_at"`
+	OldType     string            `json:"old_type"` // "movie", "tv" or "non_media"
+	NewType     string            `json:"new_type"`
+	OldCategory string            `json:"old_category,omitempty"`
+	NewCategory string            `json:"new_category,omitempty"`
+	OldTitle    string            `json:"old_title,omitempty"`
+	NewTitle    string            `json:"new_title,omitempty"` // extracted name
+	NewYear     string            `json:"new_year,omitempty"`
+	Decision    *CategoryDecision `json:"decision,omitempty"` // detection rules that fired
+	Extractor   string            `json:"extractor,omitempty"`
+	Changed     bool              `json:"changed"`
+	Error       string            `json:"error,omitempty"`
+}
+
+type ReclassifyResponse struct {
+	Success bool               `json:"success"`
+	Message string             `json:"message"`
+	Checked int                `json:"checked"`
+	Changed int                `json:"changed"`
+	Results []ReclassifyResult `json:"results,omitempty"`
+}
+
+// Reclassify re-runs extraction and detection over past torrent adds without
+// changing anything, reporting which would now be classified differently, so
+// rule changes can be checked against real history before they are trusted
+func (h *TorrentHandler) Reclassify(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(ReclassifyResponse{
+			Success: false,
+			Message: "Method not allowed. Use POST.",
+		})
+		return
+	}
+	if key := apiKeyFromContext(r.Context()); key != nil && !key.Admin {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(ReclassifyResponse{
+			Success: false,
+			Message: "Only ADMIN_KEYS can reclassify history",
+		})
+		return
+	}
+	if h.history == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ReclassifyResponse{
+			Success: false,
+			Message: "History is not enabled",
+		})
+		return
+	}
+
+	var req ReclassifyRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ReclassifyResponse{
+				Success: false,
+				Message: "Invalid request body: " + err.Error(),
+			})
+			return
+		}
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultReclassifyLimit
+	}
+	if req.Limit < 0 || req.Limit > maxReclassifyLimit {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ReclassifyResponse{
+			Success: false,
+			Message: fmt.Sprintf("limit must be between 1 and %d", maxReclassifyLimit),
+		})
+		return
+	}
+
+	resp := ReclassifyResponse{Success: true}
+	for _, record := range h.reclassifyCandidates(req) {
+		result := h.reclassifyRecord(r.Context(), record, req.Local)
+		resp.Checked++
+		if result.Changed {
+			resp.Changed++
+		}
+		if result.Changed || req.All {
+			resp.Results = append(resp.Results, result)
+		}
+	}
+	resp.Message = fmt.Sprintf("%d of %d adds would be classified differently", resp.Changed, resp.Checked)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// reclassifyCandidates returns the selected torrent adds, newest first
+func (h *TorrentHandler) reclassifyCandidates(req ReclassifyRequest) []HistoryRecord {
+	ids := make(map[int64]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		ids[id] = true
+	}
+
+	records := h.history.List()
+	var selected []HistoryRecord
+	for i := len(records) - 1; i >= 0 && len(selected) < req.Limit; i-- {
+		record := records[i]
+		if record.Source == "media" || (len(ids) > 0 && !ids[record.ID]) {
+			continue
+		}
+		if req.Since != nil && record.AddedAt.Before(*req.Since) {
+			continue
+		}
+		selected = append(selected, record)
+	}
+	return selected
+}
+
+// reclassifyRecord runs the extract and detect steps' logic on a recorded add.
+// The magnet isn't kept, so anime trackers are recognized from the recorded
+// tracker domains only.
+func (h *TorrentHandler) reclassifyRecord(ctx context.Context, record HistoryRecord, local bool) ReclassifyResult {
+	result := ReclassifyResult{
+		ID:          record.ID,
+		Name:        record.Name,
+		AddedAt:     record.AddedAt,
+		OldType:     record.MediaType,
+		OldCategory: record.Category,
+		OldTitle:    record.MediaTitle,
+	}
+	if result.OldType == "" {
+		result.OldType = ClassNonMedia
+	}
+
+	magnet := "magnet:?"
+	for _, domain := range record.Trackers {
+		magnet += "tr=udp://" + domain + "&"
+	}
+	anime := isAnimeSource(magnet, "")
+
+	name := record.Name
+	if anime {
+		name = cleanAnimeName(name)
+	}
+	var extracted *ExtractedMedia
+	if local {
+		extracted = localExtractName(name, anime)
+	} else {
+		var err error
+		if extracted, err = h.extractName(ctx, name, anime); err != nil {
+			result.Error = "extraction failed: " + err.Error()
+		}
+	}
+	if extracted != nil {
+		result.NewTitle, result.NewYear, result.Extractor = extracted.ExtractedName, extracted.Year, extracted.MediaType
+	}
+
+	if kind := classifyNonMedia(record.Name); kind != "" {
+		result.NewType = ClassNonMedia
+		result.NewCategory = h.cfg().NonMediaCategory
+		if result.NewCategory == "" {
+			result.NewCategory = kind
+		}
+	} else {
+		decision := h.cfg().Detector.explain(record.Name, anime)
+		result.Decision = &decision
+		category := decision.Category
+		if extractorCategoryApplies(record.Name, anime, extracted) {
+			if c := extractorCategory(extracted); c != "" {
+				category = c
+			}
+		}
+		result.NewType, result.NewCategory = ClassTV, category
+		if category == "radarr" {
+			result.NewType = ClassMovie
+		} else if isCompleteSeries(record.Name) {
+			result.NewCategory = h.cfg().CompleteSeriesCategory
+		}
+	}
+
+	// Titles are compared loosely since OldTitle may be the library's spelling
+	result.Changed = result.NewType != result.OldType ||
+		(result.OldCategory != "" && result.NewCategory != result.OldCategory) ||
+		(result.OldTitle != "" && result.NewTitle != "" && normalizeTitle(result.NewTitle) != normalizeTitle(result.OldTitle))
+	return result
+}
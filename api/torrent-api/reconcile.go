@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ReconcileLibrary marks history records whose movie/series was deleted from
+// Radarr/Sonarr after we added it, optionally removing the torrent that is still
+// seeding, and sends a notification for each. It runs as the "reconcile" job.
+func (h *TorrentHandler) ReconcileLibrary(ctx context.Context) error {
+	if h.history == nil {
+		return nil
+	}
+
+	// Several torrents (e.g. episodes) may share one library item
+	type libraryItem struct {
+		mediaType string
+		id        int
+	}
+	exists := make(map[libraryItem]bool)
+	removed := 0
+
+	for _, record := range h.history.List() {
+		if !record.Active() || record.MediaID == 0 {
+			continue
+		}
+
+		item := libraryItem{record.MediaType, record.MediaID}
+		found, checked := exists[item]
+		if !checked {
+			var err error
+			if item.mediaType == "movie" {
+				found, err = h.radarrClient.MovieExists(ctx, item.id)
+			} else {
+				found, err = h.sonarrClient.SeriesExists(ctx, item.id)
+			}
+			if err != nil {
+				// Don't mark anything while an *arr app is unreachable
+				return fmt.Errorf("failed to check %s %d: %w", item.mediaType, item.id, err)
+			}
+			exists[item] = found
+		}
+		if found {
+			continue
+		}
+
+		h.markRemovedUpstream(ctx, record)
+		removed++
+	}
+
+	if removed > 0 {
+		log.Printf("Reconcile: %d history records removed upstream", removed)
+	}
+	return nil
+}
+
+// markRemovedUpstream soft-deletes a history record and cleans up its torrent
+func (h *TorrentHandler) markRemovedUpstream(ctx context.Context, record HistoryRecord) {
+	service := "Sonarr"
+	if record.MediaType == "movie" {
+		service = "Radarr"
+	}
+	log.Printf("Reconcile: %s was deleted from %s", record.MediaTitle, service)
+
+	torrentRemoved := false
+	if h.cfg().ReconcileRemoveTorrents && record.InfoHash != "" {
+		if err := h.qbClient.DeleteTorrent(ctx, record.InfoHash, true); err != nil {
+			log.Printf("Warning: could not remove torrent %s: %v", record.InfoHash, err)
+		} else {
+			torrentRemoved = true
+		}
+	}
+
+	now := time.Now()
+	err := h.history.Update(record.ID, func(r *HistoryRecord) {
+		r.Status = HistoryStatusRemovedUpstream
+		r.RemovedAt = &now
+		r.TorrentRemoved = torrentRemoved
+	})
+	if err != nil {
+		log.Printf("Warning: could not update history: %v", err)
+		return
+	}
+
+	message := fmt.Sprintf("%s was deleted from %s", record.MediaTitle, service)
+	if torrentRemoved {
+		message += "; its torrent was removed from qBittorrent"
+	}
+	record.Status, record.RemovedAt, record.TorrentRemoved = HistoryStatusRemovedUpstream, &now, torrentRemoved
+	if err := h.notifier.Notify(ctx, EventLibraryItemRemoved, message, record); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReleaseStatus is where the matched title is in its release, so an add of a
+// movie that is only in cinemas can be cancelled before it downloads a CAM
+type ReleaseStatus struct {
+	Status          string     `json:"status"` // Radarr's "tba", "announced", "inCinemas" or "released"; Sonarr's "upcoming", "continuing" or "ended"
+	InCinemas       *time.Time `json:"in_cinemas,omitempty"`
+	DigitalRelease  *time.Time `json:"digital_release,omitempty"`
+	PhysicalRelease *time.Time `json:"physical_release,omitempty"`
+	Message         string     `json:"message,omitempty"` // set when the release is likely a CAM or fake
+}
+
+// movieRelease returns the release status of a Radarr lookup result, nil when
+// Radarr didn't say
+func movieRelease(movie *RadarrSearchResult) *ReleaseStatus {
+	if movie == nil || movie.Status == "" {
+		return nil
+	}
+	release := &ReleaseStatus{
+		Status:          movie.Status,
+		InCinemas:       movie.InCinemas,
+		DigitalRelease:  movie.DigitalRelease,
+		PhysicalRelease: movie.PhysicalRelease,
+	}
+	switch movie.Status {
+	case "tba", "announced":
+		release.Message = fmt.Sprintf("%s is not released yet; the download is likely a fake or a CAM", movie.Title)
+		if movie.InCinemas != nil && movie.InCinemas.After(time.Now()) {
+			release.Message = fmt.Sprintf("%s is not in cinemas until %s; the download is likely a fake or a CAM", movie.Title, movie.InCinemas.Format("2006-01-02"))
+		}
+	case "inCinemas":
+		release.Message = fmt.Sprintf("%s is only in cinemas so far; the download is likely a CAM or telesync", movie.Title)
+		if next := nextHomeRelease(movie); next != nil {
+			release.Message += fmt.Sprintf(" (home release %s)", next.Format("2006-01-02"))
+		}
+	}
+	return release
+}
+
+// nextHomeRelease is the earlier of a movie's upcoming digital and physical releases
+func nextHomeRelease(movie *RadarrSearchResult) *time.Time {
+	var next *time.Time
+	for _, date := range []*time.Time{movie.DigitalRelease, movie.PhysicalRelease} {
+		if date != nil && date.After(time.Now()) && (next == nil || date.Before(*next)) {
+			next = date
+		}
+	}
+	return next
+}
+
+// seriesRelease returns the release status of a Sonarr lookup result, nil when
+// Sonarr didn't say
+func seriesRelease(series *SonarrSearchResult) *ReleaseStatus {
+	if series == nil || series.Status == "" {
+		return nil
+	}
+	release := &ReleaseStatus{Status: series.Status}
+	if series.Status == "upcoming" {
+		release.Message = fmt.Sprintf("%s has not aired yet; the download is likely a fake", series.Title)
+	}
+	return release
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RetagRequest selects the movies and series added through this service to tag
+// or untag in Radarr/Sonarr
+type RetagRequest struct {
+	Tag       string     `json:"tag,omitempty"`       // label to apply, lowercase letters, digits and dashes
+	Requester bool       `json:"requester,omitempty"` // instead tag each item with its adding key's requester tag
+	Remove    bool       `json:"remove,omitempty"`    // remove the tag instead
+	APIKey    string     `json:"api_key,omitempty"`   // only adds made with this key
+	MediaType string     `json:"type,omitempty"`      // "movie" or "tv"; default both
+	Since     *time.Time `json:"since,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+	DryRun    bool       `json:"dry_run,omitempty"` // report what would change without changing it
+}
+
+// RetagResult is one tag change on a batch of movies or series
+type RetagResult struct {
+	Tag       string `json:"tag"`
+	MediaType string `json:"type"`      // "movie" or "tv"
+	MediaIDs  []int  `json:"media_ids"` // Radarr movie / Sonarr series IDs
+	Error     string `json:"error,omitempty"`
+}
+
+type RetagResponse struct {
+	Success bool          `json:"success"`
+	Message string        `json:"message"`
+	Matched int           `json:"matched"`           // movies and series selected
+	Skipped int           `json:"skipped,omitempty"` // added without a key, so without a requester tag
+	Changed int           `json:"changed"`           // tagged or untagged, or would be with dry_run
+	Results []RetagResult `json:"results,omitempty"`
+}
+
+// Retag applies or removes a Radarr/Sonarr tag on every movie and series
+// history says was added here and is still in the library, e.g. to tag a
+// library with requester tags after turning on REQUESTER_TAGS
+func (h *TorrentHandler) Retag(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(RetagResponse{
+			Success: false,
+			Message: "Method not allowed. Use POST.",
+		})
+		return
+	}
+	if key := apiKeyFromContext(r.Context()); key != nil && !key.Admin {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(RetagResponse{
+			Success: false,
+			Message: "Only ADMIN_KEYS can retag the library",
+		})
+		return
+	}
+	if h.history == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(RetagResponse{
+			Success: false,
+			Message: "History is not enabled",
+		})
+		return
+	}
+
+	var req RetagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(RetagResponse{
+			Success: false,
+			Message: "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+	if message := validateRetagRequest(req); message != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(RetagResponse{
+			Success: false,
+			Message: message,
+		})
+		return
+	}
+
+	resp := h.retag(r.Context(), req)
+	status := http.StatusOK
+	if !resp.Success {
+		status = http.StatusBadGateway
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// validateRetagRequest returns what is wrong with a request, or ""
+func validateRetagRequest(req RetagRequest) string {
+	switch {
+	case (req.Tag == "") == !req.Requester:
+		return "Set either tag or requester"
+	case req.Tag != "" && (req.Tag != strings.ToLower(req.Tag) || tagLabelInvalidChars.MatchString(req.Tag)):
+		return "tag may only contain lowercase letters, digits and dashes"
+	case req.MediaType != "" && req.MediaType != "movie" && req.MediaType != "tv":
+		return "Invalid type. Use 'movie' or 'tv'"
+	case req.Since != nil && req.Until != nil && req.Until.Before(*req.Since):
+		return "until is before since"
+	}
+	return ""
+}
+
+// retagBatch is the movies or series getting one tag
+type retagBatch struct {
+	tag, mediaType string
+}
+
+// retag groups the selected library items by tag and media type and changes
+// each group with one editor call. It fails only when every call failed.
+func (h *TorrentHandler) retag(ctx context.Context, req RetagRequest) RetagResponse {
+	resp := RetagResponse{Success: true}
+	batches := make(map[retagBatch][]int)
+	seen := make(map[retagBatch]map[int]bool)
+	matched, skipped := make(map[string]bool), make(map[string]bool)
+	for _, record := range h.history.List() {
+		if !record.Active() || record.MediaID == 0 || record.MediaType == "" {
+			continue
+		}
+		if (req.APIKey != "" && record.APIKey != req.APIKey) || (req.MediaType != "" && record.MediaType != req.MediaType) {
+			continue
+		}
+		if (req.Since != nil && record.AddedAt.Before(*req.Since)) || (req.Until != nil && record.AddedAt.After(*req.Until)) {
+			continue
+		}
+
+		item := fmt.Sprintf("%s %d", record.MediaType, record.MediaID)
+		if !matched[item] {
+			matched[item] = true
+			resp.Matched++
+		}
+		tag := req.Tag
+		if req.Requester {
+			if record.APIKey == "" {
+				if !skipped[item] {
+					skipped[item] = true
+					resp.Skipped++
+				}
+				continue
+			}
+			tag = requesterTag(h.cfg().RequesterTagPrefix, record.APIKey)
+		}
+		batch := retagBatch{tag: tag, mediaType: record.MediaType}
+		if seen[batch] == nil {
+			seen[batch] = make(map[int]bool)
+		}
+		if !seen[batch][record.MediaID] {
+			seen[batch][record.MediaID] = true
+			batches[batch] = append(batches[batch], record.MediaID)
+		}
+	}
+
+	failed := 0
+	for batch, ids := range batches {
+		sort.Ints(ids)
+		result := RetagResult{Tag: batch.tag, MediaType: batch.mediaType, MediaIDs: ids}
+		if !req.DryRun {
+			if err := h.applyTag(ctx, batch.mediaType == "movie", ids, batch.tag, req.Remove); err != nil {
+				log.Printf("Error retagging %d %s items with %s: %v", len(ids), batch.mediaType, batch.tag, err)
+				result.Error = err.Error()
+				failed++
+			}
+		}
+		if result.Error == "" {
+			resp.Changed += len(ids)
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	sort.Slice(resp.Results, func(i, j int) bool {
+		if resp.Results[i].Tag != resp.Results[j].Tag {
+			return resp.Results[i].Tag < resp.Results[j].Tag
+		}
+		return resp.Results[i].MediaType < resp.Results[j].MediaType
+	})
+
+	verb := "Tagged"
+	if req.Remove {
+		verb = "Untagged"
+	}
+	if req.DryRun {
+		verb = "Would change"
+	}
+	resp.Message = fmt.Sprintf("%s %d of %d items", verb, resp.Changed, resp.Matched)
+	if failed > 0 && failed == len(batches) {
+		resp.Success = false
+		resp.Message = "Failed to retag the library"
+	}
+	return resp
+}
+
+// applyTag adds or removes a tag on movies or series. Adding creates the tag
+// as needed; removing a tag the app doesn't have does nothing.
+func (h *TorrentHandler) applyTag(ctx context.Context, isMovie bool, ids []int, label string, remove bool) error {
+	apply := "add"
+	if remove {
+		apply = "remove"
+	}
+
+	var tagID int
+	var err error
+	switch {
+	case !remove && isMovie:
+		tagID, err = h.radarrClient.EnsureTag(ctx, label)
+	case !remove:
+		tagID, err = h.sonarrClient.EnsureTag(ctx, label)
+	default:
+		var tags []ArrTag
+		if isMovie {
+			tags, err = h.radarrClient.GetTags(ctx)
+		} else {
+			tags, err = h.sonarrClient.GetTags(ctx)
+		}
+		for _, tag := range tags {
+			if strings.EqualFold(tag.Label, label) {
+				tagID = tag.ID
+			}
+		}
+		if err == nil && tagID == 0 {
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if isMovie {
+		return h.radarrClient.ApplyMovieTag(ctx, ids, tagID, apply)
+	}
+	return h.sonarrClient.ApplySeriesTag(ctx, ids, tagID, apply)
+}
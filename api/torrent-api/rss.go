@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RSSFeed is one indexer feed polled by the rss worker, configured in RSS_FEEDS
+type RSSFeed struct {
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	AutoGrab   bool   `json:"auto_grab"`             // Add items matching a movie on Radarr's wanted list
+	MinQuality string `json:"min_quality,omitempty"` // Lowest acceptable resolution, e.g. "1080p"
+}
+
+// Resolutions by the quality tags ExtractMovieInfo reports
+var qualityResolutions = map[string]int{
+	"720P":  720,
+	"1080P": 1080,
+	"2160P": 2160,
+	"4K":    2160,
+	"UHD":   2160,
+}
+
+// Camera and telesync rips are never grabbed automatically
+var rejectedSources = map[string]bool{"CAM": true, "HDCAM": true, "TS": true, "TELESYNC": true}
+
+var titleNonAlnumPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// parseRSSFeeds parses the RSS_FEEDS JSON array
+func parseRSSFeeds(spec string) ([]RSSFeed, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	var feeds []RSSFeed
+	if err := json.Unmarshal([]byte(spec), &feeds); err != nil {
+		return nil, fmt.Errorf("invalid RSS_FEEDS: %w", err)
+	}
+	for _, feed := range feeds {
+		if feed.Name == "" || feed.URL == "" {
+			return nil, fmt.Errorf("every feed needs a name and url")
+		}
+		if feed.MinQuality != "" && qualityResolutions[strings.ToUpper(feed.MinQuality)] == 0 {
+			return nil, fmt.Errorf("feed %s: invalid min_quality %q", feed.Name, feed.MinQuality)
+		}
+	}
+	return feeds, nil
+}
+
+// normalizeTitle lowercases a title and reduces it to words, for comparing
+// release titles with library titles
+func normalizeTitle(title string) string {
+	title = strings.ReplaceAll(strings.ToLower(title), "&", " and ")
+	return strings.TrimSpace(titleNonAlnumPattern.ReplaceAllString(title, " "))
+}
+
+type rssDocument struct {
+	Items []rssItem `xml:"channel>item"`
+}
+
+type rssItem struct {
+	Title     string `xml:"title"`
+	Link      string `xml:"link"`
+	Enclosure struct {
+		URL string `xml:"url,attr"`
+	} `xml:"enclosure"`
+	// Torznab/Newznab attributes, e.g. <torznab:attr name="infohash" value="..."/>
+	Attrs []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	} `xml:"attr"`
+}
+
+func (item rssItem) attr(name string) string {
+	for _, a := range item.Attrs {
+		if strings.EqualFold(a.Name, name) {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// magnetLink returns the item's magnet, built from its info hash if needed, or ""
+// for items that only offer a .torrent download
+func (item rssItem) magnetLink() string {
+	for _, link := range []string{item.attr("magneturl"), item.Link, item.Enclosure.URL} {
+		if isValidMagnetLink(link) {
+			return link
+		}
+	}
+	if hash := item.attr("infohash"); hash != "" {
+		params := url.Values{}
+		params.Set("dn", item.Title)
+		return "magnet:?xt=urn:btih:" + strings.ToLower(hash) + "&" + params.Encode()
+	}
+	return ""
+}
+
+// RSSWatcher grabs feed releases of movies Radarr is missing
+type RSSWatcher struct {
+	feeds      []RSSFeed
+	handler    *TorrentHandler
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	grabbed map[int]time.Time // Radarr movie IDs grabbed by this process
+}
+
+func NewRSSWatcher(feeds []RSSFeed, handler *TorrentHandler) *RSSWatcher {
+	return &RSSWatcher{
+		feeds:   feeds,
+		handler: handler,
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: newTracingTransport(),
+		},
+		grabbed: make(map[int]time.Time),
+	}
+}
+
+// fetch downloads and parses a feed
+func (w *RSSWatcher) fetch(ctx context.Context, feed RSSFeed) ([]rssItem, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feed.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch feed %s: %w", feed.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch feed %s: status %d", feed.Name, resp.StatusCode)
+	}
+
+	var doc rssDocument
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 10<<20)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse feed %s: %w", feed.Name, err)
+	}
+	return doc.Items, nil
+}
+
+// acceptable reports whether a release meets the feed's quality bar
+func (feed RSSFeed) acceptable(info MovieInfo) bool {
+	if rejectedSources[strings.ToUpper(info.Source)] {
+		return false
+	}
+	if feed.MinQuality == "" {
+		return true
+	}
+	return qualityResolutions[info.Quality] >= qualityResolutions[strings.ToUpper(feed.MinQuality)]
+}
+
+// Poll checks every auto-grab feed against Radarr's wanted list and adds the
+// first acceptable release of each missing movie. It runs as the "rss" job.
+func (w *RSSWatcher) Poll(ctx context.Context) error {
+	h := w.handler
+	if h.maintenance.State().Enabled {
+		log.Printf("Skipping RSS poll, maintenance mode is on")
+		return nil
+	}
+
+	missing, err := h.radarrClient.GetMissing(ctx, 1000)
+	if err != nil {
+		return fmt.Errorf("failed to get Radarr wanted list: %w", err)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	queued, err := h.radarrClient.GetQueuedMovieIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get Radarr queue: %w", err)
+	}
+
+	// Wanted movies by normalized title and year
+	wanted := make(map[string]RadarrLibraryMovie, len(missing))
+	for _, movie := range missing {
+		wanted[normalizeTitle(movie.Title)+" "+strconv.Itoa(movie.Year)] = movie
+	}
+
+	added := make(map[string]bool)
+	if h.history != nil {
+		for _, record := range h.history.List() {
+			if record.InfoHash != "" {
+				added[record.InfoHash] = true
+			}
+		}
+	}
+
+	var errs []string
+	for _, feed := range w.feeds {
+		if !feed.AutoGrab {
+			continue
+		}
+
+		items, err := w.fetch(ctx, feed)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		for _, item := range items {
+			// The extracted title keeps the year for lookups
+			info := ExtractMovieInfo(item.Title)
+			title := strings.TrimSuffix(normalizeTitle(info.Title), " "+info.Year)
+			movie, ok := wanted[title+" "+info.Year]
+			if !ok || queued[movie.ID] || !feed.acceptable(info) || w.recentlyGrabbed(movie.ID) {
+				continue
+			}
+			magnetLink := item.magnetLink()
+			if magnetLink == "" || added[extractInfoHash(magnetLink)] {
+				continue
+			}
+
+			log.Printf("RSS %s: grabbing %s for wanted movie %s (%d)", feed.Name, item.Title, movie.Title, movie.Year)
+			w.markGrabbed(movie.ID)
+			added[extractInfoHash(magnetLink)] = true
+
+			// Unattended grabs queue behind adds someone is waiting for
+			_, err := h.runAddPipeline(withJobPriority(ctx, PriorityBackground), AddTorrentRequest{
+				MagnetLink: magnetLink,
+				Type:       "movie",
+				Feed:       feed.Name,
+			}, nil)
+			if err != nil {
+				log.Printf("Warning: RSS %s: could not add %s: %v", feed.Name, item.Title, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Radarr takes a moment to pick up a new download in its queue, so a grabbed
+// movie is skipped for a while even when the queue doesn't show it yet
+const rssGrabCooldown = 6 * time.Hour
+
+func (w *RSSWatcher) recentlyGrabbed(movieID int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	at, ok := w.grabbed[movieID]
+	return ok && time.Since(at) < rssGrabCooldown
+}
+
+func (w *RSSWatcher) markGrabbed(movieID int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.grabbed[movieID] = time.Now()
+}
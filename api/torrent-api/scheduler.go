@@ -0,0 +1,428 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schedule is a parsed cron expression ("min hour dom month dow"),
+// a shortcut (@hourly, @daily, @weekly) or a fixed interval (@every 15m)
+type Schedule struct {
+	expr    string
+	every   time.Duration
+	minute  uint64
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	domStar bool
+	dowStar bool
+}
+
+var scheduleShortcuts = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// ParseSchedule parses a cron expression or @every interval
+func ParseSchedule(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval in %q: %w", expr, err)
+		}
+		if d < time.Minute {
+			return nil, fmt.Errorf("interval in %q must be at least 1m", expr)
+		}
+		return &Schedule{expr: expr, every: d}, nil
+	}
+
+	cron := expr
+	if shortcut, ok := scheduleShortcuts[expr]; ok {
+		cron = shortcut
+	}
+
+	fields := strings.Fields(cron)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 cron fields", expr)
+	}
+
+	s := &Schedule{expr: expr}
+	bounds := []struct {
+		set      *uint64
+		min, max int
+	}{
+		{&s.minute, 0, 59},
+		{&s.hour, 0, 23},
+		{&s.dom, 1, 31},
+		{&s.month, 1, 12},
+		{&s.dow, 0, 6},
+	}
+	for i, b := range bounds {
+		set, err := parseCronField(fields[i], b.min, b.max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", expr, err)
+		}
+		*b.set = set
+	}
+	s.domStar = fields[2] == "*"
+	s.dowStar = fields[4] == "*"
+
+	return s, nil
+}
+
+// parseCronField parses "*", "5", "1-5", "*/15", "0-30/10" and comma lists into a bitmask
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			a, errA := strconv.Atoi(bounds[0])
+			b, errB := strconv.Atoi(bounds[1])
+			if errA != nil || errB != nil {
+				return 0, fmt.Errorf("bad range %q", part)
+			}
+			lo, hi = a, b
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("bad value %q", part)
+			}
+			lo, hi = n, n
+		}
+
+		// Sunday may be written as 7
+		if max == 6 && hi == 7 {
+			set |= 1
+			if lo == 7 {
+				continue
+			}
+			hi = 6
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value out of range in %q", part)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// matchesDay follows cron semantics: when both day-of-month and day-of-week
+// are restricted, a day matching either one runs
+func (s *Schedule) matchesDay(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next returns the first run time strictly after t, evaluated in loc
+func (s *Schedule) Next(t time.Time, loc *time.Location) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+
+	t = t.In(loc).Truncate(time.Minute).Add(time.Minute)
+	// A matching minute always exists within a few years (Feb 29 at worst)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// scheduleFromEnv returns SCHEDULE_<NAME> if set, otherwise def
+func scheduleFromEnv(name, def string) string {
+	if v, ok := os.LookupEnv("SCHEDULE_" + strings.ToUpper(name)); ok {
+		return strings.TrimSpace(v)
+	}
+	return def
+}
+
+// ScheduledJobInfo describes a registered background worker for /api/schedules
+type ScheduledJobInfo struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Schedule    string     `json:"schedule"`
+	Enabled     bool       `json:"enabled"`
+	Running     bool       `json:"running"`
+	NextRun     *time.Time `json:"next_run,omitempty"`
+	LastRun     *time.Time `json:"last_run,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+type scheduledJob struct {
+	name        string
+	description string
+	schedule    *Schedule
+	enabled     bool
+	run         func(ctx context.Context) error
+	timer       *time.Timer
+	running     bool
+	nextRun     time.Time
+	lastRun     time.Time
+	lastErr     string
+}
+
+// Scheduler runs background workers on cron-like schedules in a configured timezone
+type Scheduler struct {
+	mu      sync.Mutex
+	loc     *time.Location
+	jobs    map[string]*scheduledJob
+	ctx     context.Context
+	started bool
+}
+
+func NewScheduler(loc *time.Location) *Scheduler {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &Scheduler{
+		loc:  loc,
+		jobs: make(map[string]*scheduledJob),
+	}
+}
+
+// Register adds a worker. An empty expr registers it disabled until a schedule is set.
+func (s *Scheduler) Register(name, description, expr string, run func(ctx context.Context) error) error {
+	job := &scheduledJob{name: name, description: description, run: run}
+	if expr != "" {
+		schedule, err := ParseSchedule(expr)
+		if err != nil {
+			return err
+		}
+		job.schedule = schedule
+		job.enabled = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.jobs[name]; exists {
+		return fmt.Errorf("job %s already registered", name)
+	}
+	s.jobs[name] = job
+	if s.started {
+		s.arm(job)
+	}
+	return nil
+}
+
+// Start arms all enabled jobs; they stop when ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ctx = ctx
+	s.started = true
+	for _, job := range s.jobs {
+		s.arm(job)
+	}
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, job := range s.jobs {
+			if job.timer != nil {
+				job.timer.Stop()
+			}
+		}
+	}()
+}
+
+// Update sets the timezone (unless empty) and job schedules, where an empty
+// expr disables the job. Everything is checked first: on any error nothing
+// changes, and every problem is returned at once.
+func (s *Scheduler) Update(timezone string, schedules map[string]string) error {
+	var errs []error
+	var loc *time.Location
+	if timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			errs = append(errs, fmt.Errorf("invalid timezone %q: %w", timezone, err))
+		}
+	}
+
+	names := make([]string, 0, len(schedules))
+	for name := range schedules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parsed := make(map[string]*Schedule, len(schedules))
+	for _, name := range names {
+		if _, ok := s.jobs[name]; !ok {
+			errs = append(errs, fmt.Errorf("unknown job: %s", name))
+			continue
+		}
+		if expr := schedules[name]; expr != "" {
+			schedule, err := ParseSchedule(expr)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+			parsed[name] = schedule
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	for _, name := range names {
+		job := s.jobs[name]
+		job.schedule = parsed[name]
+		job.enabled = job.schedule != nil
+		if s.started && loc == nil {
+			s.arm(job)
+		}
+	}
+	if loc != nil {
+		s.loc = loc
+		if s.started {
+			for _, job := range s.jobs {
+				s.arm(job)
+			}
+		}
+	}
+	return nil
+}
+
+// Timezone returns the name of the scheduling timezone
+func (s *Scheduler) Timezone() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loc.String()
+}
+
+// List returns all jobs sorted by name
+func (s *Scheduler) List() []ScheduledJobInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos := make([]ScheduledJobInfo, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		info := ScheduledJobInfo{
+			Name:        job.name,
+			Description: job.description,
+			Enabled:     job.enabled,
+			Running:     job.running,
+			LastError:   job.lastErr,
+		}
+		if job.schedule != nil {
+			info.Schedule = job.schedule.String()
+		}
+		if job.enabled && !job.nextRun.IsZero() {
+			next := job.nextRun.In(s.loc)
+			info.NextRun = &next
+		}
+		if !job.lastRun.IsZero() {
+			last := job.lastRun.In(s.loc)
+			info.LastRun = &last
+		}
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// arm (re)schedules the job's timer; callers hold s.mu
+func (s *Scheduler) arm(job *scheduledJob) {
+	if job.timer != nil {
+		job.timer.Stop()
+		job.timer = nil
+	}
+	job.nextRun = time.Time{}
+	if !job.enabled || job.schedule == nil || s.ctx == nil || s.ctx.Err() != nil {
+		return
+	}
+
+	job.nextRun = job.schedule.Next(time.Now(), s.loc)
+	if job.nextRun.IsZero() {
+		return
+	}
+	job.timer = time.AfterFunc(time.Until(job.nextRun), func() { s.fire(job, true) })
+}
+
+func (s *Scheduler) fire(job *scheduledJob, rearm bool) {
+	s.mu.Lock()
+	if job.running || s.ctx == nil {
+		// Skip overlapping runs of a slow job
+		if rearm {
+			s.arm(job)
+		}
+		s.mu.Unlock()
+		return
+	}
+	job.running = true
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	err := job.run(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.running = false
+	job.lastRun = time.Now()
+	job.lastErr = ""
+	if err != nil {
+		job.lastErr = err.Error()
+		log.Printf("Scheduled job %s failed: %v", job.name, err)
+	}
+	if rearm {
+		s.arm(job)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type ScraperClient struct {
+	httpClient *http.Client
+}
+
+// ScrapedRelease is a single magnet found on a result page
+type ScrapedRelease struct {
+	Name       string `json:"name"`
+	MagnetLink string `json:"magnet_link"`
+	InfoHash   string `json:"info_hash"`
+	Quality    string `json:"quality,omitempty"`
+	Source     string `json:"source,omitempty"`
+	Codec      string `json:"codec,omitempty"`
+	HDR        string `json:"hdr,omitempty"`
+	Audio      string `json:"audio,omitempty"`
+}
+
+// siteScraper knows how to pull releases out of one site's pages
+type siteScraper struct {
+	name  string
+	hosts []string
+	parse func(page string) []ScrapedRelease
+}
+
+// Public trackers YTS uses in its own magnet links
+var ytsTrackers = []string{
+	"udp://open.demonii.com:1337/announce",
+	"udp://tracker.openbittorrent.com:80",
+	"udp://tracker.coppersurfer.tk:6969",
+	"udp://glotorrents.pw:6969/announce",
+	"udp://tracker.opentrackr.org:1337/announce",
+	"udp://torrent.gresille.org:80/announce",
+	"udp://p4p.arenabg.com:1337",
+	"udp://tracker.leechers-paradise.org:6969",
+}
+
+var (
+	magnetHrefPattern  = regexp.MustCompile(`href="(magnet:\?[^"]+)"`)
+	ytsDownloadPattern = regexp.MustCompile(`href="https?://yts\.[a-z]+/torrent/download/([A-Fa-f0-9]{40})"[^>]*title="Download ([^"]+) Torrent"`)
+)
+
+var siteScrapers = []siteScraper{
+	{
+		name:  "yts",
+		hosts: []string{"yts.mx", "yts.lt", "yts.am", "yts.ag", "yts.rs"},
+		parse: parseYTSPage,
+	},
+	{
+		name:  "eztv",
+		hosts: []string{"eztv.re", "eztv.wf", "eztv.tf", "eztv.yt", "eztvx.to"},
+		parse: parseMagnetLinks,
+	},
+	{
+		name:  "nyaa",
+		hosts: []string{"nyaa.si", "sukebei.nyaa.si", "nyaa.land"},
+		parse: parseMagnetLinks,
+	},
+}
+
+func NewScraperClient() *ScraperClient {
+	return &ScraperClient{
+		httpClient: &http.Client{
+			Timeout:   15 * time.Second,
+			Transport: newTracingTransport(),
+		},
+	}
+}
+
+// findSiteScraper returns the scraper registered for the page's host, if any
+func findSiteScraper(pageURL string) (*siteScraper, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid page URL: scheme must be http or https")
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	for i := range siteScrapers {
+		for _, h := range siteScrapers[i].hosts {
+			if host == h {
+				return &siteScrapers[i], nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no scraper available for site: %s", host)
+}
+
+// Scrape fetches a result page and returns every magnet and quality variant on it
+func (c *ScraperClient) Scrape(pageURL string) (string, []ScrapedRelease, error) {
+	scraper, err := findSiteScraper(pageURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	resp, err := c.httpClient.Get(pageURL)
+	if err != nil {
+		return scraper.name, nil, fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return scraper.name, nil, fmt.Errorf("failed to fetch page: status %d", resp.StatusCode)
+	}
+
+	// Result pages are small; cap the read so a bad URL can't exhaust memory
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
+	if err != nil {
+		return scraper.name, nil, fmt.Errorf("failed to read page: %w", err)
+	}
+
+	return scraper.name, scraper.parse(string(body)), nil
+}
+
+// parseMagnetLinks collects all magnet hrefs on a page, de-duplicated by info hash
+func parseMagnetLinks(page string) []ScrapedRelease {
+	var releases []ScrapedRelease
+	seen := make(map[string]bool)
+
+	for _, match := range magnetHrefPattern.FindAllStringSubmatch(page, -1) {
+		magnetLink := html.UnescapeString(match[1])
+		hash := extractInfoHash(magnetLink)
+		if hash == "" || seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		releases = append(releases, newScrapedRelease(extractNameFromMagnet(magnetLink), magnetLink, hash))
+	}
+
+	return releases
+}
+
+// parseYTSPage reads the per-quality download buttons on a YTS movie page.
+// YTS only links .torrent files there, so magnets are rebuilt from the hash.
+func parseYTSPage(page string) []ScrapedRelease {
+	var releases []ScrapedRelease
+	seen := make(map[string]bool)
+
+	for _, match := range ytsDownloadPattern.FindAllStringSubmatch(page, -1) {
+		hash := strings.ToLower(match[1])
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+
+		name := html.UnescapeString(match[2])
+		params := url.Values{}
+		params.Set("dn", name)
+		magnetLink := "magnet:?xt=urn:btih:" + hash + "&" + params.Encode()
+		for _, tr := range ytsTrackers {
+			magnetLink += "&tr=" + url.QueryEscape(tr)
+		}
+		releases = append(releases, newScrapedRelease(name, magnetLink, hash))
+	}
+
+	// Fall back to plain magnets if the page layout changed
+	if len(releases) == 0 {
+		return parseMagnetLinks(page)
+	}
+
+	return releases
+}
+
+func newScrapedRelease(name, magnetLink, hash string) ScrapedRelease {
+	info := ExtractMovieInfo(name)
+	return ScrapedRelease{
+		Name:       name,
+		MagnetLink: magnetLink,
+		InfoHash:   hash,
+		Quality:    info.Quality,
+		Source:     info.Source,
+		Codec:      info.Codec,
+		HDR:        info.HDR,
+		Audio:      info.Audio,
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// maxSearchResults limits GET /api/search, since every result carries a poster
+const maxSearchResults = 20
+
+// SearchResult is a Radarr or Sonarr lookup result offered before an add
+type SearchResult struct {
+	Type     string `json:"type"` // "movie" or "tv"
+	Title    string `json:"title"`
+	Year     int    `json:"year,omitempty"`
+	TMDBID   int    `json:"tmdb_id,omitempty"`
+	TVDBID   int    `json:"tvdb_id,omitempty"`
+	Poster   string `json:"poster,omitempty"`
+	Overview string `json:"overview,omitempty"`
+	Link     string `json:"link,omitempty"`  // TMDB/TVDB page
+	Exact    bool   `json:"exact,omitempty"` // title (and year, if given) match the query
+}
+
+// newSearchResult describes the Radarr or Sonarr lookup result a card was made of
+func newSearchResult(card *MediaCard, movie *RadarrSearchResult, series *SonarrSearchResult) SearchResult {
+	result := SearchResult{
+		Type:     card.Type,
+		Title:    card.Title,
+		Year:     card.Year,
+		Poster:   card.Poster,
+		Overview: card.Overview,
+		Link:     card.Link,
+	}
+	if movie != nil {
+		result.TMDBID = movie.TMDBID
+	}
+	if series != nil {
+		result.TVDBID = series.TVDBID
+	}
+	return result
+}
+
+type SearchResponse struct {
+	Success bool           `json:"success"`
+	Message string         `json:"message"`
+	Results []SearchResult `json:"results"`
+}
+
+// Search looks ?q= up in Radarr and Sonarr so the extension can show a picker
+// before an add. ?type= is "movie", "tv" or "all" (default); ?year= narrows
+// the lookup. Exact title matches come first, then the two lookups interleaved.
+func (h *TorrentHandler) Search(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(SearchResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(SearchResponse{
+			Success: false,
+			Message: "q is required",
+		})
+		return
+	}
+	mediaType := r.URL.Query().Get("type")
+	switch mediaType {
+	case "all":
+		mediaType = ""
+	case "", "movie", "tv":
+	case "series":
+		mediaType = "tv"
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(SearchResponse{
+			Success: false,
+			Message: "Invalid type. Use 'movie', 'tv' or 'all'",
+		})
+		return
+	}
+	year := r.URL.Query().Get("year")
+
+	candidates, _, err := h.findBotCandidates(r.Context(), query, year, mediaType, maxSearchResults)
+	if err != nil {
+		log.Printf("Error searching for %s: %v", query, err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(SearchResponse{
+			Success: false,
+			Message: "Search failed: " + err.Error(),
+		})
+		return
+	}
+
+	wanted := normalizeTitle(query)
+	results := make([]SearchResult, 0, len(candidates))
+	for _, c := range candidates {
+		result := newSearchResult(c.Card, c.Movie, c.Series)
+		result.Exact = c.exact(wanted, year)
+		results = append(results, result)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SearchResponse{
+		Success: true,
+		Message: "OK",
+		Results: results,
+	})
+}
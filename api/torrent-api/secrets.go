@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"filippo.io/age"
+	"github.com/joho/godotenv"
+)
+
+// SecretStore resolves credentials from, in order of precedence:
+//  1. the plain environment variable (NAME)
+//  2. a file named by NAME_FILE (Docker/Kubernetes secrets convention)
+//  3. an age-encrypted dotenv file (SECRETS_AGE_FILE + SECRETS_AGE_IDENTITY_FILE)
+//  4. a HashiCorp Vault KV secret (VAULT_ADDR + VAULT_TOKEN + VAULT_SECRET_PATH)
+//
+// Values resolved with Get are registered with the log redactor; Lookup leaves
+// them out, for credentials that aren't secret, like the qBittorrent username.
+type SecretStore struct {
+	ageSecrets   map[string]string
+	vaultSecrets map[string]string
+}
+
+// LoadSecretStore decrypts/fetches the configured secret sources once at startup
+func LoadSecretStore() (*SecretStore, error) {
+	store := &SecretStore{}
+
+	if path := os.Getenv("SECRETS_AGE_FILE"); path != "" {
+		secrets, err := loadAgeSecrets(path, os.Getenv("SECRETS_AGE_IDENTITY_FILE"))
+		if err != nil {
+			return nil, err
+		}
+		store.ageSecrets = secrets
+	}
+
+	if addr := os.Getenv("VAULT_ADDR"); addr != "" {
+		token, err := readEnvOrFile("VAULT_TOKEN")
+		if err != nil {
+			return nil, err
+		}
+		secrets, err := loadVaultSecrets(addr, token, os.Getenv("VAULT_SECRET_PATH"))
+		if err != nil {
+			return nil, err
+		}
+		store.vaultSecrets = secrets
+	}
+
+	return store, nil
+}
+
+// Get returns the secret value for name, or "" if no source defines it, and
+// masks it in the logs
+func (s *SecretStore) Get(name string) (string, error) {
+	value, err := s.Lookup(name)
+	if err != nil {
+		return "", err
+	}
+	secretRedactor.Add(value)
+	return value, nil
+}
+
+// Lookup returns the value for name like Get, without masking it in the logs
+func (s *SecretStore) Lookup(name string) (string, error) {
+	value, err := readEnvOrFile(name)
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		value = s.ageSecrets[name]
+	}
+	if value == "" {
+		value = s.vaultSecrets[name]
+	}
+	return value, nil
+}
+
+// readEnvOrFile reads NAME, falling back to the contents of the file named by NAME_FILE
+func readEnvOrFile(name string) (string, error) {
+	if value := os.Getenv(name); value != "" {
+		return value, nil
+	}
+
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s_FILE: %w", name, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// loadAgeSecrets decrypts a dotenv-format file encrypted with age
+func loadAgeSecrets(path, identityPath string) (map[string]string, error) {
+	if identityPath == "" {
+		return nil, fmt.Errorf("SECRETS_AGE_FILE requires SECRETS_AGE_IDENTITY_FILE")
+	}
+
+	identityFile, err := os.Open(identityPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open age identity: %w", err)
+	}
+	defer identityFile.Close()
+
+	identities, err := age.ParseIdentities(identityFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse age identity: %w", err)
+	}
+
+	encrypted, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open age secrets file: %w", err)
+	}
+	defer encrypted.Close()
+
+	reader, err := age.Decrypt(encrypted, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt age secrets file: %w", err)
+	}
+
+	plaintext, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt age secrets file: %w", err)
+	}
+
+	secrets, err := godotenv.UnmarshalBytes(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse age secrets file: %w", err)
+	}
+	return secrets, nil
+}
+
+// loadVaultSecrets reads a KV secret (v1 or v2) from Vault, e.g. path "secret/data/torrent-api"
+func loadVaultSecrets(addr, token, path string) (map[string]string, error) {
+	if token == "" || path == "" {
+		return nil, fmt.Errorf("VAULT_ADDR requires VAULT_TOKEN and VAULT_SECRET_PATH")
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(addr, "/"), strings.TrimPrefix(path, "/"))
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Vault secret: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read Vault secret: status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse Vault secret: %w", err)
+	}
+
+	// KV v2 nests the values under data.data
+	data := result.Data
+	if nested, ok := data["data"]; ok {
+		var inner map[string]json.RawMessage
+		if err := json.Unmarshal(nested, &inner); err == nil {
+			data = inner
+		}
+	}
+
+	secrets := make(map[string]string, len(data))
+	for key, raw := range data {
+		var value string
+		if err := json.Unmarshal(raw, &value); err == nil {
+			secrets[key] = value
+		}
+	}
+	return secrets, nil
+}
+
+// Redactor masks registered secret values in any text passing through it
+type Redactor struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// secretRedactor holds every secret the service has loaded
+var secretRedactor = &Redactor{}
+
+// Add registers a value to be masked. Very short values are ignored to
+// avoid mangling unrelated text.
+func (r *Redactor) Add(secret string) {
+	if len(secret) < 4 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.secrets {
+		if s == secret {
+			return
+		}
+	}
+	r.secrets = append(r.secrets, secret)
+}
+
+// Redact replaces every registered secret in s with "****"
+func (r *Redactor) Redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, "****")
+	}
+	return s
+}
+
+// redactingWriter masks secrets before writing, so they never reach the logs
+type redactingWriter struct {
+	w        io.Writer
+	redactor *Redactor
+}
+
+func (rw *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := rw.w.Write([]byte(rw.redactor.Redact(string(p)))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// SeedingPolicy is a qBittorrent share limit set when a torrent is added.
+// Unset fields keep qBittorrent's global limit; -1 means no limit.
+type SeedingPolicy struct {
+	RatioLimit       *float64 `json:"ratio_limit,omitempty"`
+	SeedingTimeLimit *int     `json:"seeding_time_limit,omitempty"` // minutes
+}
+
+// SeedingPolicies picks share limits by tracker and category, configured in SEEDING_POLICIES
+type SeedingPolicies struct {
+	Private         *SeedingPolicy           `json:"private,omitempty"`
+	Public          *SeedingPolicy           `json:"public,omitempty"`
+	Trackers        map[string]SeedingPolicy `json:"trackers,omitempty"`         // by tracker domain, before private/public
+	PrivateTrackers []string                 `json:"private_trackers,omitempty"` // domains without a passkey in the URL
+	Categories      map[string]SeedingPolicy `json:"categories,omitempty"`       // by qBittorrent category, after private, before public
+}
+
+// Private trackers put a per-user key in the announce URL
+var passkeyPattern = regexp.MustCompile(`(?i)[?&](passkey|authkey|torrent_pass|pid)=|/[0-9a-z]{24,}(/|$)`)
+
+// parseSeedingPolicies parses the SEEDING_POLICIES JSON object
+func parseSeedingPolicies(spec string) (SeedingPolicies, error) {
+	var policies SeedingPolicies
+	if strings.TrimSpace(spec) == "" {
+		return policies, nil
+	}
+	if err := json.Unmarshal([]byte(spec), &policies); err != nil {
+		return policies, fmt.Errorf("invalid SEEDING_POLICIES: %w", err)
+	}
+
+	check := func(name string, policy *SeedingPolicy) error {
+		if policy == nil {
+			return nil
+		}
+		if r := policy.RatioLimit; r != nil && *r < 0 && *r != -1 {
+			return fmt.Errorf("invalid SEEDING_POLICIES: %s ratio_limit must be >= 0 or -1", name)
+		}
+		if t := policy.SeedingTimeLimit; t != nil && *t < 0 && *t != -1 {
+			return fmt.Errorf("invalid SEEDING_POLICIES: %s seeding_time_limit must be >= 0 or -1", name)
+		}
+		return nil
+	}
+	if err := check("private", policies.Private); err != nil {
+		return policies, err
+	}
+	if err := check("public", policies.Public); err != nil {
+		return policies, err
+	}
+	trackers := make(map[string]SeedingPolicy, len(policies.Trackers))
+	for domain, policy := range policies.Trackers {
+		policy := policy
+		if err := check(domain, &policy); err != nil {
+			return policies, err
+		}
+		trackers[strings.ToLower(domain)] = policy
+	}
+	policies.Trackers = trackers
+	for category, policy := range policies.Categories {
+		policy := policy
+		if err := check("category "+category, &policy); err != nil {
+			return policies, err
+		}
+	}
+	for i, domain := range policies.PrivateTrackers {
+		policies.PrivateTrackers[i] = strings.ToLower(domain)
+	}
+	return policies, nil
+}
+
+// matchesDomain reports whether host is domain or one of its subdomains
+func matchesDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// PolicyFor returns the share limits for a magnet's trackers and category and
+// which rule chose them: a tracker domain, "private", "category:<name>" or
+// "public". A magnet without trackers (DHT only) is public. The policy is nil
+// when nothing is configured for the magnet.
+func (s SeedingPolicies) PolicyFor(magnetLink, category string) (*SeedingPolicy, string) {
+	var trackers []string
+	if u, err := url.Parse(magnetLink); err == nil {
+		trackers = u.Query()["tr"]
+	}
+
+	private := false
+	for _, tracker := range trackers {
+		u, err := url.Parse(tracker)
+		if err != nil {
+			continue
+		}
+		host := strings.ToLower(u.Hostname())
+		// The most specific domain wins
+		matched := ""
+		for domain := range s.Trackers {
+			if matchesDomain(host, domain) && len(domain) > len(matched) {
+				matched = domain
+			}
+		}
+		if matched != "" {
+			policy := s.Trackers[matched]
+			return &policy, matched
+		}
+		for _, domain := range s.PrivateTrackers {
+			if matchesDomain(host, domain) {
+				private = true
+			}
+		}
+		if passkeyPattern.MatchString(u.Path + "?" + u.RawQuery) {
+			private = true
+		}
+	}
+
+	if private && s.Private != nil {
+		return s.Private, "private"
+	}
+	if policy, ok := s.Categories[category]; ok {
+		return &policy, "category:" + category
+	}
+	if private {
+		return nil, "private"
+	}
+	return s.Public, "public"
+}
+
+type SeedingCategoriesResponse struct {
+	Success    bool                     `json:"success"`
+	Message    string                   `json:"message"`
+	Categories map[string]SeedingPolicy `json:"categories"`
+	Updated    map[string]int           `json:"updated,omitempty"` // torrents given the new limits, by category
+}
+
+// SeedingCategories returns the share limits by category; PUT changes them and
+// applies them to the torrents already in those categories. The body maps
+// categories to policies, null removing one. Changes last until SEEDING_POLICIES
+// is reloaded or the service restarts.
+func (h *TorrentHandler) SeedingCategories(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if key := apiKeyFromContext(r.Context()); key != nil && !key.Admin {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(SeedingCategoriesResponse{
+				Success: false,
+				Message: "Only ADMIN_KEYS can change share limits",
+			})
+			return
+		}
+
+		var changes map[string]*SeedingPolicy
+		if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(SeedingCategoriesResponse{
+				Success: false,
+				Message: "Invalid request body: " + err.Error(),
+			})
+			return
+		}
+
+		policies, err := mergeSeedingCategories(h.cfg().Seeding, changes)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(SeedingCategoriesResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		config := h.cfg()
+		config.Seeding = policies
+		h.config.Store(&config)
+
+		updated, err := h.applySeedingCategories(r.Context(), changes)
+		if err != nil {
+			log.Printf("Error applying share limits: %v", err)
+			w.WriteHeader(http.StatusBadGateway)
+			json.NewEncoder(w).Encode(SeedingCategoriesResponse{
+				Success:    false,
+				Message:    "Limits saved but not applied to existing torrents: " + err.Error(),
+				Categories: policies.Categories,
+				Updated:    updated,
+			})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(SeedingCategoriesResponse{
+			Success:    true,
+			Message:    "Share limits updated",
+			Categories: policies.Categories,
+			Updated:    updated,
+		})
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(SeedingCategoriesResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET or PUT.",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SeedingCategoriesResponse{
+		Success:    true,
+		Message:    "OK",
+		Categories: h.cfg().Seeding.Categories,
+	})
+}
+
+// mergeSeedingCategories applies the changed category policies to current
+func mergeSeedingCategories(current SeedingPolicies, changes map[string]*SeedingPolicy) (SeedingPolicies, error) {
+	policies := current
+	policies.Categories = make(map[string]SeedingPolicy, len(current.Categories))
+	for category, policy := range current.Categories {
+		policies.Categories[category] = policy
+	}
+	for category, policy := range changes {
+		if category == "" {
+			return current, fmt.Errorf("category name is required")
+		}
+		if policy == nil {
+			delete(policies.Categories, category)
+			continue
+		}
+		policies.Categories[category] = *policy
+	}
+
+	// Reuse the SEEDING_POLICIES checks
+	spec, err := json.Marshal(policies)
+	if err != nil {
+		return current, err
+	}
+	return parseSeedingPolicies(string(spec))
+}
+
+// applySeedingCategories creates the changed categories in qBittorrent and sets
+// the limits of every torrent already in them
+func (h *TorrentHandler) applySeedingCategories(ctx context.Context, changes map[string]*SeedingPolicy) (map[string]int, error) {
+	updated := make(map[string]int)
+	for category, policy := range changes {
+		if policy != nil {
+			if err := h.qbClient.EnsureCategory(ctx, category); err != nil {
+				return updated, err
+			}
+		}
+		torrents, err := h.qbClient.GetCategoryTorrents(ctx, category)
+		if err != nil {
+			return updated, fmt.Errorf("failed to list %s torrents: %w", category, err)
+		}
+		var hashes []string
+		for _, torrent := range torrents {
+			hashes = append(hashes, torrent.Hash)
+		}
+		if len(hashes) == 0 {
+			continue
+		}
+		if err := h.qbClient.SetShareLimits(ctx, hashes, policy); err != nil {
+			return updated, err
+		}
+		updated[category] = len(hashes)
+		log.Printf("Share limits of %d %s torrents updated", len(hashes), category)
+	}
+	return updated, nil
+}
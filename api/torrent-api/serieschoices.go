@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SeriesChoice is the series a user picked for an ambiguous title
+type SeriesChoice struct {
+	TVDBID   int       `json:"tvdb_id"`
+	Title    string    `json:"title"`
+	Year     int       `json:"year,omitempty"`
+	ChosenAt time.Time `json:"chosen_at"`
+}
+
+// SeriesChoices remembers which series a title meant, by normalized title, in
+// memory and in SERIES_CHOICES_FILE when set, so "The Office" keeps meaning
+// the show the user picked once instead of Sonarr's first lookup result
+type SeriesChoices struct {
+	mu      sync.Mutex
+	path    string
+	choices map[string]SeriesChoice
+}
+
+func NewSeriesChoices() *SeriesChoices {
+	return &SeriesChoices{choices: make(map[string]SeriesChoice)}
+}
+
+// Load reads the choices file and saves to it from now on; a missing file is fine
+func (c *SeriesChoices) Load(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.path = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read series choices: %w", err)
+	}
+	if err := json.Unmarshal(data, &c.choices); err != nil {
+		return fmt.Errorf("failed to parse series choices %s: %w", path, err)
+	}
+	return nil
+}
+
+// Get returns the series picked for a title. A year that disagrees with the
+// pick means another show, so nothing is returned.
+func (c *SeriesChoices) Get(title, year string) (SeriesChoice, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	choice, ok := c.choices[normalizeTitle(title)]
+	if !ok || (year != "" && choice.Year != 0 && year != strconv.Itoa(choice.Year)) {
+		return SeriesChoice{}, false
+	}
+	return choice, true
+}
+
+// Remember records the series picked for a title, replacing an earlier pick
+func (c *SeriesChoices) Remember(title string, series *SonarrSearchResult) {
+	key := normalizeTitle(title)
+	if key == "" || series == nil || series.TVDBID == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.choices[key].TVDBID == series.TVDBID {
+		return
+	}
+	c.choices[key] = SeriesChoice{TVDBID: series.TVDBID, Title: series.Title, Year: series.Year, ChosenAt: time.Now()}
+	log.Printf("Remembering %q as %s (%d), TVDB %d", title, series.Title, series.Year, series.TVDBID)
+	if err := c.save(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+func (c *SeriesChoices) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(c.choices)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".serieschoices-*")
+	if err != nil {
+		return fmt.Errorf("failed to save series choices: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save series choices: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save series choices: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to save series choices: %w", err)
+	}
+	return nil
+}
+
+// rememberedSeries looks up the series picked earlier for a title, or returns
+// nil to match it as usual, also when Sonarr no longer finds the pick
+func (h *TorrentHandler) rememberedSeries(ctx context.Context, title, year string) *SonarrSearchResult {
+	choice, ok := h.seriesChoices.Get(title, year)
+	if !ok {
+		return nil
+	}
+	series, err := h.sonarrClient.LookupSeriesByTVDBID(ctx, choice.TVDBID)
+	if err != nil {
+		log.Printf("Warning: could not look up %s (TVDB %d) picked for %q: %v", choice.Title, choice.TVDBID, title, err)
+		return nil
+	}
+	debugf(ctx, "Using %s (%d), TVDB %d, picked earlier for %q", series.Title, series.Year, series.TVDBID, title)
+	return series
+}
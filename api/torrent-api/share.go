@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Share payloads are a few lines of text; anything larger isn't one
+const maxSharePayload = 64 << 10
+
+// ShareRequest is what a share sheet sends: Web Share Target fields, or the
+// whole text when the payload is plain text
+type ShareRequest struct {
+	Title string `json:"title,omitempty"`
+	Text  string `json:"text,omitempty"`
+	URL   string `json:"url,omitempty"`
+	Type  string `json:"type,omitempty"` // "movie" or "tv", like /api/torrent
+}
+
+// Share adds the magnet found in a share-sheet payload, e.g. from an Android
+// browser's "share to" menu. It accepts JSON, form posts (Web Share Target) and
+// plain text, and answers like /api/torrent.
+func (h *TorrentHandler) Share(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(AddTorrentResponse{
+			Success: false,
+			Message: "Method not allowed. Use POST.",
+		})
+		return
+	}
+
+	share, err := readSharePayload(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(AddTorrentResponse{
+			Success: false,
+			Message: "Invalid share payload: " + err.Error(),
+		})
+		return
+	}
+
+	req, ok := share.addRequest()
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(AddTorrentResponse{
+			Success: false,
+			Message: "No magnet link in the shared text",
+		})
+		return
+	}
+	h.addTorrent(w, r, req)
+}
+
+// readSharePayload decodes the body by its content type; unknown types are
+// read as plain text
+func readSharePayload(r *http.Request) (ShareRequest, error) {
+	r.Body = http.MaxBytesReader(nil, r.Body, maxSharePayload)
+	var share ShareRequest
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		err := json.NewDecoder(r.Body).Decode(&share)
+		return share, err
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		if err := r.ParseMultipartForm(maxSharePayload); err != nil && err != http.ErrNotMultipart {
+			return share, err
+		}
+		share.Title, share.Text, share.URL, share.Type = r.FormValue("title"), r.FormValue("text"), r.FormValue("url"), r.FormValue("type")
+		return share, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	share.Text = string(body)
+	share.Type = r.URL.Query().Get("type")
+	return share, err
+}
+
+// addRequest finds the magnet in the shared fields. The page title, or the text
+// around the magnet, names the torrent when the magnet has no dn, and a shared
+// web page is kept as the source URL.
+func (s ShareRequest) addRequest() (AddTorrentRequest, bool) {
+	var magnet string
+	for _, field := range []string{s.URL, s.Text, s.Title} {
+		if magnet = magnetInTextPattern.FindString(field); magnet != "" {
+			break
+		}
+	}
+	// Share sheets quote or wrap links
+	magnet = strings.TrimRight(magnet, `"'<>)],.;`)
+	if magnet == "" {
+		return AddTorrentRequest{}, false
+	}
+
+	req := AddTorrentRequest{MagnetLink: magnet, Type: s.Type}
+	if strings.HasPrefix(s.URL, "http://") || strings.HasPrefix(s.URL, "https://") {
+		req.SourceURL = s.URL
+	}
+
+	hint := strings.TrimSpace(s.Title)
+	if hint == "" {
+		hint = strings.TrimSpace(strings.Replace(s.Text, magnet, "", 1))
+		hint, _, _ = strings.Cut(hint, "\n")
+	}
+	if u, err := url.Parse(magnet); err == nil && u.Query().Get("dn") == "" && hint != "" {
+		req.MagnetLink += "&dn=" + url.QueryEscape(hint)
+	}
+	return req, true
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// simulateCorpus is the built-in corpus of recorded torrent names
+//
+//go:embed simulate_corpus.json
+var simulateCorpus []byte
+
+// Each simulated add gets this long
+const simulateCaseTimeout = 30 * time.Second
+
+// SimulateCase is a recorded torrent name and what it should become
+type SimulateCase struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`            // "movie", "tv", "game", "software" or "book"
+	Title string `json:"title,omitempty"` // the Radarr/Sonarr title it should match
+	Year  int    `json:"year,omitempty"`
+	// Page the magnet came from, for anime detection, e.g. https://nyaa.si/view/1
+	SourceURL string `json:"source_url,omitempty"`
+}
+
+// SimulateCorpus is the cases to replay plus decoy titles, which the fake
+// Radarr/Sonarr lookups offer beside the right ones
+type SimulateCorpus struct {
+	Cases  []SimulateCase `json:"cases"`
+	Decoys []SimulateCase `json:"decoys,omitempty"`
+}
+
+// runSimulate replays a corpus of torrent names through the full add pipeline
+// against embedded fake qBittorrent, Radarr and Sonarr servers and prints the
+// classification and matching accuracy, as a regression check for detector and
+// name cleaning changes. Detection settings come from the environment.
+func runSimulate(args []string) error {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	corpusPath := flags.String("corpus", "", "corpus JSON file (default: the built-in corpus)")
+	extractorURL := flags.String("extractor", "", "name extractor URL (default: local extraction only)")
+	verbose := flags.Bool("v", false, "print every case and the pipeline log, not only misses")
+	failUnder := flags.Float64("fail-under", 0, "exit non-zero when either accuracy is below this percentage")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	data := simulateCorpus
+	if *corpusPath != "" {
+		var err error
+		if data, err = os.ReadFile(*corpusPath); err != nil {
+			return err
+		}
+	}
+	var corpus SimulateCorpus
+	if err := json.Unmarshal(data, &corpus); err != nil {
+		return fmt.Errorf("invalid corpus: %w", err)
+	}
+	if len(corpus.Cases) == 0 {
+		return fmt.Errorf("corpus has no cases")
+	}
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	config, err := loadHandlerConfig()
+	if err != nil {
+		return err
+	}
+	// Nothing may leave the process: no tracker scrapes, Usenet or waits on metadata
+	config.HealthCheck, config.NZBFallback, config.FileCheckWait = "", false, 0
+	config.IndexerSearch, config.SearchPace = false, 0
+
+	sim := newSimulatedServices(corpus)
+	defer sim.Close()
+	extractor := sim.extractor.URL
+	if *extractorURL != "" {
+		extractor = *extractorURL
+	}
+	history, _ := NewHistoryStore("", 0)
+	h := NewTorrentHandler(
+		NewQBittorrentClient(sim.qbittorrent.URL, "admin", "simulate"),
+		NewRadarrClient(sim.radarr.URL, "simulate"),
+		NewSonarrClient(sim.sonarr.URL, "simulate"),
+		NewNameExtractorClient(extractor, 1500*time.Millisecond),
+		NewScraperClient(), NewScheduler(nil), nil, history, nil, config,
+	)
+
+	var classified, matched, matchable int
+	for _, c := range corpus.Cases {
+		ctx, cancel := context.WithTimeout(context.Background(), simulateCaseTimeout)
+		p, err := h.runAddPipeline(ctx, AddTorrentRequest{MagnetLink: simulateMagnet(c.Name), SourceURL: c.SourceURL}, nil)
+		cancel()
+
+		kind := p.mediaKind()
+		if c.Type == "series" {
+			c.Type = "tv"
+		}
+		var problems []string
+		if kind == c.Type {
+			classified++
+		} else if kind == "" {
+			problems = append(problems, "not classified, want "+c.Type)
+		} else {
+			problems = append(problems, fmt.Sprintf("classified as %s, want %s", kind, c.Type))
+		}
+		if c.Title != "" && (c.Type == "movie" || c.Type == "tv") {
+			matchable++
+			got := "nothing"
+			switch {
+			case p.MovieMatch != nil && p.IsMovie:
+				got = simulateTitle(p.MovieMatch.Title, p.MovieMatch.Year)
+			case p.SeriesMatch != nil && !p.IsMovie:
+				got = simulateTitle(p.SeriesMatch.Title, p.SeriesMatch.Year)
+			}
+			if want := simulateTitle(c.Title, c.Year); got == want || (c.Year == 0 && strings.HasPrefix(got, want+" (")) {
+				matched++
+			} else {
+				problems = append(problems, fmt.Sprintf("matched %s, want %s", got, want))
+			}
+		}
+		if err != nil {
+			problems = append(problems, "add failed: "+err.Error())
+		}
+
+		switch {
+		case len(problems) > 0:
+			fmt.Printf("MISS  %s\n      %s\n", c.Name, strings.Join(problems, "; "))
+		case *verbose:
+			fmt.Printf("ok    %s\n", c.Name)
+		}
+	}
+
+	classification := percent(classified, len(corpus.Cases))
+	fmt.Printf("\nClassification: %d/%d (%.1f%%)\n", classified, len(corpus.Cases), classification)
+	matching := 100.0
+	if matchable > 0 {
+		matching = percent(matched, matchable)
+		fmt.Printf("Matching:       %d/%d (%.1f%%)\n", matched, matchable, matching)
+	}
+	if classification < *failUnder || matching < *failUnder {
+		return fmt.Errorf("accuracy below %.1f%%", *failUnder)
+	}
+	return nil
+}
+
+func percent(n, total int) float64 {
+	return float64(n) * 100 / float64(total)
+}
+
+func simulateTitle(title string, year int) string {
+	if year == 0 {
+		return fmt.Sprintf("%q", title)
+	}
+	return fmt.Sprintf("%q (%d)", title, year)
+}
+
+// simulateMagnet makes a magnet link for a recorded name, with a made-up hash
+func simulateMagnet(name string) string {
+	hash := sha1.Sum([]byte(name))
+	return "magnet:?xt=urn:btih:" + hex.EncodeToString(hash[:]) + "&dn=" + url.QueryEscape(name)
+}
+
+// simulatedServices are the fake qBittorrent, Radarr, Sonarr and extractor
+type simulatedServices struct {
+	qbittorrent, radarr, sonarr, extractor *httptest.Server
+
+	mu        sync.Mutex
+	movies    []SimulateCase
+	series    []SimulateCase
+	libraryID int
+}
+
+func newSimulatedServices(corpus SimulateCorpus) *simulatedServices {
+	s := &simulatedServices{}
+	for _, c := range append(append([]SimulateCase{}, corpus.Cases...), corpus.Decoys...) {
+		switch {
+		case c.Title == "":
+		case c.Type == "movie":
+			s.movies = append(s.movies, c)
+		case c.Type == "tv" || c.Type == "series":
+			s.series = append(s.series, c)
+		}
+	}
+
+	s.qbittorrent = httptest.NewServer(http.HandlerFunc(s.serveQBittorrent))
+	s.radarr = httptest.NewServer(s.arrHandler("movie", s.movies))
+	s.sonarr = httptest.NewServer(s.arrHandler("series", s.series))
+	// The extractor is down, so local extraction is what gets measured
+	s.extractor = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	return s
+}
+
+func (s *simulatedServices) Close() {
+	s.qbittorrent.Close()
+	s.radarr.Close()
+	s.sonarr.Close()
+	s.extractor.Close()
+}
+
+func (s *simulatedServices) serveQBittorrent(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/v2/auth/login", "/api/v2/torrents/add":
+		io.WriteString(w, "Ok.")
+	case "/api/v2/app/version":
+		io.WriteString(w, "v4.6.0")
+	case "/api/v2/app/webapiVersion":
+		io.WriteString(w, "2.9.3")
+	case "/api/v2/torrents/info", "/api/v2/torrents/files", "/api/v2/torrents/trackers":
+		io.WriteString(w, "[]")
+	case "/api/v2/torrents/categories", "/api/v2/app/preferences":
+		io.WriteString(w, "{}")
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// arrHandler fakes the Radarr ("movie") or Sonarr ("series") API: lookups
+// return the catalog titles sharing a word with the term, most shared first,
+// and adds are accepted into an always empty library
+func (s *simulatedServices) arrHandler(resource string, catalog []SimulateCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/api/v3/"+resource+"/lookup":
+			json.NewEncoder(w).Encode(simulateLookup(resource, catalog, r.URL.Query().Get("term")))
+		case r.URL.Path == "/api/v3/"+resource && r.Method == http.MethodPost:
+			var item map[string]any
+			json.NewDecoder(r.Body).Decode(&item)
+			s.mu.Lock()
+			s.libraryID++
+			item["id"] = s.libraryID
+			s.mu.Unlock()
+			json.NewEncoder(w).Encode(item)
+		case r.URL.Path == arrRootFolderEndpoint:
+			io.WriteString(w, `[{"id":1,"path":"/media/`+resource+`","accessible":true}]`)
+		case r.URL.Path == arrQualityProfileEndpoint:
+			io.WriteString(w, `[{"id":1,"name":"Any","cutoff":1}]`)
+		case r.URL.Path == "/api/v3/system/status":
+			io.WriteString(w, `{"version":"5.0.0"}`)
+		case r.Method == http.MethodGet:
+			io.WriteString(w, "[]")
+		default:
+			io.WriteString(w, "{}")
+		}
+	})
+}
+
+// simulateLookup ranks the catalog by the words each title shares with term
+func simulateLookup(resource string, catalog []SimulateCase, term string) []map[string]any {
+	words := strings.Fields(normalizeTitle(term))
+	type scored struct {
+		index, score int
+	}
+	var hits []scored
+	for i, c := range catalog {
+		score := 0
+		for _, word := range strings.Fields(normalizeTitle(c.Title)) {
+			for _, w := range words {
+				if w == word && len(w) > 1 && w != "the" {
+					score++
+					break
+				}
+			}
+		}
+		if score > 0 {
+			hits = append(hits, scored{i, score})
+		}
+	}
+	sort.SliceStable(hits, func(a, b int) bool { return hits[a].score > hits[b].score })
+
+	idField := "tmdbId"
+	if resource == "series" {
+		idField = "tvdbId"
+	}
+	results := make([]map[string]any, 0, len(hits))
+	for _, hit := range hits {
+		c := catalog[hit.index]
+		results = append(results, map[string]any{
+			"title":     c.Title,
+			"titleSlug": strings.ReplaceAll(normalizeTitle(c.Title), " ", "-"),
+			"year":      c.Year,
+			idField:     hit.index + 1,
+			"status":    "ended",
+		})
+	}
+	return results
+}
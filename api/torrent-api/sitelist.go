@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Torrent sites whose names end up in release titles
+var builtinSiteNames = []string{
+	"tamilrockers", "tamilmv", "tamilblasters", "tamilyogi", "isaimini", "movierulz", "filmyzilla",
+	"bolly4u", "khatrimaza", "123movies", "putlocker", "fmovies", "gomovies", "primewire", "solarmovie",
+	"yesmovies", "cmovies", "bmovies", "azmovies", "lookmovie", "flixtor", "hdeuropix", "soap2day",
+	"bflix", "m4uhd", "hdtoday", "myflixer", "dopebox", "sockshare", "vumoo", "1337x", "kickass",
+	"piratebay", "rartv", "ettv", "eztv",
+}
+
+// Release groups; everything from the group on is release info
+var builtinReleaseGroups = []string{
+	"YIFY", "YTS", "RARBG", "SPARKS", "AXXO", "FGT", "EVO", "GECKOS", "DRONES", "STUTTERSHIT", "PSA",
+	"MkvCage", "ETRG", "EtHD", "VPPV", "ION10", "BONE", "NTG", "CMRG", "FLUX", "NOGRP",
+}
+
+// Remote names are joined into a regexp, so keep them to plain words of at
+// least 3 characters
+var siteListNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 ._+&-]{2,63}$`)
+
+// Words common in titles: as a site or group name, any title containing one
+// would be cut short
+var commonTitleWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "from": true, "you": true, "all": true,
+	"one": true, "two": true, "man": true, "men": true, "day": true, "night": true, "love": true,
+	"life": true, "war": true, "wars": true, "world": true, "time": true, "king": true, "star": true,
+	"new": true, "old": true, "big": true, "last": true, "first": true, "dark": true, "black": true,
+	"white": true, "red": true, "blue": true, "house": true, "home": true, "girl": true, "boy": true,
+	"city": true, "story": true, "game": true, "dead": true, "death": true, "lost": true, "live": true,
+	"best": true, "good": true, "bad": true, "blood": true, "movie": true, "movies": true, "film": true,
+	"show": true, "series": true, "season": true, "episode": true, "part": true, "american": true,
+}
+
+// SiteList is the SITE_LIST_URL document. Its names are added to the built-in ones.
+type SiteList struct {
+	Version       string   `json:"version,omitempty"`
+	Sites         []string `json:"sites"`
+	ReleaseGroups []string `json:"release_groups"`
+}
+
+// sitePatterns are the compiled site and release group names cleanTorrentName uses
+type sitePatterns struct {
+	version string
+	sites   *regexp.Regexp
+	groups  *regexp.Regexp
+}
+
+var (
+	builtinSitePatterns = compileSiteList(SiteList{Version: "builtin"})
+	// Replaced when a remote list is loaded
+	currentSitePatterns atomic.Pointer[sitePatterns]
+)
+
+func activeSitePatterns() *sitePatterns {
+	if patterns := currentSitePatterns.Load(); patterns != nil {
+		return patterns
+	}
+	return builtinSitePatterns
+}
+
+// validate rejects lists with names that would match too much of a title
+func (l SiteList) validate() error {
+	if len(l.Sites) == 0 && len(l.ReleaseGroups) == 0 {
+		return fmt.Errorf("site list is empty")
+	}
+	for _, name := range append(append([]string{}, l.Sites...), l.ReleaseGroups...) {
+		if !siteListNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid name %q in site list", name)
+		}
+		if commonTitleWords[strings.ToLower(strings.TrimSpace(name))] {
+			return fmt.Errorf("name %q in site list is a common title word", name)
+		}
+	}
+	return nil
+}
+
+// compileSiteList builds the patterns for the built-in names plus list's
+func compileSiteList(list SiteList) *sitePatterns {
+	return &sitePatterns{
+		version: list.Version,
+		sites:   regexp.MustCompile(`(?i)\b(` + namesPattern(builtinSiteNames, list.Sites) + `)\b\s*-?\s*`),
+		groups:  regexp.MustCompile(`(?i)\b(` + namesPattern(builtinReleaseGroups, list.ReleaseGroups) + `)\b.*`),
+	}
+}
+
+// namesPattern joins names into a regexp alternation, longest first. Dots and
+// underscores match the spaces cleanTorrentName turns them into.
+func namesPattern(lists ...[]string) string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, list := range lists {
+		for _, name := range list {
+			name = strings.NewReplacer(".", " ", "_", " ").Replace(strings.ToLower(strings.TrimSpace(name)))
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	for i, name := range names {
+		names[i] = strings.ReplaceAll(regexp.QuoteMeta(name), " ", `\s+`)
+	}
+	return strings.Join(names, "|")
+}
+
+// SiteListUpdater keeps the site list in sync with SITE_LIST_URL. Lists are
+// signed with an ed25519 key: the hex signature of the document is served at
+// the same URL plus ".sig".
+type SiteListUpdater struct {
+	url        string
+	publicKey  ed25519.PublicKey // nil when SITE_LIST_PUBLIC_KEY is unset
+	cachePath  string            // last good list, for restarts without network
+	httpClient *http.Client
+}
+
+func NewSiteListUpdater(url, publicKey, cachePath string) (*SiteListUpdater, error) {
+	u := &SiteListUpdater{
+		url:       url,
+		cachePath: cachePath,
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: newTracingTransport(),
+		},
+	}
+	if publicKey != "" {
+		key, err := hex.DecodeString(publicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("SITE_LIST_PUBLIC_KEY must be a hex ed25519 public key")
+		}
+		u.publicKey = key
+	}
+	return u, nil
+}
+
+// LoadCache applies the list saved by the last successful refresh
+func (u *SiteListUpdater) LoadCache() error {
+	if u.cachePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(u.cachePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read site list cache: %w", err)
+	}
+	list, err := parseSiteList(data)
+	if err != nil {
+		return fmt.Errorf("invalid site list cache: %w", err)
+	}
+	currentSitePatterns.Store(compileSiteList(list))
+	log.Printf("Loaded cached site list %s: %d sites, %d release groups", list.Version, len(list.Sites), len(list.ReleaseGroups))
+	return nil
+}
+
+// Refresh downloads and applies the remote list. On failure the current list,
+// cached or built-in, stays in use.
+func (u *SiteListUpdater) Refresh(ctx context.Context) error {
+	data, err := u.fetch(ctx, u.url, 1<<20)
+	if err != nil {
+		return fmt.Errorf("failed to download site list: %w", err)
+	}
+	if u.publicKey != nil {
+		signature, err := u.fetch(ctx, u.url+".sig", 1024)
+		if err != nil {
+			return fmt.Errorf("failed to download site list signature: %w", err)
+		}
+		raw, err := hex.DecodeString(strings.TrimSpace(string(signature)))
+		if err != nil || !ed25519.Verify(u.publicKey, data, raw) {
+			return fmt.Errorf("site list signature does not match SITE_LIST_PUBLIC_KEY")
+		}
+	}
+
+	list, err := parseSiteList(data)
+	if err != nil {
+		return err
+	}
+	if current := activeSitePatterns(); list.Version != "" && list.Version == current.version {
+		return nil
+	}
+	currentSitePatterns.Store(compileSiteList(list))
+	log.Printf("Updated site list to %s: %d sites, %d release groups", list.Version, len(list.Sites), len(list.ReleaseGroups))
+
+	if err := u.saveCache(data); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return nil
+}
+
+func (u *SiteListUpdater) fetch(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request failed with status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+func parseSiteList(data []byte) (SiteList, error) {
+	var list SiteList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return list, fmt.Errorf("invalid site list: %w", err)
+	}
+	if err := list.validate(); err != nil {
+		return list, err
+	}
+	return list, nil
+}
+
+func (u *SiteListUpdater) saveCache(data []byte) error {
+	if u.cachePath == "" {
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(u.cachePath), ".sitelist-*")
+	if err != nil {
+		return fmt.Errorf("failed to save site list cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save site list cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save site list cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), u.cachePath); err != nil {
+		return fmt.Errorf("failed to save site list cache: %w", err)
+	}
+	return nil
+}
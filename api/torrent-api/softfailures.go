@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of soft failure: the add went on without the backend's answer
+const (
+	SoftFailureTimeout     = "timeout"
+	SoftFailureUnavailable = "unavailable"
+	SoftFailureNotFound    = "not_found"           // the lookup found no match
+	SoftFailureSkipped     = "library_add_skipped" // the title never got to the library
+	SoftFailureError       = "error"
+)
+
+// Backends with soft failures, and the default WARN threshold of each
+var softFailureBackends = []string{DependencyExtractor, DependencyRadarr, DependencySonarr, DependencyTautulli}
+
+const defaultSoftFailureWarn = 10
+
+// softFailureKind tells timeouts and outages from other errors
+func softFailureKind(err error) string {
+	var timeout *StepTimeoutError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &timeout), errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return SoftFailureTimeout
+	case isUnavailable(err):
+		return SoftFailureUnavailable
+	}
+	return SoftFailureError
+}
+
+// softFailureBucket counts the failures of one minute
+type softFailureBucket struct {
+	minute time.Time
+	count  int
+}
+
+// SoftFailures counts, per backend and kind, the failures adds carried on
+// through, in minute buckets over a rolling window
+type SoftFailures struct {
+	mu      sync.Mutex
+	buckets map[string]map[string][]softFailureBucket
+}
+
+func NewSoftFailures() *SoftFailures {
+	return &SoftFailures{buckets: make(map[string]map[string][]softFailureBucket)}
+}
+
+// Record counts one failure, dropping buckets older than window
+func (s *SoftFailures) Record(backend, kind string, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kinds, ok := s.buckets[backend]
+	if !ok {
+		kinds = make(map[string][]softFailureBucket)
+		s.buckets[backend] = kinds
+	}
+	minute := time.Now().Truncate(time.Minute)
+	buckets := pruneSoftFailures(kinds[kind], window)
+	if n := len(buckets); n > 0 && buckets[n-1].minute.Equal(minute) {
+		buckets[n-1].count++
+	} else {
+		buckets = append(buckets, softFailureBucket{minute: minute, count: 1})
+	}
+	kinds[kind] = buckets
+}
+
+// Counts returns the failures per backend and kind within window
+func (s *SoftFailures) Counts(window time.Duration) map[string]map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]map[string]int, len(s.buckets))
+	for backend, kinds := range s.buckets {
+		for kind, buckets := range kinds {
+			buckets = pruneSoftFailures(buckets, window)
+			kinds[kind] = buckets
+			total := 0
+			for _, b := range buckets {
+				total += b.count
+			}
+			if total == 0 {
+				continue
+			}
+			if counts[backend] == nil {
+				counts[backend] = make(map[string]int)
+			}
+			counts[backend][kind] = total
+		}
+	}
+	return counts
+}
+
+// pruneSoftFailures drops the buckets that ended before the window
+func pruneSoftFailures(buckets []softFailureBucket, window time.Duration) []softFailureBucket {
+	cutoff := time.Now().Add(-window)
+	i := 0
+	for i < len(buckets) && buckets[i].minute.Add(time.Minute).Before(cutoff) {
+		i++
+	}
+	return buckets[i:]
+}
+
+// recordSoftFailure counts a failure an add carried on through
+func (h *TorrentHandler) recordSoftFailure(backend, kind string) {
+	h.softFailures.Record(backend, kind, h.cfg().SoftFailureWindow)
+}
+
+// parseSoftFailureWarn parses SOFT_FAILURE_WARN: a count for every backend, or
+// comma-separated "backend=n" entries such as "extractor=5,radarr=20"; unlisted
+// backends keep the default of 10
+func parseSoftFailureWarn(spec string) (map[string]int, error) {
+	thresholds := make(map[string]int, len(softFailureBackends))
+	for _, backend := range softFailureBackends {
+		thresholds[backend] = defaultSoftFailureWarn
+	}
+	spec = strings.TrimSpace(spec)
+	if n, err := strconv.Atoi(spec); err == nil && n > 0 {
+		for backend := range thresholds {
+			thresholds[backend] = n
+		}
+		return thresholds, nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		backend, value, _ := strings.Cut(entry, "=")
+		backend = strings.TrimSpace(backend)
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if _, ok := thresholds[backend]; !ok || err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid SOFT_FAILURE_WARN entry %q: use a count, or backend=n with one of %s and n at least 1", entry, strings.Join(softFailureBackends, ", "))
+		}
+		thresholds[backend] = n
+	}
+	return thresholds, nil
+}
@@ -1,10 +1,10 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -13,23 +13,60 @@ import (
 )
 
 type SonarrClient struct {
-	baseURL    string
-	apiKey     string
+	baseURL    *arrBaseURL
+	apiKey     *arrAPIKey
 	httpClient *http.Client
+	defaults   ArrDefaults
+	// What to do when a new series' folder clashes with another series' folder
+	pathConflict string
 }
 
+// Policies for SONARR_PATH_CONFLICT
+const (
+	PathConflictError  = "error"  // fail the add with PATH_CONFLICT
+	PathConflictSuffix = "suffix" // add the series under "<folder> [tvdbid-N]"
+)
+
 type SonarrSeries struct {
-	ID               int               `json:"id,omitempty"`
-	Title            string            `json:"title"`
-	TitleSlug        string            `json:"titleSlug"`
-	Year             int               `json:"year"`
-	TVDBID           int               `json:"tvdbId"`
-	QualityProfileID int               `json:"qualityProfileId"`
-	RootFolderPath   string            `json:"rootFolderPath"`
-	Monitored        bool              `json:"monitored"`
-	SeasonFolder     bool              `json:"seasonFolder"`
-	SeriesType       string            `json:"seriesType"`
-	AddOptions       *SonarrAddOptions `json:"addOptions,omitempty"`
+	ID               int    `json:"id,omitempty"`
+	Title            string `json:"title"`
+	TitleSlug        string `json:"titleSlug"`
+	Year             int    `json:"year"`
+	TVDBID           int    `json:"tvdbId"`
+	QualityProfileID int    `json:"qualityProfileId"`
+	// Sonarr v3 only; 0 leaves the default
+	LanguageProfileID int               `json:"languageProfileId,omitempty"`
+	RootFolderPath    string            `json:"rootFolderPath"`
+	Path              string            `json:"path,omitempty"` // overrides the folder Sonarr would pick
+	Monitored         bool              `json:"monitored"`
+	SeasonFolder      bool              `json:"seasonFolder"`
+	SeriesType        string            `json:"seriesType"`
+	Seasons           []SonarrSeason    `json:"seasons,omitempty"`
+	AddOptions        *SonarrAddOptions `json:"addOptions,omitempty"`
+}
+
+// SonarrLibrarySeries is a series in the library with its file statistics
+type SonarrLibrarySeries struct {
+	ID         int                    `json:"id"`
+	Title      string                 `json:"title"`
+	Year       int                    `json:"year"`
+	TVDBID     int                    `json:"tvdbId"`
+	Status     string                 `json:"status"` // "continuing", "ended", ...
+	Monitored  bool                   `json:"monitored"`
+	Path       string                 `json:"path"`
+	Statistics SonarrSeriesStatistics `json:"statistics"`
+}
+
+type SonarrSeriesStatistics struct {
+	EpisodeFileCount  int   `json:"episodeFileCount"`
+	EpisodeCount      int   `json:"episodeCount"` // monitored aired episodes plus those with files
+	TotalEpisodeCount int   `json:"totalEpisodeCount"`
+	SizeOnDisk        int64 `json:"sizeOnDisk"`
+}
+
+type SonarrSeason struct {
+	SeasonNumber int  `json:"seasonNumber"`
+	Monitored    bool `json:"monitored"`
 }
 
 type SonarrAddOptions struct {
@@ -39,87 +76,169 @@ type SonarrAddOptions struct {
 }
 
 type SonarrSearchResult struct {
-	Title     string `json:"title"`
-	TitleSlug string `json:"titleSlug"`
-	Year      int    `json:"year"`
-	TVDBID    int    `json:"tvdbId"`
+	Title         string         `json:"title"`
+	TitleSlug     string         `json:"titleSlug"`
+	Year          int            `json:"year"`
+	TVDBID        int            `json:"tvdbId"`
+	Folder        string         `json:"folder,omitempty"` // folder name Sonarr would create
+	Certification string         `json:"certification"`
+	Status        string         `json:"status"` // "continuing", "ended" or "upcoming"
+	Seasons       []SonarrSeason `json:"seasons"`
+	Overview      string         `json:"overview"`
+	RemotePoster  string         `json:"remotePoster"`
+
+	Correction *LookupCorrection `json:"-"` // set when a transformed term matched
+}
+
+// Airing reports whether the series is still producing episodes
+func (r *SonarrSearchResult) Airing() bool {
+	return r.Status == "continuing" || r.Status == "upcoming"
+}
+
+// MonitorFutureLatestSeason monitors future episodes with only the latest season monitored
+const MonitorFutureLatestSeason = "future_latest_season"
+
+// MonitorCompleteSeries monitors every regular season, for complete series packs
+const MonitorCompleteSeries = "complete_series"
+
+// sonarrMonitorOptions are the accepted monitor settings: Sonarr's own add
+// options plus MonitorFutureLatestSeason
+var sonarrMonitorOptions = map[string]bool{
+	"all": true, "future": true, "missing": true, "existing": true, "pilot": true,
+	"firstSeason": true, "latestSeason": true, "lastSeason": true, "recent": true, "none": true,
+	MonitorFutureLatestSeason: true,
 }
 
 type SonarrRootFolder struct {
-	ID   int    `json:"id"`
-	Path string `json:"path"`
+	ID         int    `json:"id"`
+	Path       string `json:"path"`
+	Accessible bool   `json:"accessible"`
+	FreeSpace  *int64 `json:"freeSpace,omitempty"`
+}
+
+// Validate checks the folder is mounted and has room, since Sonarr otherwise
+// rejects the add with an opaque error when e.g. an NFS mount is down
+func (f SonarrRootFolder) Validate() error {
+	if !f.Accessible {
+		return newAPIError(ErrCodeRootFolderInaccessible, "Sonarr root folder %s is not accessible", f.Path)
+	}
+	if f.FreeSpace != nil && *f.FreeSpace <= 0 {
+		return newAPIError(ErrCodeRootFolderInaccessible, "Sonarr root folder %s has no free space", f.Path)
+	}
+	return nil
 }
 
 type SonarrQualityProfile struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID     int                     `json:"id"`
+	Name   string                  `json:"name"`
+	Cutoff int                     `json:"cutoff"`
+	Items  []ArrQualityProfileItem `json:"items"`
+}
+
+type SonarrEpisodeFile struct {
+	ID      int        `json:"id"`
+	Size    int64      `json:"size"`
+	Quality ArrQuality `json:"quality"`
+}
+
+// SonarrEpisode is an episode of a series in the Sonarr library
+type SonarrEpisode struct {
+	ID            int                 `json:"id"`
+	SeriesID      int                 `json:"seriesId"`
+	SeasonNumber  int                 `json:"seasonNumber"`
+	EpisodeNumber int                 `json:"episodeNumber"`
+	Title         string              `json:"title"`
+	Monitored     bool                `json:"monitored"`
+	HasFile       bool                `json:"hasFile"`
+	AirDateUTC    *time.Time          `json:"airDateUtc,omitempty"`
+	Series        *SonarrSearchResult `json:"series,omitempty"`
+	EpisodeFile   *SonarrEpisodeFile  `json:"episodeFile,omitempty"`
+}
+
+type sonarrEpisodePage struct {
+	ArrPage
+	Records []SonarrEpisode `json:"records"`
 }
 
 func NewSonarrClient(baseURL, apiKey string) *SonarrClient {
 	return &SonarrClient{
-		baseURL: strings.TrimSuffix(baseURL, "/"),
-		apiKey:  apiKey,
+		baseURL: newArrBaseURL(baseURL),
+		apiKey:  newArrAPIKey(apiKey),
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:       30 * time.Second,
+			Transport:     newTracingTransport(),
+			CheckRedirect: arrCheckRedirect,
 		},
 	}
 }
 
-func (c *SonarrClient) doRequest(method, endpoint string, body interface{}) ([]byte, error) {
-	var reqBody io.Reader
-	if body != nil {
-		jsonData, err := json.Marshal(body)
-		if err != nil {
-			return nil, err
-		}
-		reqBody = bytes.NewBuffer(jsonData)
-	}
+func (c *SonarrClient) doRequest(ctx context.Context, method, endpoint string, body interface{}) ([]byte, error) {
+	return doArrRequest(ctx, "Sonarr", c.httpClient, c.baseURL, c.apiKey.Get(), method, endpoint, body)
+}
 
-	req, err := http.NewRequest(method, fmt.Sprintf("%s%s", c.baseURL, endpoint), reqBody)
-	if err != nil {
-		return nil, err
-	}
+// TestAPIKey checks that Sonarr accepts key, without using it for anything else
+func (c *SonarrClient) TestAPIKey(ctx context.Context, key string) error {
+	_, err := doArrRequest(ctx, "Sonarr", c.httpClient, c.baseURL, key, "GET", "/api/v3/system/status", nil)
+	return err
+}
 
-	req.Header.Set("X-Api-Key", c.apiKey)
-	req.Header.Set("Content-Type", "application/json")
+// SetAPIKey replaces the API key after it was rotated in Sonarr
+func (c *SonarrClient) SetAPIKey(key string) {
+	c.apiKey.set(key)
+}
 
-	resp, err := c.httpClient.Do(req)
+// SearchSeries searches for a series by term
+func (c *SonarrClient) SearchSeries(ctx context.Context, term string) ([]SonarrSearchResult, error) {
+	endpoint := fmt.Sprintf("/api/v3/series/lookup?term=%s", url.QueryEscape(term))
+	respBody, err := c.doRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
+	var results []SonarrSearchResult
+	if err := json.Unmarshal(respBody, &results); err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("API error: status %d, body: %s", resp.StatusCode, string(respBody))
-	}
-
-	return respBody, nil
+	return results, nil
 }
 
-// SearchSeries searches for a series by term
-func (c *SonarrClient) SearchSeries(term string) ([]SonarrSearchResult, error) {
-	endpoint := fmt.Sprintf("/api/v3/series/lookup?term=%s", url.QueryEscape(term))
-	respBody, err := c.doRequest("GET", endpoint, nil)
+// LookupSeriesByTVDBID looks a series up by its TVDB ID
+func (c *SonarrClient) LookupSeriesByTVDBID(ctx context.Context, tvdbID int) (*SonarrSearchResult, error) {
+	results, err := c.SearchSeries(ctx, fmt.Sprintf("tvdb:%d", tvdbID))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to search series: %w", err)
+	}
+	for i := range results {
+		if results[i].TVDBID == tvdbID {
+			return &results[i], nil
+		}
 	}
+	return nil, fmt.Errorf("series not found: tvdb:%d", tvdbID)
+}
 
-	var results []SonarrSearchResult
-	if err := json.Unmarshal(respBody, &results); err != nil {
-		return nil, err
+// SetDefaults sets the root folder and quality profile new series are added with
+func (c *SonarrClient) SetPathConflict(policy string) error {
+	switch policy {
+	case "":
+		policy = PathConflictError
+	case PathConflictError, PathConflictSuffix:
+	default:
+		return fmt.Errorf("use %s or %s, got %q", PathConflictError, PathConflictSuffix, policy)
 	}
+	c.pathConflict = policy
+	return nil
+}
 
-	return results, nil
+func (c *SonarrClient) SetDefaults(defaults ArrDefaults) {
+	c.defaults = defaults
 }
 
 // GetRootFolders gets available root folders
-func (c *SonarrClient) GetRootFolders() ([]SonarrRootFolder, error) {
-	respBody, err := c.doRequest("GET", "/api/v3/rootfolder", nil)
+func (c *SonarrClient) GetRootFolders(ctx context.Context) ([]SonarrRootFolder, error) {
+	respBody, err := arrCache.Fetch(c.baseURL.Get(), arrRootFolderEndpoint, func() ([]byte, error) {
+		return c.doRequest(ctx, "GET", arrRootFolderEndpoint, nil)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -133,8 +252,10 @@ func (c *SonarrClient) GetRootFolders() ([]SonarrRootFolder, error) {
 }
 
 // GetQualityProfiles gets available quality profiles
-func (c *SonarrClient) GetQualityProfiles() ([]SonarrQualityProfile, error) {
-	respBody, err := c.doRequest("GET", "/api/v3/qualityprofile", nil)
+func (c *SonarrClient) GetQualityProfiles(ctx context.Context) ([]SonarrQualityProfile, error) {
+	respBody, err := arrCache.Fetch(c.baseURL.Get(), arrQualityProfileEndpoint, func() ([]byte, error) {
+		return c.doRequest(ctx, "GET", arrQualityProfileEndpoint, nil)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -148,8 +269,8 @@ func (c *SonarrClient) GetQualityProfiles() ([]SonarrQualityProfile, error) {
 }
 
 // AddSeries adds a series to Sonarr
-func (c *SonarrClient) AddSeries(series SonarrSeries) (*SonarrSeries, error) {
-	respBody, err := c.doRequest("POST", "/api/v3/series", series)
+func (c *SonarrClient) AddSeries(ctx context.Context, series SonarrSeries) (*SonarrSeries, error) {
+	respBody, err := c.doRequest(ctx, "POST", "/api/v3/series", series)
 	if err != nil {
 		return nil, err
 	}
@@ -162,13 +283,248 @@ func (c *SonarrClient) AddSeries(series SonarrSeries) (*SonarrSeries, error) {
 	return &result, nil
 }
 
-// AddSeriesFromMagnet extracts series info from magnet and adds to Sonarr
-func (c *SonarrClient) AddSeriesFromMagnet(magnetLink string, extractedMedia *ExtractedMedia) (*SonarrSeries, error) {
+// GetCutoffUnmet returns monitored episodes whose file is below the quality profile cutoff
+func (c *SonarrClient) GetCutoffUnmet(ctx context.Context, pageSize int) ([]SonarrEpisode, int, error) {
+	endpoint := fmt.Sprintf("/api/v3/wanted/cutoff?page=1&pageSize=%d&monitored=true&includeSeries=true&includeEpisodeFile=true", pageSize)
+	respBody, err := c.doRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var page sonarrEpisodePage
+	if err := json.Unmarshal(respBody, &page); err != nil {
+		return nil, 0, err
+	}
+
+	return page.Records, page.TotalRecords, nil
+}
+
+// GetCalendar returns the episodes airing between start and end
+func (c *SonarrClient) GetCalendar(ctx context.Context, start, end time.Time) ([]SonarrEpisode, error) {
+	endpoint := fmt.Sprintf("/api/v3/calendar?start=%s&end=%s&includeSeries=true", start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+	respBody, err := c.doRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var episodes []SonarrEpisode
+	if err := json.Unmarshal(respBody, &episodes); err != nil {
+		return nil, err
+	}
+
+	return episodes, nil
+}
+
+// GetSeasonEpisodes returns a season's episodes of a library series with their files
+func (c *SonarrClient) GetSeasonEpisodes(ctx context.Context, seriesID, season int) ([]SonarrEpisode, error) {
+	endpoint := fmt.Sprintf("/api/v3/episode?seriesId=%d&seasonNumber=%d&includeEpisodeFile=true", seriesID, season)
+	respBody, err := c.doRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var episodes []SonarrEpisode
+	if err := json.Unmarshal(respBody, &episodes); err != nil {
+		return nil, err
+	}
+
+	return episodes, nil
+}
+
+// DeleteEpisodeFile deletes an episode file from disk and from Sonarr
+func (c *SonarrClient) DeleteEpisodeFile(ctx context.Context, fileID int) error {
+	_, err := c.doRequest(ctx, "DELETE", fmt.Sprintf("/api/v3/episodefile/%d", fileID), nil)
+	return err
+}
+
+// SearchReleases asks Sonarr's indexers for releases of a library episode
+func (c *SonarrClient) SearchReleases(ctx context.Context, episodeID int) ([]ArrRelease, error) {
+	respBody, err := c.doRequest(ctx, "GET", fmt.Sprintf("/api/v3/release?episodeId=%d", episodeID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []ArrRelease
+	if err := json.Unmarshal(respBody, &releases); err != nil {
+		return nil, err
+	}
+
+	return releases, nil
+}
+
+// SearchSeasonReleases asks Sonarr's indexers for releases of a season of a library series
+func (c *SonarrClient) SearchSeasonReleases(ctx context.Context, seriesID, season int) ([]ArrRelease, error) {
+	respBody, err := c.doRequest(ctx, "GET", fmt.Sprintf("/api/v3/release?seriesId=%d&seasonNumber=%d", seriesID, season), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []ArrRelease
+	if err := json.Unmarshal(respBody, &releases); err != nil {
+		return nil, err
+	}
+
+	return releases, nil
+}
+
+// GrabRelease sends a release found by a release search to the download client
+func (c *SonarrClient) GrabRelease(ctx context.Context, release *ArrRelease) error {
+	_, err := c.doRequest(ctx, "POST", "/api/v3/release", map[string]interface{}{
+		"guid":      release.GUID,
+		"indexerId": release.IndexerID,
+	})
+	return err
+}
+
+// SeriesExists reports whether a series with the given ID is still in the library
+func (c *SonarrClient) SeriesExists(ctx context.Context, seriesID int) (bool, error) {
+	_, err := c.doRequest(ctx, "GET", fmt.Sprintf("/api/v3/series/%d", seriesID), nil)
+	if isArrNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetAllSeries returns every series in the library
+func (c *SonarrClient) GetAllSeries(ctx context.Context) ([]SonarrLibrarySeries, error) {
+	respBody, err := c.doRequest(ctx, "GET", "/api/v3/series", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var series []SonarrLibrarySeries
+	if err := json.Unmarshal(respBody, &series); err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+// FindSeriesByTVDBID returns the library series with a TVDB ID, usually none or one
+func (c *SonarrClient) FindSeriesByTVDBID(ctx context.Context, tvdbID int) ([]SonarrLibrarySeries, error) {
+	respBody, err := c.doRequest(ctx, "GET", fmt.Sprintf("/api/v3/series?tvdbId=%d", tvdbID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var series []SonarrLibrarySeries
+	if err := json.Unmarshal(respBody, &series); err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+// EnsureTag returns the ID of the tag with label, creating it if needed
+func (c *SonarrClient) EnsureTag(ctx context.Context, label string) (int, error) {
+	return ensureArrTag(ctx, c.doRequest, label)
+}
+
+// AddSeriesTag adds a tag to a series, keeping its other tags
+func (c *SonarrClient) AddSeriesTag(ctx context.Context, seriesID, tagID int) error {
+	return c.ApplySeriesTag(ctx, []int{seriesID}, tagID, "add")
+}
+
+// ApplySeriesTag adds ("add") or removes ("remove") a tag on series, keeping their other tags
+func (c *SonarrClient) ApplySeriesTag(ctx context.Context, seriesIDs []int, tagID int, apply string) error {
+	_, err := c.doRequest(ctx, "PUT", "/api/v3/series/editor", map[string]interface{}{
+		"seriesIds": seriesIDs,
+		"tags":      []int{tagID},
+		"applyTags": apply,
+	})
+	return err
+}
+
+// UnmonitorSeries stops Sonarr from searching for a series
+func (c *SonarrClient) UnmonitorSeries(ctx context.Context, seriesID int) error {
+	_, err := c.doRequest(ctx, "PUT", "/api/v3/series/editor", map[string]interface{}{
+		"seriesIds": []int{seriesID},
+		"monitored": false,
+	})
+	return err
+}
+
+// DeleteSeries removes a series from Sonarr, leaving any files on disk
+func (c *SonarrClient) DeleteSeries(ctx context.Context, seriesID int) error {
+	_, err := c.doRequest(ctx, "DELETE", fmt.Sprintf("/api/v3/series/%d?deleteFiles=false", seriesID), nil)
+	return err
+}
+
+// GetSystemStatus returns the app name and version, and doubles as a connectivity check
+func (c *SonarrClient) GetSystemStatus(ctx context.Context) (*ArrSystemStatus, error) {
+	respBody, err := c.doRequest(ctx, "GET", "/api/v3/system/status", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var status ArrSystemStatus
+	if err := json.Unmarshal(respBody, &status); err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// GetDownloadClients returns the configured download clients
+func (c *SonarrClient) GetDownloadClients(ctx context.Context) ([]ArrDownloadClient, error) {
+	respBody, err := c.doRequest(ctx, "GET", "/api/v3/downloadclient", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var clients []ArrDownloadClient
+	if err := json.Unmarshal(respBody, &clients); err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// GetDownloadClientSchema returns the blank definition for a download client implementation
+func (c *SonarrClient) GetDownloadClientSchema(ctx context.Context, implementation string) (*ArrDownloadClient, error) {
+	respBody, err := c.doRequest(ctx, "GET", "/api/v3/downloadclient/schema", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var schemas []ArrDownloadClient
+	if err := json.Unmarshal(respBody, &schemas); err != nil {
+		return nil, err
+	}
+
+	for i := range schemas {
+		if schemas[i].Implementation == implementation {
+			return &schemas[i], nil
+		}
+	}
+	return nil, fmt.Errorf("Sonarr has no %s download client implementation", implementation)
+}
+
+// SaveDownloadClient creates the download client, or updates it when it has an ID
+func (c *SonarrClient) SaveDownloadClient(ctx context.Context, client ArrDownloadClient) error {
+	if client.ID == 0 {
+		_, err := c.doRequest(ctx, "POST", "/api/v3/downloadclient", client)
+		return err
+	}
+	_, err := c.doRequest(ctx, "PUT", fmt.Sprintf("/api/v3/downloadclient/%d", client.ID), client)
+	return err
+}
+
+// MatchSeries looks up the extracted media in Sonarr and returns the best match
+func (c *SonarrClient) MatchSeries(ctx context.Context, extractedMedia *ExtractedMedia) (*SonarrSearchResult, error) {
 	// Use extracted name from the extractor API
-	searchTerm := extractedMedia.ExtractedName
+	return c.MatchSeriesByName(ctx, extractedMedia.ExtractedName)
+}
 
-	// Search for the series
-	results, err := c.SearchSeries(searchTerm)
+// MatchSeriesByName looks up a search term in Sonarr and returns the best match
+func (c *SonarrClient) MatchSeriesByName(ctx context.Context, searchTerm string) (*SonarrSearchResult, error) {
+	// Search for the series, loosening the term while nothing is found
+	var results []SonarrSearchResult
+	correction, err := searchWithCorrections(searchTerm, func(term string) (bool, error) {
+		var err error
+		results, err = c.SearchSeries(ctx, term)
+		return len(results) > 0, err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to search series: %w", err)
 	}
@@ -178,99 +534,317 @@ func (c *SonarrClient) AddSeriesFromMagnet(magnetLink string, extractedMedia *Ex
 	}
 
 	// Get first result
-	searchResult := results[0]
+	match := results[0]
+	match.Correction = correction
+	return &match, nil
+}
+
+// AddMatchedSeries adds a lookup result to Sonarr using the default root folder and quality
+// profile, or those set in choice.
+// seriesType is Sonarr's series type ("standard" or "anime"); monitor is one of sonarrMonitorOptions.
+// An audio language picks the Sonarr v3 language profile for it, if there is one.
+func (c *SonarrClient) AddMatchedSeries(ctx context.Context, searchResult *SonarrSearchResult, choice ArrChoice, seriesType, monitor string, searchForMissing bool, language string) (*SonarrSeries, error) {
+	if seriesType == "" {
+		seriesType = "standard"
+	}
+	if monitor == "" {
+		monitor = "all"
+	}
 
 	// Get root folder
-	folders, err := c.GetRootFolders()
+	folders, err := c.GetRootFolders(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get root folders: %w", err)
 	}
 	if len(folders) == 0 {
 		return nil, fmt.Errorf("no root folders configured in Sonarr")
 	}
+	paths := make([]string, len(folders))
+	for i, folder := range folders {
+		paths[i] = folder.Path
+	}
+	defaults := c.defaults.with(choice)
+	folderIndex, err := defaults.rootFolderIndex("Sonarr", paths)
+	if err != nil {
+		return nil, err
+	}
+	folder := folders[folderIndex]
+	if err := folder.Validate(); err != nil {
+		return nil, err
+	}
 
 	// Get quality profile
-	profiles, err := c.GetQualityProfiles()
+	profiles, err := c.GetQualityProfiles(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get quality profiles: %w", err)
 	}
 	if len(profiles) == 0 {
 		return nil, fmt.Errorf("no quality profiles configured in Sonarr")
 	}
+	ids, names := make([]int, len(profiles)), make([]string, len(profiles))
+	for i, profile := range profiles {
+		ids[i], names[i] = profile.ID, profile.Name
+	}
+	profileID, err := defaults.qualityProfileID("Sonarr", ids, names)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sonarr v3 picks audio languages by language profile; v4 has none
+	languageProfileID := 0
+	if language != "" {
+		languageProfileID = c.languageProfileID(ctx, language)
+	}
+
+	seriesPath, err := c.seriesPath(ctx, searchResult, folder.Path)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create series
 	series := SonarrSeries{
-		Title:            searchResult.Title,
-		TitleSlug:        searchResult.TitleSlug,
-		Year:             searchResult.Year,
-		TVDBID:           searchResult.TVDBID,
-		QualityProfileID: profiles[0].ID,
-		RootFolderPath:   folders[0].Path,
-		Monitored:        true,
-		SeasonFolder:     true,
-		SeriesType:       "standard",
+		Title:             searchResult.Title,
+		TitleSlug:         searchResult.TitleSlug,
+		Year:              searchResult.Year,
+		TVDBID:            searchResult.TVDBID,
+		QualityProfileID:  profileID,
+		LanguageProfileID: languageProfileID,
+		RootFolderPath:    folder.Path,
+		Path:              seriesPath,
+		Monitored:         true,
+		SeasonFolder:      true,
+		SeriesType:        seriesType,
 		AddOptions: &SonarrAddOptions{
-			SearchForMissingEpisodes:     false, // Don't search, we're adding via torrent
+			SearchForMissingEpisodes:     searchForMissing,
 			SearchForCutoffUnmetEpisodes: false,
-			Monitor:                      "all",
+			Monitor:                      monitor,
 		},
 	}
 
-	return c.AddSeries(series)
+	switch monitor {
+	case MonitorFutureLatestSeason:
+		series.AddOptions.Monitor = "future"
+		series.Seasons = latestSeasonOnly(searchResult.Seasons)
+	case MonitorCompleteSeries:
+		series.AddOptions.Monitor = "all"
+		series.Seasons = regularSeasons(searchResult.Seasons)
+	}
+
+	return c.AddSeries(ctx, series)
+}
+
+// SonarrLanguageProfile is a Sonarr v3 language profile
+type SonarrLanguageProfile struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Cutoff struct {
+		Name string `json:"name"`
+	} `json:"cutoff"`
+}
+
+// GetQueue returns Sonarr's activity queue with each record's series and episode
+func (c *SonarrClient) GetQueue(ctx context.Context) ([]ArrQueueRecord, error) {
+	return getArrQueue(ctx, c.doRequest, "Series", "Episode")
+}
+
+// GetTags lists the tags
+func (c *SonarrClient) GetTags(ctx context.Context) ([]ArrTag, error) {
+	return listArrTags(ctx, c.doRequest)
 }
 
-// AddSeriesByName searches for a series by name and adds it to Sonarr
-func (c *SonarrClient) AddSeriesByName(searchTerm string) (*SonarrSeries, error) {
-	// Search for the series
-	results, err := c.SearchSeries(searchTerm)
+// GetLanguageProfiles lists the language profiles, which only Sonarr v3 has
+func (c *SonarrClient) GetLanguageProfiles(ctx context.Context) ([]SonarrLanguageProfile, error) {
+	respBody, err := c.doRequest(ctx, "GET", "/api/v3/languageprofile", nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search series: %w", err)
+		return nil, err
 	}
 
-	if len(results) == 0 {
-		return nil, fmt.Errorf("series not found: %s", searchTerm)
+	var profiles []SonarrLanguageProfile
+	if err := json.Unmarshal(respBody, &profiles); err != nil {
+		return nil, err
 	}
+	return profiles, nil
+}
 
-	// Get first result
-	searchResult := results[0]
+// languageProfileID returns the language profile named after language, or
+// else the one whose cutoff is language; 0 if there is none
+func (c *SonarrClient) languageProfileID(ctx context.Context, language string) int {
+	profiles, err := c.GetLanguageProfiles(ctx)
+	if err != nil {
+		log.Printf("Warning: could not list Sonarr language profiles: %v", err)
+		return 0
+	}
+	for _, profile := range profiles {
+		if strings.EqualFold(profile.Name, language) {
+			log.Printf("Using language profile %s", profile.Name)
+			return profile.ID
+		}
+	}
+	for _, profile := range profiles {
+		if strings.EqualFold(profile.Cutoff.Name, language) {
+			log.Printf("Using language profile %s for %s audio", profile.Name, language)
+			return profile.ID
+		}
+	}
+	return 0
+}
 
-	// Get root folder
-	folders, err := c.GetRootFolders()
+// seriesPath checks the folder Sonarr would create for a new series against the
+// other series in the root folder. Sonarr rejects a clash with an opaque 400, so
+// it is reported as PATH_CONFLICT, or with SONARR_PATH_CONFLICT=suffix the
+// series gets its own folder. "" leaves the folder to Sonarr.
+func (c *SonarrClient) seriesPath(ctx context.Context, searchResult *SonarrSearchResult, rootFolder string) (string, error) {
+	if searchResult.Folder == "" {
+		return "", nil
+	}
+	existing, err := c.GetAllSeries(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get root folders: %w", err)
+		return "", fmt.Errorf("failed to list series: %w", err)
 	}
-	if len(folders) == 0 {
-		return nil, fmt.Errorf("no root folders configured in Sonarr")
+
+	key := seriesFolderKey(searchResult.Folder)
+	for _, series := range existing {
+		parent, name := splitSeriesPath(series.Path)
+		// The same show is reported by Sonarr as already added
+		if series.TVDBID == searchResult.TVDBID || seriesFolderKey(name) != key || !sameFolder(parent, rootFolder) {
+			continue
+		}
+		if c.pathConflict != PathConflictSuffix {
+			return "", newAPIError(ErrCodePathConflict, "the Sonarr folder %q for %s clashes with %s (series %d) at %s", searchResult.Folder, searchResult.Title, series.Title, series.ID, series.Path)
+		}
+		name = fmt.Sprintf("%s [tvdbid-%d]", searchResult.Folder, searchResult.TVDBID)
+		return strings.TrimRight(rootFolder, `/\`) + pathSeparator(rootFolder) + name, nil
+	}
+	return "", nil
+}
+
+// seriesFolderKey reduces a folder name to what near-identical folders share,
+// e.g. "Show & Co (2020)" and "show and co 2020"
+func seriesFolderKey(name string) string {
+	return normalizeTitle(name)
+}
+
+// splitSeriesPath splits a Sonarr path, which may be a Windows path, into its
+// parent folder and last element
+func splitSeriesPath(path string) (parent, name string) {
+	path = strings.TrimRight(path, `/\`)
+	i := strings.LastIndexAny(path, `/\`)
+	if i < 0 {
+		return "", path
 	}
+	return path[:i], path[i+1:]
+}
 
-	// Get quality profile
-	profiles, err := c.GetQualityProfiles()
+func sameFolder(a, b string) bool {
+	return strings.EqualFold(strings.TrimRight(a, `/\`), strings.TrimRight(b, `/\`))
+}
+
+// pathSeparator is a backslash for Windows root folders like D:\TV
+func pathSeparator(rootFolder string) string {
+	if strings.Contains(rootFolder, `\`) {
+		return `\`
+	}
+	return "/"
+}
+
+// latestSeasonOnly marks only the highest numbered regular season as monitored
+func latestSeasonOnly(seasons []SonarrSeason) []SonarrSeason {
+	latest := 0
+	for _, season := range seasons {
+		if season.SeasonNumber > latest {
+			latest = season.SeasonNumber
+		}
+	}
+
+	result := make([]SonarrSeason, len(seasons))
+	for i, season := range seasons {
+		result[i] = SonarrSeason{SeasonNumber: season.SeasonNumber, Monitored: season.SeasonNumber == latest}
+	}
+	return result
+}
+
+// regularSeasons marks every season but specials (season 0) as monitored
+func regularSeasons(seasons []SonarrSeason) []SonarrSeason {
+	result := make([]SonarrSeason, len(seasons))
+	for i, season := range seasons {
+		result[i] = SonarrSeason{SeasonNumber: season.SeasonNumber, Monitored: season.SeasonNumber > 0}
+	}
+	return result
+}
+
+// SonarrManualImportItem is a file Sonarr found in a folder, with the episodes it
+// matched. Quality and languages are passed back to the import unchanged.
+type SonarrManualImportItem struct {
+	Path         string          `json:"path"`
+	SeasonNumber *int            `json:"seasonNumber,omitempty"`
+	Episodes     []SonarrEpisode `json:"episodes"`
+	Quality      json.RawMessage `json:"quality,omitempty"`
+	Languages    json.RawMessage `json:"languages,omitempty"`
+	ReleaseGroup string          `json:"releaseGroup,omitempty"`
+	Rejections   []struct {
+		Reason string `json:"reason"`
+	} `json:"rejections,omitempty"`
+}
+
+// GetManualImport lists the video files in folder matched against a series
+func (c *SonarrClient) GetManualImport(ctx context.Context, folder string, seriesID int) ([]SonarrManualImportItem, error) {
+	endpoint := fmt.Sprintf("/api/v3/manualimport?folder=%s&seriesId=%d&filterExistingFiles=true", url.QueryEscape(folder), seriesID)
+	respBody, err := c.doRequest(ctx, "GET", endpoint, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get quality profiles: %w", err)
+		return nil, err
 	}
-	if len(profiles) == 0 {
-		return nil, fmt.Errorf("no quality profiles configured in Sonarr")
+
+	var items []SonarrManualImportItem
+	if err := json.Unmarshal(respBody, &items); err != nil {
+		return nil, err
 	}
+	return items, nil
+}
 
-	// Create series
-	series := SonarrSeries{
-		Title:            searchResult.Title,
-		TitleSlug:        searchResult.TitleSlug,
-		Year:             searchResult.Year,
-		TVDBID:           searchResult.TVDBID,
-		QualityProfileID: profiles[0].ID,
-		RootFolderPath:   folders[0].Path,
-		Monitored:        true,
-		SeasonFolder:     true,
-		SeriesType:       "standard",
-		AddOptions: &SonarrAddOptions{
-			SearchForMissingEpisodes:     true, // Search for episodes after adding
-			SearchForCutoffUnmetEpisodes: false,
-			Monitor:                      "all",
-		},
+// ManualImport queues a ManualImport command for items of a series. importMode is
+// "copy" (the torrent keeps seeding) or "move".
+func (c *SonarrClient) ManualImport(ctx context.Context, seriesID int, downloadID, importMode string, items []SonarrManualImportItem) error {
+	type importFile struct {
+		Path         string          `json:"path"`
+		SeriesID     int             `json:"seriesId"`
+		EpisodeIDs   []int           `json:"episodeIds"`
+		Quality      json.RawMessage `json:"quality,omitempty"`
+		Languages    json.RawMessage `json:"languages,omitempty"`
+		ReleaseGroup string          `json:"releaseGroup,omitempty"`
+		DownloadID   string          `json:"downloadId,omitempty"`
+	}
+
+	files := make([]importFile, 0, len(items))
+	for _, item := range items {
+		file := importFile{
+			Path:         item.Path,
+			SeriesID:     seriesID,
+			Quality:      item.Quality,
+			Languages:    item.Languages,
+			ReleaseGroup: item.ReleaseGroup,
+			DownloadID:   downloadID,
+		}
+		for _, episode := range item.Episodes {
+			file.EpisodeIDs = append(file.EpisodeIDs, episode.ID)
+		}
+		files = append(files, file)
 	}
 
-	return c.AddSeries(series)
+	_, err := c.doRequest(ctx, "POST", "/api/v3/command", map[string]interface{}{
+		"name":       "ManualImport",
+		"importMode": importMode,
+		"files":      files,
+	})
+	return err
+}
+
+// TriggerSearch starts Sonarr's search for the missing episodes of a library series
+func (c *SonarrClient) TriggerSearch(ctx context.Context, seriesID int) error {
+	_, err := c.doRequest(ctx, "POST", "/api/v3/command", map[string]interface{}{
+		"name":     "SeriesSearch",
+		"seriesId": seriesID,
+	})
+	return err
 }
 
 // cleanSeriesName removes quality tags, season/episode info from torrent names
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxStatsReleaseGroups limits the release groups GET /api/stats lists
+const maxStatsReleaseGroups = 10
+
+// otherFailureCode counts failures without an error code
+const otherFailureCode = "OTHER"
+
+// AddCounts is the outcome of a set of adds
+type AddCounts struct {
+	Adds        int     `json:"adds"`
+	Succeeded   int     `json:"succeeded"`
+	Failed      int     `json:"failed"`
+	SuccessRate float64 `json:"success_rate"`
+}
+
+func (c *AddCounts) count(record HistoryRecord) {
+	c.Adds++
+	if record.Status == HistoryStatusFailed {
+		c.Failed++
+	} else {
+		c.Succeeded++
+	}
+	c.SuccessRate = ratio(c.Succeeded, c.Adds)
+}
+
+// ReleaseGroupStats is the adds of torrents from one release group
+type ReleaseGroupStats struct {
+	Group string `json:"group"`
+	AddCounts
+}
+
+// LibraryAddStats is how many torrents classified as a movie or series made it
+// into Radarr/Sonarr
+type LibraryAddStats struct {
+	Attempted int     `json:"attempted"` // succeeded torrent adds classified as a movie or series
+	Added     int     `json:"added"`
+	Pending   int     `json:"pending"` // waiting for Radarr/Sonarr to come back (libraryretry worker)
+	HitRate   float64 `json:"hit_rate"`
+}
+
+type StatsResponse struct {
+	Success       bool                 `json:"success"`
+	Message       string               `json:"message"`
+	Total         AddCounts            `json:"total"`
+	ByType        map[string]AddCounts `json:"by_type,omitempty"`        // "movie", "tv" or "other" for non-media and unclassified adds
+	BySource      map[string]AddCounts `json:"by_source,omitempty"`      // "torrent", "media", "rss" or "watch"
+	FailureCodes  map[string]int       `json:"failure_codes,omitempty"`  // error codes, "OTHER" for failures without one
+	ReleaseGroups []ReleaseGroupStats  `json:"release_groups,omitempty"` // most added first
+	LibraryAdd    LibraryAddStats      `json:"library_add"`
+}
+
+// addStats aggregates the finished history adds since since
+func addStats(records []HistoryRecord, since time.Time) StatsResponse {
+	stats := StatsResponse{
+		ByType:       make(map[string]AddCounts),
+		BySource:     make(map[string]AddCounts),
+		FailureCodes: make(map[string]int),
+	}
+	groups := make(map[string]*ReleaseGroupStats)
+	for _, record := range records {
+		if record.Status == HistoryStatusProcessing || record.AddedAt.Before(since) {
+			continue
+		}
+		stats.Total.count(record)
+
+		kind := record.MediaType
+		if kind == "" {
+			kind = "other"
+		}
+		counts := stats.ByType[kind]
+		counts.count(record)
+		stats.ByType[kind] = counts
+
+		source := record.Source
+		if source == "" {
+			source = "torrent"
+		}
+		counts = stats.BySource[source]
+		counts.count(record)
+		stats.BySource[source] = counts
+
+		if record.Status == HistoryStatusFailed {
+			code := record.Code
+			if code == "" {
+				code = otherFailureCode
+			}
+			stats.FailureCodes[code]++
+		}
+
+		// Title adds have no release, and no library add to miss
+		if source == "media" {
+			continue
+		}
+		if group := ExtractMovieInfo(record.Name).Group; group != "" {
+			if groups[group] == nil {
+				groups[group] = &ReleaseGroupStats{Group: group}
+			}
+			groups[group].count(record)
+		}
+		if record.Status != HistoryStatusFailed && record.MediaType != "" {
+			stats.LibraryAdd.Attempted++
+			switch {
+			case record.MediaID != 0:
+				stats.LibraryAdd.Added++
+			case record.LibraryRetry:
+				stats.LibraryAdd.Pending++
+			}
+		}
+	}
+	stats.LibraryAdd.HitRate = ratio(stats.LibraryAdd.Added, stats.LibraryAdd.Attempted)
+
+	for _, group := range groups {
+		stats.ReleaseGroups = append(stats.ReleaseGroups, *group)
+	}
+	sort.Slice(stats.ReleaseGroups, func(i, j int) bool {
+		if stats.ReleaseGroups[i].Adds != stats.ReleaseGroups[j].Adds {
+			return stats.ReleaseGroups[i].Adds > stats.ReleaseGroups[j].Adds
+		}
+		return stats.ReleaseGroups[i].Group < stats.ReleaseGroups[j].Group
+	})
+	if len(stats.ReleaseGroups) > maxStatsReleaseGroups {
+		stats.ReleaseGroups = stats.ReleaseGroups[:maxStatsReleaseGroups]
+	}
+	return stats
+}
+
+// Stats reports add counts by type and source, failure codes, the most common
+// release groups and the library add hit rate from the history, for tuning the
+// detector. ?days= limits it to recent adds.
+func (h *TorrentHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(StatsResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+	if h.history == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(StatsResponse{
+			Success: false,
+			Message: "History is not enabled",
+		})
+		return
+	}
+
+	var since time.Time
+	if value := r.URL.Query().Get("days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(StatsResponse{
+				Success: false,
+				Message: "days must be a positive number",
+			})
+			return
+		}
+		since = time.Now().AddDate(0, 0, -days)
+	}
+
+	stats := addStats(h.history.List(), since)
+	stats.Success, stats.Message = true, "OK"
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// Radarr and Sonarr only accept lowercase letters, digits and dashes in tag labels
+var tagLabelInvalidChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// requesterTag returns the tag label for an API key name, e.g. "req-alice"
+func requesterTag(prefix, name string) string {
+	label := tagLabelInvalidChars.ReplaceAllString(strings.ToLower(prefix+name), "-")
+	return strings.Trim(label, "-")
+}
+
+// tagRequester tags the movie or series with the requesting API key's name when
+// REQUESTER_TAGS is on. Requests without a key are not tagged.
+func (h *TorrentHandler) tagRequester(ctx context.Context, isMovie bool, mediaID int) error {
+	config := h.cfg()
+	key := apiKeyFromContext(ctx)
+	if !config.RequesterTags || key == nil || mediaID == 0 {
+		return nil
+	}
+	label := requesterTag(config.RequesterTagPrefix, key.Name)
+
+	if isMovie {
+		tagID, err := h.radarrClient.EnsureTag(ctx, label)
+		if err != nil {
+			return err
+		}
+		return h.radarrClient.AddMovieTag(ctx, mediaID, tagID)
+	}
+	tagID, err := h.sonarrClient.EnsureTag(ctx, label)
+	if err != nil {
+		return err
+	}
+	return h.sonarrClient.AddSeriesTag(ctx, mediaID, tagID)
+}
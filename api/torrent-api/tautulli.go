@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TautulliClient reads Plex watch history through Tautulli's API
+type TautulliClient struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// WatchRecord is the most recent completed watch of a title
+type WatchRecord struct {
+	Title     string    `json:"title"`
+	Year      int       `json:"year,omitempty"`
+	User      string    `json:"user"`
+	WatchedAt time.Time `json:"watched_at"`
+}
+
+type tautulliHistoryRow struct {
+	Title            string       `json:"title"`
+	GrandparentTitle string       `json:"grandparent_title"`
+	Year             tautulliYear `json:"year"`
+	User             string       `json:"user"`
+	FriendlyName     string       `json:"friendly_name"`
+	Date             int64        `json:"date"`
+	MediaType        string       `json:"media_type"`
+	WatchedStatus    float64      `json:"watched_status"`
+}
+
+// tautulliYear is a history row's year, which Tautulli sends as a number, a
+// string, "" or null; the last two are 0
+type tautulliYear int
+
+func (y *tautulliYear) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*y = 0
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid year %s", data)
+	}
+	*y = tautulliYear(n)
+	return nil
+}
+
+type tautulliHistoryResponse struct {
+	Response struct {
+		Result  string `json:"result"`
+		Message string `json:"message"`
+		Data    struct {
+			Data []tautulliHistoryRow `json:"data"`
+		} `json:"data"`
+	} `json:"response"`
+}
+
+func NewTautulliClient(baseURL, apiKey string) *TautulliClient {
+	return &TautulliClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		httpClient: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: newTracingTransport(),
+		},
+	}
+}
+
+// FindWatched returns the latest fully watched play of a movie (isMovie) or any
+// episode of a series, or nil if the household hasn't watched it
+func (c *TautulliClient) FindWatched(ctx context.Context, title string, year int, isMovie bool) (*WatchRecord, error) {
+	params := url.Values{}
+	params.Set("apikey", c.apiKey)
+	params.Set("cmd", "get_history")
+	params.Set("search", title)
+	params.Set("length", "50")
+	params.Set("order_column", "date")
+	params.Set("order_dir", "desc")
+	if isMovie {
+		params.Set("media_type", "movie")
+	} else {
+		params.Set("media_type", "episode")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/v2?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Tautulli: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Tautulli API error: status %d", resp.StatusCode)
+	}
+
+	var result tautulliHistoryResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if result.Response.Result != "success" {
+		return nil, fmt.Errorf("Tautulli API error: %s", result.Response.Message)
+	}
+
+	for _, row := range result.Response.Data.Data {
+		// Only count plays that were watched to the end
+		if row.WatchedStatus < 1 {
+			continue
+		}
+
+		rowTitle := row.Title
+		if !isMovie {
+			rowTitle = row.GrandparentTitle
+		}
+		if !strings.EqualFold(rowTitle, title) {
+			continue
+		}
+
+		rowYear := int(row.Year)
+		if isMovie && year != 0 && rowYear != 0 && rowYear != year {
+			continue
+		}
+
+		user := row.FriendlyName
+		if user == "" {
+			user = row.User
+		}
+		return &WatchRecord{
+			Title:     rowTitle,
+			Year:      rowYear,
+			User:      user,
+			WatchedAt: time.Unix(row.Date, 0),
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// watchedWarning formats a watch record as a response warning
+func watchedWarning(record *WatchRecord) string {
+	return fmt.Sprintf("%s watched %s in %d", record.User, record.Title, record.WatchedAt.Year())
+}
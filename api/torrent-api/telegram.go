@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const telegramAPIURL = "https://api.telegram.org"
+
+// Telegram allows about one message edit per second in a chat
+const telegramEditInterval = 1500 * time.Millisecond
+
+// Match choices can be picked for this long
+const telegramChoiceTTL = 15 * time.Minute
+
+// TelegramBot adds magnet links and titles sent to it in the allowed chats.
+// Ambiguous titles are answered with an inline keyboard of the matches, and the
+// bot's message is edited as the add progresses.
+type TelegramBot struct {
+	baseURL    string
+	token      string
+	chats      map[int64]bool
+	handler    *TorrentHandler
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	choices map[string]*telegramChoice
+	nextID  int
+}
+
+// telegramChoice is a keyboard of matches waiting for the user's pick
+type telegramChoice struct {
+	name       string
+	candidates []botCandidate
+	created    time.Time
+}
+
+type TelegramChat struct {
+	ID int64 `json:"id"`
+}
+
+type TelegramUser struct {
+	ID    int64 `json:"id"`
+	IsBot bool  `json:"is_bot"`
+}
+
+type TelegramMessage struct {
+	MessageID int64         `json:"message_id"`
+	Chat      TelegramChat  `json:"chat"`
+	From      *TelegramUser `json:"from,omitempty"`
+	Text      string        `json:"text"`
+}
+
+type TelegramCallbackQuery struct {
+	ID      string           `json:"id"`
+	Data    string           `json:"data"`
+	Message *TelegramMessage `json:"message,omitempty"`
+}
+
+type TelegramUpdate struct {
+	UpdateID      int64                  `json:"update_id"`
+	Message       *TelegramMessage       `json:"message,omitempty"`
+	CallbackQuery *TelegramCallbackQuery `json:"callback_query,omitempty"`
+}
+
+type telegramInlineKeyboard struct {
+	InlineKeyboard [][]telegramButton `json:"inline_keyboard"`
+}
+
+type telegramButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
+}
+
+// parseTelegramChats parses the comma-separated TELEGRAM_CHAT_IDS
+func parseTelegramChats(spec string) (map[int64]bool, error) {
+	chats := make(map[int64]bool)
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chat ID %q", field)
+		}
+		chats[id] = true
+	}
+	if len(chats) == 0 {
+		return nil, fmt.Errorf("at least one chat ID is required")
+	}
+	return chats, nil
+}
+
+// NewTelegramBot creates a bot that only answers in chats
+func NewTelegramBot(token string, chats map[int64]bool, handler *TorrentHandler) *TelegramBot {
+	return &TelegramBot{
+		baseURL: telegramAPIURL,
+		token:   token,
+		chats:   chats,
+		handler: handler,
+		httpClient: &http.Client{
+			// Above the getUpdates long-poll timeout
+			Timeout:   60 * time.Second,
+			Transport: newTracingTransport(),
+		},
+		choices: make(map[string]*telegramChoice),
+	}
+}
+
+// call invokes a Bot API method and decodes its result
+func (b *TelegramBot) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	jsonBody, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.baseURL+"/bot"+b.token+"/"+method, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		// The URL contains the token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to execute %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 10<<20)).Decode(&envelope); err != nil {
+		return fmt.Errorf("failed to decode %s response (status %d): %w", method, resp.StatusCode, err)
+	}
+	if !envelope.OK {
+		return fmt.Errorf("telegram %s failed with status %d: %s", method, resp.StatusCode, envelope.Description)
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(envelope.Result, result); err != nil {
+			return fmt.Errorf("failed to decode %s result: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// Poll long-polls for updates until ctx is done
+func (b *TelegramBot) Poll(ctx context.Context) {
+	var offset int64
+	for ctx.Err() == nil {
+		var updates []TelegramUpdate
+		err := b.call(ctx, "getUpdates", map[string]interface{}{
+			"offset":          offset,
+			"timeout":         30,
+			"allowed_updates": []string{"message", "callback_query"},
+		}, &updates)
+		if err != nil {
+			log.Printf("Warning: could not get Telegram updates: %v", err)
+			sleepContext(ctx, 5*time.Second)
+			continue
+		}
+
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			switch {
+			case update.Message != nil:
+				go b.handleMessage(ctx, update.Message)
+			case update.CallbackQuery != nil:
+				go b.handleCallback(ctx, update.CallbackQuery)
+			}
+		}
+	}
+}
+
+// handleMessage starts an add for a magnet link, command or title
+func (b *TelegramBot) handleMessage(ctx context.Context, msg *TelegramMessage) {
+	if !b.chats[msg.Chat.ID] {
+		log.Printf("Ignoring Telegram message from chat %d, not in TELEGRAM_CHAT_IDS", msg.Chat.ID)
+		return
+	}
+	if (msg.From != nil && msg.From.IsBot) || strings.TrimSpace(msg.Text) == "" {
+		return
+	}
+
+	req, ok := parseBotMessage(msg.Text)
+	mediaType := ""
+	switch {
+	case !ok && strings.HasPrefix(msg.Text, "/"):
+		// Other commands, e.g. /start
+		b.send(ctx, msg, "Send a magnet link or a title, or use /add_movie and /add_tv.", nil)
+		return
+	case !ok:
+		req = newBotMediaRequest("", msg.Text, "")
+	case req.Command == BotCommandAddMovie:
+		mediaType = "movie"
+	case req.Command == BotCommandAddTV:
+		mediaType = "tv"
+	}
+
+	if req.Command == BotCommandMagnet || req.Name == "" {
+		reply, err := b.send(ctx, msg, "Working on it…", nil)
+		if err == nil {
+			b.run(ctx, msg.Chat.ID, reply.MessageID, req)
+		}
+		return
+	}
+
+	// A series picked for this title before is added without asking again
+	if mediaType != "movie" {
+		if series := b.handler.rememberedSeries(ctx, req.Name, req.Year); series != nil {
+			req.Candidate = &botCandidate{Card: seriesCard(series), Series: series}
+			reply, err := b.send(ctx, msg, "Adding "+req.Candidate.Card.Heading()+"…", nil)
+			if err == nil {
+				b.run(ctx, msg.Chat.ID, reply.MessageID, req)
+			}
+			return
+		}
+	}
+
+	candidates, exact, err := b.handler.findBotCandidates(ctx, req.Name, req.Year, mediaType, maxBotCandidates)
+	if err != nil {
+		b.send(ctx, msg, "Failed to look up "+req.Name+": "+err.Error(), nil)
+		return
+	}
+	if len(candidates) == 0 {
+		b.send(ctx, msg, "Nothing found for "+req.Name, nil)
+		return
+	}
+
+	// One exact (or only one) match is added straight away
+	if exact == 1 || len(candidates) == 1 {
+		reply, err := b.send(ctx, msg, "Adding "+candidates[0].Card.Heading()+"…", nil)
+		if err == nil {
+			req.Candidate = &candidates[0]
+			b.run(ctx, msg.Chat.ID, reply.MessageID, req)
+		}
+		return
+	}
+
+	id := b.storeChoice(req.Name, candidates)
+	keyboard := &telegramInlineKeyboard{}
+	for i, c := range candidates {
+		icon := "🎬 "
+		if c.Series != nil {
+			icon = "📺 "
+		}
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []telegramButton{
+			{Text: icon + c.Card.Heading(), CallbackData: id + ":" + strconv.Itoa(i)},
+		})
+	}
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []telegramButton{{Text: "Cancel", CallbackData: id + ":cancel"}})
+	b.send(ctx, msg, "Which one did you mean?", keyboard)
+}
+
+// handleCallback adds the match picked on an inline keyboard, editing the keyboard message
+func (b *TelegramBot) handleCallback(ctx context.Context, query *TelegramCallbackQuery) {
+	if query.Message == nil || !b.chats[query.Message.Chat.ID] {
+		return
+	}
+	// Stop the button's loading spinner
+	if err := b.call(ctx, "answerCallbackQuery", map[string]interface{}{"callback_query_id": query.ID}, nil); err != nil {
+		log.Printf("Warning: could not answer Telegram callback: %v", err)
+	}
+
+	chatID, messageID := query.Message.Chat.ID, query.Message.MessageID
+	id, pick, _ := strings.Cut(query.Data, ":")
+	choice := b.takeChoice(id)
+	if choice == nil {
+		b.edit(ctx, chatID, messageID, "This choice has expired, send the title again.")
+		return
+	}
+	index, err := strconv.Atoi(pick)
+	if err != nil || index < 0 || index >= len(choice.candidates) {
+		b.edit(ctx, chatID, messageID, "Cancelled.")
+		return
+	}
+
+	candidate := choice.candidates[index]
+	if candidate.Series != nil {
+		b.handler.seriesChoices.Remember(choice.name, candidate.Series)
+	}
+	b.edit(ctx, chatID, messageID, "Adding "+candidate.Card.Heading()+"…")
+	b.run(ctx, chatID, messageID, botRequest{Name: choice.name, Candidate: &candidate})
+}
+
+// run executes a bot request, editing messageID with progress and the result
+func (b *TelegramBot) run(ctx context.Context, chatID, messageID int64, req botRequest) {
+	b.handler.runBotRequest(ctx, req, throttleUpdates(telegramEditInterval, func(update botUpdate) {
+		b.edit(ctx, chatID, messageID, telegramText(update))
+	}))
+}
+
+// telegramText renders an update with the match card below it
+func telegramText(update botUpdate) string {
+	text := botUpdateText(update)
+	if card := update.Card; card != nil {
+		text += "\n\n" + card.Heading()
+		if card.Overview != "" {
+			text += "\n" + truncate(card.Overview, 350)
+		}
+		if card.Link != "" {
+			text += "\n" + card.Link
+		}
+	}
+	return truncate(text, 4096)
+}
+
+func (b *TelegramBot) send(ctx context.Context, replyTo *TelegramMessage, text string, keyboard *telegramInlineKeyboard) (*TelegramMessage, error) {
+	params := map[string]interface{}{
+		"chat_id":             replyTo.Chat.ID,
+		"text":                text,
+		"reply_to_message_id": replyTo.MessageID,
+	}
+	if keyboard != nil {
+		params["reply_markup"] = keyboard
+	}
+
+	var sent TelegramMessage
+	if err := b.call(ctx, "sendMessage", params, &sent); err != nil {
+		log.Printf("Warning: could not reply on Telegram: %v", err)
+		return nil, err
+	}
+	return &sent, nil
+}
+
+// edit replaces a message's text, removing any inline keyboard
+func (b *TelegramBot) edit(ctx context.Context, chatID, messageID int64, text string) {
+	err := b.call(ctx, "editMessageText", map[string]interface{}{
+		"chat_id":    chatID,
+		"message_id": messageID,
+		"text":       text,
+	}, nil)
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		log.Printf("Warning: could not update Telegram message: %v", err)
+	}
+}
+
+// storeChoice keeps candidates for a keyboard and returns its callback ID
+func (b *TelegramBot) storeChoice(name string, candidates []botCandidate) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for id, choice := range b.choices {
+		if time.Since(choice.created) > telegramChoiceTTL {
+			delete(b.choices, id)
+		}
+	}
+	b.nextID++
+	id := strconv.Itoa(b.nextID)
+	b.choices[id] = &telegramChoice{name: name, candidates: candidates, created: time.Now()}
+	return id
+}
+
+// takeChoice removes and returns a pending choice, or nil if unknown or expired
+func (b *TelegramBot) takeChoice(id string) *telegramChoice {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	choice := b.choices[id]
+	delete(b.choices, id)
+	if choice == nil || time.Since(choice.created) > telegramChoiceTTL {
+		return nil
+	}
+	return choice
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Nesting deeper than this is no .torrent file
+const maxBencodeDepth = 64
+
+// bdecoder reads the bencoded metainfo of a .torrent file, keeping the raw
+// bytes of the top-level info dictionary, which the info hash is taken over
+type bdecoder struct {
+	data  []byte
+	pos   int
+	depth int
+	info  []byte
+}
+
+func (d *bdecoder) value() (interface{}, error) {
+	if d.pos >= len(d.data) {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		end := bytes.IndexByte(d.data[d.pos:], 'e')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated integer at %d", d.pos)
+		}
+		n, err := strconv.ParseInt(string(d.data[d.pos+1:d.pos+end]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer at %d", d.pos)
+		}
+		d.pos += end + 1
+		return n, nil
+	case c == 'l' || c == 'd':
+		if d.depth++; d.depth > maxBencodeDepth {
+			return nil, fmt.Errorf("nested too deeply")
+		}
+		defer func() { d.depth-- }()
+		d.pos++
+		if c == 'l' {
+			var list []interface{}
+			for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+				v, err := d.value()
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			d.pos++
+			return list, nil
+		}
+		dict := make(map[string]interface{})
+		for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+			key, err := d.str()
+			if err != nil {
+				return nil, err
+			}
+			start := d.pos
+			v, err := d.value()
+			if err != nil {
+				return nil, err
+			}
+			if key == "info" && d.depth == 1 {
+				d.info = d.data[start:d.pos]
+			}
+			dict[key] = v
+		}
+		d.pos++
+		return dict, nil
+	case c >= '0' && c <= '9':
+		return d.str()
+	}
+	return nil, fmt.Errorf("invalid value at %d", d.pos)
+}
+
+func (d *bdecoder) str() (string, error) {
+	colon := bytes.IndexByte(d.data[d.pos:], ':')
+	if colon < 0 {
+		return "", fmt.Errorf("invalid string at %d", d.pos)
+	}
+	n, err := strconv.Atoi(string(d.data[d.pos : d.pos+colon]))
+	start := d.pos + colon + 1
+	if err != nil || n < 0 || n > len(d.data)-start {
+		return "", fmt.Errorf("invalid string at %d", d.pos)
+	}
+	d.pos = start + n
+	return string(d.data[start:d.pos]), nil
+}
+
+// torrentFile is the part of a .torrent file's metainfo the add pipeline uses
+type torrentFile struct {
+	hash     [sha1.Size]byte // v1 info hash
+	name     string
+	trackers []string
+}
+
+// parseTorrentFile reads the v1 info hash, name and trackers of a .torrent file
+func parseTorrentFile(data []byte) (*torrentFile, error) {
+	d := &bdecoder{data: data}
+	v, err := d.value()
+	if err != nil {
+		return nil, fmt.Errorf("invalid torrent file: %w", err)
+	}
+	meta, ok := v.(map[string]interface{})
+	if !ok || d.info == nil {
+		return nil, fmt.Errorf("invalid torrent file: no info dictionary")
+	}
+	info, _ := meta["info"].(map[string]interface{})
+	if _, ok := info["pieces"]; !ok {
+		// v2-only torrents have no v1 info hash for a btih magnet
+		return nil, fmt.Errorf("v2-only torrent files are not supported")
+	}
+
+	t := &torrentFile{hash: sha1.Sum(d.info)}
+	t.name, _ = info["name"].(string)
+	seen := make(map[string]bool)
+	addTracker := func(v interface{}) {
+		if tracker, _ := v.(string); tracker != "" && !seen[tracker] {
+			seen[tracker] = true
+			t.trackers = append(t.trackers, tracker)
+		}
+	}
+	addTracker(meta["announce"])
+	tiers, _ := meta["announce-list"].([]interface{})
+	for _, tier := range tiers {
+		trackers, _ := tier.([]interface{})
+		for _, tracker := range trackers {
+			addTracker(tracker)
+		}
+	}
+	return t, nil
+}
+
+// magnet returns a magnet link for the file. Without trackers it has only the
+// info hash and name: that is the link that gets recorded, as a private
+// tracker's announce URL carries the user's passkey.
+func (t *torrentFile) magnet(withTrackers bool) string {
+	params := url.Values{}
+	if t.name != "" {
+		params.Set("dn", t.name)
+	}
+	if withTrackers && len(t.trackers) > 0 {
+		params["tr"] = t.trackers
+	}
+
+	magnetLink := "magnet:?xt=urn:btih:" + hex.EncodeToString(t.hash[:])
+	if len(params) > 0 {
+		magnetLink += "&" + params.Encode()
+	}
+	return magnetLink
+}
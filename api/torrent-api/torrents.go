@@ -0,0 +1,506 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// qBittorrent tracker status codes
+var trackerStatusNames = map[int]string{
+	0: "disabled",
+	1: "not_contacted",
+	2: "working",
+	3: "updating",
+	4: "not_working",
+}
+
+// TorrentTracker is a tracker of a torrent in qBittorrent
+type TorrentTracker struct {
+	URL      string `json:"url"`
+	Status   string `json:"status"` // "working", "not_working", "not_contacted", "updating" or "disabled"
+	Tier     int    `json:"tier"`
+	Seeds    int    `json:"seeds"`
+	Leechers int    `json:"leechers"`
+	Message  string `json:"message,omitempty"`
+}
+
+// TrackerEditRequest is the body of POST and DELETE /api/torrent/{hash}/trackers
+type TrackerEditRequest struct {
+	URLs       []string `json:"urls"`
+	Reannounce bool     `json:"reannounce,omitempty"` // announce to the trackers right after adding them
+}
+
+// TorrentDeleteRequest is the optional body of DELETE /api/torrent/{hash}
+type TorrentDeleteRequest struct {
+	DeleteFiles bool   `json:"delete_files,omitempty"` // Delete the downloaded files too
+	Library     string `json:"library,omitempty"`      // "unmonitor" or "remove" the movie/series it was added to; default leaves it
+}
+
+type TorrentActionResponse struct {
+	Success  bool             `json:"success"`
+	Message  string           `json:"message"`
+	Hash     string           `json:"hash,omitempty"`
+	Trackers []TorrentTracker `json:"trackers,omitempty"`
+	Warnings []string         `json:"warnings,omitempty"`
+}
+
+// qBittorrent state filters accepted by GET /api/torrents
+var torrentFilters = map[string]bool{
+	"all": true, "downloading": true, "seeding": true, "completed": true, "paused": true, "stopped": true,
+	"active": true, "inactive": true, "resumed": true, "running": true, "stalled": true,
+	"stalled_uploading": true, "stalled_downloading": true, "errored": true,
+}
+
+// TorrentStatus is a torrent's download status as GET /api/torrents shows it
+type TorrentStatus struct {
+	Name          string  `json:"name"`
+	Hash          string  `json:"hash"`
+	Category      string  `json:"category,omitempty"`
+	State         string  `json:"state"`         // qBittorrent's state, e.g. "downloading" or "stalledDL"
+	Progress      float64 `json:"progress"`      // 0 to 1
+	ETA           *int64  `json:"eta,omitempty"` // seconds; missing when qBittorrent can't tell
+	Size          int64   `json:"size"`
+	DownloadSpeed int64   `json:"download_speed"` // bytes/s
+}
+
+type TorrentsResponse struct {
+	Success  bool            `json:"success"`
+	Message  string          `json:"message"`
+	Torrents []TorrentStatus `json:"torrents"`
+}
+
+// ListTorrents lists the torrents in qBittorrent with their progress, so the
+// extension can show download status without talking to qBittorrent. It shows
+// the unfinished ones unless ?filter= picks another qBittorrent state filter;
+// ?category= narrows it to one category.
+func (h *TorrentHandler) ListTorrents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(TorrentsResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+
+	filter := r.URL.Query().Get("filter")
+	if filter == "" {
+		filter = "downloading"
+	}
+	if !torrentFilters[filter] {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(TorrentsResponse{
+			Success: false,
+			Message: "Invalid filter: " + filter,
+		})
+		return
+	}
+
+	torrents, err := h.qbClient.ListTorrents(r.Context(), filter, r.URL.Query().Get("category"))
+	if err != nil {
+		log.Printf("Error listing torrents: %v", err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(TorrentsResponse{
+			Success: false,
+			Message: "Failed to list torrents: " + err.Error(),
+		})
+		return
+	}
+
+	list := make([]TorrentStatus, 0, len(torrents))
+	for _, t := range torrents {
+		status := TorrentStatus{
+			Name:          t.Name,
+			Hash:          t.Hash,
+			Category:      t.Category,
+			State:         t.State,
+			Progress:      t.Progress,
+			Size:          t.Size,
+			DownloadSpeed: t.DlSpeed,
+		}
+		if t.ETA >= 0 && t.ETA < qbETAInfinity {
+			eta := t.ETA
+			status.ETA = &eta
+		}
+		list = append(list, status)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TorrentsResponse{
+		Success:  true,
+		Message:  "OK",
+		Torrents: list,
+	})
+}
+
+// TorrentByHash serves actions on a torrent already in qBittorrent:
+//
+//	DELETE /api/torrent/{hash}  {"delete_files": true, "library": "remove"}
+//	POST   /api/torrent/{hash}/reannounce
+//	GET    /api/torrent/{hash}/trackers
+//	POST   /api/torrent/{hash}/trackers  {"urls": [...]}
+//	DELETE /api/torrent/{hash}/trackers  {"urls": [...]}
+func (h *TorrentHandler) TorrentByHash(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/torrent/"), "/")
+	hashPart, action, _ := strings.Cut(rest, "/")
+	hashBytes, err := decodeInfoHash(hashPart)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: "Invalid info hash: use the 40 character hex or 32 character base32 form",
+		})
+		return
+	}
+	hash := hex.EncodeToString(hashBytes)
+
+	switch action {
+	case "":
+		h.deleteTorrent(w, r, hash)
+	case "reannounce":
+		h.reannounceTorrent(w, r, hash)
+	case "trackers":
+		h.torrentTrackers(w, r, hash)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: "Unknown torrent action; use reannounce or trackers",
+		})
+	}
+}
+
+// deleteTorrent removes a torrent from qBittorrent and, on request, unmonitors or
+// removes the movie or series its add went to, to undo an add. Keys that aren't
+// admins may only delete their own adds.
+func (h *TorrentHandler) deleteTorrent(w http.ResponseWriter, r *http.Request, hash string) {
+	// Only accept DELETE requests
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: "Method not allowed. Use DELETE.",
+		})
+		return
+	}
+
+	var req TorrentDeleteRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(TorrentActionResponse{
+				Success: false,
+				Message: "Invalid request body: " + err.Error(),
+			})
+			return
+		}
+	}
+	if req.Library != "" && req.Library != "unmonitor" && req.Library != "remove" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: "Invalid library: use unmonitor or remove",
+		})
+		return
+	}
+
+	if !h.requireOwner(w, r, hash, "delete") || !h.requireTorrent(w, r, hash) {
+		return
+	}
+	var record *HistoryRecord
+	if h.history != nil {
+		record = h.history.LatestAdded(hash)
+	}
+
+	if err := h.qbClient.DeleteTorrent(r.Context(), hash, req.DeleteFiles); err != nil {
+		log.Printf("Error deleting torrent %s: %v", hash, err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: err.Error(),
+			Hash:    hash,
+		})
+		return
+	}
+	log.Printf("Torrent %s deleted (files: %t)", hash, req.DeleteFiles)
+
+	message := "Torrent deleted"
+	if req.DeleteFiles {
+		message = "Torrent and its files deleted"
+	}
+	var warnings []string
+	libraryDone := false
+	switch {
+	case req.Library == "":
+	case record == nil || record.MediaID == 0:
+		warnings = append(warnings, "No movie or series is recorded for this torrent; the library was left alone")
+	default:
+		if err := h.removeFromLibrary(r.Context(), record, req.Library == "remove"); err != nil {
+			log.Printf("Warning: could not %s %s: %v", req.Library, record.MediaTitle, err)
+			warnings = append(warnings, fmt.Sprintf("Could not %s %s: %v", req.Library, record.MediaTitle, err))
+		} else {
+			libraryDone = true
+			if req.Library == "remove" {
+				message += "; " + record.MediaTitle + " removed from the library"
+			} else {
+				message += "; " + record.MediaTitle + " unmonitored"
+			}
+		}
+	}
+
+	if record != nil {
+		now := time.Now()
+		err := h.history.Update(record.ID, func(r *HistoryRecord) {
+			r.TorrentRemoved = true
+			if libraryDone && req.Library == "remove" {
+				r.Status, r.RemovedAt = HistoryStatusDeleted, &now
+			}
+		})
+		if err != nil {
+			log.Printf("Warning: could not update history: %v", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TorrentActionResponse{
+		Success:  true,
+		Message:  message,
+		Hash:     hash,
+		Warnings: warnings,
+	})
+}
+
+// removeFromLibrary unmonitors, or with remove deletes, the movie or series of
+// a history record. Files already imported are kept either way.
+func (h *TorrentHandler) removeFromLibrary(ctx context.Context, record *HistoryRecord, remove bool) error {
+	switch {
+	case record.MediaType == "movie" && remove:
+		return h.radarrClient.DeleteMovie(ctx, record.MediaID)
+	case record.MediaType == "movie":
+		return h.radarrClient.UnmonitorMovie(ctx, record.MediaID)
+	case remove:
+		return h.sonarrClient.DeleteSeries(ctx, record.MediaID)
+	}
+	return h.sonarrClient.UnmonitorSeries(ctx, record.MediaID)
+}
+
+// requireTorrent writes a 404 or 502 and returns false unless qBittorrent has the torrent
+func (h *TorrentHandler) requireTorrent(w http.ResponseWriter, r *http.Request, hash string) bool {
+	torrent, err := h.qbClient.GetTorrent(r.Context(), hash)
+	if err != nil {
+		log.Printf("Error looking up torrent %s: %v", hash, err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: "Failed to look up the torrent in qBittorrent: " + err.Error(),
+			Hash:    hash,
+		})
+		return false
+	}
+	if torrent == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: "qBittorrent has no torrent with this hash",
+			Hash:    hash,
+		})
+		return false
+	}
+	return true
+}
+
+// requireOwner answers 403 and returns false unless the request's key added the
+// torrent or is an admin key; action names what was refused, e.g. "delete"
+func (h *TorrentHandler) requireOwner(w http.ResponseWriter, r *http.Request, hash, action string) bool {
+	key := apiKeyFromContext(r.Context())
+	if key == nil || key.Admin {
+		return true
+	}
+	var record *HistoryRecord
+	if h.history != nil {
+		record = h.history.LatestAdded(hash)
+	}
+	if record != nil && record.APIKey == key.Name {
+		return true
+	}
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(TorrentActionResponse{
+		Success: false,
+		Message: "Only ADMIN_KEYS can " + action + " torrents other keys added",
+		Hash:    hash,
+	})
+	return false
+}
+
+// reannounceTorrent asks qBittorrent to announce to the torrent's trackers now,
+// instead of waiting out the announce interval of a stalled download
+func (h *TorrentHandler) reannounceTorrent(w http.ResponseWriter, r *http.Request, hash string) {
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: "Method not allowed. Use POST.",
+		})
+		return
+	}
+	if !h.requireOwner(w, r, hash, "reannounce") || !h.requireTorrent(w, r, hash) {
+		return
+	}
+
+	if err := h.qbClient.Reannounce(r.Context(), hash); err != nil {
+		log.Printf("Error reannouncing %s: %v", hash, err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: err.Error(),
+			Hash:    hash,
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TorrentActionResponse{
+		Success: true,
+		Message: "Reannounced to all trackers",
+		Hash:    hash,
+	})
+}
+
+// torrentTrackers lists, adds or removes a torrent's trackers, e.g. to replace
+// the dead trackers a magnet was added with
+func (h *TorrentHandler) torrentTrackers(w http.ResponseWriter, r *http.Request, hash string) {
+	// Only accept GET, POST and DELETE requests
+	if r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET, POST or DELETE.",
+		})
+		return
+	}
+
+	var req TrackerEditRequest
+	if r.Method != http.MethodGet {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(TorrentActionResponse{
+				Success: false,
+				Message: "Invalid request body: " + err.Error(),
+			})
+			return
+		}
+		if message := validateTrackerURLs(req.URLs); message != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(TorrentActionResponse{
+				Success: false,
+				Message: message,
+			})
+			return
+		}
+		if !h.requireOwner(w, r, hash, "edit the trackers of") {
+			return
+		}
+	}
+	if !h.requireTorrent(w, r, hash) {
+		return
+	}
+
+	message := "OK"
+	var err error
+	switch r.Method {
+	case http.MethodPost:
+		if err = h.qbClient.AddTrackers(r.Context(), hash, req.URLs); err == nil && req.Reannounce {
+			err = h.qbClient.Reannounce(r.Context(), hash)
+		}
+		message = "Trackers added"
+		if req.Reannounce {
+			message = "Trackers added and reannounced"
+		}
+	case http.MethodDelete:
+		err = h.qbClient.RemoveTrackers(r.Context(), hash, req.URLs)
+		message = "Trackers removed"
+	}
+	if err != nil {
+		log.Printf("Error editing trackers of %s: %v", hash, err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: err.Error(),
+			Hash:    hash,
+		})
+		return
+	}
+
+	trackers, err := h.qbClient.GetTrackers(r.Context(), hash)
+	if err != nil {
+		log.Printf("Error fetching trackers of %s: %v", hash, err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: "Failed to fetch trackers: " + err.Error(),
+			Hash:    hash,
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TorrentActionResponse{
+		Success:  true,
+		Message:  message,
+		Hash:     hash,
+		Trackers: torrentTrackerList(trackers),
+	})
+}
+
+// validateTrackerURLs returns why the URLs can't be added or removed, or ""
+func validateTrackerURLs(urls []string) string {
+	if len(urls) == 0 {
+		return "urls is required"
+	}
+	for _, tracker := range urls {
+		u, err := url.Parse(tracker)
+		if err != nil || u.Host == "" {
+			return "Invalid tracker URL: " + tracker
+		}
+		switch u.Scheme {
+		case "http", "https", "udp", "ws", "wss":
+		default:
+			return "Invalid tracker URL: " + tracker + " (use http, https, udp, ws or wss)"
+		}
+	}
+	return ""
+}
+
+// torrentTrackerList converts qBittorrent's trackers, leaving out the DHT, PeX
+// and LSD pseudo-trackers
+func torrentTrackerList(trackers []QBTracker) []TorrentTracker {
+	list := make([]TorrentTracker, 0, len(trackers))
+	for _, tracker := range trackers {
+		if strings.HasPrefix(tracker.URL, "** [") {
+			continue
+		}
+		list = append(list, TorrentTracker{
+			URL:      tracker.URL,
+			Status:   trackerStatusNames[tracker.Status],
+			Tier:     tracker.Tier,
+			Seeds:    tracker.Seeds,
+			Leechers: tracker.Leechers,
+			Message:  tracker.Message,
+		})
+	}
+	return list
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Minimal OpenTelemetry-compatible tracing: W3C traceparent propagation and
+// an OTLP/HTTP JSON exporter, so traces show up in Jaeger/Tempo/any collector.
+
+// OTLP span kinds
+const (
+	SpanKindInternal = 1
+	SpanKindServer   = 2
+	SpanKindClient   = 3
+)
+
+// tracer is nil when tracing is disabled, making every span a no-op
+var tracer *Tracer
+
+type Tracer struct {
+	endpoint    string
+	serviceName string
+	httpClient  *http.Client
+
+	mu      sync.Mutex
+	pending []*Span
+	flush   chan struct{}
+}
+
+type Span struct {
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	errMessage string
+	ended      bool
+	mu         sync.Mutex
+}
+
+type spanContextKey struct{}
+
+// NewTracer creates an exporter posting to endpoint (e.g. http://jaeger:4318/v1/traces)
+func NewTracer(endpoint, serviceName string) *Tracer {
+	return &Tracer{
+		endpoint:    endpoint,
+		serviceName: serviceName,
+		// Plain transport: the exporter's own requests must not be traced
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		flush:      make(chan struct{}, 1),
+	}
+}
+
+// Run exports batches every interval until ctx is cancelled
+func (t *Tracer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.flush:
+		case <-ctx.Done():
+			t.export()
+			return
+		}
+		t.export()
+	}
+}
+
+func (t *Tracer) enqueue(span *Span) {
+	t.mu.Lock()
+	t.pending = append(t.pending, span)
+	full := len(t.pending) >= 512
+	t.mu.Unlock()
+
+	if full {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *Tracer) export() {
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+
+	if len(spans) == 0 {
+		return
+	}
+
+	otlpSpans := make([]map[string]interface{}, 0, len(spans))
+	for _, span := range spans {
+		otlpSpans = append(otlpSpans, span.otlp())
+	}
+
+	payload := map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": otlpAttributes(map[string]interface{}{"service.name": t.serviceName}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "torrent-api"},
+						"spans": otlpSpans,
+					},
+				},
+			},
+		},
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Warning: could not encode traces: %v", err)
+		return
+	}
+
+	resp, err := t.httpClient.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Warning: could not export %d spans: %v", len(spans), err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Warning: trace export rejected: status %d", resp.StatusCode)
+	}
+}
+
+// StartSpan starts a child of the span in ctx (or a new trace) and returns a
+// context carrying it. It returns a nil span when tracing is disabled.
+func StartSpan(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if tracer == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+	}
+	if parent, ok := ctx.Value(spanContextKey{}).(*Span); ok && parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else if remote, ok := ctx.Value(remoteParentKey{}).(remoteParent); ok {
+		span.traceID = remote.traceID
+		span.parentID = remote.spanID
+	} else {
+		rand.Read(span.traceID[:])
+	}
+	rand.Read(span.spanID[:])
+
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// SetAttribute records a key/value on the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attributes[key] = value
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.errMessage = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if tracer != nil {
+		tracer.enqueue(s)
+	}
+}
+
+// traceparent formats the span as a W3C trace context header
+func (s *Span) traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(s.traceID[:]), hex.EncodeToString(s.spanID[:]))
+}
+
+func (s *Span) otlp() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	span := map[string]interface{}{
+		"traceId":           hex.EncodeToString(s.traceID[:]),
+		"spanId":            hex.EncodeToString(s.spanID[:]),
+		"name":              s.name,
+		"kind":              s.kind,
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		"attributes":        otlpAttributes(s.attributes),
+	}
+	if s.parentID != ([8]byte{}) {
+		span["parentSpanId"] = hex.EncodeToString(s.parentID[:])
+	}
+	if s.errMessage != "" {
+		span["status"] = map[string]interface{}{"code": 2, "message": secretRedactor.Redact(s.errMessage)}
+	}
+	return span
+}
+
+func otlpAttributes(attrs map[string]interface{}) []interface{} {
+	result := make([]interface{}, 0, len(attrs))
+	for key, value := range attrs {
+		var v map[string]interface{}
+		switch val := value.(type) {
+		case int:
+			v = map[string]interface{}{"intValue": strconv.Itoa(val)}
+		case int64:
+			v = map[string]interface{}{"intValue": strconv.FormatInt(val, 10)}
+		case bool:
+			v = map[string]interface{}{"boolValue": val}
+		case float64:
+			v = map[string]interface{}{"doubleValue": val}
+		default:
+			v = map[string]interface{}{"stringValue": secretRedactor.Redact(fmt.Sprint(val))}
+		}
+		result = append(result, map[string]interface{}{"key": key, "value": v})
+	}
+	return result
+}
+
+// remoteParent is a span context received in an incoming traceparent header
+type remoteParent struct {
+	traceID [16]byte
+	spanID  [8]byte
+}
+
+type remoteParentKey struct{}
+
+// parseTraceparent parses "00-<trace-id>-<span-id>-<flags>"
+func parseTraceparent(header string) (remoteParent, bool) {
+	var parent remoteParent
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return parent, false
+	}
+	traceID, err1 := hex.DecodeString(parts[1])
+	spanID, err2 := hex.DecodeString(parts[2])
+	if err1 != nil || err2 != nil {
+		return parent, false
+	}
+	copy(parent.traceID[:], traceID)
+	copy(parent.spanID[:], spanID)
+	return parent, parent.traceID != [16]byte{}
+}
+
+// statusRecorder captures the response status for the server span
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets streaming handlers keep working through the recorder
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// tracingMiddleware starts a server span per request, continuing the caller's trace
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tracer == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		if parent, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+			ctx = context.WithValue(ctx, remoteParentKey{}, parent)
+		}
+
+		ctx, span := StartSpan(ctx, r.Method+" "+r.URL.Path, SpanKindServer)
+		defer span.End()
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.target", r.URL.Path)
+		if v := extensionVersion(r); v != "" {
+			span.SetAttribute("client.extension_version", v)
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttribute("http.status_code", rec.status)
+		if rec.status >= 500 {
+			span.RecordError(fmt.Errorf("HTTP %d", rec.status))
+		}
+	})
+}
+
+// tracingTransport creates a client span per downstream request and injects traceparent
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func newTracingTransport() http.RoundTripper {
+	return &tracingTransport{base: http.DefaultTransport}
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = withUserAgent(req)
+	if debug := debugLogFromContext(req.Context()); debug != nil {
+		return debug.capture(req, t.roundTrip)
+	}
+	return t.roundTrip(req)
+}
+
+func (t *tracingTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if tracer == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, span := StartSpan(req.Context(), req.Method+" "+req.URL.Host, SpanKindClient)
+	defer span.End()
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path)
+
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(ctx)
+	req.Header.Set("traceparent", span.traceparent())
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= 400 {
+		span.RecordError(fmt.Errorf("HTTP %d", resp.StatusCode))
+	}
+	return resp, nil
+}
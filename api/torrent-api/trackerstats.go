@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Torrents are followed for completion this long after they were added
+const trackerStatsFollowWindow = 30 * 24 * time.Hour
+
+// A download still stalled this long after the add counts as stalled
+const trackerStallGrace = time.Hour
+
+// Domain for magnets without trackers
+const dhtOnlyDomain = "dht"
+
+// TrackerStats is the add outcome of torrents announcing to one tracker domain
+type TrackerStats struct {
+	Domain         string     `json:"domain"`
+	Adds           int        `json:"adds"`
+	Succeeded      int        `json:"succeeded"`
+	Failed         int        `json:"failed"`
+	Stalled        int        `json:"stalled"`   // stalled after the grace period and never finished
+	Completed      int        `json:"completed"` // finished downloading in qBittorrent
+	SuccessRate    float64    `json:"success_rate"`
+	StallRate      float64    `json:"stall_rate"`      // of succeeded adds
+	CompletionRate float64    `json:"completion_rate"` // of succeeded adds
+	LastAdd        *time.Time `json:"last_add,omitempty"`
+}
+
+type TrackerStatsResponse struct {
+	Success  bool           `json:"success"`
+	Message  string         `json:"message"`
+	Trackers []TrackerStats `json:"trackers,omitempty"`
+}
+
+// trackerDomains returns the registrable domains of a magnet's trackers,
+// e.g. "opentrackr.org" for udp://tracker.opentrackr.org:1337/announce
+func trackerDomains(magnetLink string) []string {
+	u, err := url.Parse(magnetLink)
+	if err != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var domains []string
+	for _, tracker := range u.Query()["tr"] {
+		tu, err := url.Parse(tracker)
+		if err != nil || tu.Hostname() == "" {
+			continue
+		}
+		domain := registrableDomain(strings.ToLower(tu.Hostname()))
+		if !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// registrableDomain keeps the last two labels, or three for "example.co.uk"
+// style suffixes; IP addresses are kept whole
+func registrableDomain(host string) string {
+	labels := strings.Split(host, ".")
+	if len(labels) <= 2 || isNumericHost(labels) {
+		return host
+	}
+	keep := 2
+	if len(labels[len(labels)-1]) == 2 && len(labels[len(labels)-2]) <= 3 && len(labels) > 2 {
+		keep = 3
+	}
+	return strings.Join(labels[len(labels)-keep:], ".")
+}
+
+func isNumericHost(labels []string) bool {
+	for _, label := range labels {
+		if _, err := strconv.Atoi(label); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// TrackTorrentProgress is the trackerstats worker: it records when recently
+// added torrents finish or stall in qBittorrent, for /api/stats/trackers, and
+// deletes the Sonarr files a finished replace add was waiting on
+func (h *TorrentHandler) TrackTorrentProgress(ctx context.Context) error {
+	if h.history == nil {
+		return nil
+	}
+
+	var pending []HistoryRecord
+	for _, record := range h.history.List() {
+		if !record.Active() || record.InfoHash == "" {
+			continue
+		}
+		// Deletes that failed after the download finished are tried again
+		if record.CompletedAt != nil && len(record.ReplaceEpisodeFiles) > 0 {
+			h.finishReplace(ctx, record)
+			continue
+		}
+		if record.CompletedAt == nil && time.Since(record.AddedAt) < trackerStatsFollowWindow {
+			pending = append(pending, record)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	data, err := h.qbClient.GetMainData(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list torrents: %w", err)
+	}
+
+	now := time.Now()
+	for _, record := range pending {
+		torrent, ok := data.Torrents[infoHashHex("magnet:?xt=urn:btih:"+record.InfoHash)]
+		if !ok {
+			continue
+		}
+		completed := torrent.Progress >= 1
+		stalled := !completed && record.StalledAt == nil && now.Sub(record.AddedAt) > trackerStallGrace &&
+			(torrent.State == "stalledDL" || torrent.State == "metaDL")
+		if !completed && !stalled {
+			continue
+		}
+		if err := h.history.Update(record.ID, func(r *HistoryRecord) {
+			if completed {
+				r.CompletedAt = &now
+			} else {
+				r.StalledAt = &now
+			}
+		}); err != nil {
+			return err
+		}
+		if completed {
+			h.publishRecordEvent(EventDownloadCompleted, record, "")
+			if len(record.ReplaceEpisodeFiles) > 0 {
+				h.finishReplace(ctx, record)
+			}
+		}
+	}
+	return nil
+}
+
+// finishReplace deletes a finished replace add's Sonarr files, keeping the ones
+// that failed on the record
+func (h *TorrentHandler) finishReplace(ctx context.Context, record HistoryRecord) {
+	failed := h.replaceEpisodeFiles(ctx, record)
+	if err := h.history.Update(record.ID, func(r *HistoryRecord) {
+		r.ReplaceEpisodeFiles = failed
+	}); err != nil {
+		log.Printf("Warning: could not update history: %v", err)
+	}
+}
+
+// trackerStats groups history torrent adds since since by tracker domain, most
+// used first
+func trackerStats(records []HistoryRecord, since time.Time) []TrackerStats {
+	byDomain := make(map[string]*TrackerStats)
+	for _, record := range records {
+		if record.Source == "media" || record.AddedAt.Before(since) {
+			continue
+		}
+		domains := record.Trackers
+		if len(domains) == 0 {
+			domains = []string{dhtOnlyDomain}
+		}
+		for _, domain := range domains {
+			stats, ok := byDomain[domain]
+			if !ok {
+				stats = &TrackerStats{Domain: domain}
+				byDomain[domain] = stats
+			}
+			stats.Adds++
+			if record.Status == HistoryStatusFailed {
+				stats.Failed++
+			} else {
+				stats.Succeeded++
+			}
+			switch {
+			case record.CompletedAt != nil:
+				stats.Completed++
+			case record.StalledAt != nil:
+				stats.Stalled++
+			}
+			if addedAt := record.AddedAt; stats.LastAdd == nil || addedAt.After(*stats.LastAdd) {
+				stats.LastAdd = &addedAt
+			}
+		}
+	}
+
+	result := make([]TrackerStats, 0, len(byDomain))
+	for _, stats := range byDomain {
+		stats.SuccessRate = ratio(stats.Succeeded, stats.Adds)
+		stats.StallRate = ratio(stats.Stalled, stats.Succeeded)
+		stats.CompletionRate = ratio(stats.Completed, stats.Succeeded)
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Adds != result[j].Adds {
+			return result[i].Adds > result[j].Adds
+		}
+		return result[i].Domain < result[j].Domain
+	})
+	return result
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(int(float64(n)/float64(total)*1000+0.5)) / 1000
+}
+
+// TrackerStats reports add success, stall and completion rates per tracker
+// domain from the history. ?days= limits it to recent adds.
+func (h *TorrentHandler) TrackerStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(TrackerStatsResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+	if h.history == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(TrackerStatsResponse{
+			Success: false,
+			Message: "History is not enabled",
+		})
+		return
+	}
+
+	var since time.Time
+	if value := r.URL.Query().Get("days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(TrackerStatsResponse{
+				Success: false,
+				Message: "days must be a positive number",
+			})
+			return
+		}
+		since = time.Now().AddDate(0, 0, -days)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TrackerStatsResponse{
+		Success:  true,
+		Message:  "OK",
+		Trackers: trackerStats(h.history.List(), since),
+	})
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultUndoWindow is how long an add's undo_token works without UNDO_WINDOW
+const defaultUndoWindow = 5 * time.Minute
+
+// undoEntry is what POST /api/undo rolls back for a token
+type undoEntry struct {
+	hash         string // hex info hash
+	mediaType    string // "movie" or "tv"
+	mediaID      int
+	mediaTitle   string
+	libraryAdded bool // this add created the movie or series, so undo removes it
+	historyID    int64
+	apiKey       string // name of the key that made the add
+	expiresAt    time.Time
+}
+
+// UndoTokens holds the undo tokens of recent adds in memory; each works once
+type UndoTokens struct {
+	mu      sync.Mutex
+	entries map[string]undoEntry
+}
+
+func NewUndoTokens() *UndoTokens {
+	return &UndoTokens{entries: make(map[string]undoEntry)}
+}
+
+// Issue stores an entry under a new random token, dropping expired ones
+func (u *UndoTokens) Issue(entry undoEntry) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := time.Now()
+	for t, e := range u.entries {
+		if now.After(e.expiresAt) {
+			delete(u.entries, t)
+		}
+	}
+	u.entries[token] = entry
+	return token, nil
+}
+
+// Take removes and returns a token's entry, unless it is unknown or expired
+func (u *UndoTokens) Take(token string) (undoEntry, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	entry, ok := u.entries[token]
+	delete(u.entries, token)
+	if !ok || time.Now().After(entry.expiresAt) {
+		return undoEntry{}, false
+	}
+	return entry, true
+}
+
+// Restore puts back a taken entry whose undo didn't happen
+func (u *UndoTokens) Restore(token string, entry undoEntry) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.entries[token] = entry
+}
+
+// issueUndo returns a token that rolls back a successful torrent add within
+// UNDO_WINDOW, or "" when undo is off or the add can't be taken back, such as
+// an NZB grabbed instead or a torrent qBittorrent already had
+func (h *TorrentHandler) issueUndo(p *AddPipeline) (string, *time.Time) {
+	window := h.cfg().UndoWindow
+	if window <= 0 || p.Usenet != nil || p.TorrentExisted {
+		return "", nil
+	}
+	hash, err := decodeInfoHash(extractInfoHash(p.Request.MagnetLink))
+	if err != nil {
+		return "", nil
+	}
+
+	expiresAt := time.Now().Add(window)
+	token, err := h.undoTokens.Issue(undoEntry{
+		hash:         hex.EncodeToString(hash),
+		mediaType:    p.mediaKind(),
+		mediaID:      p.MediaID,
+		mediaTitle:   p.MediaTitle,
+		libraryAdded: p.AddedToLibrary,
+		historyID:    p.HistoryID,
+		apiKey:       p.APIKey,
+		expiresAt:    expiresAt,
+	})
+	if err != nil {
+		log.Printf("Warning: could not issue undo token: %v", err)
+		return "", nil
+	}
+	return token, &expiresAt
+}
+
+type UndoRequest struct {
+	Token string `json:"token"` // undo_token of the add response
+}
+
+// Undo rolls back a torrent add with the undo_token of its response: the
+// torrent and its files are deleted, and the movie or series is removed when
+// the add created it. Only the key that made the add, or an admin, can undo it.
+func (h *TorrentHandler) Undo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: "Method not allowed. Use POST.",
+		})
+		return
+	}
+
+	var req UndoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: "Invalid request body: token is required",
+		})
+		return
+	}
+
+	entry, ok := h.undoTokens.Take(req.Token)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: "Undo token is unknown or has expired",
+		})
+		return
+	}
+	if key := apiKeyFromContext(r.Context()); key != nil && !key.Admin && key.Name != entry.apiKey {
+		h.undoTokens.Restore(req.Token, entry)
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: "Only ADMIN_KEYS can undo adds other keys made",
+		})
+		return
+	}
+
+	message, warnings, err := h.undo(r.Context(), entry)
+	if err != nil {
+		log.Printf("Error undoing add of %s: %v", entry.hash, err)
+		h.undoTokens.Restore(req.Token, entry)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(TorrentActionResponse{
+			Success: false,
+			Message: err.Error(),
+			Hash:    entry.hash,
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TorrentActionResponse{
+		Success:  true,
+		Message:  message,
+		Hash:     entry.hash,
+		Warnings: warnings,
+	})
+}
+
+// undo deletes the torrent and then the library item the add created. Only a
+// failed torrent delete is an error, so the token can be used again.
+func (h *TorrentHandler) undo(ctx context.Context, entry undoEntry) (string, []string, error) {
+	if err := h.qbClient.DeleteTorrent(ctx, entry.hash, true); err != nil {
+		return "", nil, err
+	}
+	log.Printf("Add of torrent %s undone", entry.hash)
+
+	message := "Add undone; torrent and its files deleted"
+	var warnings []string
+	libraryRemoved := false
+	if entry.libraryAdded && entry.mediaID != 0 {
+		record := &HistoryRecord{MediaType: entry.mediaType, MediaID: entry.mediaID}
+		if err := h.removeFromLibrary(ctx, record, true); err != nil {
+			log.Printf("Warning: could not remove %s: %v", entry.mediaTitle, err)
+			warnings = append(warnings, fmt.Sprintf("Could not remove %s from the library: %v", entry.mediaTitle, err))
+		} else {
+			libraryRemoved = true
+			message += "; " + entry.mediaTitle + " removed from the library"
+		}
+	}
+
+	if h.history != nil && entry.historyID != 0 {
+		now := time.Now()
+		err := h.history.Update(entry.historyID, func(r *HistoryRecord) {
+			r.TorrentRemoved = true
+			if libraryRemoved || !entry.libraryAdded {
+				r.Status, r.RemovedAt = HistoryStatusDeleted, &now
+			}
+		})
+		if err != nil {
+			log.Printf("Warning: could not update history: %v", err)
+		}
+	}
+	return message, warnings, nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Health statuses of /health
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusWarn = "warn" // soft failures reached the threshold
+	HealthStatusDown = "down" // the ping failed
+)
+
+// Each upstream gets this long to answer the /health ping
+const healthPingTimeout = 5 * time.Second
+
+// /health reuses its pings for this long, so frequent or anonymous probes don't
+// each log in to qBittorrent and call every backend
+const healthPingTTL = 10 * time.Second
+
+// pingedBackends are the upstreams /health pings; Tautulli only reports soft failures
+var pingedBackends = []string{DependencyQBittorrent, DependencyRadarr, DependencySonarr, DependencyExtractor}
+
+// UpstreamErrors keeps each upstream's last failed ping, so a backend that
+// recovered still shows what went wrong
+type UpstreamErrors struct {
+	mu   sync.Mutex
+	last map[string]upstreamError
+}
+
+type upstreamError struct {
+	message string
+	at      time.Time
+}
+
+func NewUpstreamErrors() *UpstreamErrors {
+	return &UpstreamErrors{last: make(map[string]upstreamError)}
+}
+
+// Record stores a failed ping and returns the backend's last error
+func (u *UpstreamErrors) Record(backend string, err error) (string, *time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if err != nil {
+		u.last[backend] = upstreamError{message: err.Error(), at: time.Now()}
+	}
+	last, ok := u.last[backend]
+	if !ok {
+		return "", nil
+	}
+	return last.message, &last.at
+}
+
+// HealthPings keeps the last round of /health pings
+type HealthPings struct {
+	mu      sync.Mutex
+	at      time.Time
+	results map[string]pingResult
+}
+
+type pingResult struct {
+	latency int64 // milliseconds
+	err     error
+}
+
+func NewHealthPings() *HealthPings {
+	return &HealthPings{}
+}
+
+// BackendHealth is a backend's ping result and its soft failures in the window
+type BackendHealth struct {
+	Status        string         `json:"status"`               // "ok", "warn" or "down"
+	LatencyMillis *int64         `json:"latency_ms,omitempty"` // of the ping; missing for Tautulli
+	LastError     string         `json:"last_error,omitempty"` // of the last failed ping, even when it has recovered since
+	LastErrorAt   *time.Time     `json:"last_error_at,omitempty"`
+	SoftFailures  int            `json:"soft_failures"`
+	Threshold     int            `json:"threshold,omitempty"`
+	Kinds         map[string]int `json:"kinds,omitempty"`
+}
+
+type HealthResponse struct {
+	Status        string                   `json:"status"` // the worst backend status
+	WindowSeconds int                      `json:"window_seconds"`
+	Backends      map[string]BackendHealth `json:"backends"`
+}
+
+// pingBackend checks that an upstream answers: a qBittorrent session, the
+// Radarr/Sonarr system status, or a name extraction
+func (h *TorrentHandler) pingBackend(ctx context.Context, backend string) error {
+	switch backend {
+	case DependencyQBittorrent:
+		return h.qbClient.KeepAlive(ctx)
+	case DependencyRadarr:
+		_, err := h.radarrClient.GetSystemStatus(ctx)
+		return err
+	case DependencySonarr:
+		_, err := h.sonarrClient.GetSystemStatus(ctx)
+		return err
+	case DependencyExtractor:
+		return h.extractorClient.Ping(ctx)
+	}
+	return nil
+}
+
+// Health pings qBittorrent, Radarr, Sonarr and the extractor and reports each
+// one's latency and last error with the soft failures of the last
+// SOFT_FAILURE_WINDOW. It returns 503 when any of them is down. Requests
+// without an API key, when keys are required, only get the statuses.
+func (h *TorrentHandler) Health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	config := h.cfg()
+	counts := h.softFailures.Counts(config.SoftFailureWindow)
+	resp := HealthResponse{
+		Status:        HealthStatusOK,
+		WindowSeconds: int(config.SoftFailureWindow.Seconds()),
+		Backends:      make(map[string]BackendHealth),
+	}
+	for _, backend := range softFailureBackends {
+		backendHealth := BackendHealth{Status: HealthStatusOK, Threshold: config.SoftFailureWarn[backend], Kinds: counts[backend]}
+		for _, n := range counts[backend] {
+			backendHealth.SoftFailures += n
+		}
+		if backendHealth.Threshold > 0 && backendHealth.SoftFailures >= backendHealth.Threshold {
+			backendHealth.Status = HealthStatusWarn
+		}
+		resp.Backends[backend] = backendHealth
+	}
+
+	for backend, result := range h.pingBackends(r.Context()) {
+		lastError, lastErrorAt := h.upstreamErrors.Record(backend, nil)
+		backendHealth, ok := resp.Backends[backend]
+		if !ok {
+			backendHealth.Status = HealthStatusOK
+		}
+		latency := result.latency
+		backendHealth.LatencyMillis = &latency
+		backendHealth.LastError, backendHealth.LastErrorAt = lastError, lastErrorAt
+		if result.err != nil {
+			backendHealth.Status = HealthStatusDown
+		}
+		resp.Backends[backend] = backendHealth
+	}
+
+	status := http.StatusOK
+	for _, backendHealth := range resp.Backends {
+		switch {
+		case backendHealth.Status == HealthStatusDown:
+			resp.Status, status = HealthStatusDown, http.StatusServiceUnavailable
+		case backendHealth.Status == HealthStatusWarn && resp.Status == HealthStatusOK:
+			resp.Status = HealthStatusWarn
+		}
+	}
+
+	// Errors name upstream hosts; without a key only the statuses are shown
+	if isAnonymous(r.Context()) {
+		for backend, backendHealth := range resp.Backends {
+			resp.Backends[backend] = BackendHealth{Status: backendHealth.Status}
+		}
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// pingBackends pings every backend concurrently, or returns the last round's
+// results when they are under healthPingTTL old. Concurrent callers share a round.
+func (h *TorrentHandler) pingBackends(ctx context.Context) map[string]pingResult {
+	h.healthPings.mu.Lock()
+	defer h.healthPings.mu.Unlock()
+	if h.healthPings.results != nil && time.Since(h.healthPings.at) < healthPingTTL {
+		return h.healthPings.results
+	}
+
+	// The round is shared, so one caller hanging up mustn't fail it
+	ctx = context.WithoutCancel(ctx)
+	results := make(map[string]pingResult)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, backend := range pingedBackends {
+		wg.Add(1)
+		go func(backend string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, healthPingTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := h.pingBackend(ctx, backend)
+			latency := time.Since(start).Milliseconds()
+			h.upstreamErrors.Record(backend, err)
+
+			mu.Lock()
+			results[backend] = pingResult{latency: latency, err: err}
+			mu.Unlock()
+		}(backend)
+	}
+	wg.Wait()
+
+	h.healthPings.at, h.healthPings.results = time.Now(), results
+	return results
+}
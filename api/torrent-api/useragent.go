@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// version is set at build time with -ldflags "-X main.version=1.2.3"
+var version = "dev"
+
+// commit and buildDate are set at build time like version; without them
+// GET /api/version falls back to the VCS stamp Go embeds in local builds
+var (
+	commit    = ""
+	buildDate = ""
+)
+
+// userAgent is sent on every downstream request; USER_AGENT overrides it for
+// reverse proxies in front of the *arr apps that only let known agents through
+var userAgent = "torrent-api/" + version
+
+// extensionVersionHeader is sent by the browser extension with its version
+const extensionVersionHeader = "X-Extension-Version"
+
+// loadUserAgent applies USER_AGENT, if set
+func loadUserAgent() {
+	if ua := os.Getenv("USER_AGENT"); ua != "" {
+		userAgent = ua
+	}
+}
+
+// withUserAgent returns req with the User-Agent set, unless the caller set one
+func withUserAgent(req *http.Request) *http.Request {
+	if req.Header.Get("User-Agent") != "" {
+		return req
+	}
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", userAgent)
+	return req
+}
+
+// extensionVersion returns the extension version a request was sent with,
+// limited to version characters so it can't forge access log fields
+func extensionVersion(r *http.Request) string {
+	v := r.Header.Get(extensionVersionHeader)
+	if len(v) > 32 {
+		v = v[:32]
+	}
+	return strings.Map(func(c rune) rune {
+		switch {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '.', c == '-', c == '+', c == '_':
+			return c
+		}
+		return -1
+	}, v)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type VariantsRequest struct {
+	URL      string           `json:"url,omitempty"`      // Result page to scrape
+	Releases []ScrapedRelease `json:"releases,omitempty"` // Or releases found elsewhere, e.g. a search
+	Type     string           `json:"type,omitempty"`     // "movie" or "tv"; detected per title when empty
+	Add      bool             `json:"add,omitempty"`      // Add the picked variant of every title
+}
+
+// VariantGroup is one title with its quality variants, best resolution first
+type VariantGroup struct {
+	Title      string              `json:"title"`
+	Year       string              `json:"year,omitempty"`
+	Type       string              `json:"type"`             // "movie" or "tv"
+	Variants   []ScrapedRelease    `json:"variants"`         // Best resolution first
+	Picked     *int                `json:"picked,omitempty"` // Index of the variant the quality profile prefers
+	PickReason string              `json:"pick_reason"`
+	Result     *AddTorrentResponse `json:"result,omitempty"` // Outcome of the add when requested
+}
+
+type VariantsResponse struct {
+	Success bool           `json:"success"`
+	Message string         `json:"message"`
+	Site    string         `json:"site,omitempty"`
+	Groups  []VariantGroup `json:"groups"`
+}
+
+// Variants groups the quality variants of each title on a page (or in a list of
+// releases) and picks the one the Radarr/Sonarr quality profile prefers, optionally
+// adding it
+func (h *TorrentHandler) Variants(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(VariantsResponse{
+			Success: false,
+			Message: "Method not allowed. Use POST.",
+		})
+		return
+	}
+
+	// Parse request body
+	var req VariantsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(VariantsResponse{
+			Success: false,
+			Message: "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if (req.URL == "") == (len(req.Releases) == 0) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(VariantsResponse{
+			Success: false,
+			Message: "Either url or releases is required",
+		})
+		return
+	}
+
+	for _, release := range req.Releases {
+		if !isValidMagnetLink(release.MagnetLink) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(VariantsResponse{
+				Success: false,
+				Message: "Invalid magnet link format: " + release.Name,
+			})
+			return
+		}
+	}
+
+	switch req.Type {
+	case "", "movie", "tv":
+	case "series":
+		req.Type = "tv"
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(VariantsResponse{
+			Success: false,
+			Message: "Invalid type. Use 'movie' or 'tv'",
+		})
+		return
+	}
+
+	releases, site := req.Releases, ""
+	if req.URL != "" {
+		if _, err := findSiteScraper(req.URL); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(VariantsResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		var err error
+		site, releases, err = h.scraperClient.Scrape(req.URL)
+		if err != nil {
+			log.Printf("Error scraping %s: %v", req.URL, err)
+			w.WriteHeader(http.StatusBadGateway)
+			json.NewEncoder(w).Encode(VariantsResponse{
+				Success: false,
+				Message: "Failed to scrape page: " + err.Error(),
+				Site:    site,
+			})
+			return
+		}
+	}
+
+	ctx := r.Context()
+	groups := groupVariants(releases, req.Type)
+	if err := h.pickVariants(ctx, groups); err != nil {
+		log.Printf("Error reading quality profiles: %v", err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(VariantsResponse{
+			Success: false,
+			Message: "Failed to read quality profile: " + err.Error(),
+			Site:    site,
+			Groups:  groups,
+		})
+		return
+	}
+
+	if req.Add {
+		for i := range groups {
+			h.addPickedVariant(ctx, &groups[i])
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(VariantsResponse{
+		Success: true,
+		Message: fmt.Sprintf("Found %d titles in %d releases", len(groups), len(releases)),
+		Site:    site,
+		Groups:  groups,
+	})
+}
+
+// groupVariants groups releases by title and year, keeping page order between
+// titles and sorting each title's variants by resolution, then format score,
+// best first
+func groupVariants(releases []ScrapedRelease, mediaType string) []VariantGroup {
+	var groups []VariantGroup
+	index := make(map[string]int)
+
+	for _, release := range releases {
+		info := ExtractMovieInfo(release.Name)
+		if release.Quality == "" {
+			release.Quality = info.Quality
+		}
+		// The extracted title keeps the year for lookups
+		title := strings.TrimSuffix(info.Title, " "+info.Year)
+		key := normalizeTitle(title) + " " + info.Year
+
+		i, ok := index[key]
+		if !ok {
+			groupType := mediaType
+			if groupType == "" {
+				groupType = "movie"
+				if explainCategory(release.Name, false).Category == "sonarr" {
+					groupType = "tv"
+				}
+			}
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, VariantGroup{Title: title, Year: info.Year, Type: groupType})
+		}
+		groups[i].Variants = append(groups[i].Variants, release)
+	}
+
+	for _, group := range groups {
+		sort.SliceStable(group.Variants, func(a, b int) bool {
+			va, vb := group.Variants[a], group.Variants[b]
+			if ra, rb := qualityResolutions[va.Quality], qualityResolutions[vb.Quality]; ra != rb {
+				return ra > rb
+			}
+			// HDR, bit depth and audio break ties within a resolution
+			return formatScore(ExtractMovieInfo(va.Name)) > formatScore(ExtractMovieInfo(vb.Name))
+		})
+	}
+	return groups
+}
+
+// pickVariants picks each group's variant by the quality profile new movies or
+// series get (the first one, as for adds)
+func (h *TorrentHandler) pickVariants(ctx context.Context, groups []VariantGroup) error {
+	type limits struct {
+		name    string
+		allowed map[int]bool
+		cutoff  int
+	}
+	profiles := make(map[string]*limits)
+
+	for i := range groups {
+		group := &groups[i]
+		profile, ok := profiles[group.Type]
+		if !ok {
+			var items []ArrQualityProfileItem
+			var cutoff int
+			var name string
+			if group.Type == "movie" {
+				list, err := h.radarrClient.GetQualityProfiles(ctx)
+				if err != nil {
+					return fmt.Errorf("radarr: %w", err)
+				}
+				if len(list) > 0 {
+					items, cutoff, name = list[0].Items, list[0].Cutoff, list[0].Name
+				}
+			} else {
+				list, err := h.sonarrClient.GetQualityProfiles(ctx)
+				if err != nil {
+					return fmt.Errorf("sonarr: %w", err)
+				}
+				if len(list) > 0 {
+					items, cutoff, name = list[0].Items, list[0].Cutoff, list[0].Name
+				}
+			}
+			profile = &limits{name: name}
+			profile.allowed, profile.cutoff = qualityProfileResolutions(items, cutoff)
+			profiles[group.Type] = profile
+		}
+
+		picked, reason := pickVariant(group.Variants, profile.allowed, profile.cutoff)
+		if picked >= 0 {
+			group.Picked = &picked
+		}
+		group.PickReason = fmt.Sprintf("%s (profile %q)", reason, profile.name)
+	}
+	return nil
+}
+
+// pickVariant returns the index of the best allowed variant up to the cutoff
+// resolution, else the lowest allowed one above it; -1 when none is allowed.
+// variants are sorted best first.
+func pickVariant(variants []ScrapedRelease, allowed map[int]bool, cutoff int) (int, string) {
+	above := -1
+	for i, variant := range variants {
+		resolution := qualityResolutions[variant.Quality]
+		if !allowed[resolution] {
+			continue
+		}
+		if resolution <= cutoff {
+			return i, fmt.Sprintf("best allowed quality up to the %dp cutoff", cutoff)
+		}
+		above = i
+	}
+	if above >= 0 {
+		return above, fmt.Sprintf("only allowed qualities are above the %dp cutoff", cutoff)
+	}
+	return -1, "no variant has an allowed quality"
+}
+
+// addPickedVariant runs the add pipeline for a group's picked variant
+func (h *TorrentHandler) addPickedVariant(ctx context.Context, group *VariantGroup) {
+	if group.Picked == nil {
+		return
+	}
+	variant := group.Variants[*group.Picked]
+
+	p, err := h.runAddPipeline(ctx, AddTorrentRequest{MagnetLink: variant.MagnetLink, Type: group.Type}, nil)
+	result := &AddTorrentResponse{
+		Success:        err == nil,
+		Message:        "Torrent added to qBittorrent",
+		Category:       p.Category,
+		MediaTitle:     p.MediaTitle,
+		AddedToLibrary: p.AddedToLibrary,
+		Warnings:       p.Warnings,
+		Steps:          p.Steps,
+		SeedingPolicy:  p.SeedingPolicy,
+	}
+	if err != nil {
+		result.Message = "Failed to add torrent: " + err.Error()
+		result.Code = errorCode(err)
+		result.RolledBack = p.RolledBack
+	}
+	group.Result = result
+}
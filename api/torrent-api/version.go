@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// BuildInfo describes this binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+type VersionResponse struct {
+	Success  bool                  `json:"success"`
+	Message  string                `json:"message"`
+	Build    BuildInfo             `json:"build"`
+	Backends map[string]AppVersion `json:"backends,omitempty"` // qbittorrent, radarr and sonarr once they have warmed up
+}
+
+// buildInfo returns the version stamped at build time, filling in the commit
+// and date from the VCS information of a plain go build
+func buildInfo() BuildInfo {
+	info := BuildInfo{Version: version, Commit: commit, BuildDate: buildDate, GoVersion: runtime.Version()}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+	return info
+}
+
+// Version reports the build of this binary and the versions qBittorrent,
+// Radarr and Sonarr reported when they were first reached
+func (h *TorrentHandler) Version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(VersionResponse{
+			Success: false,
+			Message: "Method not allowed. Use GET.",
+		})
+		return
+	}
+
+	backends := make(map[string]AppVersion)
+	for name, dep := range h.readiness.Status().Dependencies {
+		if dep.Ready {
+			backends[name] = dep.AppVersion
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(VersionResponse{
+		Success:  true,
+		Message:  "OK",
+		Build:    buildInfo(),
+		Backends: backends,
+	})
+}
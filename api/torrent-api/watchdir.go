@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A file changed this recently may still be being written
+const watchSettleTime = 2 * time.Second
+
+// Suffixes a processed watch file is renamed with, so it isn't added again
+const (
+	watchAddedSuffix  = ".added"
+	watchFailedSuffix = ".failed"
+)
+
+// WatchFolder adds the .torrent and .magnet files dropped in WATCH_DIR, e.g. by
+// a browser that downloads .torrent files instead of opening them
+type WatchFolder struct {
+	dir     string
+	handler *TorrentHandler
+
+	// Files that could not be renamed, so they aren't added on every poll
+	stuck map[string]bool
+}
+
+func NewWatchFolder(dir string, handler *TorrentHandler) *WatchFolder {
+	return &WatchFolder{dir: dir, handler: handler, stuck: make(map[string]bool)}
+}
+
+// Poll adds every settled .torrent and .magnet file in the folder through the
+// add pipeline, then renames it with .added or .failed
+func (w *WatchFolder) Poll(ctx context.Context) error {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", w.dir, err)
+	}
+
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".torrent" && ext != ".magnet") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < watchSettleTime {
+			continue
+		}
+		path := filepath.Join(w.dir, entry.Name())
+		if w.stuck[path] {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		w.add(ctx, path)
+	}
+	return nil
+}
+
+// add runs the pipeline for one file; files that aren't a torrent or magnet are
+// recorded in the history as failed adds too
+func (w *WatchFolder) add(ctx context.Context, path string) {
+	name := filepath.Base(path)
+	req, err := readWatchFile(path)
+	if err != nil {
+		log.Printf("Warning: watch folder: %s: %v", name, err)
+		w.handler.recordHistory(HistoryRecord{
+			Source: "watch",
+			Name:   name,
+			Status: HistoryStatusFailed,
+			Error:  err.Error(),
+		})
+	} else {
+		log.Printf("Watch folder: adding %s", name)
+		req.WatchFile = name
+		if _, err = w.handler.runAddPipeline(ctx, req, nil); err != nil {
+			log.Printf("Warning: watch folder: could not add %s: %v", name, err)
+		}
+	}
+
+	suffix := watchAddedSuffix
+	if err != nil {
+		suffix = watchFailedSuffix
+	}
+	if err := os.Rename(path, path+suffix); err != nil {
+		log.Printf("Warning: watch folder: could not rename %s, skipping it until restart: %v", name, err)
+		w.stuck[path] = true
+	}
+}
+
+// readWatchFile returns the add request for a .torrent file, which uploads the
+// file itself and records only its info hash and name, or for the magnet link a
+// .magnet file holds
+func readWatchFile(path string) (AddTorrentRequest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return AddTorrentRequest{}, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".torrent") {
+		t, err := parseTorrentFile(data)
+		if err != nil {
+			return AddTorrentRequest{}, err
+		}
+		return AddTorrentRequest{MagnetLink: t.magnet(false), TorrentFile: data}, nil
+	}
+
+	magnetLink := strings.TrimSpace(string(data))
+	if i := strings.IndexAny(magnetLink, "\r\n"); i >= 0 {
+		magnetLink = magnetLink[:i]
+	}
+	req := AddTorrentRequest{MagnetLink: magnetLink}
+	if err := validateAddRequest(req); err != nil {
+		return AddTorrentRequest{}, err
+	}
+	return req, nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ArrWebhookPayload is the subset of Radarr/Sonarr's native webhook body we use
+type ArrWebhookPayload struct {
+	EventType  string             `json:"eventType"` // e.g. "Grab", "Download", "MovieAdded", "SeriesDelete", "Test"
+	DownloadID string             `json:"downloadId,omitempty"`
+	Movie      *ArrWebhookMedia   `json:"movie,omitempty"`
+	Series     *ArrWebhookMedia   `json:"series,omitempty"`
+	Release    *ArrWebhookRelease `json:"release,omitempty"`
+	// Why an import needs manual interaction
+	DownloadStatusMessages []ArrWebhookStatusMessage `json:"downloadStatusMessages,omitempty"`
+}
+
+type ArrWebhookMedia struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	Year  int    `json:"year"`
+}
+
+type ArrWebhookRelease struct {
+	ReleaseTitle string `json:"releaseTitle"`
+}
+
+type ArrWebhookStatusMessage struct {
+	Title    string   `json:"title"`
+	Messages []string `json:"messages"`
+}
+
+type WebhookResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Updated int    `json:"updated"` // history records changed by the event
+}
+
+// RadarrWebhook receives Radarr's webhook connection
+func (h *TorrentHandler) RadarrWebhook(w http.ResponseWriter, r *http.Request) {
+	h.arrWebhook(w, r, "movie")
+}
+
+// SonarrWebhook receives Sonarr's webhook connection
+func (h *TorrentHandler) SonarrWebhook(w http.ResponseWriter, r *http.Request) {
+	h.arrWebhook(w, r, "tv")
+}
+
+func (h *TorrentHandler) arrWebhook(w http.ResponseWriter, r *http.Request, mediaType string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(WebhookResponse{
+			Success: false,
+			Message: "Method not allowed. Use POST.",
+		})
+		return
+	}
+
+	// Events can delete torrents and files, so only admin keys may send them
+	if key := apiKeyFromContext(r.Context()); key != nil && !key.Admin {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(WebhookResponse{
+			Success: false,
+			Message: "Only ADMIN_KEYS can send Radarr/Sonarr webhooks",
+		})
+		return
+	}
+
+	var event ArrWebhookPayload
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(WebhookResponse{
+			Success: false,
+			Message: "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	updated := h.applyArrEvent(r.Context(), mediaType, event)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(WebhookResponse{
+		Success: true,
+		Message: "Processed " + event.EventType + " event",
+		Updated: updated,
+	})
+}
+
+// applyArrEvent moves the history records an *arr event refers to to their next
+// state and returns how many changed. Records are matched by the download's
+// info hash, falling back to the movie/series ID.
+func (h *TorrentHandler) applyArrEvent(ctx context.Context, mediaType string, event ArrWebhookPayload) int {
+	media := event.Movie
+	if mediaType == "tv" {
+		media = event.Series
+	}
+	if h.history == nil || media == nil {
+		return 0
+	}
+
+	log.Printf("Webhook: %s event for %s %q (ID: %d)", event.EventType, mediaType, media.Title, media.ID)
+
+	// Newly added library items are linked to adds whose library step found them
+	// already there or failed, by title
+	if event.EventType == "MovieAdded" || event.EventType == "SeriesAdd" {
+		return h.updateHistory(func(record HistoryRecord) bool {
+			return record.Active() && record.MediaID == 0 && record.MediaType == mediaType &&
+				normalizeTitle(record.MediaTitle) == normalizeTitle(media.Title)
+		}, func(record *HistoryRecord) {
+			record.MediaID = media.ID
+		})
+	}
+
+	// A delete is only believed once Radarr/Sonarr confirm it, as in the reconcile worker
+	if event.EventType == "MovieDelete" || event.EventType == "SeriesDelete" {
+		var exists bool
+		var err error
+		if mediaType == "movie" {
+			exists, err = h.radarrClient.MovieExists(ctx, media.ID)
+		} else {
+			exists, err = h.sonarrClient.SeriesExists(ctx, media.ID)
+		}
+		if err != nil {
+			log.Printf("Warning: could not confirm %s %d was deleted: %v", mediaType, media.ID, err)
+			return 0
+		}
+		if exists {
+			log.Printf("Warning: ignored %s event: %s %d still exists", event.EventType, mediaType, media.ID)
+			return 0
+		}
+	}
+
+	var records []HistoryRecord
+	hash := strings.ToLower(event.DownloadID)
+	for _, record := range h.history.List() {
+		// A failed import can still be imported by hand; refused adds never downloaded
+		failed := record.Status == HistoryStatusFailed && !refusedAdd(record.Code)
+		if (record.Active() || failed) && hash != "" && record.InfoHash == hash {
+			records = append(records, record)
+		}
+	}
+	if len(records) == 0 {
+		for _, record := range h.history.List() {
+			if record.Active() && record.MediaType == mediaType && record.MediaID == media.ID {
+				records = append(records, record)
+			}
+		}
+	}
+
+	now := time.Now()
+	updated := 0
+	for _, record := range records {
+		var err error
+		switch event.EventType {
+		case "Grab":
+			err = h.history.Update(record.ID, func(r *HistoryRecord) {
+				r.Status, r.GrabbedAt = HistoryStatusGrabbed, &now
+				r.MediaID = media.ID
+				if r.InfoHash == "" {
+					r.InfoHash = hash
+				}
+				if event.Release != nil {
+					r.Release = event.Release.ReleaseTitle
+				}
+			})
+		case "Download":
+			err = h.history.Update(record.ID, func(r *HistoryRecord) {
+				r.Status, r.ImportedAt = HistoryStatusImported, &now
+				r.MediaID = media.ID
+			})
+		case "ManualInteractionRequired":
+			// The import failed and is waiting in the *arr queue
+			err = h.history.Update(record.ID, func(r *HistoryRecord) {
+				r.Status, r.Code = HistoryStatusFailed, ""
+				r.Error = importFailure(event)
+			})
+		case "MovieDelete", "SeriesDelete":
+			if record.MediaID != media.ID {
+				continue
+			}
+			h.markRemovedUpstream(ctx, record)
+		default:
+			// Test, Rename, Health, ... don't change history
+			return 0
+		}
+		if err != nil {
+			log.Printf("Warning: could not update history: %v", err)
+			continue
+		}
+		switch event.EventType {
+		case "Download":
+			h.publishRecordEvent(EventImportCompleted, record, "")
+		case "ManualInteractionRequired":
+			h.publishRecordEvent(EventImportFailed, record, importFailure(event))
+		}
+		updated++
+	}
+	return updated
+}
+
+// importFailure describes why an import needs manual interaction
+func importFailure(event ArrWebhookPayload) string {
+	message := "import needs manual interaction"
+	for _, status := range event.DownloadStatusMessages {
+		message += "; " + strings.Join(status.Messages, ", ")
+	}
+	return message
+}
+
+// updateHistory applies fn to every record match accepts and returns how many there were
+func (h *TorrentHandler) updateHistory(match func(record HistoryRecord) bool, fn func(record *HistoryRecord)) int {
+	updated := 0
+	for _, record := range h.history.List() {
+		if !match(record) {
+			continue
+		}
+		if err := h.history.Update(record.ID, fn); err != nil {
+			log.Printf("Warning: could not update history: %v", err)
+			continue
+		}
+		updated++
+	}
+	return updated
+}
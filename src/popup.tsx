@@ -10,6 +10,12 @@ interface LinkData {
 const API_URL = "http://localhost:9080/api/torrent";
 const MEDIA_API_URL = "http://localhost:9080/api/media";
 
+// Sent with every API request so the server log shows which extension build called it
+const API_HEADERS = {
+  "Content-Type": "application/json",
+  "X-Extension-Version": chrome.runtime.getManifest().version,
+};
+
 interface ImdbInfo {
   title: string;
   year: string;
@@ -84,9 +90,7 @@ const Popup = () => {
     try {
       const response = await fetch(API_URL, {
         method: "POST",
-        headers: {
-          "Content-Type": "application/json",
-        },
+        headers: API_HEADERS,
         body: JSON.stringify({ magnet_link: magnetLink }),
       });
 
@@ -150,9 +154,7 @@ const Popup = () => {
     try {
       const response = await fetch(MEDIA_API_URL, {
         method: "POST",
-        headers: {
-          "Content-Type": "application/json",
-        },
+        headers: API_HEADERS,
         body: JSON.stringify({
           name: imdbInfo.title,
           type: imdbInfo.type,